@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/service"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 回测入口：使用与实时相同的配置和决策流水线回放历史数据
+//
+//	go run ./cmd/backtest -format bars -interval 1m -data BTCUSDT=btc_1m.csv,ETHUSDT=eth_1m.csv
+//	go run ./cmd/backtest -format ws -data record.jsonl -out result.json
+func main() {
+	configPath := flag.String("config", "config", "配置文件所在目录")
+	format := flag.String("format", "ticks", "数据格式: ticks (逐笔成交 CSV), bars (K 线 CSV), ws (录制的 Okx WS 消息)")
+	data := flag.String("data", "", "数据文件，逗号分隔；ticks/bars 格式写作 SYMBOL=path")
+	interval := flag.String("interval", "1m", "bars 格式的 K 线周期")
+	outPath := flag.String("out", "", "将回测结果写入 JSON 文件")
+	reportPath := flag.String("report", "", "将绩效报告写入 JSON 文件")
+	htmlPath := flag.String("html", "", "将 HTML 报告 (净值/回撤/价格与开平仓/市场状态) 写入文件")
+	riskFree := flag.Float64("risk-free", 0, "年化无风险利率 (Sharpe / Sortino 使用)")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+
+	cfg := service.LoadConfig(*configPath)
+
+	engine, err := backtest.NewEngine(cfg, service.Logger)
+	if err != nil {
+		service.Logger.Fatal("Failed to create backtest engine", zap.Error(err))
+	}
+
+	source, closeFiles, err := backtest.OpenSources(*format, *data, *interval, engine.Symbols())
+	if err != nil {
+		service.Logger.Fatal("Failed to open backtest data", zap.Error(err))
+	}
+	defer closeFiles()
+
+	started := time.Now()
+	result, err := engine.Run(context.Background(), source)
+	if err != nil {
+		service.Logger.Error("Backtest stopped early", zap.Error(err))
+	}
+
+	fmt.Printf("Backtest %s -> %s (%d events, %d klines, %d signals) in %s\n",
+		result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339),
+		result.Events, result.KLines, result.Signals, time.Since(started).Round(time.Millisecond))
+
+	report := analytics.Analyze(result.Trades, result.EquityCurve, analytics.Options{RiskFreeRate: *riskFree})
+	if err := report.WriteTable(os.Stdout); err != nil {
+		service.Logger.Error("Failed to print backtest report", zap.Error(err))
+	}
+	if len(result.Shadow) > 0 {
+		fmt.Println("\nShadow Optimizer Recommendations")
+		for _, rec := range result.Shadow {
+			fmt.Printf("%s  %s  score %.4f vs %.4f  applied=%v\n  %s\n",
+				rec.Time.UTC().Format(time.RFC3339), rec.Shadow, rec.Score, rec.IncumbentScore, rec.Applied, rec.To)
+		}
+	}
+	for name, levels := range result.Grid {
+		fmt.Printf("\nGrid Levels (%s)\n", name)
+		fmt.Printf("  %-6s %-12s %-8s %-11s %-12s %s\n", "Level", "Price", "Entries", "Round Trips", "Net PnL", "Fees")
+		for _, lv := range levels {
+			fmt.Printf("  %-6d %-12.4f %-8d %-11d %-12.2f %.2f\n", lv.Index, lv.Price, lv.Entries, lv.RoundTrips, lv.RealizedPnL, lv.Fees)
+		}
+	}
+	if *reportPath != "" {
+		payload, err := report.JSON()
+		if err != nil {
+			service.Logger.Fatal("Failed to encode backtest report", zap.Error(err))
+		}
+		if err := os.WriteFile(*reportPath, payload, 0o644); err != nil {
+			service.Logger.Fatal("Failed to write backtest report", zap.Error(err))
+		}
+	}
+	if *htmlPath != "" {
+		if err := writeHTMLReport(*htmlPath, result, report); err != nil {
+			service.Logger.Fatal("Failed to write HTML report", zap.Error(err))
+		}
+	}
+
+	if *outPath != "" {
+		payload, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			service.Logger.Fatal("Failed to encode backtest result", zap.Error(err))
+		}
+		if err := os.WriteFile(*outPath, payload, 0o644); err != nil {
+			service.Logger.Fatal("Failed to write backtest result", zap.Error(err))
+		}
+	}
+}
+
+// writeHTMLReport 将回测结果写入单文件 HTML 报告
+func writeHTMLReport(path string, result *backtest.Result, report *analytics.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return analytics.WriteHTML(f, analytics.SessionData{
+		Title:       fmt.Sprintf("Backtest %s -> %s", result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339)),
+		Trades:      result.Trades,
+		Equity:      result.EquityCurve,
+		Prices:      result.Prices,
+		Transitions: result.Transitions,
+	}, report)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/service"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 历史数据导入入口：将交易所导出的 CSV / Parquet 逐笔成交或 K 线流式转换为回测使用的格式
+// (ticks: timestamp,price,volume,side；bars: ts,open,high,low,close,volume)，同时校验并统计无效行
+//
+//	go run ./cmd/import -preset binance-aggtrades -in BTCUSDT-aggTrades-2024-01.csv -out btc_ticks.csv
+//	go run ./cmd/import -kind candles -in okx_candles.parquet -columns time=ts,volume=vol -out btc_1m.csv
+//	go run ./cmd/import -kind ticks -in trades.csv -time-format "2006-01-02 15:04:05" -tz Asia/Shanghai -out ticks.csv
+func main() {
+	kind := flag.String("kind", "", "数据类型: ticks 逐笔成交 / candles K 线 (使用 -preset 时可省略)")
+	in := flag.String("in", "", "输入文件 (.csv、.csv.gz 或 .parquet)")
+	out := flag.String("out", "", "输出 CSV 文件 (为空时只校验)")
+	format := flag.String("format", "", "输入格式: csv / parquet (默认按扩展名判断)")
+	preset := flag.String("preset", "", "交易所列布局预设: "+strings.Join(backtest.ImportPresetNames(), ", "))
+	columns := flag.String("columns", "", "列映射 field=列名或列号，逗号分隔，例如 time=ts,price=px")
+	timeFormat := flag.String("time-format", "", "时间列格式: auto, ms, s, us, ns, rfc3339 或 Go 时间布局")
+	tz := flag.String("tz", "UTC", "时间布局不含时区时使用的时区")
+	symbol := flag.String("symbol", "", "交易对 (仅用于日志)")
+	interval := flag.String("interval", "1m", "candles 的 K 线周期")
+	strict := flag.Bool("strict", false, "遇到无效行时停止 (默认跳过并统计)")
+	buyerMaker := flag.Bool("side-buyer-maker", false, "side 列为 is_buyer_maker (true/false) 而不是 buy/sell")
+	delimiter := flag.String("delimiter", ",", "CSV 分隔符")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+	logger := service.Logger
+
+	if *in == "" {
+		logger.Fatal("-in is required")
+	}
+
+	var cfg backtest.ImportConfig
+	if *preset != "" {
+		var err error
+		if cfg, err = backtest.ImportPreset(*preset); err != nil {
+			logger.Fatal("Invalid -preset", zap.Error(err))
+		}
+	}
+	if *kind != "" {
+		cfg.Kind = *kind
+	}
+	if *columns != "" {
+		if cfg.Columns == nil {
+			cfg.Columns = make(map[string]string)
+		}
+		for _, spec := range strings.Split(*columns, ",") {
+			parts := strings.SplitN(strings.TrimSpace(spec), "=", 2)
+			if len(parts) != 2 {
+				logger.Fatal("Invalid -columns, expected field=column", zap.String("Spec", spec))
+			}
+			cfg.Columns[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		logger.Fatal("Invalid -tz", zap.Error(err))
+	}
+	barInterval, err := service.ParseIntervalDuration(*interval)
+	if err != nil {
+		logger.Fatal("Invalid -interval", zap.Error(err))
+	}
+	if len([]rune(*delimiter)) != 1 {
+		logger.Fatal("Invalid -delimiter, expected a single character", zap.String("Delimiter", *delimiter))
+	}
+	cfg.Format = *format
+	cfg.Symbol = *symbol
+	cfg.TimeFormat = *timeFormat
+	cfg.Location = location
+	cfg.Interval = barInterval
+	cfg.Strict = *strict
+	cfg.SideBuyerMaker = cfg.SideBuyerMaker || *buyerMaker
+	cfg.Delimiter = []rune(*delimiter)[0]
+
+	importer, err := backtest.OpenImporter(*in, cfg)
+	if err != nil {
+		logger.Fatal("Failed to open input", zap.Error(err))
+	}
+	defer importer.Close()
+
+	output := io.Discard
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			logger.Fatal("Failed to create output file", zap.String("Path", *out), zap.Error(err))
+		}
+		defer f.Close()
+		output = f
+	}
+	buffered := bufio.NewWriterSize(output, 1<<20)
+	writer := csv.NewWriter(buffered)
+
+	started := time.Now()
+	if err := convert(importer, cfg.Kind, writer); err != nil {
+		logger.Fatal("Import failed", zap.Error(err))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		logger.Fatal("Failed to write output file", zap.Error(err))
+	}
+	if err := buffered.Flush(); err != nil {
+		logger.Fatal("Failed to write output file", zap.Error(err))
+	}
+
+	printStats(importer.Stats(), time.Since(started))
+}
+
+// convert 逐行读取导入器并写出回测格式的 CSV
+func convert(importer *backtest.Importer, kind string, w *csv.Writer) error {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	if kind == backtest.ImportCandles {
+		if err := w.Write([]string{"ts", "open", "high", "low", "close", "volume"}); err != nil {
+			return err
+		}
+		for {
+			kline, err := importer.NextKLine()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			record := []string{strconv.FormatInt(kline.StartTime.UnixMilli(), 10),
+				format(kline.Open), format(kline.High), format(kline.Low), format(kline.Close), format(kline.Volume)}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.Write([]string{"timestamp", "price", "volume", "side"}); err != nil {
+		return err
+	}
+	for {
+		ticker, err := importer.NextTicker()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		side := "buy"
+		if ticker.IsBuyerMaker {
+			side = "sell"
+		}
+		record := []string{strconv.FormatInt(ticker.Timestamp, 10), format(ticker.Price), format(ticker.Volume), side}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// printStats 输出导入统计
+func printStats(stats backtest.ImportStats, elapsed time.Duration) {
+	fmt.Printf("Rows:     %d\n", stats.Rows)
+	fmt.Printf("Imported: %d\n", stats.Imported)
+	if stats.Imported > 0 {
+		fmt.Printf("Range:    %s - %s\n", stats.First.UTC().Format(time.RFC3339), stats.Last.UTC().Format(time.RFC3339))
+	}
+	if stats.Gaps > 0 {
+		fmt.Printf("Gaps:     %d missing bars\n", stats.Gaps)
+	}
+	reasons := make([]string, 0, len(stats.Invalid))
+	for reason := range stats.Invalid {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("Skipped:  %d (%s)\n", stats.Invalid[reason], reason)
+	}
+	fmt.Printf("Elapsed:  %s\n", elapsed.Round(time.Millisecond))
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto-algo-trader/internal/analytics"
 	"crypto-algo-trader/internal/api"
 	executor "crypto-algo-trader/internal/executor"
 	"crypto-algo-trader/internal/model"
 	"crypto-algo-trader/internal/service"
 	"crypto-algo-trader/internal/strategy"
-	"crypto-algo-trader/pkg/ta"
 	"fmt"
 	"go.uber.org/zap"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -32,9 +36,72 @@ func main() {
 	// 2. 初始化单个 Connector (连接器只负责连接和收集所有数据)
 	connector := api.NewConnector(cfg.Exchange.WSURL, symbols)
 
+	// 录制原始 WS 消息，供回测引擎回放
+	if cfg.Exchange.RecordFile != "" {
+		recordFile, err := os.OpenFile(cfg.Exchange.RecordFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			service.Logger.Fatal("Failed to open WS record file", zap.Error(err))
+		}
+		defer recordFile.Close()
+		connector.RecordTo(recordFile)
+	}
+
 	// 3. 启动 Connector
 	go connector.Start()
 
+	// 所有交易实例共享同一个模拟账户 (全仓组合视图)
+	simCfg := executor.SimulatorConfig{
+		InitialCapital:        cfg.Simulator.InitialCapital,
+		Leverage:              cfg.Simulator.Leverage,
+		FeeRate:               cfg.Simulator.FeeRate,
+		PositionMode:          model.PositionMode(cfg.Simulator.PositionMode),
+		MarginMode:            cfg.Simulator.MarginMode,
+		MaintenanceMarginRate: cfg.Simulator.MaintenanceMarginRate,
+
+		PassiveFillModel:       cfg.Simulator.PassiveFillModel,
+		PassiveFillProbability: cfg.Simulator.PassiveFillProbability,
+		PassiveFillSeed:        cfg.Simulator.PassiveFillSeed,
+	}
+	simAccount := executor.NewSimulatorAccount(&simCfg, service.Logger)
+
+	// 订单链路延迟模型 (基于事件时钟，成交使用订单到达时的价格)
+	latencyCfg := cfg.Simulator.Latency
+	latencyModel, err := executor.NewLatencyModel(executor.LatencyConfig{
+		Mode:         latencyCfg.Mode,
+		SubmitMs:     latencyCfg.SubmitMs,
+		AckMs:        latencyCfg.AckMs,
+		CancelMs:     latencyCfg.CancelMs,
+		Distribution: latencyCfg.Distribution,
+		StdDevRatio:  latencyCfg.StdDevRatio,
+		Seed:         latencyCfg.Seed,
+		ReplayFile:   latencyCfg.ReplayFile,
+	})
+	if err != nil {
+		service.Logger.Fatal("Invalid simulator latency configuration", zap.Error(err))
+	}
+	simAccount.SetLatencyModel(latencyModel)
+
+	// 会话记录器：采样净值和价格，退出时生成 HTML 报告
+	recorder := analytics.NewSessionRecorder()
+	go logAccountSummary(simAccount, recorder, time.Minute)
+
+	// 深度快照用于估计模拟限价单的排队位置
+	go func() {
+		for book := range connector.GetOrderBookChannel() {
+			simAccount.UpdateOrderBook(book)
+		}
+	}()
+
+	// 跨实例行情分析：消费所有实例的 K 线，供策略查询相关性、beta 和配对价差
+	var market *analytics.MarketAnalytics
+	if cfg.Analytics.Enabled {
+		market = analytics.NewMarketAnalytics(cfg.Analytics)
+	}
+
+	// 记录已启动的流水线，退出时收集各状态机的状态切换
+	var pipelinesMu sync.Mutex
+	var pipelines []*strategy.Pipeline
+
 	// 4. 为每个交易实例启动一个隔离的业务 Goroutine
 	for instanceName, instanceCfg := range cfg.Instances {
 
@@ -45,31 +112,56 @@ func main() {
 			instanceLogger := service.Logger.With(zap.String("Instance", name), zap.String("Symbol", instance.Symbol))
 			instanceLogger.Info("Starting isolated trading pipeline...")
 
-			// Ticker Input: 使用 Connector 的统一输出通道
-			tickerInputChan := connector.GetTickerChannel()
+			// Ticker Input: 每个实例单独订阅自己 Symbol 的 Ticker (共享一个通道时各实例会互相抢走对方的 Ticker)
+			tickerInputChan := connector.SubscribeTickers(instance.Symbol)
 
-			// Data Engine: 消费统一通道，但只处理自己的 Symbol
+			// Data Engine: 聚合本实例 Symbol 的 K 线
 			dataEngine := model.NewDataEngine(tickerInputChan, instance.Symbol)
 
 			// 初始化 SimulatorExecutor (注入 Ticker 源)
-			// SimConfig 包含初始资金、杠杆
-			simConfig := &executor.SimulatorConfig{
-				InitialCapital: 10000.00, // 从配置中读取
-				Leverage:       10,       // 合约默认杠杆
-			}
-			// 注入 DataEngine 的 Ticker 广播通道
-			simulatorExecutor := executor.NewSimulatorExecutor(
-				simConfig,
+			// 在共享账户上下单：杠杆取实例的 Risk.FixedLeverage，保证金占用受 Allocation 限制
+			simulatorExecutor := executor.NewSharedSimulatorExecutor(
+				simAccount,
+				instance.Symbol,
+				float64(instance.Risk.FixedLeverage),
+				instance.Allocation,
 				dataEngine.GetBroadcasterTickerChannel(), // Ticker 源
 				instanceLogger,
 			)
-			// 启动 SimulatorExecutor 的内部 Goroutine (实时监控 PnL 和止损)
-			go simulatorExecutor.StartMonitor()
 
-			// 初始化 TA, StateMachine, SignalGenerator
-			taClient := ta.NewTACalculator(instanceLogger)
-			stateMachine := strategy.NewStateMachine(taClient, &instance.Strategy)
-			signalGenerator := strategy.NewSignalGenerator(taClient, stateMachine, &instance.Risk, instanceLogger)
+			// 持仓模式是账户级设置，策略层需与共享账户保持一致
+			instance.Risk.PositionMode = cfg.Simulator.PositionMode
+
+			// 初始化 TA, StateMachine 和策略 (与回测引擎共用同一条决策流水线)
+			pipeline, err := strategy.NewPipeline(name, &instance, simulatorExecutor, instanceLogger)
+			if err != nil {
+				instanceLogger.Fatal("Failed to create strategy pipeline", zap.Error(err))
+			}
+			pipeline.SetMarketAnalytics(market)
+			pipelinesMu.Lock()
+			pipelines = append(pipelines, pipeline)
+			pipelinesMu.Unlock()
+
+			// 影子优化器：在同一行情上用扰动参数运行影子策略，推荐 (或热切换) 更优的参数
+			var shadow *strategy.ShadowOptimizer
+			if instance.Shadow.Enabled {
+				shadow, err = strategy.NewShadowOptimizer(pipeline, instance, simCfg, instanceLogger)
+				if err != nil {
+					instanceLogger.Fatal("Failed to start shadow optimizer", zap.Error(err))
+				}
+				shadow.SetMarketAnalytics(market)
+			}
+
+			// 消费 Ticker：实时监控 PnL 和止损，并更新逐笔指标 (影子账户与主账户消费同一个 Ticker 源)
+			go func() {
+				for ticker := range dataEngine.GetBroadcasterTickerChannel() {
+					simulatorExecutor.OnTicker(ticker)
+					pipeline.OnTicker(ticker)
+					if shadow != nil {
+						shadow.OnTicker(ticker)
+					}
+				}
+			}()
 
 			// 初始化交易执行器 (L3)
 			// 构造 Okx Executor 所需的配置 (使用 executor.OkxConfig 结构)
@@ -89,26 +181,66 @@ func main() {
 			// 启动主循环 (消费 KLine，驱动决策和执行)
 			klineChan := dataEngine.GetKlineChannel()
 			for kline := range klineChan {
-				// A: 更新指标
-				taClient.UpdateKLine(kline)
-				// B: 状态机检查状态
-				stateMachine.CheckAndTransition(kline)
-
-				// C: 获取当前持仓
-				currentPosition, _ := simulatorExecutor.GetCurrentPosition(context.Background())
-
-				// D: 信号生成检查
-				signal := signalGenerator.GenerateSignal(kline, currentPosition)
-
-				// E: 执行器执行信号
-				if signal.Action != model.ActionNone {
-					instanceLogger.Info("!!! NEW TRADING SIGNAL !!!", zap.String("Signal", signal.String()))
-					simulatorExecutor.ExecuteSignal(context.Background(), signal)
+				market.OnKLine(kline)
+				pipeline.OnKLine(context.Background(), kline)
+				if shadow != nil {
+					shadow.OnKLine(context.Background(), kline)
 				}
 			}
 		}(instanceName, instanceCfg)
 	}
 
-	// 保持主 Goroutine 不退出
-	select {}
+	// 保持主 Goroutine 运行，直到收到退出信号
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	if cfg.Simulator.ReportFile != "" {
+		pipelinesMu.Lock()
+		var transitions []model.StateTransition
+		for _, pipeline := range pipelines {
+			transitions = append(transitions, pipeline.StateMachine.GetTransitionHistory()...)
+		}
+		pipelinesMu.Unlock()
+
+		summary := simAccount.GetSummary()
+		recorder.Record(time.Now(), summary.Equity, summary.Balance, summary.Prices)
+		writeSessionReport(cfg.Simulator.ReportFile, recorder.Session("Simulator Session", simAccount.GetTradeHistory(), transitions))
+	}
+}
+
+// writeSessionReport 将实时模拟会话写入 HTML 报告
+func writeSessionReport(path string, session analytics.SessionData) {
+	f, err := os.Create(path)
+	if err != nil {
+		service.Logger.Error("Failed to create session report", zap.Error(err))
+		return
+	}
+	defer f.Close()
+
+	if err := analytics.WriteHTML(f, session, nil); err != nil {
+		service.Logger.Error("Failed to write session report", zap.Error(err))
+		return
+	}
+	service.Logger.Info("Session report written", zap.String("Path", path))
+}
+
+// logAccountSummary 定期输出共享模拟账户的合并视图 (余额/净值/浮动盈亏/保证金率)，并采样到会话记录器
+func logAccountSummary(account *executor.SimulatorAccount, recorder *analytics.SessionRecorder, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		summary := account.GetSummary()
+		recorder.Record(now, summary.Equity, summary.Balance, summary.Prices)
+		service.Logger.Info("Simulator Account Summary",
+			zap.Float64("Balance", summary.Balance),
+			zap.Float64("Equity", summary.Equity),
+			zap.Float64("UPL", summary.UPL),
+			zap.Float64("MarginUsed", summary.MarginUsed),
+			zap.Float64("Available", summary.AvailableBalance),
+			zap.Float64("MarginRatio", summary.MarginRatio),
+			zap.Int("Positions", len(summary.Positions)),
+		)
+	}
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/service"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Monte Carlo 稳健性分析入口：对回测的交易序列重采样/打乱、随机跳过交易并叠加滑点噪声，
+// 输出最终收益、最大回撤和破产概率的分布，并据此建议 MaxPerTradeRisk 和 MaxAllowedDrawdown
+//
+//	go run ./cmd/montecarlo -format bars -data BTCUSDT=btc_1m.csv -runs 5000 -skip 0.1 -slippage 2
+//	go run ./cmd/montecarlo -result result.json -method shuffle -target-dd 0.15 -confidence 0.95
+func main() {
+	configPath := flag.String("config", "config", "配置文件所在目录")
+	resultPath := flag.String("result", "", "cmd/backtest -out 输出的回测结果 JSON (为空时按 -format/-data 运行回测)")
+	format := flag.String("format", "ticks", "数据格式: ticks, bars, ws (同 cmd/backtest)")
+	data := flag.String("data", "", "数据文件，逗号分隔；ticks/bars 格式写作 SYMBOL=path")
+	interval := flag.String("interval", "1m", "bars 格式的 K 线周期")
+	runs := flag.Int("runs", analytics.DefaultMonteCarloRuns, "模拟路径数")
+	method := flag.String("method", analytics.MonteCarloBootstrap, "重采样方式: bootstrap 有放回重采样 / shuffle 打乱顺序")
+	skip := flag.Float64("skip", 0, "每笔交易被随机跳过的概率")
+	slippage := flag.Float64("slippage", 0, "每次成交的附加滑点噪声标准差 (基点)")
+	ruin := flag.Float64("ruin", analytics.DefaultRuinThreshold, "净值亏损达到该比例视为破产")
+	seed := flag.Int64("seed", 1, "随机种子")
+	risk := flag.Float64("risk", 0, "回测使用的 MaxPerTradeRisk (默认取配置)")
+	targetDD := flag.Float64("target-dd", 0.15, "风险建议：最大回撤的目标上限")
+	confidence := flag.Float64("confidence", 0.95, "风险建议：最大回撤分位数的置信水平")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+	logger := service.Logger
+	// 策略组件使用全局日志，只保留错误日志
+	service.Logger = logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar()
+
+	if *method != analytics.MonteCarloBootstrap && *method != analytics.MonteCarloShuffle {
+		logger.Fatal("Invalid -method", zap.String("Method", *method))
+	}
+
+	cfg := service.LoadConfig(*configPath)
+
+	var result *backtest.Result
+	if *resultPath != "" {
+		payload, err := os.ReadFile(*resultPath)
+		if err != nil {
+			logger.Fatal("Failed to read backtest result", zap.Error(err))
+		}
+		result = &backtest.Result{}
+		if err := json.Unmarshal(payload, result); err != nil {
+			logger.Fatal("Failed to decode backtest result", zap.Error(err))
+		}
+	} else {
+		result = runBacktest(cfg, *format, *data, *interval, logger)
+	}
+	if len(result.Trades) == 0 {
+		logger.Fatal("Backtest has no trades")
+	}
+
+	initialEquity := cfg.Simulator.InitialCapital
+	if len(result.EquityCurve) > 0 {
+		initialEquity = result.EquityCurve[0].Equity
+	}
+	currentRisk := *risk
+	if currentRisk <= 0 {
+		currentRisk = configuredRisk(cfg)
+	}
+
+	mcCfg := analytics.MonteCarloConfig{
+		Runs:          *runs,
+		Method:        *method,
+		SkipProb:      *skip,
+		SlippageBps:   *slippage,
+		RuinThreshold: *ruin,
+		Seed:          *seed,
+	}
+	mc := analytics.MonteCarlo(result.Trades, initialEquity, mcCfg)
+	if err := mc.WriteTable(os.Stdout); err != nil {
+		logger.Error("Failed to print Monte Carlo results", zap.Error(err))
+	}
+
+	fmt.Println("\nRisk Sizing")
+	sizing := analytics.SizeRisk(result.Trades, initialEquity, mcCfg, currentRisk, *targetDD, *confidence)
+	if err := sizing.WriteTable(os.Stdout); err != nil {
+		logger.Error("Failed to print risk sizing", zap.Error(err))
+	}
+}
+
+// runBacktest 用当前配置回测数据
+func runBacktest(cfg *service.Config, format, data, interval string, logger *zap.SugaredLogger) *backtest.Result {
+	engine, err := backtest.NewEngine(cfg, service.Logger)
+	if err != nil {
+		logger.Fatal("Failed to create backtest engine", zap.Error(err))
+	}
+	source, closeFiles, err := backtest.OpenSources(format, data, interval, engine.Symbols())
+	if err != nil {
+		logger.Fatal("Failed to open backtest data", zap.Error(err))
+	}
+	defer closeFiles()
+
+	result, err := engine.Run(context.Background(), source)
+	if err != nil {
+		logger.Error("Backtest stopped early", zap.Error(err))
+	}
+	return result
+}
+
+// configuredRisk 返回配置中的 MaxPerTradeRisk (多个实例时取实例名排序后的第一个)
+func configuredRisk(cfg *service.Config) float64 {
+	names := make([]string, 0, len(cfg.Instances))
+	for name := range cfg.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return 0
+	}
+	return cfg.Instances[names[0]].Risk.MaxPerTradeRisk
+}
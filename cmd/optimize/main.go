@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/optimizer"
+	"crypto-algo-trader/internal/service"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// paramFlags 可重复的 -param 参数
+type paramFlags []string
+
+func (p *paramFlags) String() string     { return strings.Join(*p, " ") }
+func (p *paramFlags) Set(v string) error { *p = append(*p, v); return nil }
+
+// 自适应参数优化入口：用遗传算法或 TPE 在连续/整数/分类参数空间中搜索，
+// 每次试验都是一次完整回测。-study 指定的日志记录每次试验，中断后用相同的命令即可从断点继续
+//
+//	go run ./cmd/optimize -format bars -data BTCUSDT=btc_1m.csv -algo tpe -trials 200 -seed 7 \
+//	    -param TrendThreshold=50:75 -param ATRVolThreshold=0.0002:0.002:log -param MAPeriod=10:60 \
+//	    -param DefaultRiskRewardRatio=1.5,2,2.5,3 -patience 60 -study tpe.jsonl
+func main() {
+	var specs paramFlags
+	configPath := flag.String("config", "config", "配置文件所在目录")
+	format := flag.String("format", "ticks", "数据格式: ticks, bars, ws (同 cmd/backtest)")
+	data := flag.String("data", "", "数据文件，逗号分隔；ticks/bars 格式写作 SYMBOL=path")
+	interval := flag.String("interval", "1m", "bars 格式的 K 线周期")
+	flag.Var(&specs, "param", "搜索维度 Name=min:max、Name=min:max:log 或 Name=v1,v2,... (可重复)，可用参数: "+strings.Join(optimizer.ParamNames(), ", "))
+	algo := flag.String("algo", "tpe", "搜索算法: ga 遗传算法 / tpe 贝叶斯优化")
+	trials := flag.Int("trials", 100, "最多评估的试验次数")
+	seed := flag.Int64("seed", 1, "随机种子 (相同的种子和数据得到相同的搜索轨迹)")
+	patience := flag.Int("patience", 0, "连续多少次试验没有提升时提前停止 (0 不启用)")
+	minDelta := flag.Float64("min-delta", 0, "视为提升的最小分数增量")
+	studyPath := flag.String("study", "", "study 日志 (JSON Lines)，已存在时从中恢复")
+	population := flag.Int("population", optimizer.DefaultPopulation, "ga: 每代个体数")
+	mutation := flag.Float64("mutation", optimizer.DefaultMutationRate, "ga: 每个参数的变异概率")
+	startup := flag.Int("startup", optimizer.DefaultTPEStartup, "tpe: 随机采样的启动试验数")
+	batch := flag.Int("batch", optimizer.DefaultTPEBatch, "tpe: 每批并行评估的候选数")
+	objectiveName := flag.String("objective", "sharpe", "优化目标: "+strings.Join(analytics.ObjectiveNames(), ", "))
+	minTrades := flag.Int("min-trades", 0, "交易数少于该值的结果视为无效")
+	workers := flag.Int("workers", 0, "并行回测数量 (默认 CPU 核数)")
+	top := flag.Int("top", 10, "终端输出的前 N 名 (0 为全部)")
+	csvPath := flag.String("csv", "", "将全部试验结果写入 CSV 文件")
+	riskFree := flag.Float64("risk-free", 0, "年化无风险利率 (Sharpe / Sortino 使用)")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+	logger := service.Logger
+	// 策略组件使用全局日志，优化时只保留错误日志，避免大量回测的逐笔日志
+	service.Logger = logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar()
+
+	cfg := service.LoadConfig(*configPath)
+
+	var space []optimizer.Dimension
+	for _, spec := range specs {
+		d, err := optimizer.ParseDimension(spec)
+		if err != nil {
+			logger.Fatal("Invalid -param", zap.Error(err))
+		}
+		space = append(space, d)
+	}
+	if len(space) == 0 {
+		logger.Fatal("At least one -param is required")
+	}
+	objective, err := analytics.ParseObjective(*objectiveName)
+	if err != nil {
+		logger.Fatal("Invalid -objective", zap.Error(err))
+	}
+
+	var sampler optimizer.Sampler
+	switch *algo {
+	case "ga":
+		sampler = optimizer.NewGenetic(space, optimizer.GeneticConfig{Population: *population, MutationRate: *mutation}, *seed)
+	case "tpe":
+		sampler = optimizer.NewTPE(space, optimizer.TPEConfig{Startup: *startup, Batch: *batch}, *seed)
+	default:
+		logger.Fatal("Invalid -algo", zap.String("Algo", *algo))
+	}
+
+	var study *optimizer.Study
+	if *studyPath != "" {
+		header := optimizer.StudyHeader{
+			Sampler:   sampler.Name(),
+			Seed:      *seed,
+			Objective: objective.Name,
+			MinTrades: *minTrades,
+			Data:      *format + " " + *data,
+		}
+		for _, d := range space {
+			header.Space = append(header.Space, d.String())
+		}
+		study, err = optimizer.OpenStudy(*studyPath, header)
+		if err != nil {
+			logger.Fatal("Failed to open study", zap.Error(err))
+		}
+		defer study.Close()
+		if study.Len() > 0 {
+			logger.Infow("Resuming study", "Path", *studyPath, "RecordedTrials", study.Len())
+		}
+	}
+
+	var symbols []string
+	for _, instance := range cfg.Instances {
+		symbols = append(symbols, instance.Symbol)
+	}
+	events, err := backtest.LoadEvents(*format, *data, *interval, symbols)
+	if err != nil {
+		logger.Fatal("Failed to load backtest data", zap.Error(err))
+	}
+
+	runner := optimizer.NewRunner(cfg, objective, logger)
+	runner.Options = analytics.Options{RiskFreeRate: *riskFree}
+	runner.Workers = *workers
+	runner.MinTrades = *minTrades
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logger.Infow("Starting optimisation", "Sampler", sampler.Name(), "MaxTrials", *trials, "Events", len(events))
+	started := time.Now()
+	result, err := optimizer.Search(ctx, runner, optimizer.Dataset{Events: events}, sampler, study,
+		optimizer.SearchConfig{MaxTrials: *trials, Patience: *patience, MinDelta: *minDelta})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Fatal("Optimisation failed", zap.Error(err))
+	}
+	fmt.Printf("Optimise (%s): %d trials (%d resumed) in %s, objective %s, stopped: %s\n\n",
+		sampler.Name(), len(result.Trials), result.Resumed, time.Since(started).Round(time.Millisecond), objective.Name, result.StopReason)
+	if len(result.Trials) == 0 {
+		return
+	}
+
+	names := optimizer.DimensionNames(space)
+	ranked := optimizer.Rank(result.Trials)
+	if err := optimizer.WriteTable(os.Stdout, names, objective.Name, ranked, *top); err != nil {
+		logger.Error("Failed to print optimisation results", zap.Error(err))
+	}
+	if result.Best.Index >= 0 {
+		fmt.Printf("\nBest trial #%d: %s\n", result.Best.Index, result.Best.Params.Format(names))
+	}
+
+	if *csvPath != "" {
+		f, err := os.Create(*csvPath)
+		if err != nil {
+			logger.Fatal("Failed to create output file", zap.String("Path", *csvPath), zap.Error(err))
+		}
+		defer f.Close()
+		if err := optimizer.WriteCSV(f, names, ranked); err != nil {
+			logger.Fatal("Failed to write output file", zap.String("Path", *csvPath), zap.Error(err))
+		}
+	}
+}
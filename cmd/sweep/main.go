@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/optimizer"
+	"crypto-algo-trader/internal/service"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// paramFlags 可重复的 -param 参数
+type paramFlags []string
+
+func (p *paramFlags) String() string     { return strings.Join(*p, " ") }
+func (p *paramFlags) Set(v string) error { *p = append(*p, v); return nil }
+
+// 参数扫描入口：在同一份历史数据上并行回测参数网格，按目标排序输出结果和热力图
+//
+//	go run ./cmd/sweep -format bars -data BTCUSDT=btc_1m.csv \
+//	    -param TrendThreshold=55:70:5 -param ATRVolThreshold=0.0003,0.0005,0.0008 -objective sharpe
+//	go run ./cmd/sweep -format bars -data BTCUSDT=btc_1m.csv -mode random -samples 50 \
+//	    -param MAPeriod=10:50:5 -param RSIPeriod=7:21:1 -csv sweep.csv -heatmap sweep.html
+func main() {
+	var specs paramFlags
+	configPath := flag.String("config", "config", "配置文件所在目录")
+	format := flag.String("format", "ticks", "数据格式: ticks, bars, ws (同 cmd/backtest)")
+	data := flag.String("data", "", "数据文件，逗号分隔；ticks/bars 格式写作 SYMBOL=path")
+	interval := flag.String("interval", "1m", "bars 格式的 K 线周期")
+	flag.Var(&specs, "param", "扫描参数 Name=min:max:step 或 Name=v1,v2,... (可重复)，可用参数: "+strings.Join(optimizer.ParamNames(), ", "))
+	mode := flag.String("mode", "grid", "grid 笛卡尔网格 / random 从网格中随机抽样")
+	samples := flag.Int("samples", 50, "random 模式的抽样数量")
+	seed := flag.Int64("seed", 1, "random 模式的随机种子")
+	objectiveName := flag.String("objective", "sharpe", "排序目标: "+strings.Join(analytics.ObjectiveNames(), ", "))
+	minTrades := flag.Int("min-trades", 0, "交易数少于该值的结果不参与排名")
+	workers := flag.Int("workers", 0, "并行回测数量 (默认 CPU 核数)")
+	top := flag.Int("top", 20, "终端输出的前 N 名 (0 为全部)")
+	csvPath := flag.String("csv", "", "将全部结果写入 CSV 文件")
+	heatmapPath := flag.String("heatmap", "", "将两两参数的热力图写入 HTML 文件")
+	riskFree := flag.Float64("risk-free", 0, "年化无风险利率 (Sharpe / Sortino 使用)")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+	logger := service.Logger
+	// 策略组件使用全局日志，扫描时只保留错误日志，避免成百上千次回测的逐笔日志
+	service.Logger = logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar()
+
+	cfg := service.LoadConfig(*configPath)
+
+	var ranges []optimizer.ParamRange
+	for _, spec := range specs {
+		r, err := optimizer.ParseRange(spec)
+		if err != nil {
+			logger.Fatal("Invalid -param", zap.Error(err))
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		logger.Fatal("At least one -param is required")
+	}
+	objective, err := analytics.ParseObjective(*objectiveName)
+	if err != nil {
+		logger.Fatal("Invalid -objective", zap.Error(err))
+	}
+
+	var sets []optimizer.ParamSet
+	switch *mode {
+	case "grid":
+		sets = optimizer.Grid(ranges)
+	case "random":
+		sets = optimizer.RandomSample(ranges, *samples, *seed)
+	default:
+		logger.Fatal("Invalid -mode", zap.String("Mode", *mode))
+	}
+
+	events, err := backtest.LoadEvents(*format, *data, *interval, instanceSymbols(cfg))
+	if err != nil {
+		logger.Fatal("Failed to load backtest data", zap.Error(err))
+	}
+
+	runner := optimizer.NewRunner(cfg, objective, logger)
+	runner.Options = analytics.Options{RiskFreeRate: *riskFree}
+	runner.Workers = *workers
+	runner.MinTrades = *minTrades
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logger.Infow("Starting parameter sweep", "Mode", *mode, "Runs", len(sets), "GridSize", optimizer.GridSize(ranges), "Events", len(events))
+	started := time.Now()
+	trials := runner.Run(ctx, optimizer.Dataset{Events: events}, sets)
+	ranked := optimizer.Rank(trials)
+	fmt.Printf("Sweep: %d runs in %s, objective %s\n\n", len(trials), time.Since(started).Round(time.Millisecond), objective.Name)
+
+	names := optimizer.RangeNames(ranges)
+	if err := optimizer.WriteTable(os.Stdout, names, objective.Name, ranked, *top); err != nil {
+		logger.Error("Failed to print sweep results", zap.Error(err))
+	}
+
+	maps := optimizer.Heatmaps(trials, ranges)
+	for _, h := range maps {
+		fmt.Println()
+		h.WriteText(os.Stdout)
+	}
+
+	if *csvPath != "" {
+		writeFile(*csvPath, logger, func(f *os.File) error {
+			return optimizer.WriteCSV(f, names, ranked)
+		})
+	}
+	if *heatmapPath != "" {
+		writeFile(*heatmapPath, logger, func(f *os.File) error {
+			return optimizer.WriteHeatmapsHTML(f, "Parameter Sweep", objective.Name, maps)
+		})
+	}
+}
+
+// instanceSymbols 返回配置中所有交易实例的交易对 (ws 录制回放按交易对过滤)
+func instanceSymbols(cfg *service.Config) []string {
+	var symbols []string
+	for _, instance := range cfg.Instances {
+		symbols = append(symbols, instance.Symbol)
+	}
+	return symbols
+}
+
+// writeFile 创建文件并写入内容，失败时终止
+func writeFile(path string, logger *zap.SugaredLogger, write func(f *os.File) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		logger.Fatal("Failed to create output file", zap.String("Path", path), zap.Error(err))
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		logger.Fatal("Failed to write output file", zap.String("Path", path), zap.Error(err))
+	}
+}
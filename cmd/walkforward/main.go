@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/optimizer"
+	"crypto-algo-trader/internal/service"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// paramFlags 可重复的 -param 参数
+type paramFlags []string
+
+func (p *paramFlags) String() string     { return strings.Join(*p, " ") }
+func (p *paramFlags) Set(v string) error { *p = append(*p, v); return nil }
+
+// 滚动优化 (walk-forward) 入口：在每个样本内窗口上扫描参数，用最优参数回测紧随其后的样本外窗口，
+// 拼接样本外净值曲线并输出参数稳定性和 walk-forward 效率，用于判断参数是否可以上线
+//
+//	go run ./cmd/walkforward -format bars -data BTCUSDT=btc_1m.csv -train 30d -test 7d \
+//	    -param TrendThreshold=55:70:5 -param DefaultRiskRewardRatio=1.5,2,3 -objective sharpe -html wf.html
+func main() {
+	var specs paramFlags
+	configPath := flag.String("config", "config", "配置文件所在目录")
+	format := flag.String("format", "ticks", "数据格式: ticks, bars, ws (同 cmd/backtest)")
+	data := flag.String("data", "", "数据文件，逗号分隔；ticks/bars 格式写作 SYMBOL=path")
+	interval := flag.String("interval", "1m", "bars 格式的 K 线周期")
+	flag.Var(&specs, "param", "扫描参数 Name=min:max:step 或 Name=v1,v2,... (可重复)，可用参数: "+strings.Join(optimizer.ParamNames(), ", "))
+	mode := flag.String("mode", "grid", "grid 笛卡尔网格 / random 从网格中随机抽样")
+	samples := flag.Int("samples", 50, "random 模式的抽样数量")
+	seed := flag.Int64("seed", 1, "random 模式的随机种子")
+	objectiveName := flag.String("objective", "sharpe", "样本内优化目标: "+strings.Join(analytics.ObjectiveNames(), ", "))
+	minTrades := flag.Int("min-trades", 0, "样本内交易数少于该值的参数不参与选择")
+	workers := flag.Int("workers", 0, "并行回测数量 (默认 CPU 核数)")
+	train := flag.String("train", "30d", "样本内窗口长度 (例如 30d, 720h)")
+	test := flag.String("test", "7d", "样本外窗口长度")
+	step := flag.String("step", "", "窗口推进距离 (默认等于 -test)")
+	anchored := flag.Bool("anchored", false, "锚定模式：样本内窗口固定从数据起点开始并逐步扩大")
+	warmup := flag.String("warmup", "5d", "每个窗口前用于预热指标的数据长度 (4h 指标需要约 5 天)")
+	minWFE := flag.Float64("min-wfe", 0.5, "判定参数可上线的最低 walk-forward 效率")
+	htmlPath := flag.String("html", "", "将拼接后的样本外结果写入 HTML 报告")
+	riskFree := flag.Float64("risk-free", 0, "年化无风险利率 (Sharpe / Sortino 使用)")
+	flag.Parse()
+
+	service.InitLogger()
+	defer service.Logger.Sync()
+	logger := service.Logger
+	// 策略组件使用全局日志，优化时只保留错误日志，避免大量回测的逐笔日志
+	service.Logger = logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar()
+
+	cfg := service.LoadConfig(*configPath)
+
+	wfCfg := optimizer.WalkForwardConfig{
+		Train:    parseSpan("train", *train, logger),
+		Test:     parseSpan("test", *test, logger),
+		Step:     parseSpan("step", *step, logger),
+		Anchored: *anchored,
+		Warmup:   parseSpan("warmup", *warmup, logger),
+	}
+
+	var ranges []optimizer.ParamRange
+	for _, spec := range specs {
+		r, err := optimizer.ParseRange(spec)
+		if err != nil {
+			logger.Fatal("Invalid -param", zap.Error(err))
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		logger.Fatal("At least one -param is required")
+	}
+	objective, err := analytics.ParseObjective(*objectiveName)
+	if err != nil {
+		logger.Fatal("Invalid -objective", zap.Error(err))
+	}
+
+	var sets []optimizer.ParamSet
+	switch *mode {
+	case "grid":
+		sets = optimizer.Grid(ranges)
+	case "random":
+		sets = optimizer.RandomSample(ranges, *samples, *seed)
+	default:
+		logger.Fatal("Invalid -mode", zap.String("Mode", *mode))
+	}
+
+	var symbols []string
+	for _, instance := range cfg.Instances {
+		symbols = append(symbols, instance.Symbol)
+	}
+	events, err := backtest.LoadEvents(*format, *data, *interval, symbols)
+	if err != nil {
+		logger.Fatal("Failed to load backtest data", zap.Error(err))
+	}
+
+	runner := optimizer.NewRunner(cfg, objective, logger)
+	runner.Options = analytics.Options{RiskFreeRate: *riskFree}
+	runner.Workers = *workers
+	runner.MinTrades = *minTrades
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	started := time.Now()
+	names := optimizer.RangeNames(ranges)
+	result, err := optimizer.WalkForward(ctx, runner, events, wfCfg, sets, names)
+	if err != nil {
+		logger.Fatal("Walk-forward failed", zap.Error(err))
+	}
+	fmt.Printf("Walk-forward: %d windows x %d param sets in %s, objective %s\n\n",
+		len(result.Windows), len(sets), time.Since(started).Round(time.Millisecond), objective.Name)
+
+	if err := optimizer.WriteWalkForward(os.Stdout, result, names, objective.Name); err != nil {
+		logger.Error("Failed to print walk-forward results", zap.Error(err))
+	}
+	fmt.Println("\nStitched Out-of-Sample Performance")
+	if err := result.Report.WriteTable(os.Stdout); err != nil {
+		logger.Error("Failed to print out-of-sample report", zap.Error(err))
+	}
+
+	// 上线判定：样本外整体盈利，且 walk-forward 效率不低于阈值
+	promote := result.Report.TotalReturn > 0 && !math.IsNaN(result.Efficiency) && result.Efficiency >= *minWFE
+	verdict := "REJECT"
+	if promote {
+		verdict = "PROMOTE"
+	}
+	fmt.Printf("\nVerdict: %s (OOS return %.2f%%, WFE %.2f, threshold %.2f)\n",
+		verdict, result.Report.TotalReturn*100, result.Efficiency, *minWFE)
+
+	if *htmlPath != "" {
+		if err := writeHTML(*htmlPath, result); err != nil {
+			logger.Fatal("Failed to write HTML report", zap.Error(err))
+		}
+	}
+}
+
+// parseSpan 解析窗口长度 (支持 m/h/d 单位，空字符串或 "0" 表示 0)
+func parseSpan(name string, value string, logger *zap.SugaredLogger) time.Duration {
+	if value == "" || value == "0" {
+		return 0
+	}
+	d, err := service.ParseIntervalDuration(value)
+	if err != nil {
+		logger.Fatal("Invalid window length", zap.String("Flag", name), zap.Error(err))
+	}
+	return d
+}
+
+// writeHTML 将拼接后的样本外净值、交易和状态切换写入 HTML 报告
+func writeHTML(path string, result *optimizer.WalkForwardResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	session := analytics.SessionData{
+		Title:  "Walk-Forward Out-of-Sample",
+		Trades: result.Trades,
+		Equity: result.Equity,
+		Prices: make(map[string][]model.PricePoint),
+	}
+	for _, w := range result.Windows {
+		session.Transitions = append(session.Transitions, w.Transitions...)
+		for symbol, series := range w.Prices {
+			session.Prices[symbol] = append(session.Prices[symbol], series...)
+		}
+	}
+	return analytics.WriteHTML(f, session, result.Report)
+}
@@ -0,0 +1,405 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionData 生成 HTML 报告所需的会话数据，回测结果和实时模拟会话 (SessionRecorder) 都可以提供
+type SessionData struct {
+	Title       string
+	Trades      []*model.TradeRecord
+	Equity      []model.EquityPoint
+	Prices      map[string][]model.PricePoint
+	Transitions []model.StateTransition
+}
+
+// 图表尺寸
+const (
+	chartWidth     = 1100.0
+	chartPadLeft   = 70.0
+	chartPadRight  = 20.0
+	chartPadTop    = 10.0
+	chartPadBottom = 24.0
+	maxChartPoints = 1500 // 每条曲线最多绘制的点数 (超过时均匀抽样)
+)
+
+// stateColors 各市场状态在图表中的颜色
+var stateColors = map[model.MarketState]string{
+	model.StateStrongUpTrend:   "#2e7d32",
+	model.StateStrongDownTrend: "#c62828",
+	model.StateHighVolRanging:  "#ef6c00",
+	model.StateLowVolRanging:   "#1565c0",
+	model.StateInitial:         "#9e9e9e",
+}
+
+// stateColor 返回市场状态的颜色 (未知状态为灰色)
+func stateColor(state model.MarketState) string {
+	if color, ok := stateColors[state]; ok {
+		return color
+	}
+	return "#9e9e9e"
+}
+
+// WriteHTML 生成单文件、无外部依赖的 HTML 报告：净值和回撤曲线、带开平仓标记的价格图、
+// 市场状态时间线以及统计表。report 为 nil 时根据 data 现场计算
+func WriteHTML(w io.Writer, data SessionData, report *Report) error {
+	if report == nil {
+		report = Analyze(data.Trades, data.Equity, Options{})
+	}
+	if data.Title == "" {
+		data.Title = "Trading Session Report"
+	}
+
+	t0, t1 := sessionRange(data)
+
+	symbols := make([]string, 0, len(data.Prices))
+	for symbol := range data.Prices {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var priceCharts []priceChartView
+	for _, symbol := range symbols {
+		priceCharts = append(priceCharts, priceChartView{
+			Symbol:   symbol,
+			Chart:    priceChart(symbol, data, t0, t1),
+			Timeline: regimeTimeline(symbol, data.Transitions, t0, t1),
+		})
+	}
+
+	var tableBuf strings.Builder
+	if err := report.WriteTable(&tableBuf); err != nil {
+		return err
+	}
+
+	view := htmlView{
+		Title:       data.Title,
+		Generated:   time.Now().UTC().Format(time.RFC3339),
+		Report:      report,
+		Equity:      equityChart(data.Equity, t0, t1),
+		Drawdown:    drawdownChart(data.Equity, t0, t1),
+		PriceCharts: priceCharts,
+		Legend:      legend(),
+		Table:       tableBuf.String(),
+		Trades:      data.Trades,
+	}
+	return htmlTemplate.Execute(w, view)
+}
+
+type htmlView struct {
+	Title       string
+	Generated   string
+	Report      *Report
+	Equity      template.HTML
+	Drawdown    template.HTML
+	PriceCharts []priceChartView
+	Legend      template.HTML
+	Table       string
+	Trades      []*model.TradeRecord
+}
+
+type priceChartView struct {
+	Symbol   string
+	Chart    template.HTML
+	Timeline template.HTML
+}
+
+// sessionRange 会话的时间范围 (取净值、价格和交易中最早和最晚的时间)
+func sessionRange(data SessionData) (time.Time, time.Time) {
+	var t0, t1 time.Time
+	extend := func(t time.Time) {
+		if t.IsZero() {
+			return
+		}
+		if t0.IsZero() || t.Before(t0) {
+			t0 = t
+		}
+		if t1.IsZero() || t.After(t1) {
+			t1 = t
+		}
+	}
+	for _, p := range data.Equity {
+		extend(p.Time)
+	}
+	for _, series := range data.Prices {
+		for _, p := range series {
+			extend(p.Time)
+		}
+	}
+	for _, trade := range data.Trades {
+		extend(trade.EntryTime)
+		extend(trade.ExitTime)
+	}
+	if !t1.After(t0) {
+		t1 = t0.Add(time.Minute)
+	}
+	return t0, t1
+}
+
+// svgChart 一个时间为横轴的 SVG 图表
+type svgChart struct {
+	height     float64
+	t0, t1     time.Time
+	yMin, yMax float64
+	b          strings.Builder
+}
+
+func newChart(height float64, t0, t1 time.Time, yMin, yMax float64) *svgChart {
+	if yMax <= yMin {
+		yMin, yMax = yMin-1, yMax+1
+	}
+	c := &svgChart{height: height, t0: t0, t1: t1, yMin: yMin, yMax: yMax}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %.0f %.0f" width="100%%">`, chartWidth, height)
+	return c
+}
+
+func (c *svgChart) x(t time.Time) float64 {
+	span := c.t1.Sub(c.t0).Seconds()
+	return chartPadLeft + (chartWidth-chartPadLeft-chartPadRight)*t.Sub(c.t0).Seconds()/span
+}
+
+func (c *svgChart) y(v float64) float64 {
+	plot := c.height - chartPadTop - chartPadBottom
+	return chartPadTop + plot*(1-(v-c.yMin)/(c.yMax-c.yMin))
+}
+
+// axes 绘制边框、水平网格线及纵轴和时间轴标签
+func (c *svgChart) axes(format func(float64) string) {
+	bottom := c.height - chartPadBottom
+	fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#ccc"/>`,
+		chartPadLeft, chartPadTop, chartWidth-chartPadLeft-chartPadRight, bottom-chartPadTop)
+	for i := 0; i <= 4; i++ {
+		v := c.yMin + (c.yMax-c.yMin)*float64(i)/4
+		y := c.y(v)
+		fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`, chartPadLeft, y, chartWidth-chartPadRight, y)
+		fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="end" fill="#555">%s</text>`, chartPadLeft-6, y+4, html.EscapeString(format(v)))
+	}
+	for i := 0; i <= 5; i++ {
+		t := c.t0.Add(time.Duration(float64(c.t1.Sub(c.t0)) * float64(i) / 5))
+		fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle" fill="#555">%s</text>`, c.x(t), c.height-6, t.UTC().Format("01-02 15:04"))
+	}
+}
+
+// polyline 绘制一条曲线，fillTo 非 nil 时填充到该值的水平线
+func (c *svgChart) polyline(times []time.Time, values []float64, color string, fillTo *float64) {
+	if len(times) == 0 {
+		return
+	}
+	var points strings.Builder
+	for _, i := range sampleIndexes(len(times), maxChartPoints) {
+		fmt.Fprintf(&points, "%.1f,%.1f ", c.x(times[i]), c.y(values[i]))
+	}
+	if fillTo != nil {
+		base := c.y(*fillTo)
+		fmt.Fprintf(&c.b, `<polygon points="%.1f,%.1f %s%.1f,%.1f" fill="%s" fill-opacity="0.25" stroke="none"/>`,
+			c.x(times[0]), base, points.String(), c.x(times[len(times)-1]), base, color)
+	}
+	fmt.Fprintf(&c.b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.2"/>`, strings.TrimSpace(points.String()), color)
+}
+
+func (c *svgChart) html() template.HTML {
+	c.b.WriteString(`</svg>`)
+	return template.HTML(c.b.String())
+}
+
+// sampleIndexes 在 n 个点中均匀选取最多 max 个下标 (始终包含首尾)
+func sampleIndexes(n int, max int) []int {
+	if n <= max {
+		indexes := make([]int, n)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	indexes := make([]int, 0, max)
+	step := float64(n-1) / float64(max-1)
+	for i := 0; i < max; i++ {
+		indexes = append(indexes, int(math.Round(float64(i)*step)))
+	}
+	return indexes
+}
+
+// equityChart 净值曲线
+func equityChart(equity []model.EquityPoint, t0, t1 time.Time) template.HTML {
+	times := make([]time.Time, len(equity))
+	values := make([]float64, len(equity))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, p := range equity {
+		times[i], values[i] = p.Time, p.Equity
+		lo, hi = math.Min(lo, p.Equity), math.Max(hi, p.Equity)
+	}
+	if len(equity) == 0 {
+		lo, hi = 0, 1
+	}
+
+	c := newChart(260, t0, t1, lo, hi)
+	c.axes(func(v float64) string { return fmt.Sprintf("%.0f", v) })
+	c.polyline(times, values, "#1565c0", nil)
+	return c.html()
+}
+
+// drawdownChart 回撤曲线 (相对历史最高净值的百分比)
+func drawdownChart(equity []model.EquityPoint, t0, t1 time.Time) template.HTML {
+	times := make([]time.Time, len(equity))
+	values := make([]float64, len(equity))
+	peak, worst := 0.0, 0.0
+	for i, p := range equity {
+		peak = math.Max(peak, p.Equity)
+		dd := 0.0
+		if peak > 0 {
+			dd = -(peak - p.Equity) / peak * 100
+		}
+		times[i], values[i] = p.Time, dd
+		worst = math.Min(worst, dd)
+	}
+
+	zero := 0.0
+	c := newChart(160, t0, t1, math.Min(worst, -1), 0)
+	c.axes(func(v float64) string { return fmt.Sprintf("%.1f%%", v) })
+	c.polyline(times, values, "#c62828", &zero)
+	return c.html()
+}
+
+// priceChart 价格曲线，叠加开仓 (三角形) 和平仓 (圆点) 标记，颜色为开仓时的市场状态
+func priceChart(symbol string, data SessionData, t0, t1 time.Time) template.HTML {
+	series := data.Prices[symbol]
+	times := make([]time.Time, len(series))
+	values := make([]float64, len(series))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, p := range series {
+		times[i], values[i] = p.Time, p.Price
+		lo, hi = math.Min(lo, p.Price), math.Max(hi, p.Price)
+	}
+
+	var trades []*model.TradeRecord
+	for _, trade := range data.Trades {
+		if trade.Symbol == symbol {
+			trades = append(trades, trade)
+			lo = math.Min(lo, math.Min(trade.EntryPrice, trade.ExitPrice))
+			hi = math.Max(hi, math.Max(trade.EntryPrice, trade.ExitPrice))
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 1
+	}
+	margin := (hi - lo) * 0.03
+
+	c := newChart(300, t0, t1, lo-margin, hi+margin)
+	c.axes(func(v float64) string { return fmt.Sprintf("%.2f", v) })
+	c.polyline(times, values, "#424242", nil)
+
+	for _, trade := range trades {
+		color := stateColor(trade.SourceState)
+		ex, ey := c.x(trade.EntryTime), c.y(trade.EntryPrice)
+		// 多头开仓为向上三角形，空头为向下三角形
+		tip := -6.0
+		if trade.PosSide == model.DirShort {
+			tip = 6.0
+		}
+		fmt.Fprintf(&c.b, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="%s"><title>%s</title></polygon>`,
+			ex, ey+tip, ex-5, ey-tip, ex+5, ey-tip, color,
+			html.EscapeString(fmt.Sprintf("ENTRY %s %s @ %.4f (%s) %s", trade.PosSide, trade.Symbol, trade.EntryPrice, trade.SourceState, trade.EntryTime.UTC().Format(time.RFC3339))))
+
+		xx, xy := c.x(trade.ExitTime), c.y(trade.ExitPrice)
+		fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-opacity="0.35" stroke-dasharray="2,2"/>`, ex, ey, xx, xy, color)
+		fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="3.5" fill="white" stroke="%s" stroke-width="1.5"><title>%s</title></circle>`,
+			xx, xy, color,
+			html.EscapeString(fmt.Sprintf("EXIT %s %s @ %.4f [%s] PnL %.2f %s", trade.PosSide, trade.Symbol, trade.ExitPrice, trade.TriggerReason, netPnL(trade), trade.ExitTime.UTC().Format(time.RFC3339))))
+	}
+	return c.html()
+}
+
+// regimeTimeline 状态机的市场状态时间线：每个状态区间一段色块
+func regimeTimeline(symbol string, transitions []model.StateTransition, t0, t1 time.Time) template.HTML {
+	c := newChart(46, t0, t1, 0, 1)
+	top, height := chartPadTop, 46-chartPadTop-chartPadBottom
+
+	state := model.StateInitial
+	from := t0
+	draw := func(to time.Time) {
+		if !to.After(from) {
+			return
+		}
+		fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`,
+			c.x(from), top, math.Max(c.x(to)-c.x(from), 0.5), height, stateColor(state),
+			html.EscapeString(fmt.Sprintf("%s %s -> %s", state, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))))
+	}
+	for _, tr := range transitions {
+		if tr.Symbol != "" && tr.Symbol != symbol {
+			continue
+		}
+		draw(tr.Time)
+		state, from = tr.To, tr.Time
+	}
+	draw(t1)
+	return c.html()
+}
+
+// legend 市场状态颜色图例
+func legend() template.HTML {
+	states := []model.MarketState{
+		model.StateStrongUpTrend, model.StateStrongDownTrend,
+		model.StateHighVolRanging, model.StateLowVolRanging, model.StateInitial,
+	}
+	var b strings.Builder
+	for _, state := range states {
+		fmt.Fprintf(&b, `<span class="legend"><i style="background:%s"></i>%s</span>`, stateColor(state), html.EscapeString(string(state)))
+	}
+	return template.HTML(b.String())
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pnl":  netPnL,
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 20px; } h2 { font-size: 16px; margin-top: 28px; }
+pre { background: #f7f7f7; padding: 12px; font-size: 12px; overflow-x: auto; }
+table { border-collapse: collapse; font-size: 12px; }
+td, th { border-bottom: 1px solid #eee; padding: 3px 8px; text-align: right; }
+th { background: #fafafa; } td:first-child, th:first-child { text-align: left; }
+.legend { margin-right: 14px; font-size: 12px; } .legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+.pos { color: #2e7d32; } .neg { color: #c62828; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}}</p>
+
+<h2>Statistics</h2>
+<pre>{{.Table}}</pre>
+
+<h2>Equity</h2>
+{{.Equity}}
+
+<h2>Drawdown</h2>
+{{.Drawdown}}
+
+<div>{{.Legend}}</div>
+{{range .PriceCharts}}
+<h2>{{.Symbol}} price &amp; regime</h2>
+{{.Chart}}
+{{.Timeline}}
+{{end}}
+
+<h2>Trades</h2>
+<table>
+<tr><th>Symbol</th><th>Side</th><th>State</th><th>Entry</th><th>Entry Price</th><th>Exit</th><th>Exit Price</th><th>Size</th><th>Reason</th><th>Net PnL</th></tr>
+{{range .Trades}}<tr><td>{{.Symbol}}</td><td>{{.PosSide}}</td><td>{{.SourceState}}</td><td>{{time .EntryTime}}</td><td>{{printf "%.4f" .EntryPrice}}</td><td>{{time .ExitTime}}</td><td>{{printf "%.4f" .ExitPrice}}</td><td>{{printf "%.4f" .Size}}</td><td>{{.TriggerReason}}</td>{{$p := pnl .}}<td class="{{if gt $p 0.0}}pos{{else}}neg{{end}}">{{printf "%.2f" $p}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,378 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"crypto-algo-trader/pkg/ta"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 跨实例行情分析的默认参数 (AnalyticsConfig 中对应字段为 0 / 空时使用)
+const (
+	DefaultMarketInterval = "1h"
+	DefaultMarketWindow   = 100
+	DefaultBenchmark      = "BTCUSDT"
+	DefaultADFCritical    = -3.34 // Engle-Granger 两变量 5% 临界值
+)
+
+// PairStats 一个配对的滚动协整统计 (最近一根已对齐的 K 线)
+type PairStats struct {
+	Base         string
+	Quote        string
+	Time         time.Time // 最近一根 K 线的起始时间
+	HedgeRatio   float64   // log(Base) 对 log(Quote) 的 OLS 斜率
+	Intercept    float64
+	Spread       float64 // 最新价差 (OLS 残差)
+	StdDev       float64 // 窗口内价差的标准差
+	ZScore       float64 // 最新价差的 z-score
+	ADF          float64 // 价差的 Dickey-Fuller t 统计量，越小越平稳
+	HalfLife     float64 // 均值回归半衰期 (K 线数量)，价差不回归时为 0
+	Cointegrated bool    // ADF 低于临界值
+}
+
+// MarketAnalytics 跨交易实例的行情分析服务：消费所有 DataEngine 的 K 线，按 K 线起始时间对齐各交易对的收盘价，
+// 维护滚动相关性、对基准的 beta 和配置配对的价差 z-score，供策略查询。
+// 所有方法都可以并发调用；nil 的 *MarketAnalytics 的 OnKLine 为空操作
+type MarketAnalytics struct {
+	mu          sync.RWMutex
+	interval    string
+	window      int
+	benchmark   string
+	adfCritical float64
+	pairs       []service.PairConfig
+
+	symbols   []string              // 已出现的交易对 (排序)
+	returns   map[string]*ta.Ring   // 对数收益
+	logPrices map[string]*ta.Ring   // 对数价格 (配对回归使用)
+	last      map[string]float64    // 最近一次对齐的收盘价 (缺失的 K 线沿用该价格)
+	rowTime   time.Time             // 正在收集的 K 线起始时间
+	row       map[string]float64    // 该时间各交易对的收盘价
+	time      time.Time             // 最近一次对齐的 K 线起始时间
+	pairStats map[string]*PairStats // "Base/Quote" -> 最新统计
+}
+
+// NewMarketAnalytics 按配置创建行情分析服务
+func NewMarketAnalytics(cfg service.AnalyticsConfig) *MarketAnalytics {
+	m := &MarketAnalytics{
+		interval:    cfg.Interval,
+		window:      cfg.Window,
+		benchmark:   cfg.Benchmark,
+		adfCritical: cfg.ADFCritical,
+		returns:     make(map[string]*ta.Ring),
+		logPrices:   make(map[string]*ta.Ring),
+		last:        make(map[string]float64),
+		row:         make(map[string]float64),
+		pairStats:   make(map[string]*PairStats),
+	}
+	if m.interval == "" {
+		m.interval = DefaultMarketInterval
+	}
+	if m.window < 2 {
+		m.window = DefaultMarketWindow
+	}
+	if m.benchmark == "" {
+		m.benchmark = DefaultBenchmark
+	}
+	if m.adfCritical == 0 {
+		m.adfCritical = DefaultADFCritical
+	}
+	for _, pair := range cfg.Pairs {
+		if pair.Window < 3 {
+			pair.Window = m.window
+		}
+		m.pairs = append(m.pairs, pair)
+	}
+	return m
+}
+
+// Interval 返回使用的 K 线周期
+func (m *MarketAnalytics) Interval() string { return m.interval }
+
+// Benchmark 返回计算 beta 的基准交易对
+func (m *MarketAnalytics) Benchmark() string { return m.benchmark }
+
+// OnKLine 接收一根完成的 K 线 (其他周期忽略)。同一时间的 K 线全部到齐，或某个交易对出现更晚的 K 线时，
+// 该时间的各交易对收盘价完成对齐，没有收到 K 线的交易对沿用上一收盘价 (收益为 0)；已对齐时间的迟到 K 线被丢弃
+func (m *MarketAnalytics) OnKLine(kline model.KLine) {
+	if m == nil || kline.Interval != m.interval || kline.Close <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !kline.StartTime.After(m.time) && !m.time.IsZero() {
+		return
+	}
+	if len(m.row) > 0 && kline.StartTime.After(m.rowTime) {
+		m.commit()
+	}
+	m.rowTime = kline.StartTime
+	m.row[kline.Symbol] = kline.Close
+	// 所有已知交易对都到齐时立即对齐，策略在同一根 K 线上就能看到最新的统计
+	if len(m.symbols) > 0 && len(m.row) >= len(m.symbols) {
+		m.commit()
+	}
+}
+
+// commit 对齐 rowTime 的收盘价，更新收益序列和配对统计
+func (m *MarketAnalytics) commit() {
+	for symbol := range m.row {
+		if _, ok := m.last[symbol]; !ok {
+			m.returns[symbol] = ta.NewRing(m.window)
+			m.logPrices[symbol] = ta.NewRing(m.maxWindow())
+			m.last[symbol] = 0
+			m.symbols = append(m.symbols, symbol)
+			sort.Strings(m.symbols)
+		}
+	}
+	for _, symbol := range m.symbols {
+		price, ok := m.row[symbol]
+		if !ok {
+			price = m.last[symbol]
+		}
+		if prev := m.last[symbol]; prev > 0 {
+			m.returns[symbol].Push(math.Log(price / prev))
+		}
+		m.logPrices[symbol].Push(math.Log(price))
+		m.last[symbol] = price
+	}
+	m.time = m.rowTime
+	m.row = make(map[string]float64)
+
+	for _, pair := range m.pairs {
+		if stats, ok := m.computePair(pair); ok {
+			m.pairStats[pairKey(pair.Base, pair.Quote)] = stats
+		}
+	}
+}
+
+// maxWindow 配对回归需要保留的最长对数价格序列
+func (m *MarketAnalytics) maxWindow() int {
+	n := m.window
+	for _, pair := range m.pairs {
+		if pair.Window > n {
+			n = pair.Window
+		}
+	}
+	return n
+}
+
+// Time 返回最近一次对齐的 K 线起始时间
+func (m *MarketAnalytics) Time() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.time
+}
+
+// Symbols 返回已出现的交易对 (排序)
+func (m *MarketAnalytics) Symbols() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.symbols...)
+}
+
+// Correlation 返回两个交易对对数收益的滚动相关系数，窗口未填满时返回 false
+func (m *MarketAnalytics) Correlation(a string, b string) (float64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.correlation(a, b)
+}
+
+func (m *MarketAnalytics) correlation(a string, b string) (float64, bool) {
+	x, y, ok := m.alignedReturns(a, b)
+	if !ok {
+		return 0, false
+	}
+	_, varX, varY, cov := moments(x, y)
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// CorrelationMatrix 返回所有交易对的滚动相关矩阵 (顺序与 symbols 一致，数据不足的位置为 NaN)
+func (m *MarketAnalytics) CorrelationMatrix() (symbols []string, matrix [][]float64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	symbols = append([]string(nil), m.symbols...)
+	matrix = make([][]float64, len(symbols))
+	for i := range symbols {
+		matrix[i] = make([]float64, len(symbols))
+		for j := range symbols {
+			switch {
+			case i == j:
+				matrix[i][j] = 1
+			case j < i:
+				matrix[i][j] = matrix[j][i]
+			default:
+				corr, ok := m.correlation(symbols[i], symbols[j])
+				if !ok {
+					corr = math.NaN()
+				}
+				matrix[i][j] = corr
+			}
+		}
+	}
+	return symbols, matrix
+}
+
+// Beta 返回交易对相对基准的滚动 beta (cov / var(基准))，窗口未填满或基准未出现时返回 false
+func (m *MarketAnalytics) Beta(symbol string) (float64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	x, y, ok := m.alignedReturns(m.benchmark, symbol)
+	if !ok {
+		return 0, false
+	}
+	_, varX, _, cov := moments(x, y)
+	if varX == 0 {
+		return 0, false
+	}
+	return cov / varX, true
+}
+
+// Pair 返回配置的配对的最新统计 (价差窗口填满前返回 false)
+func (m *MarketAnalytics) Pair(base string, quote string) (PairStats, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats, ok := m.pairStats[pairKey(base, quote)]
+	if !ok {
+		return PairStats{}, false
+	}
+	return *stats, true
+}
+
+// Pairs 返回所有已有统计的配对 (按配置顺序)
+func (m *MarketAnalytics) Pairs() []PairStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []PairStats
+	for _, pair := range m.pairs {
+		if stats, ok := m.pairStats[pairKey(pair.Base, pair.Quote)]; ok {
+			out = append(out, *stats)
+		}
+	}
+	return out
+}
+
+// alignedReturns 返回两个交易对最近 window 个对齐的对数收益
+func (m *MarketAnalytics) alignedReturns(a string, b string) ([]float64, []float64, bool) {
+	ra, rb := m.returns[a], m.returns[b]
+	if ra.Len() < m.window || rb.Len() < m.window {
+		return nil, nil, false
+	}
+	return ra.Values(), rb.Values(), true
+}
+
+// computePair 用最近 pair.Window 个对数价格做 OLS 回归，计算价差、z-score 和残差的 ADF 统计量
+func (m *MarketAnalytics) computePair(pair service.PairConfig) (*PairStats, bool) {
+	yRing, xRing := m.logPrices[pair.Base], m.logPrices[pair.Quote]
+	n := pair.Window
+	if yRing.Len() < n || xRing.Len() < n {
+		return nil, false
+	}
+	y, x := lastValues(yRing, n), lastValues(xRing, n)
+
+	means, varX, _, cov := moments(x, y)
+	if varX == 0 {
+		return nil, false
+	}
+	hedge := cov / varX
+	intercept := means[1] - hedge*means[0]
+	spread := make([]float64, n)
+	for i := range spread {
+		spread[i] = y[i] - intercept - hedge*x[i]
+	}
+
+	stats := &PairStats{
+		Base:       pair.Base,
+		Quote:      pair.Quote,
+		Time:       m.time,
+		HedgeRatio: hedge,
+		Intercept:  intercept,
+		Spread:     spread[n-1],
+	}
+	// OLS 残差的均值为 0
+	var sumSq float64
+	for _, e := range spread {
+		sumSq += e * e
+	}
+	stats.StdDev = math.Sqrt(sumSq / float64(n-1))
+	if stats.StdDev > 0 {
+		stats.ZScore = stats.Spread / stats.StdDev
+	}
+
+	gamma, tStat, ok := dickeyFuller(spread)
+	if ok {
+		stats.ADF = tStat
+		stats.Cointegrated = tStat < m.adfCritical
+		if gamma < 0 && gamma > -1 {
+			stats.HalfLife = -math.Ln2 / math.Log(1+gamma)
+		}
+	}
+	return stats, true
+}
+
+// dickeyFuller 对序列做无常数项的 Dickey-Fuller 回归 Δe_t = γ·e_{t-1} + ε，返回 γ 及其 t 统计量
+func dickeyFuller(e []float64) (gamma float64, tStat float64, ok bool) {
+	m := len(e) - 1
+	if m < 3 {
+		return 0, 0, false
+	}
+	var sxx, sxy float64
+	for i := 1; i <= m; i++ {
+		sxx += e[i-1] * e[i-1]
+		sxy += e[i-1] * (e[i] - e[i-1])
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+	gamma = sxy / sxx
+	var rss float64
+	for i := 1; i <= m; i++ {
+		r := e[i] - e[i-1] - gamma*e[i-1]
+		rss += r * r
+	}
+	se := math.Sqrt(rss / float64(m-1) / sxx)
+	if se == 0 {
+		return gamma, 0, false
+	}
+	return gamma, gamma / se, true
+}
+
+// moments 返回两个等长序列的均值 [x, y]、样本方差和样本协方差
+func moments(x []float64, y []float64) (means [2]float64, varX float64, varY float64, cov float64) {
+	n := float64(len(x))
+	for i := range x {
+		means[0] += x[i]
+		means[1] += y[i]
+	}
+	means[0] /= n
+	means[1] /= n
+	for i := range x {
+		dx, dy := x[i]-means[0], y[i]-means[1]
+		varX += dx * dx
+		varY += dy * dy
+		cov += dx * dy
+	}
+	return means, varX / (n - 1), varY / (n - 1), cov / (n - 1)
+}
+
+// lastValues 返回环形缓冲区最新的 n 个元素 (最旧在前)
+func lastValues(r *ta.Ring, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = r.Ago(n - 1 - i)
+	}
+	return values
+}
+
+func pairKey(base string, quote string) string { return base + "/" + quote }
@@ -0,0 +1,335 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"text/tabwriter"
+)
+
+// Monte Carlo 的默认设置 (MonteCarloConfig 中对应字段为 0 时使用)
+const (
+	DefaultMonteCarloRuns = 1000
+	DefaultRuinThreshold  = 0.5 // 净值相对初始资金亏损 50% 视为破产
+)
+
+// 交易序列的重采样方式
+const (
+	MonteCarloBootstrap = "bootstrap" // 有放回地重采样 (交易数不变)：估计 "同样的优势，不同的运气"
+	MonteCarloShuffle   = "shuffle"   // 随机打乱顺序：总收益不变，只改变回撤路径
+)
+
+// MonteCarloConfig Monte Carlo 模拟的设置
+type MonteCarloConfig struct {
+	Runs          int
+	Method        string  // bootstrap (默认) / shuffle
+	SkipProb      float64 // 每笔交易被随机跳过的概率 (模拟漏单、断线、资金占用错过的机会)
+	SlippageBps   float64 // 每次成交的附加滑点噪声标准差 (基点)，按半正态分布总是不利于成交
+	RiskScale     float64 // 每笔收益率的缩放倍数 (评估不同的 MaxPerTradeRisk)，默认 1
+	RuinThreshold float64 // 净值相对初始资金的亏损达到该比例视为破产
+	Seed          int64
+}
+
+// Distribution 一个模拟指标在全部路径上的分布
+type Distribution struct {
+	Mean float64
+	Std  float64
+	Min  float64
+	P1   float64
+	P5   float64
+	P25  float64
+	P50  float64
+	P75  float64
+	P95  float64
+	P99  float64
+	Max  float64
+
+	sorted []float64
+}
+
+// Percentile 返回第 p 百分位 (0-100，线性插值)
+func (d Distribution) Percentile(p float64) float64 {
+	return percentile(d.sorted, p)
+}
+
+// MonteCarloResult Monte Carlo 模拟结果
+type MonteCarloResult struct {
+	Config MonteCarloConfig
+	Runs   int
+	Trades int // 原始交易数
+
+	// 原始交易序列 (按平仓时间复利) 的结果，用于与分布对比
+	ObservedReturn   float64
+	ObservedDrawdown float64
+
+	FinalReturn Distribution // 最终收益率
+	MaxDrawdown Distribution // 最大回撤 (比例)
+	RiskOfRuin  float64      // 任意时刻净值亏损达到 RuinThreshold 的路径比例
+	ProbLoss    float64      // 最终亏损的路径比例
+}
+
+// tradeOutcome 一笔交易相对开仓时账户余额的收益率，以及用于叠加滑点的成交额
+type tradeOutcome struct {
+	ret   float64
+	entry float64 // 开仓成交额 / 当时余额
+	exit  float64 // 平仓成交额 / 当时余额
+}
+
+// tradeOutcomes 按平仓时间把交易盈亏换算成相对当时余额 (初始资金 + 此前已实现的净盈亏) 的收益率，
+// 使模拟结果与资金规模无关
+func tradeOutcomes(trades []*model.TradeRecord, initialEquity float64) []tradeOutcome {
+	sorted := make([]*model.TradeRecord, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ExitTime.Before(sorted[j].ExitTime) })
+
+	outcomes := make([]tradeOutcome, 0, len(sorted))
+	balance := initialEquity
+	for _, trade := range sorted {
+		if balance <= 0 {
+			break
+		}
+		pnl := netPnL(trade)
+		outcomes = append(outcomes, tradeOutcome{
+			ret:   pnl / balance,
+			entry: trade.Size * trade.EntryPrice / balance,
+			exit:  trade.Size * trade.ExitPrice / balance,
+		})
+		balance += pnl
+	}
+	return outcomes
+}
+
+// MonteCarlo 对交易序列做 Monte Carlo 模拟：每条路径按 Method 重采样交易，随机跳过交易并叠加滑点噪声，
+// 从 1 开始按复利累积收益率，统计最终收益、最大回撤和破产概率的分布。相同的 Seed 得到相同的结果
+func MonteCarlo(trades []*model.TradeRecord, initialEquity float64, cfg MonteCarloConfig) *MonteCarloResult {
+	if cfg.Runs <= 0 {
+		cfg.Runs = DefaultMonteCarloRuns
+	}
+	if cfg.Method == "" {
+		cfg.Method = MonteCarloBootstrap
+	}
+	if cfg.RiskScale <= 0 {
+		cfg.RiskScale = 1
+	}
+	if cfg.RuinThreshold <= 0 {
+		cfg.RuinThreshold = DefaultRuinThreshold
+	}
+
+	outcomes := tradeOutcomes(trades, initialEquity)
+	result := &MonteCarloResult{Config: cfg, Runs: cfg.Runs, Trades: len(outcomes)}
+	result.ObservedReturn, result.ObservedDrawdown, _ = simulatePath(outcomes, identity(len(outcomes)), nil, 1)
+	if len(outcomes) == 0 {
+		return result
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	returns := make([]float64, cfg.Runs)
+	drawdowns := make([]float64, cfg.Runs)
+	ruined, losses := 0, 0
+	order := identity(len(outcomes))
+	for run := 0; run < cfg.Runs; run++ {
+		switch cfg.Method {
+		case MonteCarloShuffle:
+			rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		default:
+			for i := range order {
+				order[i] = rng.Intn(len(outcomes))
+			}
+		}
+
+		path := pathNoise{rng: rng, skip: cfg.SkipProb, slippage: cfg.SlippageBps / 1e4}
+		final, dd, minEquity := simulatePath(outcomes, order, &path, cfg.RiskScale)
+		returns[run], drawdowns[run] = final, dd
+		if minEquity <= 1-cfg.RuinThreshold {
+			ruined++
+		}
+		if final < 0 {
+			losses++
+		}
+	}
+
+	result.FinalReturn = distribution(returns)
+	result.MaxDrawdown = distribution(drawdowns)
+	result.RiskOfRuin = float64(ruined) / float64(cfg.Runs)
+	result.ProbLoss = float64(losses) / float64(cfg.Runs)
+	return result
+}
+
+// pathNoise 一条路径上的随机跳过和滑点噪声
+type pathNoise struct {
+	rng      *rand.Rand
+	skip     float64
+	slippage float64 // 滑点标准差 (比例)
+}
+
+// simulatePath 按 order 复利累积收益率，返回最终收益率、最大回撤和路径上的最低净值 (初始为 1)。
+// 路径总是走完全部交易 (不在破产时提前停止)，使不同 scale 的模拟消耗相同的随机数
+func simulatePath(outcomes []tradeOutcome, order []int, noise *pathNoise, scale float64) (float64, float64, float64) {
+	equity, peak, maxDD, minEquity := 1.0, 1.0, 0.0, 1.0
+	for _, i := range order {
+		o := outcomes[i]
+		ret := o.ret
+		if noise != nil {
+			if noise.skip > 0 && noise.rng.Float64() < noise.skip {
+				continue
+			}
+			if noise.slippage > 0 {
+				ret -= (o.entry*math.Abs(noise.rng.NormFloat64()) + o.exit*math.Abs(noise.rng.NormFloat64())) * noise.slippage
+			}
+		}
+
+		equity *= 1 + ret*scale
+		if equity < 0 {
+			equity = 0
+		}
+		peak = math.Max(peak, equity)
+		if peak > 0 {
+			maxDD = math.Max(maxDD, 1-equity/peak)
+		}
+		minEquity = math.Min(minEquity, equity)
+	}
+	return equity - 1, maxDD, minEquity
+}
+
+func identity(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// distribution 统计样本的均值、标准差和分位数
+func distribution(values []float64) Distribution {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	d := Distribution{sorted: sorted}
+	d.Mean, d.Std = meanStd(sorted)
+	if len(sorted) == 0 {
+		return d
+	}
+	d.Min, d.Max = sorted[0], sorted[len(sorted)-1]
+	d.P1, d.P5, d.P25, d.P50 = percentile(sorted, 1), percentile(sorted, 5), percentile(sorted, 25), percentile(sorted, 50)
+	d.P75, d.P95, d.P99 = percentile(sorted, 75), percentile(sorted, 95), percentile(sorted, 99)
+	return d
+}
+
+// percentile 已排序样本的第 p 百分位 (线性插值)
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo < 0 {
+		return sorted[0]
+	}
+	if hi >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// RiskSizing 基于 Monte Carlo 的风险参数建议
+type RiskSizing struct {
+	Confidence  float64 // 置信水平 (例如 0.95)
+	CurrentRisk float64 // 回测使用的 MaxPerTradeRisk
+
+	// DrawdownAtCurrent 当前风险下最大回撤的 Confidence 分位数。
+	// 回撤熔断 (MaxAllowedDrawdown) 应设在该值之上，否则正常的波动也会触发熔断
+	DrawdownAtCurrent float64
+	RuinAtCurrent     float64
+
+	// RecommendedRisk 使最大回撤的 Confidence 分位数不超过 TargetDrawdown 的最大 MaxPerTradeRisk
+	// (收益率按 RecommendedRisk / CurrentRisk 线性缩放)
+	TargetDrawdown      float64
+	RecommendedRisk     float64
+	DrawdownAtRecommend float64
+	RuinAtRecommend     float64
+	ReturnAtRecommend   float64 // 推荐风险下最终收益率的中位数
+}
+
+// SizeRisk 在当前风险 currentRisk 下模拟回撤分布，并二分搜索收益缩放倍数，
+// 找到 Confidence 分位数的最大回撤恰好不超过 targetDrawdown 的每笔风险
+func SizeRisk(trades []*model.TradeRecord, initialEquity float64, cfg MonteCarloConfig, currentRisk float64, targetDrawdown float64, confidence float64) RiskSizing {
+	sizing := RiskSizing{Confidence: confidence, CurrentRisk: currentRisk, TargetDrawdown: targetDrawdown}
+	p := confidence * 100
+
+	cfg.RiskScale = 1
+	current := MonteCarlo(trades, initialEquity, cfg)
+	sizing.DrawdownAtCurrent = current.MaxDrawdown.Percentile(p)
+	sizing.RuinAtCurrent = current.RiskOfRuin
+
+	// 相同的种子使每次模拟使用相同的随机路径，回撤分位数随缩放倍数单调变化
+	lo, hi := 0.0, 1.0
+	for hi < 64 {
+		cfg.RiskScale = hi
+		if MonteCarlo(trades, initialEquity, cfg).MaxDrawdown.Percentile(p) > targetDrawdown {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	for i := 0; i < 30; i++ {
+		mid := (lo + hi) / 2
+		cfg.RiskScale = mid
+		if MonteCarlo(trades, initialEquity, cfg).MaxDrawdown.Percentile(p) > targetDrawdown {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	sizing.RecommendedRisk = currentRisk * lo
+	if lo > 0 {
+		cfg.RiskScale = lo
+		recommended := MonteCarlo(trades, initialEquity, cfg)
+		sizing.DrawdownAtRecommend = recommended.MaxDrawdown.Percentile(p)
+		sizing.RuinAtRecommend = recommended.RiskOfRuin
+		sizing.ReturnAtRecommend = recommended.FinalReturn.P50
+	}
+	return sizing
+}
+
+// WriteTable 以终端表格输出模拟结果
+func (r *MonteCarloResult) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	cfg := r.Config
+	fmt.Fprintf(tw, "Monte Carlo\t%d runs x %d trades (%s, skip %s, slippage %.1f bps, risk x%.2f, seed %d)\n",
+		r.Runs, r.Trades, cfg.Method, percent(cfg.SkipProb), cfg.SlippageBps, cfg.RiskScale, cfg.Seed)
+	fmt.Fprintf(tw, "Observed Return / Max DD\t%s / %s\n", percent(r.ObservedReturn), percent(r.ObservedDrawdown))
+
+	fmt.Fprintf(tw, "\n\tMean\tP1\tP5\tP25\tP50\tP75\tP95\tP99\n")
+	for _, row := range []struct {
+		name string
+		d    Distribution
+	}{{"Final Return", r.FinalReturn}, {"Max Drawdown", r.MaxDrawdown}} {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.name, percent(row.d.Mean),
+			percent(row.d.P1), percent(row.d.P5), percent(row.d.P25), percent(row.d.P50),
+			percent(row.d.P75), percent(row.d.P95), percent(row.d.P99))
+	}
+
+	fmt.Fprintf(tw, "\nProbability of Loss\t%s\n", percent(r.ProbLoss))
+	fmt.Fprintf(tw, "Risk of Ruin (-%s)\t%s\n", percent(cfg.RuinThreshold), percent(r.RiskOfRuin))
+	return tw.Flush()
+}
+
+// WriteTable 以终端表格输出风险参数建议
+func (s RiskSizing) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	conf := fmt.Sprintf("%g", s.Confidence*100)
+	fmt.Fprintf(tw, "MaxPerTradeRisk (current)\t%s\n", percent(s.CurrentRisk))
+	fmt.Fprintf(tw, "  P%s Max Drawdown / Risk of Ruin\t%s / %s\n", conf, percent(s.DrawdownAtCurrent), percent(s.RuinAtCurrent))
+	fmt.Fprintf(tw, "  Suggested MaxAllowedDrawdown\t>= %s (drawdown breaker above normal variance)\n", percent(s.DrawdownAtCurrent))
+	fmt.Fprintf(tw, "MaxPerTradeRisk (recommended)\t%s (P%s max drawdown <= %s)\n", percent(s.RecommendedRisk), conf, percent(s.TargetDrawdown))
+	fmt.Fprintf(tw, "  P%s Max Drawdown / Risk of Ruin\t%s / %s\n", conf, percent(s.DrawdownAtRecommend), percent(s.RuinAtRecommend))
+	fmt.Fprintf(tw, "  Median Final Return\t%s\n", percent(s.ReturnAtRecommend))
+	return tw.Flush()
+}
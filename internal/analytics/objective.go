@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Objective 优化/评估目标：根据绩效报告给出一个分数，分数越高越好
+type Objective struct {
+	Name  string
+	Score func(report *Report) float64
+}
+
+// objectives 可选的优化目标
+var objectives = map[string]func(report *Report) float64{
+	"sharpe":  func(r *Report) float64 { return r.Sharpe },
+	"sortino": func(r *Report) float64 { return r.Sortino },
+	"calmar":  func(r *Report) float64 { return r.Calmar },
+	"return":  func(r *Report) float64 { return r.TotalReturn },
+	"net_pnl": func(r *Report) float64 { return r.NetPnL },
+	"profit_factor": func(r *Report) float64 {
+		// 没有亏损交易时盈亏比为 +Inf，排序时视为一个很大的有限值
+		return math.Min(r.ProfitFactor, 1e6)
+	},
+	"expectancy": func(r *Report) float64 { return r.Expectancy },
+	"drawdown":   func(r *Report) float64 { return -r.MaxDrawdown }, // 回撤越小越好
+}
+
+// ParseObjective 按名称查找优化目标
+func ParseObjective(name string) (Objective, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	score, ok := objectives[name]
+	if !ok {
+		return Objective{}, fmt.Errorf("unknown objective %q (available: %s)", name, strings.Join(ObjectiveNames(), ", "))
+	}
+	return Objective{Name: name, Score: score}, nil
+}
+
+// ObjectiveNames 返回所有优化目标名称 (排序)
+func ObjectiveNames() []string {
+	names := make([]string, 0, len(objectives))
+	for name := range objectives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,360 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// Year 加密货币 7x24 交易，年化按自然年计算
+const Year = 365 * 24 * time.Hour
+
+// Options 绩效计算参数
+type Options struct {
+	RiskFreeRate float64 // 年化无风险利率 (Sharpe / Sortino 使用)，默认 0
+}
+
+// Breakdown 一组交易 (按开仓状态或平仓原因分组) 的统计
+type Breakdown struct {
+	Trades       int
+	Wins         int
+	WinRate      float64
+	NetPnL       float64 // 扣除手续费后的盈亏
+	AvgPnL       float64
+	ProfitFactor float64 // 总盈利 / 总亏损 (无亏损时为 +Inf，JSON 中输出为 0)
+
+	grossWin  float64
+	grossLoss float64
+}
+
+// Report 回测绩效报告
+type Report struct {
+	Start         time.Time
+	End           time.Time
+	InitialEquity float64
+	FinalEquity   float64
+
+	TotalReturn      float64 // 总收益率
+	AnnualizedReturn float64 // 年化收益率 (复利)
+	Volatility       float64 // 年化波动率 (基于净值曲线采样收益)
+	Sharpe           float64
+	Sortino          float64
+	Calmar           float64 // 年化收益率 / 最大回撤
+
+	MaxDrawdown         float64       // 最大回撤 (比例)
+	MaxDrawdownDuration time.Duration `json:"-"` // 最长的水下时间 (从前高到恢复前高)，JSON 中输出为 MaxDrawdownDurationSeconds
+
+	Trades       int
+	Wins         int
+	Losses       int
+	WinRate      float64
+	AvgWin       float64
+	AvgLoss      float64 // 平均亏损 (负数)
+	Expectancy   float64 // 每笔交易的期望盈亏
+	ProfitFactor float64
+	NetPnL       float64
+	TotalFees    float64
+	ExposureTime float64 // 持仓时间占回测区间的比例
+
+	ByState  map[string]*Breakdown // 按开仓时的市场状态 (SourceState)
+	ByReason map[string]*Breakdown // 按平仓原因 (TriggerReason)
+}
+
+// Analyze 根据交易记录和采样的净值曲线计算绩效报告。
+// 收益率类指标基于净值曲线 (按采样间隔年化)，交易类指标基于扣除手续费后的逐笔盈亏。
+func Analyze(trades []*model.TradeRecord, equity []model.EquityPoint, opts Options) *Report {
+	report := &Report{
+		ByState:  make(map[string]*Breakdown),
+		ByReason: make(map[string]*Breakdown),
+	}
+
+	analyzeEquity(report, equity, opts)
+	analyzeTrades(report, trades)
+
+	return report
+}
+
+// analyzeEquity 计算收益、波动、风险调整收益和回撤
+func analyzeEquity(report *Report, equity []model.EquityPoint, opts Options) {
+	if len(equity) == 0 {
+		return
+	}
+
+	first, last := equity[0], equity[len(equity)-1]
+	report.Start, report.End = first.Time, last.Time
+	report.InitialEquity, report.FinalEquity = first.Equity, last.Equity
+	if first.Equity > 0 {
+		report.TotalReturn = last.Equity/first.Equity - 1
+	}
+
+	span := last.Time.Sub(first.Time)
+	if span > 0 && first.Equity > 0 && last.Equity > 0 {
+		report.AnnualizedReturn = math.Pow(last.Equity/first.Equity, float64(Year)/float64(span)) - 1
+	}
+
+	report.MaxDrawdown, report.MaxDrawdownDuration = drawdown(equity)
+	if report.MaxDrawdown > 0 {
+		report.Calmar = report.AnnualizedReturn / report.MaxDrawdown
+	}
+
+	returns := periodReturns(equity)
+	if len(returns) < 2 {
+		return
+	}
+	periodsPerYear := float64(Year) / float64(medianInterval(equity))
+	riskFree := opts.RiskFreeRate / periodsPerYear
+
+	mean, std := meanStd(returns)
+	report.Volatility = std * math.Sqrt(periodsPerYear)
+	if std > 0 {
+		report.Sharpe = (mean - riskFree) / std * math.Sqrt(periodsPerYear)
+	}
+
+	downside := 0.0
+	for _, r := range returns {
+		if d := r - riskFree; d < 0 {
+			downside += d * d
+		}
+	}
+	downside = math.Sqrt(downside / float64(len(returns)))
+	if downside > 0 {
+		report.Sortino = (mean - riskFree) / downside * math.Sqrt(periodsPerYear)
+	}
+}
+
+// analyzeTrades 计算胜率、盈亏比、期望、持仓时间占比以及分组统计
+func analyzeTrades(report *Report, trades []*model.TradeRecord) {
+	var grossWin, grossLoss float64
+	for _, trade := range trades {
+		pnl := netPnL(trade)
+		report.Trades++
+		report.NetPnL += pnl
+		report.TotalFees += trade.Fee
+		if pnl > 0 {
+			report.Wins++
+			grossWin += pnl
+		} else {
+			report.Losses++
+			grossLoss += -pnl
+		}
+
+		addBreakdown(report.ByState, string(trade.SourceState), pnl)
+		addBreakdown(report.ByReason, trade.TriggerReason, pnl)
+	}
+
+	if report.Trades > 0 {
+		report.WinRate = float64(report.Wins) / float64(report.Trades)
+		report.Expectancy = report.NetPnL / float64(report.Trades)
+	}
+	if report.Wins > 0 {
+		report.AvgWin = grossWin / float64(report.Wins)
+	}
+	if report.Losses > 0 {
+		report.AvgLoss = -grossLoss / float64(report.Losses)
+	}
+	report.ProfitFactor = profitFactor(grossWin, grossLoss)
+
+	for _, group := range []map[string]*Breakdown{report.ByState, report.ByReason} {
+		for _, b := range group {
+			b.WinRate = float64(b.Wins) / float64(b.Trades)
+			b.AvgPnL = b.NetPnL / float64(b.Trades)
+			b.ProfitFactor = profitFactor(b.grossWin, b.grossLoss)
+		}
+	}
+
+	if span := report.End.Sub(report.Start); span > 0 {
+		report.ExposureTime = float64(exposure(trades, report.Start, report.End)) / float64(span)
+	}
+}
+
+// addBreakdown 将一笔交易计入分组统计 (比例类指标在全部计入后统一计算)
+func addBreakdown(group map[string]*Breakdown, key string, pnl float64) {
+	if key == "" {
+		key = "UNKNOWN"
+	}
+	b, ok := group[key]
+	if !ok {
+		b = &Breakdown{}
+		group[key] = b
+	}
+	b.Trades++
+	b.NetPnL += pnl
+	if pnl > 0 {
+		b.Wins++
+		b.grossWin += pnl
+	} else {
+		b.grossLoss += -pnl
+	}
+}
+
+// netPnL 扣除开平仓手续费后的逐笔盈亏
+func netPnL(trade *model.TradeRecord) float64 {
+	return trade.RealizedPnL - trade.Fee
+}
+
+// profitFactor 总盈利 / 总亏损，没有亏损时返回 +Inf
+func profitFactor(grossWin, grossLoss float64) float64 {
+	if grossLoss == 0 {
+		if grossWin == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return grossWin / grossLoss
+}
+
+// periodReturns 净值曲线相邻采样点之间的简单收益率
+func periodReturns(equity []model.EquityPoint) []float64 {
+	returns := make([]float64, 0, len(equity))
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1].Equity <= 0 {
+			continue
+		}
+		returns = append(returns, equity[i].Equity/equity[i-1].Equity-1)
+	}
+	return returns
+}
+
+// medianInterval 采样间隔的中位数 (用于推算年化周期数，避免最后一个强制采样点干扰)
+func medianInterval(equity []model.EquityPoint) time.Duration {
+	intervals := make([]time.Duration, 0, len(equity))
+	for i := 1; i < len(equity); i++ {
+		if d := equity[i].Time.Sub(equity[i-1].Time); d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+	if len(intervals) == 0 {
+		return time.Minute
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return intervals[len(intervals)/2]
+}
+
+// meanStd 样本均值和标准差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
+
+// drawdown 计算最大回撤和最长水下时间
+func drawdown(equity []model.EquityPoint) (float64, time.Duration) {
+	var maxDD float64
+	var maxDuration time.Duration
+
+	peak := equity[0].Equity
+	peakTime := equity[0].Time
+	underwater := false
+	for _, point := range equity {
+		if point.Equity >= peak {
+			if d := point.Time.Sub(peakTime); underwater && d > maxDuration {
+				maxDuration = d
+			}
+			peak, peakTime, underwater = point.Equity, point.Time, false
+			continue
+		}
+		underwater = true
+		if peak > 0 {
+			maxDD = math.Max(maxDD, (peak-point.Equity)/peak)
+		}
+	}
+	// 回测结束时仍在水下
+	if d := equity[len(equity)-1].Time.Sub(peakTime); underwater && d > maxDuration {
+		maxDuration = d
+	}
+	return maxDD, maxDuration
+}
+
+// exposure 计算 [start, end] 区间内至少持有一条持仓腿的总时长 (合并重叠的持仓区间)
+func exposure(trades []*model.TradeRecord, start, end time.Time) time.Duration {
+	type interval struct{ from, to time.Time }
+	intervals := make([]interval, 0, len(trades))
+	for _, trade := range trades {
+		from, to := trade.EntryTime, trade.ExitTime
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			intervals = append(intervals, interval{from, to})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].from.Before(intervals[j].from) })
+
+	var total time.Duration
+	var current *interval
+	for i := range intervals {
+		iv := intervals[i]
+		if current != nil && !iv.from.After(current.to) {
+			if iv.to.After(current.to) {
+				current.to = iv.to
+			}
+			continue
+		}
+		if current != nil {
+			total += current.to.Sub(current.from)
+		}
+		current = &iv
+	}
+	if current != nil {
+		total += current.to.Sub(current.from)
+	}
+	return total
+}
+
+// MarshalJSON 将无穷大或 NaN 的指标输出为 0 (JSON 不支持 Inf / NaN，例如很短区间的年化收益率会溢出)，时长输出为秒
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type alias Report
+	clean := *r
+	for _, v := range []*float64{
+		&clean.InitialEquity, &clean.FinalEquity, &clean.TotalReturn, &clean.AnnualizedReturn,
+		&clean.Volatility, &clean.Sharpe, &clean.Sortino, &clean.Calmar, &clean.MaxDrawdown,
+		&clean.WinRate, &clean.AvgWin, &clean.AvgLoss, &clean.Expectancy, &clean.ProfitFactor,
+		&clean.NetPnL, &clean.TotalFees, &clean.ExposureTime,
+	} {
+		*v = finite(*v)
+	}
+	out := struct {
+		*alias
+		MaxDrawdownDurationSeconds float64
+	}{
+		alias:                      (*alias)(&clean),
+		MaxDrawdownDurationSeconds: r.MaxDrawdownDuration.Seconds(),
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON 同 Report.MarshalJSON
+func (b *Breakdown) MarshalJSON() ([]byte, error) {
+	type alias Breakdown
+	clean := *b
+	for _, v := range []*float64{&clean.WinRate, &clean.NetPnL, &clean.AvgPnL, &clean.ProfitFactor} {
+		*v = finite(*v)
+	}
+	return json.Marshal((*alias)(&clean))
+}
+
+func finite(v float64) float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+// shortWindow 几个小时内先回撤再上涨 50% 的净值曲线：年化收益率溢出为 +Inf
+func shortWindow() ([]*model.TradeRecord, []model.EquityPoint) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	equity := []model.EquityPoint{
+		{Time: start, Equity: 10000},
+		{Time: start.Add(time.Hour), Equity: 9000},
+		{Time: start.Add(2 * time.Hour), Equity: 12000},
+		{Time: start.Add(3 * time.Hour), Equity: 15000},
+	}
+	trades := []*model.TradeRecord{{
+		EntryTime:     start,
+		ExitTime:      start.Add(3 * time.Hour),
+		PosSide:       model.DirLong,
+		RealizedPnL:   5000,
+		TriggerReason: "TP",
+		SourceState:   model.StateStrongUpTrend,
+	}}
+	return trades, equity
+}
+
+func TestReportMarshalJSONNonFinite(t *testing.T) {
+	trades, equity := shortWindow()
+	report := Analyze(trades, equity, Options{})
+	if !math.IsInf(report.AnnualizedReturn, 1) || !math.IsInf(report.Calmar, 1) {
+		t.Fatalf("AnnualizedReturn %v / Calmar %v, want +Inf for a 3 hour +50%% window", report.AnnualizedReturn, report.Calmar)
+	}
+	// 没有亏损交易：盈亏比为 +Inf
+	if !math.IsInf(report.ProfitFactor, 1) || !math.IsInf(report.ByState[string(model.StateStrongUpTrend)].ProfitFactor, 1) {
+		t.Fatalf("ProfitFactor %v, want +Inf without losing trades", report.ProfitFactor)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.AnnualizedReturn != 0 || decoded.Calmar != 0 || decoded.ProfitFactor != 0 {
+		t.Errorf("non-finite fields decoded as %v / %v / %v, want 0", decoded.AnnualizedReturn, decoded.Calmar, decoded.ProfitFactor)
+	}
+	if decoded.TotalReturn != report.TotalReturn || decoded.MaxDrawdown != report.MaxDrawdown {
+		t.Errorf("finite fields changed: %+v", decoded)
+	}
+	if decoded.ByState[string(model.StateStrongUpTrend)].ProfitFactor != 0 {
+		t.Errorf("breakdown ProfitFactor not sanitised")
+	}
+}
+
+func TestReportMarshalJSONNaN(t *testing.T) {
+	report := &Report{Sharpe: math.NaN(), Sortino: math.Inf(-1), Volatility: math.NaN(), Expectancy: 1.5}
+	report.ByReason = map[string]*Breakdown{"SL": {AvgPnL: math.NaN(), WinRate: math.Inf(1)}}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Sharpe != 0 || decoded.Sortino != 0 || decoded.Volatility != 0 || decoded.Expectancy != 1.5 {
+		t.Errorf("decoded %+v", decoded)
+	}
+	// 序列化不修改原报告
+	if !math.IsNaN(report.Sharpe) || !math.IsNaN(report.ByReason["SL"].AvgPnL) {
+		t.Errorf("MarshalJSON modified the report")
+	}
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"crypto-algo-trader/internal/model"
+	"sync"
+	"time"
+)
+
+// SessionRecorder 为实时模拟会话采样净值和价格，会话结束时与交易记录、状态切换一起生成报告
+// (回测引擎在 backtest.Result 中直接提供这些数据)
+type SessionRecorder struct {
+	mu     sync.Mutex
+	equity []model.EquityPoint
+	prices map[string][]model.PricePoint
+}
+
+// NewSessionRecorder 创建一个空的会话记录器
+func NewSessionRecorder() *SessionRecorder {
+	return &SessionRecorder{prices: make(map[string][]model.PricePoint)}
+}
+
+// Record 记录一个采样点 (净值、余额以及各交易对的最新价格)
+func (r *SessionRecorder) Record(t time.Time, equity float64, balance float64, prices map[string]float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.equity = append(r.equity, model.EquityPoint{Time: t, Equity: equity, Balance: balance})
+	for symbol, price := range prices {
+		if price > 0 {
+			r.prices[symbol] = append(r.prices[symbol], model.PricePoint{Time: t, Price: price})
+		}
+	}
+}
+
+// Session 返回截至当前的会话数据 (交易记录和状态切换由调用方提供)
+func (r *SessionRecorder) Session(title string, trades []*model.TradeRecord, transitions []model.StateTransition) SessionData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	equity := make([]model.EquityPoint, len(r.equity))
+	copy(equity, r.equity)
+	prices := make(map[string][]model.PricePoint, len(r.prices))
+	for symbol, series := range r.prices {
+		prices[symbol] = append([]model.PricePoint(nil), series...)
+	}
+
+	return SessionData{
+		Title:       title,
+		Trades:      trades,
+		Equity:      equity,
+		Prices:      prices,
+		Transitions: transitions,
+	}
+}
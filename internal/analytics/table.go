@@ -0,0 +1,69 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// JSON 将报告编码为缩进的 JSON
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteTable 以终端表格的形式输出报告
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Period\t%s -> %s\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Equity\t%.2f -> %.2f\n", r.InitialEquity, r.FinalEquity)
+	fmt.Fprintf(tw, "Total Return\t%s\n", percent(r.TotalReturn))
+	fmt.Fprintf(tw, "Annualized Return\t%s\n", percent(r.AnnualizedReturn))
+	fmt.Fprintf(tw, "Volatility\t%s\n", percent(r.Volatility))
+	fmt.Fprintf(tw, "Sharpe / Sortino / Calmar\t%.2f / %.2f / %.2f\n", r.Sharpe, r.Sortino, r.Calmar)
+	fmt.Fprintf(tw, "Max Drawdown\t%s (%s underwater)\n", percent(r.MaxDrawdown), r.MaxDrawdownDuration.Round(time.Minute))
+	fmt.Fprintf(tw, "Trades\t%d (%d W / %d L, win rate %s)\n", r.Trades, r.Wins, r.Losses, percent(r.WinRate))
+	fmt.Fprintf(tw, "Avg Win / Avg Loss\t%.2f / %.2f\n", r.AvgWin, r.AvgLoss)
+	fmt.Fprintf(tw, "Expectancy\t%.2f\n", r.Expectancy)
+	fmt.Fprintf(tw, "Profit Factor\t%s\n", ratio(r.ProfitFactor))
+	fmt.Fprintf(tw, "Net PnL / Fees\t%.2f / %.2f\n", r.NetPnL, r.TotalFees)
+	fmt.Fprintf(tw, "Exposure\t%s\n", percent(r.ExposureTime))
+
+	writeBreakdown(tw, "By Source State", r.ByState)
+	writeBreakdown(tw, "By Exit Reason", r.ByReason)
+
+	return tw.Flush()
+}
+
+// writeBreakdown 输出一组分组统计 (按分组名排序)
+func writeBreakdown(w io.Writer, title string, group map[string]*Breakdown) {
+	if len(group) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(group))
+	for key := range group {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "\n%s\tTrades\tWin Rate\tNet PnL\tAvg PnL\tProfit Factor\n", title)
+	for _, key := range keys {
+		b := group[key]
+		fmt.Fprintf(w, "  %s\t%d\t%s\t%.2f\t%.2f\t%s\n", key, b.Trades, percent(b.WinRate), b.NetPnL, b.AvgPnL, ratio(b.ProfitFactor))
+	}
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+func ratio(v float64) string {
+	if math.IsInf(v, 1) {
+		return "inf"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
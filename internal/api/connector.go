@@ -4,7 +4,9 @@ import (
 	"crypto-algo-trader/internal/model"
 	"crypto-algo-trader/internal/service"
 	"encoding/json"
+	"io"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -38,21 +40,35 @@ type OkxTickerData struct {
 	InstId    string `json:"instId"`
 }
 
+// OkxBookData 结构体，用于解析 books5 频道数据 (每档为 [价格, 数量, 废弃字段, 订单数])
+type OkxBookData struct {
+	Asks      [][]string `json:"asks"`
+	Bids      [][]string `json:"bids"`
+	Timestamp string     `json:"ts"`
+}
+
 // 映射 InstId 到 Symbol (例如 BTC-USDT-SWAP -> BTCUSDT)
 type InstMap map[string]string
 
-// Connector 结构体 (保持不变)
+// tickerBufferSize 每个 Ticker 订阅通道的缓冲区大小
+const tickerBufferSize = 2048
+
+// Connector 结构体
 type Connector struct {
-	wsConn        *websocket.Conn
-	wsURL         string
-	instToSymbol  InstMap // InstID -> Symbol 的映射
-	tickerChannel chan model.Ticker
+	wsConn       *websocket.Conn
+	wsURL        string
+	instToSymbol InstMap              // InstID -> Symbol 的映射
+	bookChannel  chan model.OrderBook // books5 深度快照 (用于模拟限价单排队位置)
+	recorder     io.Writer            // 原始 WS 消息录制目标 (nil 表示不录制)
+
+	subMu       sync.RWMutex
+	subscribers map[string][]chan model.Ticker // Symbol -> 订阅该交易对 Ticker 的通道 (每个订阅方一个)
 }
 
-// NewConnector (保持不变)
+// NewConnector 创建订阅 symbols 的连接器
 func NewConnector(wsURL string, symbols []string) *Connector {
 	// 确保通道有足够的缓冲区来应对高频数据
-	tickerChan := make(chan model.Ticker, 2048)
+	bookChan := make(chan model.OrderBook, 256)
 	// 构造 instId: 例如 BTCUSDT -> BTC-USDT-SWAP
 	instToSymbol := make(InstMap, len(symbols))
 	for _, symbol := range symbols {
@@ -63,9 +79,10 @@ func NewConnector(wsURL string, symbols []string) *Connector {
 	service.Logger.Info("Connector initialized", zap.Strings("Symbols", symbols))
 
 	return &Connector{
-		wsURL:         wsURL,
-		instToSymbol:  instToSymbol,
-		tickerChannel: tickerChan,
+		wsURL:        wsURL,
+		instToSymbol: instToSymbol,
+		bookChannel:  bookChan,
+		subscribers:  make(map[string][]chan model.Ticker),
 	}
 }
 
@@ -85,8 +102,9 @@ func (c *Connector) Start() {
 	for instID, _ := range c.instToSymbol {
 		args = append(args, map[string]string{"channel": "trades", "instId": instID})
 		args = append(args, map[string]string{"channel": "tickers", "instId": instID})
+		args = append(args, map[string]string{"channel": "books5", "instId": instID})
 	}
-	// 同时订阅 'trade' 和 'tickers' 频道
+	// 同时订阅 'trade'、'tickers' 和 'books5' 频道
 	subscribeMsg := map[string]interface{}{
 		"op":   "subscribe",
 		"args": args,
@@ -96,7 +114,7 @@ func (c *Connector) Start() {
 		service.Logger.Error("Failed to send WS aggregated subscription", zap.Error(err))
 		return
 	}
-	service.Logger.Info("Subscribed to all Okx TRADE and TICKERS streams successfully")
+	service.Logger.Info("Subscribed to all Okx TRADE, TICKERS and BOOKS5 streams successfully")
 
 	// 启动读循环
 	c.readLoop()
@@ -112,118 +130,203 @@ func (c *Connector) readLoop() {
 			continue // 跳过，让其重连
 		}
 
-		var wsResp OkxWsData // 使用 RawMessage 结构的 OkxWsData
-		if err := json.Unmarshal(message, &wsResp); err != nil {
-			continue
+		// 录制原始消息 (每行一条)，供回测引擎回放
+		if c.recorder != nil {
+			if _, err := c.recorder.Write(append(message, '\n')); err != nil {
+				service.Logger.Warn("Failed to record WS message", zap.Error(err))
+			}
 		}
 
-		if wsResp.Event != "" {
-			continue // 忽略订阅成功或缺取消订阅事件
-		}
+		tickers, books := c.ParseMessage(message)
 
-		instID := wsResp.Arg.InstId
-		if instID == "" || len(wsResp.Data) == 0 {
-			continue
-		}
+		// 发送给订阅了该交易对的 Data Engine
+		c.publishTickers(tickers)
 
-		symbol, ok := c.instToSymbol[instID] // 根据 InstID 查找 Symbol
-		if !ok || len(wsResp.Data) == 0 {
-			continue
+		// 深度快照只保留最新的，通道满时直接丢弃
+		for _, book := range books {
+			select {
+			case c.bookChannel <- book:
+			default:
+				service.Logger.Debug("Book channel full! Dropping book snapshot for", zap.String("Symbol", book.Symbol))
+			}
 		}
+	}
+}
 
-		if !ok {
-			return
-		}
+// ParseMessage 将一条 Okx WS 原始消息解析为内部的 Ticker (成交 / 价格快照) 和深度快照。
+// 实时读循环和回测的录制文件回放共用这一解析逻辑。
+func (c *Connector) ParseMessage(message []byte) ([]model.Ticker, []model.OrderBook) {
+	var wsResp OkxWsData // 使用 RawMessage 结构的 OkxWsData
+	if err := json.Unmarshal(message, &wsResp); err != nil {
+		return nil, nil
+	}
 
-		channel := wsResp.Arg.Channel
+	if wsResp.Event != "" {
+		return nil, nil // 忽略订阅成功或缺取消订阅事件
+	}
 
-		if channel == "trades" {
-			var trades []OkxTradeData
-			if err := json.Unmarshal(wsResp.Data, &trades); err != nil {
-				service.Logger.Error("Trade model unmarshal error", zap.Error(err))
-				continue
-			}
+	instID := wsResp.Arg.InstId
+	if instID == "" || len(wsResp.Data) == 0 {
+		return nil, nil
+	}
 
-			// 遍历收到的所有成交记录
-			for _, okxTrade := range trades {
-				// 1. 数据转换
-				price, err := service.StringToFloat(okxTrade.Price)
-				if err != nil {
-					continue
-				}
+	symbol, ok := c.instToSymbol[instID] // 根据 InstID 查找 Symbol
+	if !ok {
+		return nil, nil
+	}
 
-				volume, err := service.StringToFloat(okxTrade.Size)
-				if err != nil {
-					continue
-				}
+	var tickers []model.Ticker
+	var books []model.OrderBook
 
-				timestamp, err := service.StringToInt64(okxTrade.Timestamp)
-				if err != nil {
-					continue
-				}
+	channel := wsResp.Arg.Channel
 
-				// 2. 买卖方向判断 (Okx side: buy/sell)
-				// side="buy" 意味着这是一笔主动买入 (Taker 买入)
-				// side="sell" 意味着这是一笔主动卖出 (Taker 卖出)
-				isBuyerMaker := (okxTrade.Side != "buy") // 如果不是主动买入，则为主动卖出
-
-				// 3. 构建内部 Ticker 结构
-				ticker := model.Ticker{
-					Symbol:       symbol,
-					Timestamp:    timestamp,
-					Price:        price,
-					Volume:       volume,
-					IsBuyerMaker: isBuyerMaker,
-				}
+	if channel == "trades" {
+		var trades []OkxTradeData
+		if err := json.Unmarshal(wsResp.Data, &trades); err != nil {
+			service.Logger.Error("Trade model unmarshal error", zap.Error(err))
+			return nil, nil
+		}
 
-				// 发送给 Data Engine
-				// 使用 select/default 防止阻塞 Connector
-				select {
-				case c.tickerChannel <- ticker:
-				default:
-					service.Logger.Warn("Ticker channel full! Dropping trade model for", zap.String("Symbol", symbol))
-				}
-			}
-		} else if channel == "tickers" {
-			var tickers []OkxTickerData
-			if err := json.Unmarshal(wsResp.Data, &tickers); err != nil {
-				service.Logger.Error("Tickers model unmarshal error", zap.Error(err))
+		// 遍历收到的所有成交记录
+		for _, okxTrade := range trades {
+			// 1. 数据转换
+			price, err := service.StringToFloat(okxTrade.Price)
+			if err != nil {
 				continue
 			}
 
-			// 处理 TICKER 数据 (用于价格连续性)
-			if len(tickers) == 0 {
+			volume, err := service.StringToFloat(okxTrade.Size)
+			if err != nil {
 				continue
 			}
-			okxTicker := tickers[0] // 仅处理最新的快照
 
-			price, err := service.StringToFloat(okxTicker.LastPrice)
+			timestamp, err := service.StringToInt64(okxTrade.Timestamp)
 			if err != nil {
 				continue
 			}
 
-			timestamp, _ := service.StringToInt64(okxTicker.Timestamp)
+			// 2. 买卖方向判断 (Okx side: buy/sell)
+			// side="buy" 意味着这是一笔主动买入 (Taker 买入)
+			// side="sell" 意味着这是一笔主动卖出 (Taker 卖出)
+			isBuyerMaker := (okxTrade.Side != "buy") // 如果不是主动买入，则为主动卖出
 
-			// 构造 Ticker：volume=0, IsBuyerMaker=false (价格快照)
-			ticker := model.Ticker{
+			// 3. 构建内部 Ticker 结构
+			tickers = append(tickers, model.Ticker{
 				Symbol:       symbol,
 				Timestamp:    timestamp,
 				Price:        price,
-				Volume:       0,
-				IsBuyerMaker: false,
-			}
-			// 使用 select/default 防止阻塞 Connector
+				Volume:       volume,
+				IsBuyerMaker: isBuyerMaker,
+			})
+		}
+	} else if channel == "tickers" {
+		var okxTickers []OkxTickerData
+		if err := json.Unmarshal(wsResp.Data, &okxTickers); err != nil {
+			service.Logger.Error("Tickers model unmarshal error", zap.Error(err))
+			return nil, nil
+		}
+
+		// 处理 TICKER 数据 (用于价格连续性)
+		if len(okxTickers) == 0 {
+			return nil, nil
+		}
+		okxTicker := okxTickers[0] // 仅处理最新的快照
+
+		price, err := service.StringToFloat(okxTicker.LastPrice)
+		if err != nil {
+			return nil, nil
+		}
+
+		timestamp, _ := service.StringToInt64(okxTicker.Timestamp)
+
+		// 构造 Ticker：volume=0, IsBuyerMaker=false (价格快照)
+		tickers = append(tickers, model.Ticker{
+			Symbol:       symbol,
+			Timestamp:    timestamp,
+			Price:        price,
+			Volume:       0,
+			IsBuyerMaker: false,
+		})
+	} else if channel == "books5" {
+		var okxBooks []OkxBookData
+		if err := json.Unmarshal(wsResp.Data, &okxBooks); err != nil {
+			service.Logger.Error("Books model unmarshal error", zap.Error(err))
+			return nil, nil
+		}
+		if len(okxBooks) == 0 {
+			return nil, nil
+		}
+		okxBook := okxBooks[0]
+
+		timestamp, _ := service.StringToInt64(okxBook.Timestamp)
+		books = append(books, model.OrderBook{
+			Symbol:    symbol,
+			Timestamp: timestamp,
+			Bids:      parseBookLevels(okxBook.Bids),
+			Asks:      parseBookLevels(okxBook.Asks),
+		})
+	}
+
+	return tickers, books
+}
+
+// parseBookLevels 将 Okx 深度档位 [价格, 数量, ...] 转换为 OrderBookLevel，忽略无法解析的档位
+func parseBookLevels(raw [][]string) []model.OrderBookLevel {
+	levels := make([]model.OrderBookLevel, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			continue
+		}
+		price, err := service.StringToFloat(entry[0])
+		if err != nil {
+			continue
+		}
+		size, err := service.StringToFloat(entry[1])
+		if err != nil {
+			continue
+		}
+		levels = append(levels, model.OrderBookLevel{Price: price, Size: size})
+	}
+	return levels
+}
+
+// RecordTo 将之后收到的原始 WS 消息逐行写入 w，录制文件可由回测引擎回放。需在 Start 之前调用
+func (c *Connector) RecordTo(w io.Writer) {
+	c.recorder = w
+}
+
+// SubscribeTickers 返回只包含 symbol 的 Ticker 的新通道。每个订阅方各自一个通道，
+// 同一交易对的多个订阅方都会收到全部 Ticker (可以在 Start 之后调用)
+func (c *Connector) SubscribeTickers(symbol string) chan model.Ticker {
+	ch := make(chan model.Ticker, tickerBufferSize)
+	c.subMu.Lock()
+	c.subscribers[symbol] = append(c.subscribers[symbol], ch)
+	c.subMu.Unlock()
+	return ch
+}
+
+// publishTickers 将 Ticker 分发给订阅了对应交易对的通道。
+// 使用 select/default 防止某个订阅方阻塞 Connector (通道满时丢弃该订阅方的这笔 Ticker)
+func (c *Connector) publishTickers(tickers []model.Ticker) {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	for _, ticker := range tickers {
+		for _, ch := range c.subscribers[ticker.Symbol] {
 			select {
-			case c.tickerChannel <- ticker:
+			case ch <- ticker:
 			default:
-				service.Logger.Debug("Ticker channel full! Dropping ticker snapshot for", zap.String("Symbol", symbol))
+				if ticker.Volume > 0 {
+					service.Logger.Warn("Ticker channel full! Dropping trade model for", zap.String("Symbol", ticker.Symbol))
+				} else {
+					service.Logger.Debug("Ticker channel full! Dropping ticker snapshot for", zap.String("Symbol", ticker.Symbol))
+				}
 			}
-
 		}
 	}
 }
 
-// GetTickerChannel (保持不变)
-func (c *Connector) GetTickerChannel() chan model.Ticker {
-	return c.tickerChannel
+// GetOrderBookChannel 返回所有交易对的 books5 深度快照通道
+func (c *Connector) GetOrderBookChannel() chan model.OrderBook {
+	return c.bookChannel
 }
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// drain 取出通道中已有的 Ticker
+func drain(ch chan model.Ticker) []model.Ticker {
+	var tickers []model.Ticker
+	for {
+		select {
+		case t := <-ch:
+			tickers = append(tickers, t)
+		default:
+			return tickers
+		}
+	}
+}
+
+func TestPublishTickersPerSymbol(t *testing.T) {
+	service.Logger = zap.NewNop().Sugar()
+	c := NewConnector("", []string{"BTCUSDT", "ETHUSDT"})
+
+	btc := c.SubscribeTickers("BTCUSDT")
+	eth := c.SubscribeTickers("ETHUSDT")
+	btc2 := c.SubscribeTickers("BTCUSDT") // 同一交易对的第二个实例
+
+	c.publishTickers([]model.Ticker{
+		{Symbol: "BTCUSDT", Price: 1},
+		{Symbol: "ETHUSDT", Price: 2},
+		{Symbol: "BTCUSDT", Price: 3},
+		{Symbol: "SOLUSDT", Price: 4}, // 没有订阅方
+	})
+
+	for name, tc := range map[string]struct {
+		ch   chan model.Ticker
+		want []float64
+	}{
+		"btc":  {btc, []float64{1, 3}},
+		"btc2": {btc2, []float64{1, 3}},
+		"eth":  {eth, []float64{2}},
+	} {
+		got := drain(tc.ch)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d tickers %v, want prices %v", name, len(got), got, tc.want)
+		}
+		for i, ticker := range got {
+			if ticker.Price != tc.want[i] {
+				t.Errorf("%s[%d]: price %v, want %v", name, i, ticker.Price, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestPublishTickersSlowSubscriber(t *testing.T) {
+	service.Logger = zap.NewNop().Sugar()
+	c := NewConnector("", []string{"BTCUSDT", "ETHUSDT"})
+	btc := c.SubscribeTickers("BTCUSDT")
+	eth := c.SubscribeTickers("ETHUSDT")
+
+	// BTC 的订阅方不消费：通道写满后丢弃 BTC 的 Ticker，不阻塞也不影响 ETH
+	for i := 0; i < tickerBufferSize+10; i++ {
+		c.publishTickers([]model.Ticker{{Symbol: "BTCUSDT", Price: float64(i)}, {Symbol: "ETHUSDT", Price: float64(i)}})
+		drain(eth)
+	}
+	if got := len(drain(btc)); got != tickerBufferSize {
+		t.Errorf("btc buffered %d tickers, want %d", got, tickerBufferSize)
+	}
+	c.publishTickers([]model.Ticker{{Symbol: "ETHUSDT", Price: -1}})
+	if got := drain(eth); len(got) != 1 || got[0].Price != -1 {
+		t.Errorf("eth got %v after btc overflow", got)
+	}
+}
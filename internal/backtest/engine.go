@@ -0,0 +1,383 @@
+package backtest
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/executor"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"crypto-algo-trader/internal/strategy"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultEquitySampleInterval 净值曲线的默认采样间隔 (事件时间)
+const DefaultEquitySampleInterval = time.Minute
+
+// Result 一次回测的完整结果
+type Result struct {
+	Start       time.Time // 第一条事件的时间 (不含预热期)
+	End         time.Time // 最后一条事件的时间
+	Events      int       // 处理的事件数量 (含预热期)
+	KLines      int       // 驱动决策的 K 线数量
+	Signals     int       // 产生的信号数量
+	Summary     executor.AccountSummary
+	EquityCurve []model.EquityPoint
+	Prices      map[string][]model.PricePoint        // 与净值曲线同频采样的各交易对价格
+	Transitions []model.StateTransition              // 各实例状态机的状态切换记录 (按时间排序)
+	Shadow      []strategy.Recommendation            // 各实例影子优化器给出的参数推荐 (按时间排序)
+	Grid        map[string][]strategy.GridLevelStats // 使用网格策略的实例各档的成交统计 (按实例名)
+	Trades      []*model.TradeRecord
+	Orders      []*model.OrderRecord
+	StopUpdates []*model.StopUpdateRecord
+}
+
+// engineInstance 一个交易实例在回测中的完整流水线
+type engineInstance struct {
+	name       string
+	symbol     string
+	dataEngine *model.DataEngine
+	executor   *executor.SimulatorExecutor
+	pipeline   *strategy.Pipeline
+	shadow     *strategy.ShadowOptimizer // 未开启 Shadow 时为 nil
+}
+
+// Engine 事件驱动的回测引擎。
+// 它把历史事件按时间顺序同步地送入与实时相同的 DataEngine、TACalculator、StateMachine、
+// 策略和 SimulatorExecutor，时间完全由事件时间戳推进，因此结果确定且远快于实时。
+type Engine struct {
+	logger    *zap.SugaredLogger
+	clock     *service.SimulatedClock // 所有组件共享的事件时钟
+	account   *executor.SimulatorAccount
+	instances []*engineInstance          // 按实例名排序，保证同一事件上的处理顺序确定
+	market    *analytics.MarketAnalytics // 跨实例行情分析，未开启时为 nil
+
+	sampleInterval time.Duration
+	nextSample     int64
+	warmupUntil    int64 // 早于该时间 (毫秒) 的事件只用于预热指标，不交易也不计入结果区间
+	result         *Result
+}
+
+// NewEngine 按配置创建回测引擎：所有实例共享一个模拟账户，与实时主程序一致
+func NewEngine(cfg *service.Config, logger *zap.SugaredLogger) (*Engine, error) {
+	account, err := newSimulatorAccount(cfg.Simulator, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cfg.Instances))
+	for name := range cfg.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clock := service.NewSimulatedClock(time.UnixMilli(0))
+	account.SetClock(clock)
+
+	engine := &Engine{
+		logger:         logger,
+		clock:          clock,
+		account:        account,
+		sampleInterval: DefaultEquitySampleInterval,
+	}
+	for _, name := range names {
+		instance := cfg.Instances[name]
+		// 持仓模式是账户级设置，策略层需与共享账户保持一致
+		instance.Risk.PositionMode = cfg.Simulator.PositionMode
+
+		instanceLogger := logger.With(zap.String("Instance", name), zap.String("Symbol", instance.Symbol))
+		simulatorExecutor := executor.NewSharedSimulatorExecutor(
+			account,
+			instance.Symbol,
+			float64(instance.Risk.FixedLeverage),
+			instance.Allocation,
+			nil, // 回测直接调用 OnTicker，不使用 Ticker 通道
+			instanceLogger,
+		)
+		dataEngine := model.NewDataEngine(nil, instance.Symbol)
+		dataEngine.SetClock(clock)
+		pipeline, err := strategy.NewPipeline(name, &instance, simulatorExecutor, instanceLogger)
+		if err != nil {
+			return nil, fmt.Errorf("instance %s: %w", name, err)
+		}
+		pipeline.SetClock(clock)
+
+		var shadow *strategy.ShadowOptimizer
+		if instance.Shadow.Enabled {
+			shadow, err = strategy.NewShadowOptimizer(pipeline, instance, simulatorConfig(cfg.Simulator), instanceLogger)
+			if err != nil {
+				return nil, fmt.Errorf("instance %s: %w", name, err)
+			}
+			shadow.SetClock(clock)
+		}
+
+		engine.instances = append(engine.instances, &engineInstance{
+			name:       name,
+			symbol:     instance.Symbol,
+			dataEngine: dataEngine,
+			executor:   simulatorExecutor,
+			pipeline:   pipeline,
+			shadow:     shadow,
+		})
+	}
+	if len(engine.instances) == 0 {
+		return nil, fmt.Errorf("backtest: no instances configured")
+	}
+	if cfg.Analytics.Enabled {
+		engine.market = analytics.NewMarketAnalytics(cfg.Analytics)
+		for _, inst := range engine.instances {
+			inst.pipeline.SetMarketAnalytics(engine.market)
+			if inst.shadow != nil {
+				inst.shadow.SetMarketAnalytics(engine.market)
+			}
+		}
+	}
+
+	return engine, nil
+}
+
+// SetEquitySampleInterval 设置净值曲线的采样间隔 (事件时间)
+func (e *Engine) SetEquitySampleInterval(interval time.Duration) {
+	if interval > 0 {
+		e.sampleInterval = interval
+	}
+}
+
+// SetWarmupUntil 设置预热截止时间：之前的事件只更新指标和状态机，不生成信号，
+// 结果的起始时间和净值曲线从该时间之后的第一个事件开始 (零值表示不预热)
+func (e *Engine) SetWarmupUntil(t time.Time) {
+	e.warmupUntil = 0
+	if !t.IsZero() {
+		e.warmupUntil = t.UnixMilli()
+	}
+}
+
+// Clock 返回回测的事件时钟
+func (e *Engine) Clock() service.Clock {
+	return e.clock
+}
+
+// Account 返回回测使用的共享模拟账户
+func (e *Engine) Account() *executor.SimulatorAccount {
+	return e.account
+}
+
+// Market 返回跨实例行情分析服务 (未开启时为 nil)
+func (e *Engine) Market() *analytics.MarketAnalytics {
+	return e.market
+}
+
+// Symbols 返回回测涉及的所有交易对 (去重，按实例名顺序)
+func (e *Engine) Symbols() []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, inst := range e.instances {
+		if !seen[inst.symbol] {
+			seen[inst.symbol] = true
+			symbols = append(symbols, inst.symbol)
+		}
+	}
+	return symbols
+}
+
+// Run 顺序处理 source 中的全部事件，返回回测结果。ctx 取消时提前结束并返回已处理部分的结果
+func (e *Engine) Run(ctx context.Context, source Source) (*Result, error) {
+	e.result = &Result{Prices: make(map[string][]model.PricePoint)}
+	e.nextSample = 0
+
+	var runErr error
+	for {
+		if err := ctx.Err(); err != nil {
+			runErr = err
+			break
+		}
+		event, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			runErr = err
+			break
+		}
+		e.processEvent(ctx, event)
+	}
+
+	e.sampleEquity(e.result.End, true)
+	e.collect()
+	return e.result, runErr
+}
+
+// processEvent 处理一个事件：先由模拟账户撮合和风控，再聚合 K 线驱动策略决策
+func (e *Engine) processEvent(ctx context.Context, event Event) {
+	eventTime := time.UnixMilli(event.Timestamp)
+	warmup := event.Timestamp < e.warmupUntil
+	if !warmup && e.result.Start.IsZero() {
+		e.result.Start = eventTime
+	}
+	e.result.Events++
+	if !warmup {
+		e.result.End = eventTime
+	}
+	e.clock.Advance(eventTime)
+
+	if event.Book != nil {
+		e.account.UpdateOrderBook(*event.Book)
+	}
+	if event.Ticker == nil {
+		return
+	}
+	ticker := *event.Ticker
+
+	// 账户按交易对处理 Ticker，同一交易对的多个实例只需处理一次
+	accountUpdated := false
+	for _, inst := range e.instances {
+		if inst.symbol != ticker.Symbol {
+			continue
+		}
+		if !accountUpdated {
+			inst.executor.OnTicker(ticker)
+			accountUpdated = true
+		}
+		inst.pipeline.OnTicker(ticker)
+		if inst.shadow != nil {
+			inst.shadow.OnTicker(ticker)
+		}
+		for _, kline := range inst.dataEngine.ProcessTicker(ticker) {
+			e.result.KLines++
+			e.market.OnKLine(kline)
+			if warmup {
+				inst.pipeline.Warmup(kline)
+				if inst.shadow != nil {
+					inst.shadow.Warmup(kline)
+				}
+				continue
+			}
+			e.result.Signals += len(inst.pipeline.OnKLine(ctx, kline))
+			if inst.shadow != nil {
+				inst.shadow.OnKLine(ctx, kline)
+			}
+		}
+	}
+
+	if !warmup {
+		e.sampleEquity(eventTime, false)
+	}
+}
+
+// sampleEquity 按事件时间采样净值曲线 (force 为 true 时无视采样间隔，用于记录最后一个点)
+func (e *Engine) sampleEquity(now time.Time, force bool) {
+	if e.result.Start.IsZero() {
+		return
+	}
+	ts := now.UnixMilli()
+	if !force && ts < e.nextSample {
+		return
+	}
+
+	summary := e.account.GetSummary()
+	e.result.EquityCurve = append(e.result.EquityCurve, model.EquityPoint{
+		Time:    now,
+		Equity:  summary.Equity,
+		Balance: summary.Balance,
+	})
+	for symbol, price := range summary.Prices {
+		e.result.Prices[symbol] = append(e.result.Prices[symbol], model.PricePoint{Time: now, Price: price})
+	}
+	e.nextSample = now.Truncate(e.sampleInterval).Add(e.sampleInterval).UnixMilli()
+}
+
+// collect 汇总账户和各实例的记录
+func (e *Engine) collect() {
+	e.result.Summary = e.account.GetSummary()
+
+	seen := make(map[string]bool)
+	for _, inst := range e.instances {
+		// 同一交易对的记录在共享账户中只需收集一次
+		if seen[inst.symbol] {
+			continue
+		}
+		seen[inst.symbol] = true
+
+		trades, _ := inst.executor.GetTradeHistory()
+		stopUpdates, _ := inst.executor.GetStopUpdateHistory()
+		e.result.Trades = append(e.result.Trades, trades...)
+		e.result.StopUpdates = append(e.result.StopUpdates, stopUpdates...)
+		e.result.Orders = append(e.result.Orders, inst.executor.GetOrderHistory()...)
+	}
+
+	sort.SliceStable(e.result.Trades, func(i, j int) bool {
+		return e.result.Trades[i].ExitTime.Before(e.result.Trades[j].ExitTime)
+	})
+	sort.SliceStable(e.result.Orders, func(i, j int) bool {
+		return e.result.Orders[i].OrderID < e.result.Orders[j].OrderID
+	})
+
+	// 状态切换按实例收集 (同一交易对的多个实例各有自己的状态机)
+	for _, inst := range e.instances {
+		e.result.Transitions = append(e.result.Transitions, inst.pipeline.StateMachine.GetTransitionHistory()...)
+	}
+	sort.SliceStable(e.result.Transitions, func(i, j int) bool {
+		return e.result.Transitions[i].Time.Before(e.result.Transitions[j].Time)
+	})
+
+	for _, inst := range e.instances {
+		if inst.shadow != nil {
+			e.result.Shadow = append(e.result.Shadow, inst.shadow.Recommendations()...)
+		}
+	}
+
+	for _, inst := range e.instances {
+		if grid, ok := inst.pipeline.Strategy.(*strategy.GridStrategy); ok {
+			if e.result.Grid == nil {
+				e.result.Grid = make(map[string][]strategy.GridLevelStats)
+			}
+			e.result.Grid[inst.name] = grid.LevelStats()
+		}
+	}
+	sort.SliceStable(e.result.Shadow, func(i, j int) bool {
+		return e.result.Shadow[i].Time.Before(e.result.Shadow[j].Time)
+	})
+}
+
+// newSimulatorAccount 按配置创建共享模拟账户 (与实时主程序相同的参数映射)
+func newSimulatorAccount(cfg service.SimulatorConfig, logger *zap.SugaredLogger) (*executor.SimulatorAccount, error) {
+	simCfg := simulatorConfig(cfg)
+	account := executor.NewSimulatorAccount(&simCfg, logger)
+
+	latencyModel, err := executor.NewLatencyModel(executor.LatencyConfig{
+		Mode:         cfg.Latency.Mode,
+		SubmitMs:     cfg.Latency.SubmitMs,
+		AckMs:        cfg.Latency.AckMs,
+		CancelMs:     cfg.Latency.CancelMs,
+		Distribution: cfg.Latency.Distribution,
+		StdDevRatio:  cfg.Latency.StdDevRatio,
+		Seed:         cfg.Latency.Seed,
+		ReplayFile:   cfg.Latency.ReplayFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid simulator latency configuration: %w", err)
+	}
+	account.SetLatencyModel(latencyModel)
+
+	return account, nil
+}
+
+// simulatorConfig 将服务配置映射为模拟账户配置 (不含延迟模型)
+func simulatorConfig(cfg service.SimulatorConfig) executor.SimulatorConfig {
+	return executor.SimulatorConfig{
+		InitialCapital:        cfg.InitialCapital,
+		Leverage:              cfg.Leverage,
+		FeeRate:               cfg.FeeRate,
+		PositionMode:          model.PositionMode(cfg.PositionMode),
+		MarginMode:            cfg.MarginMode,
+		MaintenanceMarginRate: cfg.MaintenanceMarginRate,
+
+		PassiveFillModel:       cfg.PassiveFillModel,
+		PassiveFillProbability: cfg.PassiveFillProbability,
+		PassiveFillSeed:        cfg.PassiveFillSeed,
+	}
+}
@@ -0,0 +1,149 @@
+package backtest
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rangingConfig 回测 testdata/btcusdt_5m.csv (BTCUSDT 2023-11-26 12:00 起 70 小时的 M5 K 线) 的配置。
+// 这段行情的 H1 ATR/价格 在 2% 上下，阈值取 0.0195 使其在高/低波动震荡之间切换；
+// 趋势阈值取 99 关闭强趋势状态 (数据不足以预热 H4 过滤)，只检查震荡策略。highVol 为 false 时关闭默认开启的高波动震荡策略
+func rangingConfig(highVol bool) *service.Config {
+	instance := service.InstanceConfig{Symbol: "BTCUSDT"}
+	instance.Risk = service.RiskConfig{
+		MaxTotalCapital:              10000,
+		MaxPerTradeRisk:              0.01,
+		FixedLeverage:                5,
+		Symbol:                       "BTCUSDT",
+		PositionScaleFactor:          1,
+		DefaultStopLossATRMultiplier: 2,
+		DefaultRiskRewardRatio:       2,
+		MinPositionSize:              0.001,
+		TrailingStop:                 service.TrailingStopConfig{Mode: "atr", ATRMultiplier: 3, BreakevenAtR: 1},
+	}
+	instance.Strategy.DefaultMode = string(model.StateLowVolRanging)
+	instance.Strategy.ATRVolThreshold = 0.0195
+	instance.Strategy.TrendThreshold = 99
+	instance.Strategy.HighVolRanging.Disabled = !highVol
+
+	return &service.Config{
+		Simulator: service.SimulatorConfig{
+			InitialCapital:        10000,
+			Leverage:              10,
+			FeeRate:               0.0005,
+			MarginMode:            "cross",
+			MaintenanceMarginRate: 0.004,
+			PositionMode:          string(model.PosModeNet),
+		},
+		Instances: map[string]service.InstanceConfig{"btc": instance},
+	}
+}
+
+func runFixture(t *testing.T, cfg *service.Config) *Result {
+	t.Helper()
+	service.Logger = zap.NewNop().Sugar()
+	engine, err := NewEngine(cfg, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	f, err := os.Open("testdata/btcusdt_5m.csv")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	result, err := engine.Run(context.Background(), NewBarCSVSource(f, "BTCUSDT", 5*time.Minute))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return result
+}
+
+// findTrade 返回第一笔满足 match 的交易
+func findTrade(trades []*model.TradeRecord, match func(*model.TradeRecord) bool) *model.TradeRecord {
+	for _, trade := range trades {
+		if match(trade) {
+			return trade
+		}
+	}
+	return nil
+}
+
+// hasOrder 判断是否下过 reason 的开仓单
+func hasOrder(orders []*model.OrderRecord, reason string) bool {
+	for _, order := range orders {
+		if order.Action == model.ActionOpen && order.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEngineRangingRegimes(t *testing.T) {
+	result := runFixture(t, rangingConfig(true))
+
+	// 高波动震荡：越出 M5 布林带反向开仓，价格回到中轨时平仓
+	for _, reason := range []string{"High Vol Ranging: Band DN Fade", "High Vol Ranging: Band UP Fade"} {
+		if !hasOrder(result.Orders, reason) {
+			t.Errorf("no %q entry", reason)
+		}
+	}
+	fade := findTrade(result.Trades, func(trade *model.TradeRecord) bool {
+		return trade.SourceState == model.StateHighVolRanging && trade.TriggerReason == "Signal"
+	})
+	if fade == nil {
+		t.Fatalf("no high-vol fade closed by the band-middle exit")
+	}
+	if fade.PosSide != model.DirLong || fade.ExitPrice <= fade.EntryPrice || fade.RealizedPnL <= 0 {
+		t.Errorf("high-vol fade %s %.2f -> %.2f (PnL %.2f), want a long that reverted up to the middle band",
+			fade.PosSide, fade.EntryPrice, fade.ExitPrice, fade.RealizedPnL)
+	}
+	if findTrade(result.Trades, func(trade *model.TradeRecord) bool {
+		return trade.SourceState == model.StateHighVolRanging && trade.TriggerReason == "SL"
+	}) == nil {
+		t.Errorf("no high-vol fade stopped out")
+	}
+
+	// 低波动震荡：越出上轨做空，止盈或止损平仓
+	if !hasOrder(result.Orders, "Low Vol Ranging: BBands UP Fade") {
+		t.Errorf("no low-vol short entry")
+	}
+	short := findTrade(result.Trades, func(trade *model.TradeRecord) bool {
+		return trade.SourceState == model.StateLowVolRanging && trade.PosSide == model.DirShort && trade.TriggerReason == "TAKE PROFIT"
+	})
+	if short == nil {
+		t.Fatalf("no low-vol short closed by take profit")
+	}
+	if short.ExitPrice >= short.EntryPrice || short.RealizedPnL <= 0 {
+		t.Errorf("low-vol short %.2f -> %.2f (PnL %.2f), want a take profit below entry", short.EntryPrice, short.ExitPrice, short.RealizedPnL)
+	}
+	if findTrade(result.Trades, func(trade *model.TradeRecord) bool {
+		return trade.SourceState == model.StateLowVolRanging && trade.PosSide == model.DirShort && trade.TriggerReason == "SL"
+	}) == nil {
+		t.Errorf("no low-vol short stopped out")
+	}
+}
+
+// 高波动震荡策略默认开启，Disabled 时该状态不开仓
+func TestEngineHighVolRangingDisabled(t *testing.T) {
+	result := runFixture(t, rangingConfig(false))
+
+	for _, trade := range result.Trades {
+		if trade.SourceState == model.StateHighVolRanging {
+			t.Fatalf("HIGH_VOL_RANGING trade %s @ %.2f with HighVolRanging disabled", trade.PosSide, trade.EntryPrice)
+		}
+	}
+	highVol := false
+	for _, transition := range result.Transitions {
+		highVol = highVol || transition.To == model.StateHighVolRanging
+	}
+	if !highVol {
+		t.Errorf("fixture never entered HIGH_VOL_RANGING")
+	}
+}
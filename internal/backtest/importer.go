@@ -0,0 +1,629 @@
+package backtest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导入的数据类型
+const (
+	ImportTicks   = "ticks"   // 逐笔成交 -> model.Ticker
+	ImportCandles = "candles" // K 线 -> model.KLine
+)
+
+// 导入字段名 (ImportConfig.Columns 的键)
+const (
+	FieldTime   = "time"
+	FieldPrice  = "price"
+	FieldSize   = "size"
+	FieldSide   = "side"
+	FieldOpen   = "open"
+	FieldHigh   = "high"
+	FieldLow    = "low"
+	FieldClose  = "close"
+	FieldVolume = "volume"
+)
+
+// 无效行的原因 (ImportStats.Invalid 的键)
+const (
+	InvalidParse = "parse"      // 字段无法解析
+	InvalidPrice = "price"      // 价格 <= 0
+	InvalidSize  = "size"       // 成交量 / K 线成交量 < 0
+	InvalidSide  = "side"       // 无法识别的方向
+	InvalidOHLC  = "ohlc"       // High/Low 与 Open/Close 矛盾
+	InvalidOrder = "time-order" // 时间戳倒退 (K 线为不严格递增)
+)
+
+// 各数据类型的字段，顺序即无表头且未映射时的默认列顺序 (与 ticks/bars CSV 格式一致)
+var (
+	tickFields   = []string{FieldTime, FieldPrice, FieldSize, FieldSide}
+	candleFields = []string{FieldTime, FieldOpen, FieldHigh, FieldLow, FieldClose, FieldVolume}
+)
+
+// optionalFields 找不到对应列时可以缺省的字段
+var optionalFields = map[string]bool{FieldSize: true, FieldSide: true, FieldVolume: true}
+
+// fieldAliases 未映射的字段按表头名称 (不区分大小写) 自动识别的列名
+var fieldAliases = map[string][]string{
+	FieldTime:   {"timestamp", "ts", "time", "created_time", "open_time", "datetime", "date"},
+	FieldPrice:  {"price", "px"},
+	FieldSize:   {"size", "sz", "qty", "quantity", "amount", "volume"},
+	FieldSide:   {"side", "is_buyer_maker", "isbuyermaker"},
+	FieldOpen:   {"open", "o"},
+	FieldHigh:   {"high", "h"},
+	FieldLow:    {"low", "l"},
+	FieldClose:  {"close", "c"},
+	FieldVolume: {"volume", "vol", "v", "qty", "size"},
+}
+
+// ImportConfig 历史数据导入设置
+type ImportConfig struct {
+	Kind   string // ticks / candles
+	Format string // csv / parquet，为空时按扩展名判断 (.parquet 为 parquet，其余为 csv；.gz 自动解压)
+	Symbol string
+
+	// Columns 字段 -> 列名或从 0 开始的列号。未映射的字段按表头别名识别，
+	// 没有表头时按默认列顺序 (ticks: time,price,size,side；candles: time,open,high,low,close,volume)
+	Columns   map[string]string
+	Delimiter rune // CSV 分隔符，默认 ','
+
+	// TimeFormat 时间列格式：ms / s / us / ns 数字时间戳，auto (默认) 按数量级判断时间戳单位，
+	// rfc3339，或 Go 时间布局 (例如 "2006-01-02 15:04:05")
+	TimeFormat string
+	Location   *time.Location // 时间布局中不含时区时使用的时区，默认 UTC
+
+	SideBuyerMaker bool          // side 列为 Binance 的 is_buyer_maker (true 表示主动卖出)，而不是 buy/sell
+	Interval       time.Duration // candles: K 线周期 (缺口统计和展开为 Ticker 使用)，默认 1m
+	Strict         bool          // 遇到无效行时返回错误 (默认跳过并计入 ImportStats)
+}
+
+// importPresets 交易所公开历史数据的列布局
+var importPresets = map[string]ImportConfig{
+	// data.binance.vision trades: id,price,qty,quote_qty,time,is_buyer_maker,is_best_match
+	"binance-trades": {Kind: ImportTicks, SideBuyerMaker: true,
+		Columns: map[string]string{FieldTime: "4", FieldPrice: "1", FieldSize: "2", FieldSide: "5"}},
+	// data.binance.vision aggTrades: agg_trade_id,price,quantity,first_trade_id,last_trade_id,transact_time,is_buyer_maker
+	"binance-aggtrades": {Kind: ImportTicks, SideBuyerMaker: true,
+		Columns: map[string]string{FieldTime: "5", FieldPrice: "1", FieldSize: "2", FieldSide: "6"}},
+	// data.binance.vision klines: open_time,open,high,low,close,volume,close_time,...
+	"binance-klines": {Kind: ImportCandles,
+		Columns: map[string]string{FieldTime: "0", FieldOpen: "1", FieldHigh: "2", FieldLow: "3", FieldClose: "4", FieldVolume: "5"}},
+	// OKX 历史成交下载: instrument_name,trade_id,side,price,size,created_time
+	"okx-trades": {Kind: ImportTicks,
+		Columns: map[string]string{FieldTime: "created_time", FieldPrice: "price", FieldSize: "size", FieldSide: "side"}},
+}
+
+// ImportPreset 返回交易所数据的预设列布局，可用名称见 ImportPresetNames
+func ImportPreset(name string) (ImportConfig, error) {
+	preset, ok := importPresets[name]
+	if !ok {
+		return ImportConfig{}, fmt.Errorf("unknown import preset %q (available: %s)", name, strings.Join(ImportPresetNames(), ", "))
+	}
+	columns := make(map[string]string, len(preset.Columns))
+	for field, column := range preset.Columns {
+		columns[field] = column
+	}
+	preset.Columns = columns
+	return preset, nil
+}
+
+// ImportPresetNames 返回所有预设名称 (排序)
+func ImportPresetNames() []string {
+	names := make([]string, 0, len(importPresets))
+	for name := range importPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImportStats 导入统计
+type ImportStats struct {
+	Rows     int            // 读取的数据行 (不含表头)
+	Imported int            // 通过校验的行
+	Invalid  map[string]int // 按原因统计的无效行 (非 Strict 模式下被跳过)
+	Gaps     int            // candles: 相邻 K 线之间缺失的根数
+	First    time.Time
+	Last     time.Time
+}
+
+// recordReader 按行读取原始记录 (CSV 行或 Parquet 行)
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// Importer 流式读取 CSV / Parquet 历史数据，按列映射解析为 Ticker 或 KLine 并校验。
+// 每次只在内存中保留一行 (Parquet 为一小批行)，因此可以处理远大于内存的文件
+type Importer struct {
+	cfg     ImportConfig
+	records recordReader
+	closers []io.Closer
+
+	columns map[string]int // 字段 -> 列号 (-1 表示缺省)
+	pending []string       // 首行不是表头时暂存的数据行
+	row     int            // 当前数据行号 (从 1 开始，用于错误信息)
+	last    int64          // 上一条有效记录的毫秒时间戳
+	stats   ImportStats
+}
+
+// OpenImporter 打开数据文件并创建导入器，调用方负责 Close
+func OpenImporter(path string, cfg ImportConfig) (*Importer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := cfg.Format
+	name := strings.TrimSuffix(strings.ToLower(path), ".gz")
+	if format == "" {
+		format = "csv"
+		if strings.HasSuffix(name, ".parquet") {
+			format = "parquet"
+		}
+	}
+
+	var im *Importer
+	switch format {
+	case "csv":
+		var r io.Reader = bufio.NewReaderSize(f, 1<<20)
+		var closers []io.Closer
+		if strings.HasSuffix(strings.ToLower(path), ".gz") {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			r = gz
+			closers = append(closers, gz)
+		}
+		im, err = NewCSVImporter(r, cfg)
+		if err == nil {
+			im.closers = append(closers, im.closers...)
+		}
+	case "parquet":
+		im, err = newParquetImporter(f, cfg)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	im.closers = append(im.closers, f)
+	return im, nil
+}
+
+// NewCSVImporter 从 CSV 流创建导入器 (表头自动识别：首行无法按数据解析时视为表头)
+func NewCSVImporter(r io.Reader, cfg ImportConfig) (*Importer, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.LazyQuotes = true
+	if cfg.Delimiter != 0 {
+		reader.Comma = cfg.Delimiter
+	}
+
+	im, err := newImporter(reader, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := reader.Read()
+	if err == io.EOF {
+		return im, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	first = append([]string(nil), first...)
+
+	// 映射中使用列名时必须有表头；否则先尝试把首行当作数据解析
+	if !im.namedColumns() {
+		if err := im.resolveColumns(nil); err != nil {
+			return nil, err
+		}
+		if _, _, ok := im.parseTime(first); ok {
+			im.pending = first
+			return im, nil
+		}
+	}
+	if err := im.resolveColumns(first); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+// newImporter 校验设置并填充默认值
+func newImporter(records recordReader, cfg ImportConfig) (*Importer, error) {
+	if cfg.Kind != ImportTicks && cfg.Kind != ImportCandles {
+		return nil, fmt.Errorf("import kind must be %q or %q, got %q", ImportTicks, ImportCandles, cfg.Kind)
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = "auto"
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	for field := range cfg.Columns {
+		if !containsString(fieldsOf(cfg.Kind), field) {
+			return nil, fmt.Errorf("unknown %s field %q (available: %s)", cfg.Kind, field, strings.Join(fieldsOf(cfg.Kind), ", "))
+		}
+	}
+	return &Importer{
+		cfg:     cfg,
+		records: records,
+		stats:   ImportStats{Invalid: make(map[string]int)},
+	}, nil
+}
+
+func fieldsOf(kind string) []string {
+	if kind == ImportCandles {
+		return candleFields
+	}
+	return tickFields
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// namedColumns 列映射中是否使用了列名 (而不是列号)
+func (im *Importer) namedColumns() bool {
+	for _, column := range im.cfg.Columns {
+		if _, err := strconv.Atoi(column); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColumns 将字段解析为列号。header 为 nil 时表示没有表头：未映射的字段使用默认列顺序
+func (im *Importer) resolveColumns(header []string) error {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+
+	im.columns = make(map[string]int)
+	for position, field := range fieldsOf(im.cfg.Kind) {
+		column, mapped := im.cfg.Columns[field]
+		switch {
+		case mapped:
+			if i, err := strconv.Atoi(column); err == nil {
+				im.columns[field] = i
+				continue
+			}
+			i, ok := index[strings.ToLower(column)]
+			if !ok {
+				return fmt.Errorf("column %q for field %s not found in header %v", column, field, header)
+			}
+			im.columns[field] = i
+		case header == nil:
+			im.columns[field] = position
+		default:
+			im.columns[field] = -1
+			for _, alias := range fieldAliases[field] {
+				if i, ok := index[alias]; ok {
+					im.columns[field] = i
+					break
+				}
+			}
+			if im.columns[field] < 0 && !optionalFields[field] {
+				return fmt.Errorf("no column for field %s in header %v (map it explicitly)", field, header)
+			}
+		}
+	}
+	return nil
+}
+
+// Stats 返回目前为止的导入统计
+func (im *Importer) Stats() ImportStats {
+	return im.stats
+}
+
+// Close 关闭底层文件
+func (im *Importer) Close() error {
+	var first error
+	for _, c := range im.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// next 读取下一条原始记录
+func (im *Importer) next() ([]string, error) {
+	if im.pending != nil {
+		row := im.pending
+		im.pending = nil
+		im.row++
+		im.stats.Rows++
+		return row, nil
+	}
+	row, err := im.records.Read()
+	if err != nil {
+		return nil, err
+	}
+	im.row++
+	im.stats.Rows++
+	return row, nil
+}
+
+// invalid 记录一条无效行；Strict 模式下返回错误
+func (im *Importer) invalid(reason string, row []string) error {
+	im.stats.Invalid[reason]++
+	if im.cfg.Strict {
+		return fmt.Errorf("row %d: invalid %s: %v", im.row, reason, row)
+	}
+	return nil
+}
+
+// accept 记录一条有效记录的时间
+func (im *Importer) accept(ts int64) {
+	t := time.UnixMilli(ts)
+	if im.stats.Imported == 0 {
+		im.stats.First = t
+	}
+	im.stats.Last = t
+	im.stats.Imported++
+	im.last = ts
+}
+
+// NextTicker 返回下一条有效的逐笔成交，数据结束时返回 io.EOF
+func (im *Importer) NextTicker() (model.Ticker, error) {
+	for {
+		row, err := im.next()
+		if err != nil {
+			return model.Ticker{}, err
+		}
+
+		ts, _, ok := im.parseTime(row)
+		price, okPrice := im.float(row, FieldPrice, 0)
+		size, okSize := im.float(row, FieldSize, 0)
+		if !ok || !okPrice || !okSize {
+			if err := im.invalid(InvalidParse, row); err != nil {
+				return model.Ticker{}, err
+			}
+			continue
+		}
+		buyerMaker, okSide := im.side(row)
+
+		reason := ""
+		switch {
+		case !okSide:
+			reason = InvalidSide
+		case price <= 0:
+			reason = InvalidPrice
+		case size < 0:
+			reason = InvalidSize
+		case im.stats.Imported > 0 && ts < im.last:
+			reason = InvalidOrder
+		}
+		if reason != "" {
+			if err := im.invalid(reason, row); err != nil {
+				return model.Ticker{}, err
+			}
+			continue
+		}
+
+		im.accept(ts)
+		return model.Ticker{Symbol: im.cfg.Symbol, Timestamp: ts, Price: price, Volume: size, IsBuyerMaker: buyerMaker}, nil
+	}
+}
+
+// NextKLine 返回下一根有效的 K 线，数据结束时返回 io.EOF
+func (im *Importer) NextKLine() (model.KLine, error) {
+	for {
+		row, err := im.next()
+		if err != nil {
+			return model.KLine{}, err
+		}
+
+		ts, _, ok := im.parseTime(row)
+		open, okOpen := im.float(row, FieldOpen, 0)
+		high, okHigh := im.float(row, FieldHigh, 0)
+		low, okLow := im.float(row, FieldLow, 0)
+		closePrice, okClose := im.float(row, FieldClose, 0)
+		volume, okVolume := im.float(row, FieldVolume, 0)
+		if !ok || !okOpen || !okHigh || !okLow || !okClose || !okVolume {
+			if err := im.invalid(InvalidParse, row); err != nil {
+				return model.KLine{}, err
+			}
+			continue
+		}
+
+		reason := ""
+		switch {
+		case open <= 0 || high <= 0 || low <= 0 || closePrice <= 0:
+			reason = InvalidPrice
+		case high < math.Max(open, closePrice) || low > math.Min(open, closePrice) || high < low:
+			reason = InvalidOHLC
+		case volume < 0:
+			reason = InvalidSize
+		case im.stats.Imported > 0 && ts <= im.last:
+			reason = InvalidOrder
+		}
+		if reason != "" {
+			if err := im.invalid(reason, row); err != nil {
+				return model.KLine{}, err
+			}
+			continue
+		}
+
+		interval := im.cfg.Interval.Milliseconds()
+		if im.stats.Imported > 0 && ts-im.last > interval {
+			im.stats.Gaps += int((ts-im.last)/interval) - 1
+		}
+		im.accept(ts)
+
+		start := time.UnixMilli(ts)
+		return model.KLine{
+			Symbol:    im.cfg.Symbol,
+			Interval:  service.FormatInterval(im.cfg.Interval),
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     closePrice,
+			Volume:    volume,
+			StartTime: start,
+			EndTime:   start.Add(im.cfg.Interval).Add(-time.Millisecond),
+		}, nil
+	}
+}
+
+// cell 返回字段对应的单元格 (字段缺省或列不存在时返回 false)
+func (im *Importer) cell(row []string, field string) (string, bool) {
+	i := im.columns[field]
+	if i < 0 || i >= len(row) {
+		return "", false
+	}
+	return strings.TrimSpace(row[i]), true
+}
+
+// float 解析数值字段，可缺省的字段缺省时返回 def
+func (im *Importer) float(row []string, field string, def float64) (float64, bool) {
+	s, ok := im.cell(row, field)
+	if !ok || s == "" {
+		return def, optionalFields[field] && (!ok || s == "")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// side 解析成交方向，返回 IsBuyerMaker (主动卖出为 true)；字段缺省时视为主动买入
+func (im *Importer) side(row []string) (bool, bool) {
+	s, ok := im.cell(row, FieldSide)
+	if !ok || s == "" {
+		return false, true
+	}
+	s = strings.ToLower(s)
+	if im.cfg.SideBuyerMaker {
+		v, err := strconv.ParseBool(s)
+		return v, err == nil
+	}
+	switch s {
+	case "buy", "b", "bid":
+		return false, true
+	case "sell", "s", "ask":
+		return true, true
+	}
+	return false, false
+}
+
+// parseTime 按 TimeFormat 解析时间字段为毫秒时间戳
+func (im *Importer) parseTime(row []string) (int64, time.Time, bool) {
+	s, ok := im.cell(row, FieldTime)
+	if !ok || s == "" {
+		return 0, time.Time{}, false
+	}
+
+	switch im.cfg.TimeFormat {
+	case "auto", "ms", "s", "us", "ns":
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return 0, time.Time{}, false
+		}
+		unit := im.cfg.TimeFormat
+		if unit == "auto" {
+			unit = timestampUnit(v)
+		}
+		var ms float64
+		switch unit {
+		case "s":
+			ms = v * 1e3
+		case "ms":
+			ms = v
+		case "us":
+			ms = v / 1e3
+		case "ns":
+			ms = v / 1e6
+		}
+		ts := int64(math.Floor(ms))
+		return ts, time.UnixMilli(ts), true
+	case "rfc3339":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+		return t.UnixMilli(), t, true
+	default:
+		t, err := time.ParseInLocation(im.cfg.TimeFormat, s, im.cfg.Location)
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+		return t.UnixMilli(), t, true
+	}
+}
+
+// timestampUnit 按数量级判断数字时间戳的单位 (适用于 1973 年到 5138 年之间的时间)
+func timestampUnit(v float64) string {
+	switch {
+	case v < 1e11:
+		return "s"
+	case v < 1e14:
+		return "ms"
+	case v < 1e17:
+		return "us"
+	default:
+		return "ns"
+	}
+}
+
+// Source 将导入器包装为回测事件源：逐笔成交直接作为 Ticker 事件，K 线按 BarToTickers 展开
+func (im *Importer) Source() Source {
+	return &importSource{importer: im}
+}
+
+// importSource 导入器事件源
+type importSource struct {
+	importer *Importer
+	pending  []model.Ticker
+}
+
+// Next 实现 Source 接口
+func (s *importSource) Next() (Event, error) {
+	if s.importer.cfg.Kind == ImportTicks {
+		ticker, err := s.importer.NextTicker()
+		if err != nil {
+			return Event{}, err
+		}
+		return Event{Timestamp: ticker.Timestamp, Ticker: &ticker}, nil
+	}
+
+	for len(s.pending) == 0 {
+		kline, err := s.importer.NextKLine()
+		if err != nil {
+			return Event{}, err
+		}
+		s.pending = BarToTickers(kline, s.importer.cfg.Interval)
+	}
+	ticker := s.pending[0]
+	s.pending = s.pending[1:]
+	return Event{Timestamp: ticker.Timestamp, Ticker: &ticker}, nil
+}
@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatch 每次从 Parquet 文件读取的行数
+const parquetBatch = 1024
+
+// parquetRecords 按批读取 Parquet 行，并将各列值转换为字符串以复用 CSV 的解析和校验
+type parquetRecords struct {
+	reader *parquet.Reader
+	rows   []parquet.Row
+	n, i   int
+	record []string
+	done   bool
+}
+
+// newParquetImporter 从 Parquet 文件创建导入器，列映射使用 Schema 中的叶子列名 (嵌套列以 . 连接)
+func newParquetImporter(f *os.File, cfg ImportConfig) (*Importer, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	file, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	reader := parquet.NewReader(file)
+	header := make([]string, 0, len(reader.Schema().Columns()))
+	for _, path := range reader.Schema().Columns() {
+		header = append(header, strings.Join(path, "."))
+	}
+
+	records := &parquetRecords{
+		reader: reader,
+		rows:   make([]parquet.Row, parquetBatch),
+		record: make([]string, len(header)),
+	}
+	im, err := newImporter(records, cfg)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if err := im.resolveColumns(header); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	im.closers = append(im.closers, reader)
+	return im, nil
+}
+
+// Read 实现 recordReader 接口
+func (p *parquetRecords) Read() ([]string, error) {
+	for p.i >= p.n {
+		if p.done {
+			return nil, io.EOF
+		}
+		n, err := p.reader.ReadRows(p.rows)
+		p.n, p.i = n, 0
+		if err == io.EOF {
+			p.done = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range p.record {
+		p.record[i] = ""
+	}
+	for _, value := range p.rows[p.i] {
+		if column := value.Column(); column >= 0 && column < len(p.record) {
+			p.record[column] = parquetString(value)
+		}
+	}
+	p.i++
+	return p.record, nil
+}
+
+// parquetString 将 Parquet 值转换为字符串 (空值为空字符串)
+func parquetString(v parquet.Value) string {
+	if v.IsNull() {
+		return ""
+	}
+	switch v.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(v.Boolean())
+	case parquet.Int32:
+		return strconv.FormatInt(int64(v.Int32()), 10)
+	case parquet.Int64:
+		return strconv.FormatInt(v.Int64(), 10)
+	case parquet.Float:
+		return strconv.FormatFloat(float64(v.Float()), 'g', -1, 32)
+	case parquet.Double:
+		return strconv.FormatFloat(v.Double(), 'g', -1, 64)
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray())
+	}
+	return v.String()
+}
@@ -0,0 +1,120 @@
+package backtest
+
+import (
+	"crypto-algo-trader/internal/model"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetTrade Binance 风格的逐笔成交 (列名按别名识别)
+type parquetTrade struct {
+	Timestamp    int64   `parquet:"timestamp"`
+	Price        float64 `parquet:"price"`
+	Qty          float32 `parquet:"qty"`
+	IsBuyerMaker bool    `parquet:"is_buyer_maker"`
+}
+
+// parquetCandle 自定义列名、可空成交量的 K 线
+type parquetCandle struct {
+	OpenTime int32    `parquet:"open_time_s"`
+	Open     float64  `parquet:"o"`
+	High     float64  `parquet:"h"`
+	Low      float64  `parquet:"l"`
+	Close    float64  `parquet:"c"`
+	Volume   *float64 `parquet:"base_volume,optional"`
+}
+
+func TestParquetImportTicks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.parquet")
+	rows := []parquetTrade{
+		{Timestamp: importT0, Price: 100, Qty: 0.5, IsBuyerMaker: true},
+		{Timestamp: importT0 + 1, Price: 0, Qty: 1},
+		{Timestamp: importT0 + 2, Price: 101, Qty: 0.25},
+	}
+	if err := parquet.WriteFile(path, rows); err != nil {
+		t.Fatalf("write parquet: %v", err)
+	}
+
+	im, err := OpenImporter(path, ImportConfig{Kind: ImportTicks, Symbol: "BTCUSDT", SideBuyerMaker: true})
+	if err != nil {
+		t.Fatalf("OpenImporter: %v", err)
+	}
+	defer im.Close()
+	tickers, err := readTickers(im)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := []model.Ticker{
+		{Symbol: "BTCUSDT", Timestamp: importT0, Price: 100, Volume: 0.5, IsBuyerMaker: true},
+		{Symbol: "BTCUSDT", Timestamp: importT0 + 2, Price: 101, Volume: 0.25},
+	}
+	if len(tickers) != len(want) || tickers[0] != want[0] || tickers[1] != want[1] {
+		t.Errorf("tickers %+v, want %+v", tickers, want)
+	}
+	if stats := im.Stats(); stats.Rows != 3 || stats.Invalid[InvalidPrice] != 1 {
+		t.Errorf("stats %+v, want 3 rows with one invalid price", stats)
+	}
+}
+
+func TestParquetImportCandles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "klines.parquet")
+	volume := 12.5
+	rows := []parquetCandle{
+		{OpenTime: 1700000000, Open: 100, High: 110, Low: 90, Close: 105, Volume: &volume},
+		{OpenTime: 1700000060, Open: 105, High: 106, Low: 104, Close: 104.5}, // 成交量为空
+	}
+	if err := parquet.WriteFile(path, rows); err != nil {
+		t.Fatalf("write parquet: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		columns map[string]string
+		wantErr bool
+	}{
+		{name: "mapped by name", columns: map[string]string{FieldTime: "open_time_s", FieldVolume: "base_volume"}},
+		{name: "mapped by index", columns: map[string]string{FieldTime: "0", FieldVolume: "5"}},
+		{name: "missing column", columns: map[string]string{FieldTime: "close_time"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im, err := OpenImporter(path, ImportConfig{Kind: ImportCandles, Columns: tt.columns, TimeFormat: "s"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer im.Close()
+			klines, err := readKLines(im)
+			if err != nil || len(klines) != 2 {
+				t.Fatalf("klines %+v, %v; want 2", klines, err)
+			}
+			if klines[0].StartTime.UnixMilli() != importT0 || klines[0].Volume != 12.5 || klines[1].Volume != 0 || klines[1].Close != 104.5 {
+				t.Errorf("klines %+v, want 12.5 then a null (0) volume", klines)
+			}
+		})
+	}
+}
+
+func TestParquetString(t *testing.T) {
+	tests := []struct {
+		value parquet.Value
+		want  string
+	}{
+		{parquet.Value{}, ""},
+		{parquet.ValueOf(true), "true"},
+		{parquet.ValueOf(int32(-7)), "-7"},
+		{parquet.ValueOf(int64(1700000000000)), "1700000000000"},
+		{parquet.ValueOf(float32(0.1)), "0.1"},
+		{parquet.ValueOf(42000.25), "42000.25"},
+		{parquet.ValueOf([]byte("sell")), "sell"},
+	}
+	for _, tt := range tests {
+		if got := parquetString(tt.value); got != tt.want {
+			t.Errorf("parquetString(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
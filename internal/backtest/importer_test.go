@@ -0,0 +1,308 @@
+package backtest
+
+import (
+	"crypto-algo-trader/internal/model"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 2023-11-14 22:13:20 UTC
+const importT0 int64 = 1700000000000
+
+// readTickers 读取导入器中的全部逐笔成交，遇到 EOF 以外的错误时返回已读取的部分和错误
+func readTickers(im *Importer) ([]model.Ticker, error) {
+	var tickers []model.Ticker
+	for {
+		ticker, err := im.NextTicker()
+		if errors.Is(err, io.EOF) {
+			return tickers, nil
+		}
+		if err != nil {
+			return tickers, err
+		}
+		tickers = append(tickers, ticker)
+	}
+}
+
+// readKLines 读取导入器中的全部 K 线
+func readKLines(im *Importer) ([]model.KLine, error) {
+	var klines []model.KLine
+	for {
+		kline, err := im.NextKLine()
+		if errors.Is(err, io.EOF) {
+			return klines, nil
+		}
+		if err != nil {
+			return klines, err
+		}
+		klines = append(klines, kline)
+	}
+}
+
+// newCSVImporter 从 CSV 文本创建导入器
+func newCSVImporter(t *testing.T, data string, cfg ImportConfig) *Importer {
+	t.Helper()
+	im, err := NewCSVImporter(strings.NewReader(data), cfg)
+	if err != nil {
+		t.Fatalf("NewCSVImporter: %v", err)
+	}
+	return im
+}
+
+func TestImportPresets(t *testing.T) {
+	tests := []struct {
+		preset     string
+		data       string
+		wantTicker model.Ticker
+		wantKLine  model.KLine
+	}{
+		{
+			preset:     "binance-trades",
+			data:       "1,42000.5,0.01,420.005,1700000000000,true,true\n",
+			wantTicker: model.Ticker{Symbol: "BTCUSDT", Timestamp: importT0, Price: 42000.5, Volume: 0.01, IsBuyerMaker: true},
+		},
+		{
+			preset:     "binance-aggtrades",
+			data:       "7,42001,0.02,10,11,1700000000000,false\n",
+			wantTicker: model.Ticker{Symbol: "BTCUSDT", Timestamp: importT0, Price: 42001, Volume: 0.02},
+		},
+		{
+			preset:    "binance-klines",
+			data:      "1700000000000,100,110,90,105,12.5,1700000059999,1300,10,6,600,0\n",
+			wantKLine: model.KLine{Symbol: "BTCUSDT", Interval: "1m", Open: 100, High: 110, Low: 90, Close: 105, Volume: 12.5},
+		},
+		{
+			preset:     "okx-trades",
+			data:       "instrument_name,trade_id,side,price,size,created_time\nBTC-USDT-SWAP,1,sell,42000,0.5,1700000000000\n",
+			wantTicker: model.Ticker{Symbol: "BTCUSDT", Timestamp: importT0, Price: 42000, Volume: 0.5, IsBuyerMaker: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			cfg, err := ImportPreset(tt.preset)
+			if err != nil {
+				t.Fatalf("ImportPreset: %v", err)
+			}
+			cfg.Symbol = "BTCUSDT"
+			im := newCSVImporter(t, tt.data, cfg)
+			if cfg.Kind == ImportTicks {
+				tickers, err := readTickers(im)
+				if err != nil || len(tickers) != 1 || tickers[0] != tt.wantTicker {
+					t.Fatalf("tickers %+v, %v; want %+v", tickers, err, tt.wantTicker)
+				}
+				return
+			}
+			klines, err := readKLines(im)
+			if err != nil || len(klines) != 1 {
+				t.Fatalf("klines %+v, %v; want one", klines, err)
+			}
+			got := klines[0]
+			if got.StartTime.UnixMilli() != importT0 || got.EndTime.UnixMilli() != importT0+59999 {
+				t.Errorf("kline %s - %s, want the minute from %d", got.StartTime, got.EndTime, importT0)
+			}
+			got.StartTime, got.EndTime = time.Time{}, time.Time{}
+			if got != tt.wantKLine {
+				t.Errorf("kline %+v, want %+v", got, tt.wantKLine)
+			}
+		})
+	}
+
+	if _, err := ImportPreset("bybit-trades"); err == nil {
+		t.Errorf("unknown preset accepted")
+	}
+}
+
+func TestImportPresetCopiesColumns(t *testing.T) {
+	cfg, _ := ImportPreset("binance-trades")
+	cfg.Columns[FieldPrice] = "0"
+	if again, _ := ImportPreset("binance-trades"); again.Columns[FieldPrice] != "1" {
+		t.Errorf("modifying a preset changed the registry: price column %q", again.Columns[FieldPrice])
+	}
+}
+
+func TestImportHeaders(t *testing.T) {
+	want := model.Ticker{Timestamp: importT0, Price: 100, Volume: 2, IsBuyerMaker: true}
+	tests := []struct {
+		name    string
+		data    string
+		columns map[string]string
+		wantErr bool
+	}{
+		{name: "headerless default order", data: "1700000000000,100,2,sell\n"},
+		{name: "aliased header", data: "ts,px,qty,side\n1700000000000,100,2,sell\n"},
+		{name: "reordered header", data: "Side,Quantity,Price,Timestamp\nsell,2,100,1700000000000\n"},
+		{name: "header with BOM", data: "\ufefftimestamp,price,size,side\n1700000000000,100,2,sell\n"},
+		{name: "extra columns", data: "id,time,price,amount,side,note\n9,1700000000000,100,2,sell,x\n"},
+		{name: "named mapping", data: "when,last,vol,dir\n1700000000000,100,2,sell\n",
+			columns: map[string]string{FieldTime: "when", FieldPrice: "last", FieldSize: "vol", FieldSide: "dir"}},
+		{name: "headerless index mapping", data: "sell,2,100,1700000000000\n",
+			columns: map[string]string{FieldTime: "3", FieldPrice: "2", FieldSize: "1", FieldSide: "0"}},
+		{name: "missing price column", data: "ts,qty,side\n1700000000000,2,sell\n", wantErr: true},
+		{name: "mapped column not in header", data: "ts,px\n1700000000000,100\n", columns: map[string]string{FieldPrice: "last"}, wantErr: true},
+		{name: "unknown field", data: "1700000000000,100\n", columns: map[string]string{FieldOpen: "1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im, err := NewCSVImporter(strings.NewReader(tt.data), ImportConfig{Kind: ImportTicks, Columns: tt.columns})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tickers, err := readTickers(im)
+			if err != nil || len(tickers) != 1 || tickers[0] != want {
+				t.Errorf("tickers %+v, %v; want %+v", tickers, err, want)
+			}
+			if stats := im.Stats(); stats.Rows != 1 {
+				t.Errorf("%d rows, want the header excluded", stats.Rows)
+			}
+		})
+	}
+}
+
+func TestImportOptionalColumns(t *testing.T) {
+	// 没有 size / side 列：成交量为 0、视为主动买入
+	tickers, err := readTickers(newCSVImporter(t, "time,price\n1700000000000,100\n", ImportConfig{Kind: ImportTicks}))
+	if err != nil || len(tickers) != 1 || tickers[0] != (model.Ticker{Timestamp: importT0, Price: 100}) {
+		t.Errorf("tickers %+v, %v; want one price-only ticker", tickers, err)
+	}
+
+	// K 线表头别名，缺少 volume 列
+	klines, err := readKLines(newCSVImporter(t, "open_time;o;h;l;c\n1700000000000;100;101;99;100.5\n",
+		ImportConfig{Kind: ImportCandles, Delimiter: ';', Interval: 5 * time.Minute}))
+	if err != nil || len(klines) != 1 || klines[0].Close != 100.5 || klines[0].Volume != 0 || klines[0].Interval != "5m" {
+		t.Errorf("klines %+v, %v; want one 5m bar closing at 100.5", klines, err)
+	}
+}
+
+func TestImportTimestampUnits(t *testing.T) {
+	tests := []struct {
+		value  string
+		format string
+		want   int64
+	}{
+		{"1700000000", "", importT0},
+		{"1700000000.5", "", importT0 + 500},
+		{"1700000000000", "", importT0},
+		{"1700000000123456", "", importT0 + 123},
+		{"1700000000123456789", "", importT0 + 123},
+		{"1700000000", "s", importT0},
+		{"1700000000000", "ms", importT0},
+		{"1700000000000000", "us", importT0},
+		{"1700000000000000000", "ns", importT0},
+		{"1700000000", "ms", 1700000000}, // 显式单位不按数量级判断
+		{"2023-11-14T22:13:20.250Z", "rfc3339", importT0 + 250},
+		{"2023-11-15T06:13:20+08:00", "rfc3339", importT0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+"/"+tt.format, func(t *testing.T) {
+			im := newCSVImporter(t, "time,price\n"+tt.value+",100\n", ImportConfig{Kind: ImportTicks, TimeFormat: tt.format})
+			tickers, err := readTickers(im)
+			if err != nil || len(tickers) != 1 || tickers[0].Timestamp != tt.want {
+				t.Errorf("tickers %+v, %v; want timestamp %d", tickers, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportTimeLayoutLocation(t *testing.T) {
+	const layout = "2006-01-02 15:04:05"
+	tests := []struct {
+		name     string
+		location *time.Location
+		value    string
+		want     int64
+	}{
+		{"default UTC", nil, "2023-11-14 22:13:20", importT0},
+		{"UTC+8", time.FixedZone("UTC+8", 8*3600), "2023-11-15 06:13:20", importT0},
+		{"UTC-5", time.FixedZone("UTC-5", -5*3600), "2023-11-14 17:13:20", importT0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 表头中的时间列不是数字，按布局解析时仍需识别出表头
+			data := "date,open,high,low,close,volume\n" + tt.value + ",100,101,99,100,5\n"
+			im := newCSVImporter(t, data, ImportConfig{Kind: ImportCandles, TimeFormat: layout, Location: tt.location})
+			klines, err := readKLines(im)
+			if err != nil || len(klines) != 1 || klines[0].StartTime.UnixMilli() != tt.want {
+				t.Errorf("klines %+v, %v; want start %d", klines, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportInvalidRows(t *testing.T) {
+	// 每组数据：有效行、一行无效行、有效行
+	tests := []struct {
+		reason string
+		kind   string
+		data   string
+	}{
+		{InvalidParse, ImportTicks, "1700000000000,100,1,buy\n1700000000500,abc,1,buy\n1700000001000,100,1,buy\n"},
+		{InvalidParse, ImportTicks, "1700000000000,100,1,buy\nnot-a-time,100,1,buy\n1700000001000,100,1,buy\n"},
+		{InvalidPrice, ImportTicks, "1700000000000,100,1,buy\n1700000000500,0,1,buy\n1700000001000,100,1,buy\n"},
+		{InvalidSize, ImportTicks, "1700000000000,100,1,buy\n1700000000500,100,-1,buy\n1700000001000,100,1,buy\n"},
+		{InvalidSide, ImportTicks, "1700000000000,100,1,buy\n1700000000500,100,1,hold\n1700000001000,100,1,sell\n"},
+		{InvalidOrder, ImportTicks, "1700000000000,100,1,buy\n1699999999999,100,1,buy\n1700000000000,100,1,buy\n"},
+		{InvalidParse, ImportCandles, "1700000000000,100,101,99,100,1\n1700000060000,100,x,99,100,1\n1700000120000,100,101,99,100,1\n"},
+		{InvalidPrice, ImportCandles, "1700000000000,100,101,99,100,1\n1700000060000,100,101,-1,100,1\n1700000120000,100,101,99,100,1\n"},
+		{InvalidOHLC, ImportCandles, "1700000000000,100,101,99,100,1\n1700000060000,100,99.5,99,100,1\n1700000120000,100,101,99,100,1\n"},
+		{InvalidSize, ImportCandles, "1700000000000,100,101,99,100,1\n1700000060000,100,101,99,100,-3\n1700000120000,100,101,99,100,1\n"},
+		{InvalidOrder, ImportCandles, "1700000000000,100,101,99,100,1\n1700000000000,100,101,99,100,1\n1700000120000,100,101,99,100,1\n"},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			name := tt.kind + "/" + tt.reason
+			if strict {
+				name += "/strict"
+			}
+			t.Run(name, func(t *testing.T) {
+				im := newCSVImporter(t, tt.data, ImportConfig{Kind: tt.kind, Strict: strict})
+				var n int
+				var err error
+				if tt.kind == ImportTicks {
+					var tickers []model.Ticker
+					tickers, err = readTickers(im)
+					n = len(tickers)
+				} else {
+					var klines []model.KLine
+					klines, err = readKLines(im)
+					n = len(klines)
+				}
+
+				stats := im.Stats()
+				if stats.Invalid[tt.reason] != 1 || len(stats.Invalid) != 1 {
+					t.Errorf("invalid counters %v, want one %s", stats.Invalid, tt.reason)
+				}
+				if strict {
+					if err == nil || !strings.Contains(err.Error(), "row 2: invalid "+tt.reason) {
+						t.Errorf("err = %v, want row 2 invalid %s", err, tt.reason)
+					}
+					if n != 1 || stats.Rows != 2 {
+						t.Errorf("%d records from %d rows before the error, want 1 from 2", n, stats.Rows)
+					}
+					return
+				}
+				if err != nil || n != 2 || stats.Imported != 2 || stats.Rows != 3 {
+					t.Errorf("%d records (%d imported) from %d rows, err %v; want 2 from 3 with the bad row skipped",
+						n, stats.Imported, stats.Rows, err)
+				}
+			})
+		}
+	}
+}
+
+func TestImportCandleGaps(t *testing.T) {
+	data := "1700000000000,100,101,99,100,1\n1700000060000,100,101,99,100,1\n1700000240000,100,101,99,100,1\n"
+	im := newCSVImporter(t, data, ImportConfig{Kind: ImportCandles})
+	if _, err := readKLines(im); err != nil {
+		t.Fatalf("readKLines: %v", err)
+	}
+	stats := im.Stats()
+	if stats.Gaps != 2 || stats.First.UnixMilli() != importT0 || stats.Last.UnixMilli() != importT0+240000 {
+		t.Errorf("gaps %d, range %s - %s; want 2 missing bars over 4 minutes", stats.Gaps, stats.First.UTC(), stats.Last.UTC())
+	}
+}
@@ -0,0 +1,394 @@
+package backtest
+
+import (
+	"bufio"
+	"crypto-algo-trader/internal/api"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event 回测事件：一笔成交 / 价格快照，或一份深度快照
+type Event struct {
+	Timestamp int64 // 毫秒时间戳 (事件时钟)
+	Ticker    *model.Ticker
+	Book      *model.OrderBook
+}
+
+// Source 按时间顺序产出回测事件，数据结束时返回 io.EOF
+type Source interface {
+	Next() (Event, error)
+}
+
+// SliceSource 从内存中的事件切片回放
+type SliceSource struct {
+	events []Event
+	cursor int
+}
+
+// NewSliceSource 创建内存事件源 (调用方保证事件按时间排序)
+func NewSliceSource(events []Event) *SliceSource {
+	return &SliceSource{events: events}
+}
+
+// Next 实现 Source 接口
+func (s *SliceSource) Next() (Event, error) {
+	if s.cursor >= len(s.events) {
+		return Event{}, io.EOF
+	}
+	event := s.events[s.cursor]
+	s.cursor++
+	return event, nil
+}
+
+// TickCSVSource 读取逐笔成交 CSV (每行: timestamp,price,volume,side)。
+// timestamp 为毫秒时间戳，side 为主动方向 buy/sell (可省略)；无法解析的行 (例如表头) 会被跳过
+type TickCSVSource struct {
+	symbol string
+	reader *csv.Reader
+}
+
+// NewTickCSVSource 创建逐笔成交 CSV 事件源，symbol 为这份数据所属的交易对
+func NewTickCSVSource(r io.Reader, symbol string) *TickCSVSource {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &TickCSVSource{symbol: symbol, reader: reader}
+}
+
+// Next 实现 Source 接口
+func (s *TickCSVSource) Next() (Event, error) {
+	for {
+		row, err := s.reader.Read()
+		if err != nil {
+			return Event{}, err
+		}
+		if len(row) < 2 {
+			continue
+		}
+		timestamp, err := service.StringToInt64(strings.TrimSpace(row[0]))
+		if err != nil {
+			continue
+		}
+		price, err := service.StringToFloat(strings.TrimSpace(row[1]))
+		if err != nil {
+			continue
+		}
+		ticker := model.Ticker{Symbol: s.symbol, Timestamp: timestamp, Price: price}
+		if len(row) > 2 {
+			ticker.Volume, _ = service.StringToFloat(strings.TrimSpace(row[2]))
+		}
+		if len(row) > 3 {
+			// 与 Connector 一致：不是主动买入即为主动卖出
+			ticker.IsBuyerMaker = strings.ToLower(strings.TrimSpace(row[3])) != "buy"
+		}
+		return Event{Timestamp: timestamp, Ticker: &ticker}, nil
+	}
+}
+
+// BarCSVSource 读取 K 线 CSV (每行: timestamp,open,high,low,close,volume，timestamp 为 K 线开始的毫秒时间戳)，
+// 并将每根 K 线展开为 O -> H/L -> L/H -> C 四个 Ticker，使其可以经过与实时相同的 DataEngine 聚合
+type BarCSVSource struct {
+	symbol   string
+	interval time.Duration
+	reader   *csv.Reader
+	pending  []model.Ticker
+}
+
+// NewBarCSVSource 创建 K 线 CSV 事件源，interval 为数据的 K 线周期 (应不大于策略使用的最小周期)
+func NewBarCSVSource(r io.Reader, symbol string, interval time.Duration) *BarCSVSource {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return &BarCSVSource{symbol: symbol, interval: interval, reader: reader}
+}
+
+// Next 实现 Source 接口
+func (s *BarCSVSource) Next() (Event, error) {
+	for len(s.pending) == 0 {
+		row, err := s.reader.Read()
+		if err != nil {
+			return Event{}, err
+		}
+		kline, ok := parseBarRow(row, s.symbol)
+		if !ok {
+			continue
+		}
+		s.pending = BarToTickers(kline, s.interval)
+	}
+
+	ticker := s.pending[0]
+	s.pending = s.pending[1:]
+	return Event{Timestamp: ticker.Timestamp, Ticker: &ticker}, nil
+}
+
+// parseBarRow 解析一行 K 线数据，无法解析时返回 false
+func parseBarRow(row []string, symbol string) (model.KLine, bool) {
+	if len(row) < 5 {
+		return model.KLine{}, false
+	}
+	values := make([]float64, 5)
+	for i := 1; i < len(row) && i <= 5; i++ {
+		value, err := service.StringToFloat(strings.TrimSpace(row[i]))
+		if err != nil {
+			return model.KLine{}, false
+		}
+		values[i-1] = value
+	}
+	start, err := service.StringToInt64(strings.TrimSpace(row[0]))
+	if err != nil {
+		return model.KLine{}, false
+	}
+	return model.KLine{
+		Symbol:    symbol,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+		StartTime: time.UnixMilli(start),
+	}, true
+}
+
+// BarToTickers 将一根 K 线展开为四个 Ticker：阳线按 O -> L -> H -> C，阴线按 O -> H -> L -> C 的路径，
+// 成交量平均分配。展开后的路径决定了同一根 K 线内止损和止盈的触发顺序
+func BarToTickers(kline model.KLine, interval time.Duration) []model.Ticker {
+	start := kline.StartTime.UnixMilli()
+	step := interval.Milliseconds() / 3
+	if step <= 0 {
+		step = 1
+	}
+	end := start + interval.Milliseconds() - 1
+
+	path := []float64{kline.Open, kline.High, kline.Low, kline.Close}
+	if kline.Close >= kline.Open {
+		path = []float64{kline.Open, kline.Low, kline.High, kline.Close}
+	}
+	times := []int64{start, start + step, start + 2*step, end}
+
+	tickers := make([]model.Ticker, len(path))
+	prev := kline.Open
+	for i, price := range path {
+		tickers[i] = model.Ticker{
+			Symbol:       kline.Symbol,
+			Timestamp:    times[i],
+			Price:        price,
+			Volume:       kline.Volume / float64(len(path)),
+			IsBuyerMaker: price < prev, // 价格下行视为主动卖出
+		}
+		prev = price
+	}
+	return tickers
+}
+
+// WSRecordSource 回放 Connector.RecordTo 录制的原始 Okx WS 消息 (每行一条)，解析逻辑与实时完全相同
+type WSRecordSource struct {
+	connector *api.Connector
+	scanner   *bufio.Scanner
+	pending   []Event
+}
+
+// NewWSRecordSource 创建 WS 录制文件事件源，symbols 为需要回放的交易对
+func NewWSRecordSource(r io.Reader, symbols []string) *WSRecordSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &WSRecordSource{
+		connector: api.NewConnector("", symbols),
+		scanner:   scanner,
+	}
+}
+
+// Next 实现 Source 接口
+func (s *WSRecordSource) Next() (Event, error) {
+	for len(s.pending) == 0 {
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return Event{}, err
+			}
+			return Event{}, io.EOF
+		}
+		tickers, books := s.connector.ParseMessage(s.scanner.Bytes())
+		for i := range books {
+			s.pending = append(s.pending, Event{Timestamp: books[i].Timestamp, Book: &books[i]})
+		}
+		for i := range tickers {
+			s.pending = append(s.pending, Event{Timestamp: tickers[i].Timestamp, Ticker: &tickers[i]})
+		}
+	}
+
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+// mergedSource 按事件时间合并多个有序事件源 (时间相同时按事件源顺序，保证结果确定)
+type mergedSource struct {
+	sources []Source
+	heads   []*Event
+}
+
+// MergeSources 将多个各自有序的事件源合并为一个按时间排序的事件源
+func MergeSources(sources ...Source) Source {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	return &mergedSource{sources: sources, heads: make([]*Event, len(sources))}
+}
+
+// Next 实现 Source 接口
+func (m *mergedSource) Next() (Event, error) {
+	best := -1
+	for i, source := range m.sources {
+		if source == nil {
+			continue
+		}
+		if m.heads[i] == nil {
+			event, err := source.Next()
+			if err == io.EOF {
+				m.sources[i] = nil
+				continue
+			}
+			if err != nil {
+				return Event{}, fmt.Errorf("source %d: %w", i, err)
+			}
+			m.heads[i] = &event
+		}
+		if best < 0 || m.heads[i].Timestamp < m.heads[best].Timestamp {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Event{}, io.EOF
+	}
+
+	event := *m.heads[best]
+	m.heads[best] = nil
+	return event, nil
+}
+
+// ReadAll 读取事件源中的全部事件 (参数扫描等需要多次回放同一份数据时使用)
+func ReadAll(source Source) ([]Event, error) {
+	var events []Event
+	for {
+		event, err := source.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+// LoadEvents 按格式读取全部数据文件到内存 (参数扫描、滚动优化等需要反复回放同一份数据)
+func LoadEvents(format string, data string, interval string, symbols []string) ([]Event, error) {
+	source, closeFiles, err := OpenSources(format, data, interval, symbols)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFiles()
+	return ReadAll(source)
+}
+
+// SliceEvents 返回有序事件切片中时间位于 [from, to) 的部分 (零值表示不限制)，与原切片共享底层数组
+func SliceEvents(events []Event, from time.Time, to time.Time) []Event {
+	lo, hi := 0, len(events)
+	if !from.IsZero() {
+		ms := from.UnixMilli()
+		lo = sort.Search(len(events), func(i int) bool { return events[i].Timestamp >= ms })
+	}
+	if !to.IsZero() {
+		ms := to.UnixMilli()
+		hi = sort.Search(len(events), func(i int) bool { return events[i].Timestamp >= ms })
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return events[lo:hi]
+}
+
+// OpenSources 按格式打开所有数据文件，并按事件时间合并为一个事件源 (.parquet 文件通过 Importer 读取)。
+// data 为逗号分隔的文件列表，ticks/bars 格式写作 SYMBOL=path；返回的 close 函数关闭所有文件
+func OpenSources(format string, data string, interval string, symbols []string) (Source, func(), error) {
+	var files []io.Closer
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	var sources []Source
+	for _, spec := range strings.Split(data, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		symbol, path := "", spec
+		if format != "ws" {
+			parts := strings.SplitN(spec, "=", 2)
+			if len(parts) != 2 {
+				closeFiles()
+				return nil, nil, fmt.Errorf("data spec %q must be SYMBOL=path", spec)
+			}
+			symbol, path = parts[0], parts[1]
+		}
+
+		// Parquet 文件通过导入器读取，按 Schema 列名识别字段
+		if format != "ws" && strings.HasSuffix(strings.ToLower(path), ".parquet") {
+			cfg := ImportConfig{Kind: ImportTicks, Symbol: symbol}
+			if format == "bars" {
+				barInterval, err := service.ParseIntervalDuration(interval)
+				if err != nil {
+					closeFiles()
+					return nil, nil, err
+				}
+				cfg.Kind, cfg.Interval = ImportCandles, barInterval
+			}
+			im, err := OpenImporter(path, cfg)
+			if err != nil {
+				closeFiles()
+				return nil, nil, err
+			}
+			files = append(files, im)
+			sources = append(sources, im.Source())
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		files = append(files, f)
+
+		switch format {
+		case "ticks":
+			sources = append(sources, NewTickCSVSource(f, symbol))
+		case "bars":
+			barInterval, err := service.ParseIntervalDuration(interval)
+			if err != nil {
+				closeFiles()
+				return nil, nil, err
+			}
+			sources = append(sources, NewBarCSVSource(f, symbol, barInterval))
+		case "ws":
+			sources = append(sources, NewWSRecordSource(f, symbols))
+		default:
+			closeFiles()
+			return nil, nil, fmt.Errorf("unsupported data format: %s", format)
+		}
+	}
+	if len(sources) == 0 {
+		closeFiles()
+		return nil, nil, fmt.Errorf("no data files given")
+	}
+
+	return MergeSources(sources...), closeFiles, nil
+}
@@ -7,11 +7,11 @@ import (
 
 // Executor 是交易执行器的通用接口，负责与交易所通信
 type Executor interface {
-	// 接收策略信号，并尝试执行交易 (开仓、平仓、修改订单)
+	// 接收策略信号，并尝试执行交易 (开仓、平仓、修改止盈止损)
 	ExecuteSignal(ctx context.Context, signal model.Signal) error
 
-	// 查询并返回当前持仓信息
-	GetCurrentPosition(ctx context.Context) (*model.Position, error)
+	// 查询并返回当前持仓信息 (双向持仓模式下包含多空两条腿)
+	GetCurrentPosition(ctx context.Context) (model.Positions, error)
 
 	// 获取账户余额
 	GetBalance(ctx context.Context) (float64, error)
@@ -21,4 +21,19 @@ type Executor interface {
 
 	// 返回账户历史上的最高净值
 	GetMaxEquity() float64
+
+	// 返回所有止损/止盈修改记录，用于分析跟踪止损和分批止盈的效果
+	GetStopUpdateHistory() ([]*model.StopUpdateRecord, error)
+}
+
+// FillSource 可以报告成交的执行器 (策略的 OnFill 依赖它)。未实现时策略只能在 K 线上通过持仓变化感知成交
+type FillSource interface {
+	// DrainFills 返回并清空上次调用以来本实例交易对的成交 (按发生顺序)
+	DrainFills() []model.Fill
+}
+
+// OrderLister 可以列出未完成订单的执行器 (策略据此核对挂单是否被拒绝或撤销)
+type OrderLister interface {
+	// GetOpenOrders 返回本实例尚未成交也未撤销的订单
+	GetOpenOrders() []*model.OrderRecord
 }
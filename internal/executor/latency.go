@@ -0,0 +1,206 @@
+package executor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyKind 延迟的种类
+type LatencyKind string
+
+const (
+	LatencySubmit LatencyKind = "submit" // 下单：信号发出 -> 订单到达交易所撮合
+	LatencyAck    LatencyKind = "ack"    // 回报：交易所成交 -> 客户端收到确认
+	LatencyCancel LatencyKind = "cancel" // 撤单：撤单请求发出 -> 到达交易所
+)
+
+// LatencyModel 为模拟器提供订单链路的延迟样本 (基于事件时钟，而非真实等待)
+type LatencyModel interface {
+	Sample(kind LatencyKind) time.Duration
+}
+
+// LatencyConfig 延迟模型配置
+type LatencyConfig struct {
+	Mode         string  // "" 关闭 (立即成交), "fixed", "distribution", "replay"
+	SubmitMs     float64 // 下单延迟均值 (毫秒)
+	AckMs        float64 // 回报延迟均值 (毫秒)
+	CancelMs     float64 // 撤单延迟均值 (毫秒)
+	Distribution string  // distribution 模式下的分布: "normal", "lognormal", "exponential"
+	StdDevRatio  float64 // 标准差 / 均值 (normal、lognormal 使用)
+	Seed         int64   // 随机种子，保证回测可复现
+	ReplayFile   string  // replay 模式下的实测延迟文件 (CSV: kind,ms)
+}
+
+// NewLatencyModel 根据配置创建延迟模型，Mode 为空时返回 nil (不模拟延迟)
+func NewLatencyModel(cfg LatencyConfig) (LatencyModel, error) {
+	means := map[LatencyKind]float64{
+		LatencySubmit: cfg.SubmitMs,
+		LatencyAck:    cfg.AckMs,
+		LatencyCancel: cfg.CancelMs,
+	}
+
+	switch cfg.Mode {
+	case "":
+		return nil, nil
+	case "fixed":
+		return &FixedLatency{means: means}, nil
+	case "distribution":
+		return NewDistributionLatency(cfg.Distribution, means, cfg.StdDevRatio, cfg.Seed)
+	case "replay":
+		return LoadReplayLatency(cfg.ReplayFile)
+	default:
+		return nil, fmt.Errorf("unsupported latency mode: %s", cfg.Mode)
+	}
+}
+
+// FixedLatency 每种延迟都使用固定值
+type FixedLatency struct {
+	means map[LatencyKind]float64
+}
+
+// NewFixedLatency 创建固定延迟模型
+func NewFixedLatency(submit, ack, cancel time.Duration) *FixedLatency {
+	return &FixedLatency{means: map[LatencyKind]float64{
+		LatencySubmit: float64(submit) / float64(time.Millisecond),
+		LatencyAck:    float64(ack) / float64(time.Millisecond),
+		LatencyCancel: float64(cancel) / float64(time.Millisecond),
+	}}
+}
+
+// Sample 实现 LatencyModel 接口
+func (f *FixedLatency) Sample(kind LatencyKind) time.Duration {
+	return msToDuration(f.means[kind])
+}
+
+// DistributionLatency 从参数化分布中抽样延迟 (使用固定种子，结果可复现)
+type DistributionLatency struct {
+	mu          sync.Mutex
+	dist        string
+	means       map[LatencyKind]float64
+	stdDevRatio float64
+	rng         *rand.Rand
+}
+
+// NewDistributionLatency 创建分布抽样延迟模型
+func NewDistributionLatency(dist string, means map[LatencyKind]float64, stdDevRatio float64, seed int64) (*DistributionLatency, error) {
+	switch dist {
+	case "normal", "lognormal", "exponential":
+	case "":
+		dist = "lognormal" // 网络延迟通常是右偏的
+	default:
+		return nil, fmt.Errorf("unsupported latency distribution: %s", dist)
+	}
+	if stdDevRatio <= 0 {
+		stdDevRatio = 0.3
+	}
+
+	return &DistributionLatency{
+		dist:        dist,
+		means:       means,
+		stdDevRatio: stdDevRatio,
+		rng:         rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// Sample 实现 LatencyModel 接口
+func (d *DistributionLatency) Sample(kind LatencyKind) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	mean := d.means[kind]
+	if mean <= 0 {
+		return 0
+	}
+
+	var ms float64
+	switch d.dist {
+	case "normal":
+		ms = mean + d.rng.NormFloat64()*mean*d.stdDevRatio
+	case "exponential":
+		ms = d.rng.ExpFloat64() * mean
+	default: // lognormal: 按均值和变异系数反推 mu/sigma
+		sigma2 := math.Log(1 + d.stdDevRatio*d.stdDevRatio)
+		mu := math.Log(mean) - sigma2/2
+		ms = math.Exp(mu + d.rng.NormFloat64()*math.Sqrt(sigma2))
+	}
+
+	return msToDuration(math.Max(0, ms))
+}
+
+// ReplayLatency 按顺序循环回放实盘测得的延迟样本
+type ReplayLatency struct {
+	mu      sync.Mutex
+	samples map[LatencyKind][]time.Duration
+	cursor  map[LatencyKind]int
+}
+
+// LoadReplayLatency 从 CSV 文件读取实测延迟 (每行: kind,ms，例如 "submit,185")
+func LoadReplayLatency(path string) (*ReplayLatency, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadReplayLatency(f)
+}
+
+// ReadReplayLatency 从 CSV 数据读取实测延迟，忽略无法解析的行 (例如表头)
+func ReadReplayLatency(r io.Reader) (*ReplayLatency, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	replay := &ReplayLatency{
+		samples: make(map[LatencyKind][]time.Duration),
+		cursor:  make(map[LatencyKind]int),
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 2 {
+			continue
+		}
+		ms, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			continue
+		}
+		kind := LatencyKind(strings.ToLower(strings.TrimSpace(row[0])))
+		replay.samples[kind] = append(replay.samples[kind], msToDuration(ms))
+	}
+
+	if len(replay.samples) == 0 {
+		return nil, fmt.Errorf("no latency samples found")
+	}
+	return replay, nil
+}
+
+// Sample 实现 LatencyModel 接口
+func (r *ReplayLatency) Sample(kind LatencyKind) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := r.samples[kind]
+	if len(samples) == 0 {
+		return 0
+	}
+	sample := samples[r.cursor[kind]%len(samples)]
+	r.cursor[kind]++
+	return sample
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatorLatencyArrivalPrice(t *testing.T) {
+	account := newTestAccount(SimulatorConfig{})
+	account.SetLatencyModel(NewFixedLatency(100*time.Millisecond, 30*time.Millisecond, 0))
+	e := newTestSim(account, "BTCUSDT", 0)
+
+	tick(e, "BTCUSDT", 1000, 100)
+	if err := e.ExecuteSignal(context.Background(), openSignal(model.DirLong, "", 1)); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	// 订单在途期间的价格不成交，到达后按到达时的价格成交
+	tick(e, "BTCUSDT", 1050, 101)
+	if len(account.positions) != 0 {
+		t.Fatalf("order filled before it arrived")
+	}
+	tick(e, "BTCUSDT", 1100, 102)
+	record := e.GetOrderHistory()[0]
+	if record.Status != model.OrderFilled || record.SignalPrice != 100 || record.FillPrice != 102 {
+		t.Errorf("order %s, signal %.0f, fill %.0f; want FILLED at the 102 arrival price", record.Status, record.SignalPrice, record.FillPrice)
+	}
+	if got := record.ArrivalTime.UnixMilli(); got != 1100 {
+		t.Errorf("arrival %d, want 1100", got)
+	}
+	if got := record.AckTime.UnixMilli(); got != 1130 {
+		t.Errorf("ack %d, want 1130", got)
+	}
+}
+
+func TestSimulatorLatencyCancelRace(t *testing.T) {
+	// 订单 1000 发出、1100 到达；撤单 1010 发出
+	tests := []struct {
+		name       string
+		cancel     time.Duration
+		wantStatus string
+	}{
+		{"cancel arrives first", 50 * time.Millisecond, model.OrderCanceled},
+		{"cancel arrives with the order", 90 * time.Millisecond, model.OrderCanceled},
+		{"order arrives first", 200 * time.Millisecond, model.OrderFilled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{})
+			account.SetLatencyModel(NewFixedLatency(100*time.Millisecond, 0, tt.cancel))
+			e := newTestSim(account, "BTCUSDT", 0)
+
+			tick(e, "BTCUSDT", 1000, 100)
+			if err := e.ExecuteSignal(context.Background(), openSignal(model.DirLong, "", 1)); err != nil {
+				t.Fatalf("submit: %v", err)
+			}
+			tick(e, "BTCUSDT", 1010, 100)
+			if n := e.CancelPendingOrders(context.Background()); n != 1 {
+				t.Fatalf("%d cancels sent, want 1", n)
+			}
+			tick(e, "BTCUSDT", 1100, 100)
+			tick(e, "BTCUSDT", 1300, 100)
+
+			record := e.GetOrderHistory()[0]
+			if record.Status != tt.wantStatus {
+				t.Errorf("status %s, want %s", record.Status, tt.wantStatus)
+			}
+			if open := len(account.positions) == 1; open != (tt.wantStatus == model.OrderFilled) {
+				t.Errorf("position open = %v with order %s", open, record.Status)
+			}
+			if len(e.GetOpenOrders()) != 0 {
+				t.Errorf("order still pending")
+			}
+		})
+	}
+}
+
+func TestNewLatencyModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LatencyConfig
+		want    []time.Duration // 依次抽取的下单延迟
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "disabled", cfg: LatencyConfig{}, wantNil: true},
+		{name: "fixed", cfg: LatencyConfig{Mode: "fixed", SubmitMs: 120.5}, want: []time.Duration{120500 * time.Microsecond, 120500 * time.Microsecond}},
+		{name: "unknown mode", cfg: LatencyConfig{Mode: "gaussian"}, wantErr: true},
+		{name: "unknown distribution", cfg: LatencyConfig{Mode: "distribution", Distribution: "pareto"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latency, err := NewLatencyModel(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (latency == nil) != tt.wantNil {
+				t.Fatalf("model %v, want nil %v", latency, tt.wantNil)
+			}
+			for i, want := range tt.want {
+				if got := latency.Sample(LatencySubmit); got != want {
+					t.Errorf("sample %d: %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayLatencyCycles(t *testing.T) {
+	replay, err := ReadReplayLatency(strings.NewReader("kind,ms\nsubmit,100\nSUBMIT, 200\nack,5\nbad\n"))
+	if err != nil {
+		t.Fatalf("ReadReplayLatency: %v", err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 100 * time.Millisecond}
+	for i, w := range want {
+		if got := replay.Sample(LatencySubmit); got != w {
+			t.Errorf("submit sample %d: %s, want %s", i, got, w)
+		}
+	}
+	if got := replay.Sample(LatencyCancel); got != 0 {
+		t.Errorf("cancel sample %s without cancel samples, want 0", got)
+	}
+	if _, err := ReadReplayLatency(strings.NewReader("kind,ms\n")); err == nil {
+		t.Errorf("empty replay file accepted")
+	}
+}
@@ -1,6 +1,21 @@
 package executor
 
-import "go.uber.org/zap"
+import (
+	"bytes"
+	"context"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
 
 // OkxConfig 定义 Okx 执行器所需的全部配置
 type OkxConfig struct {
@@ -10,15 +25,250 @@ type OkxConfig struct {
 	Passphrase      string
 	RESTURL         string
 	MaxTotalCapital float64
+	PositionMode    model.PositionMode // 账户持仓模式 (net_mode / long_short_mode)
+	MarginMode      string             // 下单的保证金模式 (tdMode): isolated (默认) 或 cross
+	ContractValue   float64            // 每张合约的币数量 (BTC-USDT-SWAP 为 0.01)，信号数量按它换算为张数，默认 1
 }
 
 // OkxExecutor 结构体不变，使用新的 OkxConfig
 type OkxExecutor struct {
 	cfg    *OkxConfig // 使用执行器包内的配置结构
 	logger *zap.SugaredLogger
+	client *http.Client
+	clock  service.Clock // 记录修改时间使用的时钟 (请求签名始终使用系统时间)
+
+	mu                sync.Mutex
+	algoOrders        map[model.Direction]*okxAlgoOrder // 各持仓腿挂载的止盈止损委托 (单向模式 key 为 "net")
+	algoSeq           int                               // 生成止盈止损客户端委托号的序号
+	stopUpdateHistory []*model.StopUpdateRecord         // 止损/止盈修改记录
+
+	orders       map[string]model.Signal       // 按 clOrdId 记录已下订单的信号 (成交回报确定开平方向)
+	liveOrders   map[string]*model.OrderRecord // 最近一次查询到的未成交订单及之后新下的订单
+	unfilled     map[string]float64            // liveOrders 中各订单尚未成交的数量 (张)，部分成交的订单保留到完全成交
+	lastBillID   string                        // 已读取到的最新成交明细 billId
+	fillsPrimed  bool                          // 已完成第一次成交明细查询 (之后查到的成交都需要返回)
+	lastFillPoll time.Time                     // 上次查询成交明细的时间
+}
+
+// okxAlgoOrder 记录一条持仓腿当前挂载的止盈止损委托 (开仓单的 attachAlgoOrds，开仓单成交后生效)
+type okxAlgoOrder struct {
+	AlgoClOrdID     string              // 止损委托 (无分批止盈时同时带止盈) 的客户端委托号
+	StopLossPrice   float64             // 当前止损触发价
+	TakeProfitPrice float64             // 当前止盈触发价
+	TakeProfits     []okxTakeProfitAlgo // 分批止盈每一级单独的只减仓委托 (按触发顺序)
+}
+
+// okxTakeProfitAlgo 分批止盈阶梯中一级的委托
+type okxTakeProfitAlgo struct {
+	AlgoClOrdID string
+	Price       float64
 }
 
 // NewOkxExecutor 签名不变
 func NewOkxExecutor(cfg *OkxConfig, logger *zap.SugaredLogger) *OkxExecutor {
-	return &OkxExecutor{cfg: cfg, logger: logger}
+	return &OkxExecutor{
+		cfg:        cfg,
+		logger:     logger,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		clock:      service.RealClock{},
+		algoOrders: make(map[model.Direction]*okxAlgoOrder),
+		orders:     make(map[string]model.Signal),
+		liveOrders: make(map[string]*model.OrderRecord),
+		unfilled:   make(map[string]float64),
+	}
+}
+
+// SetClock 设置执行器记录事件使用的时钟
+func (e *OkxExecutor) SetClock(clock service.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.clock = clock
+}
+
+// okxResponse Okx REST V5 的通用响应结构
+type okxResponse struct {
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// okxAmendAlgoRequest 对应 POST /api/v5/trade/amend-algos 的请求体 (按客户端委托号修改)
+type okxAmendAlgoRequest struct {
+	InstID         string `json:"instId"`
+	AlgoClOrdID    string `json:"algoClOrdId"`
+	NewSlTriggerPx string `json:"newSlTriggerPx,omitempty"`
+	NewTpTriggerPx string `json:"newTpTriggerPx,omitempty"`
+}
+
+// ExecuteSignal 执行策略信号：开平仓下单 (市价/限价)、撤单和修改止盈止损委托。
+// 开仓信号的止损止盈 (及分批止盈阶梯) 随开仓单挂载，开仓单成交后生效
+func (e *OkxExecutor) ExecuteSignal(ctx context.Context, signal model.Signal) error {
+	switch signal.Action {
+	case model.ActionNone:
+		return nil
+	case model.ActionOpen, model.ActionClose:
+		return e.placeOrder(ctx, signal)
+	case model.ActionCancel:
+		return e.cancelOrders(ctx, signal.ClientOrderID)
+	case model.ActionUpdate:
+		return e.amendAlgoOrder(ctx, signal)
+	default:
+		return fmt.Errorf("okx executor: action %s not implemented", signal.Action)
+	}
+}
+
+// algoPosSide 返回记录持仓腿止盈止损委托使用的 key (单向模式为 net)
+func (e *OkxExecutor) algoPosSide(signal model.Signal) model.Direction {
+	if side := e.posSide(signal); side != "" {
+		return model.Direction(side)
+	}
+	return model.PosSideNet
+}
+
+// amendAlgoOrder 通过 amend-algos 接口修改当前持仓的止损/止盈触发价，并记录修改。
+// 分批止盈阶梯按 ActionUpdate 的语义替换剩余的级别：已触发的级别在阶梯前部，与信号中的级别按末尾对齐
+func (e *OkxExecutor) amendAlgoOrder(ctx context.Context, signal model.Signal) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	posSide := e.algoPosSide(signal)
+	algo, ok := e.algoOrders[posSide]
+	if !ok || algo.AlgoClOrdID == "" {
+		return fmt.Errorf("okx executor: no active algo order to amend for posSide %s", posSide)
+	}
+
+	var requests []okxAmendAlgoRequest
+	req := okxAmendAlgoRequest{InstID: e.instID(), AlgoClOrdID: algo.AlgoClOrdID}
+	if signal.StopLossPrice > 0 && signal.StopLossPrice != algo.StopLossPrice {
+		req.NewSlTriggerPx = formatPrice(signal.StopLossPrice)
+	}
+	if signal.TakeProfitPrice > 0 && len(algo.TakeProfits) == 0 && signal.TakeProfitPrice != algo.TakeProfitPrice {
+		req.NewTpTriggerPx = formatPrice(signal.TakeProfitPrice)
+	}
+	if req.NewSlTriggerPx != "" || req.NewTpTriggerPx != "" {
+		requests = append(requests, req)
+	}
+	if signal.TakeProfitLevels != nil {
+		if len(signal.TakeProfitLevels) > len(algo.TakeProfits) {
+			e.logger.Warnf("OKX amend-algos: %d take profit levels but only %d attached, extra levels ignored",
+				len(signal.TakeProfitLevels), len(algo.TakeProfits))
+		}
+		offset := len(algo.TakeProfits) - len(signal.TakeProfitLevels)
+		for i, level := range signal.TakeProfitLevels {
+			if offset+i < 0 {
+				continue
+			}
+			tp := algo.TakeProfits[offset+i]
+			if level.Price > 0 && level.Price != tp.Price {
+				requests = append(requests, okxAmendAlgoRequest{InstID: e.instID(), AlgoClOrdID: tp.AlgoClOrdID, NewTpTriggerPx: formatPrice(level.Price)})
+			}
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	for _, req := range requests {
+		if _, err := e.doRequest(ctx, http.MethodPost, "/api/v5/trade/amend-algos", req); err != nil {
+			return fmt.Errorf("okx amend-algos %s failed: %w", req.AlgoClOrdID, err)
+		}
+	}
+
+	record := &model.StopUpdateRecord{
+		Time:          e.clock.Now(),
+		Symbol:        e.cfg.Symbol,
+		PosSide:       signal.Direction,
+		MarketPrice:   signal.Price,
+		OldStopLoss:   algo.StopLossPrice,
+		NewStopLoss:   algo.StopLossPrice,
+		OldTakeProfit: algo.TakeProfitPrice,
+		NewTakeProfit: algo.TakeProfitPrice,
+		Reason:        signal.Reason,
+	}
+	if signal.StopLossPrice > 0 {
+		algo.StopLossPrice = signal.StopLossPrice
+		record.NewStopLoss = signal.StopLossPrice
+	}
+	if signal.TakeProfitPrice > 0 && len(algo.TakeProfits) == 0 {
+		algo.TakeProfitPrice = signal.TakeProfitPrice
+		record.NewTakeProfit = signal.TakeProfitPrice
+	}
+	if signal.TakeProfitLevels != nil {
+		offset := len(algo.TakeProfits) - len(signal.TakeProfitLevels)
+		for i, level := range signal.TakeProfitLevels {
+			if offset+i >= 0 && level.Price > 0 {
+				algo.TakeProfits[offset+i].Price = level.Price
+			}
+		}
+		record.TakeProfitLevels = len(signal.TakeProfitLevels)
+	}
+	e.stopUpdateHistory = append(e.stopUpdateHistory, record)
+
+	e.logger.Infof("OKX ALGO AMENDED: %s SL: %.4f -> %.4f, TP: %.4f -> %.4f (%d requests). Reason: %s",
+		e.instID(), record.OldStopLoss, record.NewStopLoss, record.OldTakeProfit, record.NewTakeProfit, len(requests), signal.Reason)
+
+	return nil
+}
+
+// GetStopUpdateHistory 返回所有止损/止盈修改记录
+func (e *OkxExecutor) GetStopUpdateHistory() ([]*model.StopUpdateRecord, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	records := make([]*model.StopUpdateRecord, len(e.stopUpdateHistory))
+	copy(records, e.stopUpdateHistory)
+	return records, nil
+}
+
+// instID 将 Symbol 转换为 Okx 永续合约 InstId (例如 BTCUSDT -> BTC-USDT-SWAP)，与 Connector 的映射一致
+func (e *OkxExecutor) instID() string {
+	symbol := e.cfg.Symbol
+	return symbol[:3] + "-" + symbol[3:] + "-SWAP"
+}
+
+// doRequest 发送签名的 Okx REST V5 请求，返回响应中的 data 字段
+func (e *OkxExecutor) doRequest(ctx context.Context, method string, path string, body interface{}) (json.RawMessage, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.cfg.RESTURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	// 签名: Base64(HMAC-SHA256(timestamp + method + requestPath + body, SecretKey))
+	// 交易所校验请求时间，签名必须使用真实的系统时间而不是注入的时钟
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	mac := hmac.New(sha256.New, []byte(e.cfg.SecretKey))
+	mac.Write([]byte(timestamp + method + path + string(payload)))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("OK-ACCESS-KEY", e.cfg.APIKey)
+	req.Header.Set("OK-ACCESS-SIGN", sign)
+	req.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+	req.Header.Set("OK-ACCESS-PASSPHRASE", e.cfg.Passphrase)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var okxResp okxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&okxResp); err != nil {
+		return nil, fmt.Errorf("decode response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if okxResp.Code != "0" {
+		return nil, fmt.Errorf("okx error code=%s msg=%s", okxResp.Code, okxResp.Msg)
+	}
+
+	return okxResp.Data, nil
 }
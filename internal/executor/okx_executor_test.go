@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"encoding/json"
+	"testing"
+)
+
+// ladderOpen 带止损和两级分批止盈的 0.05 BTC 开多信号
+var ladderOpen = model.Signal{
+	Action:        model.ActionOpen,
+	Direction:     model.DirLong,
+	PosSide:       model.DirLong,
+	OrderType:     model.OrderMarket,
+	Price:         100,
+	PositionSize:  0.05,
+	StopLossPrice: 95,
+	ClientOrderID: "o1",
+	TakeProfitLevels: []model.TakeProfitLevel{
+		{Price: 105, SizeRatio: 0.4},
+		{Price: 110, SizeRatio: 0.6},
+	},
+}
+
+// decodeBody 解析测试服务器收到的请求体
+func decodeBody(t *testing.T, request okxRequest, v any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(request.Body), v); err != nil {
+		t.Fatalf("decode %s body %q: %v", request.Path, request.Body, err)
+	}
+}
+
+func TestOkxOpenAttachesAlgos(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal model.Signal
+		want   []okxAttachAlgo // 忽略 AttachAlgoClOrdID
+	}{
+		{
+			name:   "stop and take profit",
+			signal: model.Signal{Action: model.ActionOpen, Direction: model.DirShort, Price: 100, PositionSize: 0.02, StopLossPrice: 104, TakeProfitPrice: 92},
+			want:   []okxAttachAlgo{{SlTriggerPx: "104", SlOrdPx: "-1", TpTriggerPx: "92", TpOrdPx: "-1"}},
+		},
+		{
+			name:   "ladder",
+			signal: ladderOpen,
+			want: []okxAttachAlgo{
+				{SlTriggerPx: "95", SlOrdPx: "-1"},
+				{TpTriggerPx: "105", TpOrdPx: "-1", Sz: "2"},
+				{TpTriggerPx: "110", TpOrdPx: "-1", Sz: "3"},
+			},
+		},
+		{
+			name:   "no stops",
+			signal: model.Signal{Action: model.ActionOpen, Direction: model.DirLong, Price: 100, PositionSize: 0.02},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake, _ := newTestOkx(t, OkxConfig{PositionMode: model.PosModeLongShort})
+			if err := e.ExecuteSignal(context.Background(), tt.signal); err != nil {
+				t.Fatalf("ExecuteSignal: %v", err)
+			}
+			var req okxOrderRequest
+			decodeBody(t, fake.sent("/api/v5/trade/order")[0], &req)
+			if len(req.AttachAlgoOrds) != len(tt.want) {
+				t.Fatalf("attachAlgoOrds %+v, want %d entries", req.AttachAlgoOrds, len(tt.want))
+			}
+			ids := make(map[string]bool)
+			for i, got := range req.AttachAlgoOrds {
+				if got.AttachAlgoClOrdID == "" || ids[got.AttachAlgoClOrdID] {
+					t.Errorf("entry %d: missing or duplicate attachAlgoClOrdId %q", i, got.AttachAlgoClOrdID)
+				}
+				ids[got.AttachAlgoClOrdID] = true
+				got.AttachAlgoClOrdID = ""
+				if got != tt.want[i] {
+					t.Errorf("entry %d: %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOkxAmendAlgoRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		update model.Signal
+		want   []okxAmendAlgoRequest // AlgoClOrdID 为开仓时 attachAlgoOrds 的下标
+	}{
+		{
+			name:   "stop loss",
+			update: model.Signal{StopLossPrice: 98},
+			want:   []okxAmendAlgoRequest{{AlgoClOrdID: "0", NewSlTriggerPx: "98"}},
+		},
+		{
+			name:   "remaining ladder level",
+			update: model.Signal{TakeProfitLevels: []model.TakeProfitLevel{{Price: 112, SizeRatio: 0.6}}},
+			want:   []okxAmendAlgoRequest{{AlgoClOrdID: "2", NewTpTriggerPx: "112"}},
+		},
+		{
+			name:   "stop loss and whole ladder",
+			update: model.Signal{StopLossPrice: 99, TakeProfitLevels: []model.TakeProfitLevel{{Price: 105, SizeRatio: 0.4}, {Price: 111, SizeRatio: 0.6}}},
+			want: []okxAmendAlgoRequest{
+				{AlgoClOrdID: "0", NewSlTriggerPx: "99"},
+				{AlgoClOrdID: "2", NewTpTriggerPx: "111"},
+			},
+		},
+		{
+			name:   "unchanged",
+			update: model.Signal{StopLossPrice: 95},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake, _ := newTestOkx(t, OkxConfig{PositionMode: model.PosModeLongShort})
+			ctx := context.Background()
+			if err := e.ExecuteSignal(ctx, ladderOpen); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			var open okxOrderRequest
+			decodeBody(t, fake.sent("/api/v5/trade/order")[0], &open)
+
+			update := tt.update
+			update.Action, update.Direction, update.PosSide, update.Reason = model.ActionUpdate, model.DirLong, model.DirLong, "Trailing Stop"
+			if err := e.ExecuteSignal(ctx, update); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			sent := fake.sent("/api/v5/trade/amend-algos")
+			if len(sent) != len(tt.want) {
+				t.Fatalf("%d amend-algos requests, want %d", len(sent), len(tt.want))
+			}
+			for i, request := range sent {
+				var got okxAmendAlgoRequest
+				decodeBody(t, request, &got)
+				want := tt.want[i]
+				want.InstID = "BTC-USDT-SWAP"
+				want.AlgoClOrdID = open.AttachAlgoOrds[want.AlgoClOrdID[0]-'0'].AttachAlgoClOrdID
+				if got != want {
+					t.Errorf("request %d: %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOkxAmendWithoutAlgoFails(t *testing.T) {
+	e, fake, _ := newTestOkx(t, OkxConfig{})
+	err := e.ExecuteSignal(context.Background(), model.Signal{Action: model.ActionUpdate, Direction: model.DirLong, StopLossPrice: 98})
+	if err == nil {
+		t.Errorf("amend without an attached algo succeeded")
+	}
+	if sent := fake.sent("/api/v5/trade/amend-algos"); len(sent) != 0 {
+		t.Errorf("sent %d amend-algos requests", len(sent))
+	}
+}
@@ -0,0 +1,486 @@
+package executor
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// okxFillPollInterval 两次查询成交明细的最小间隔 (DrainFills 在逐笔路径上调用，需要限制请求频率)
+const okxFillPollInterval = 2 * time.Second
+
+// okxOrderRequest 对应 POST /api/v5/trade/order 的请求体
+type okxOrderRequest struct {
+	InstID     string `json:"instId"`
+	TdMode     string `json:"tdMode"`
+	Side       string `json:"side"`
+	PosSide    string `json:"posSide,omitempty"`
+	OrdType    string `json:"ordType"`
+	Sz         string `json:"sz"`
+	Px         string `json:"px,omitempty"`
+	ClOrdID    string `json:"clOrdId,omitempty"`
+	ReduceOnly bool   `json:"reduceOnly,omitempty"`
+
+	AttachAlgoOrds []okxAttachAlgo `json:"attachAlgoOrds,omitempty"`
+}
+
+// okxAttachAlgo 开仓单附带的止盈止损 (attachAlgoOrds)：开仓单成交后生效，为只减仓的市价委托。
+// 分批止盈的每一级是一个带 sz 的止盈委托
+type okxAttachAlgo struct {
+	AttachAlgoClOrdID string `json:"attachAlgoClOrdId"`
+	SlTriggerPx       string `json:"slTriggerPx,omitempty"`
+	SlOrdPx           string `json:"slOrdPx,omitempty"`
+	TpTriggerPx       string `json:"tpTriggerPx,omitempty"`
+	TpOrdPx           string `json:"tpOrdPx,omitempty"`
+	Sz                string `json:"sz,omitempty"`
+}
+
+// okxClosePositionRequest 对应 POST /api/v5/trade/close-position 的请求体 (市价平掉整条持仓腿)
+type okxClosePositionRequest struct {
+	InstID  string `json:"instId"`
+	MgnMode string `json:"mgnMode"`
+	PosSide string `json:"posSide,omitempty"`
+	ClOrdID string `json:"clOrdId,omitempty"`
+}
+
+// okxCancelRequest 对应 cancel-order / cancel-batch-orders 的请求体
+type okxCancelRequest struct {
+	InstID  string `json:"instId"`
+	OrdID   string `json:"ordId,omitempty"`
+	ClOrdID string `json:"clOrdId,omitempty"`
+}
+
+// okxOrderAck 下单/撤单接口返回的单条结果
+type okxOrderAck struct {
+	OrdID   string `json:"ordId"`
+	ClOrdID string `json:"clOrdId"`
+	SCode   string `json:"sCode"`
+	SMsg    string `json:"sMsg"`
+}
+
+// okxPendingOrder GET /api/v5/trade/orders-pending 返回的未成交订单 (包括部分成交的订单)
+type okxPendingOrder struct {
+	OrdID     string `json:"ordId"`
+	ClOrdID   string `json:"clOrdId"`
+	Px        string `json:"px"`
+	Sz        string `json:"sz"`
+	AccFillSz string `json:"accFillSz"` // 部分成交订单已成交的数量
+	Side      string `json:"side"`
+	PosSide   string `json:"posSide"`
+	OrdType   string `json:"ordType"`
+	CTime     string `json:"cTime"`
+}
+
+// okxFill GET /api/v5/trade/fills 返回的成交明细 (按时间倒序)
+type okxFill struct {
+	BillID  string `json:"billId"`
+	OrdID   string `json:"ordId"`
+	ClOrdID string `json:"clOrdId"`
+	FillPx  string `json:"fillPx"`
+	FillSz  string `json:"fillSz"`
+	Side    string `json:"side"`
+	PosSide string `json:"posSide"`
+	Fee     string `json:"fee"`
+	FillPnl string `json:"fillPnl"`
+	Ts      string `json:"ts"`
+}
+
+// placeOrder 下开仓/平仓单：市价或限价 (Signal.OrderType)，平仓单为只减仓；PositionSize 为 0 的平仓使用市价全平接口
+func (e *OkxExecutor) placeOrder(ctx context.Context, signal model.Signal) error {
+	if signal.Action == model.ActionClose && signal.PositionSize <= 0 {
+		req := okxClosePositionRequest{
+			InstID:  e.instID(),
+			MgnMode: e.marginMode(),
+			PosSide: e.posSide(signal),
+			ClOrdID: signal.ClientOrderID,
+		}
+		if _, err := e.doRequest(ctx, http.MethodPost, "/api/v5/trade/close-position", req); err != nil {
+			return fmt.Errorf("okx close-position failed: %w", err)
+		}
+		e.trackOrder(signal)
+		e.logger.Infof("OKX POSITION CLOSE SENT: %s %s. Reason: %s", e.instID(), signal.Direction, signal.Reason)
+		return nil
+	}
+	if signal.PositionSize <= 0 {
+		return fmt.Errorf("okx executor: invalid position size %.8f", signal.PositionSize)
+	}
+
+	// 开多/平空为买，开空/平多为卖 (平仓信号的 Direction 为持仓方向)
+	isBuy := signal.Direction == model.DirLong
+	if signal.Action == model.ActionClose {
+		isBuy = !isBuy
+	}
+	req := okxOrderRequest{
+		InstID:     e.instID(),
+		TdMode:     e.marginMode(),
+		Side:       "sell",
+		PosSide:    e.posSide(signal),
+		OrdType:    string(model.OrderMarket),
+		Sz:         e.formatContracts(signal.PositionSize),
+		ClOrdID:    signal.ClientOrderID,
+		ReduceOnly: signal.Action == model.ActionClose && e.cfg.PositionMode != model.PosModeLongShort,
+	}
+	if isBuy {
+		req.Side = "buy"
+	}
+	if signal.OrderType == model.OrderLimit {
+		if signal.Price <= 0 {
+			return fmt.Errorf("okx executor: limit order without price")
+		}
+		req.OrdType = string(model.OrderLimit)
+		req.Px = formatPrice(signal.Price)
+	}
+	var algo *okxAlgoOrder
+	if signal.Action == model.ActionOpen {
+		req.AttachAlgoOrds, algo = e.attachAlgos(signal)
+	}
+
+	data, err := e.doRequest(ctx, http.MethodPost, "/api/v5/trade/order", req)
+	if err != nil {
+		return fmt.Errorf("okx order failed: %w", err)
+	}
+	if err := checkOrderAcks(data); err != nil {
+		return fmt.Errorf("okx order rejected: %w", err)
+	}
+	e.trackOrder(signal)
+	if algo != nil {
+		e.mu.Lock()
+		e.algoOrders[e.algoPosSide(signal)] = algo
+		e.mu.Unlock()
+	}
+
+	e.logger.Infof("OKX ORDER PLACED: %s %s %s %s sz=%s px=%s clOrdId=%s. Reason: %s",
+		req.InstID, signal.Action, req.Side, req.OrdType, req.Sz, req.Px, req.ClOrdID, signal.Reason)
+	return nil
+}
+
+// attachAlgos 生成开仓信号止损止盈的 attachAlgoOrds 和对应的委托记录 (没有止损止盈时返回 nil)。
+// 没有分批止盈阶梯时止损和止盈合为一个委托；有阶梯时止损单独一个委托，每一级一个按比例 sz 的止盈委托
+func (e *OkxExecutor) attachAlgos(signal model.Signal) ([]okxAttachAlgo, *okxAlgoOrder) {
+	if signal.StopLossPrice <= 0 && signal.TakeProfitPrice <= 0 && len(signal.TakeProfitLevels) == 0 {
+		return nil, nil
+	}
+	e.mu.Lock()
+	e.algoSeq++
+	prefix := fmt.Sprintf("a%d%d", e.clock.Now().UnixMilli(), e.algoSeq)
+	e.mu.Unlock()
+
+	algo := &okxAlgoOrder{StopLossPrice: signal.StopLossPrice}
+	main := okxAttachAlgo{AttachAlgoClOrdID: prefix + "s"}
+	if signal.StopLossPrice > 0 {
+		main.SlTriggerPx, main.SlOrdPx = formatPrice(signal.StopLossPrice), "-1"
+	}
+	if len(signal.TakeProfitLevels) == 0 && signal.TakeProfitPrice > 0 {
+		main.TpTriggerPx, main.TpOrdPx = formatPrice(signal.TakeProfitPrice), "-1"
+		algo.TakeProfitPrice = signal.TakeProfitPrice
+	}
+	var attach []okxAttachAlgo
+	if main.SlTriggerPx != "" || main.TpTriggerPx != "" {
+		attach = append(attach, main)
+		algo.AlgoClOrdID = main.AttachAlgoClOrdID
+	}
+	for i, level := range signal.TakeProfitLevels {
+		id := fmt.Sprintf("%st%d", prefix, i+1)
+		attach = append(attach, okxAttachAlgo{
+			AttachAlgoClOrdID: id,
+			TpTriggerPx:       formatPrice(level.Price),
+			TpOrdPx:           "-1",
+			Sz:                e.formatContracts(signal.PositionSize * level.SizeRatio),
+		})
+		algo.TakeProfits = append(algo.TakeProfits, okxTakeProfitAlgo{AlgoClOrdID: id, Price: level.Price})
+	}
+	if algo.AlgoClOrdID == "" && len(algo.TakeProfits) > 0 {
+		algo.AlgoClOrdID = algo.TakeProfits[0].AlgoClOrdID
+	}
+	return attach, algo
+}
+
+// cancelOrders 撤销 clientOrderID 对应的订单，为空时撤销本交易对的全部未成交订单
+func (e *OkxExecutor) cancelOrders(ctx context.Context, clientOrderID string) error {
+	if clientOrderID != "" {
+		data, err := e.doRequest(ctx, http.MethodPost, "/api/v5/trade/cancel-order", okxCancelRequest{InstID: e.instID(), ClOrdID: clientOrderID})
+		if err != nil {
+			return fmt.Errorf("okx cancel-order failed: %w", err)
+		}
+		return checkOrderAcks(data)
+	}
+
+	pending, err := e.fetchPendingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	// cancel-batch-orders 每次最多 20 笔
+	for start := 0; start < len(pending); start += 20 {
+		end := min(start+20, len(pending))
+		batch := make([]okxCancelRequest, 0, end-start)
+		for _, order := range pending[start:end] {
+			batch = append(batch, okxCancelRequest{InstID: e.instID(), OrdID: order.OrdID})
+		}
+		data, err := e.doRequest(ctx, http.MethodPost, "/api/v5/trade/cancel-batch-orders", batch)
+		if err != nil {
+			return fmt.Errorf("okx cancel-batch-orders failed: %w", err)
+		}
+		if err := checkOrderAcks(data); err != nil {
+			return err
+		}
+	}
+	e.logger.Infof("OKX ORDERS CANCELED: %s %d orders", e.instID(), len(pending))
+	return nil
+}
+
+// fetchPendingOrders 查询本交易对的未成交订单
+func (e *OkxExecutor) fetchPendingOrders(ctx context.Context) ([]okxPendingOrder, error) {
+	query := url.Values{"instType": {"SWAP"}, "instId": {e.instID()}}
+	data, err := e.doRequest(ctx, http.MethodGet, "/api/v5/trade/orders-pending?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("okx orders-pending failed: %w", err)
+	}
+	var orders []okxPendingOrder
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, fmt.Errorf("decode orders-pending: %w", err)
+	}
+	return orders, nil
+}
+
+// GetOpenOrders 实现 OrderLister 接口：返回本交易对的未成交订单。
+// 查询失败时返回最近一次成功查询的结果加上之后下的订单，避免策略把仍在挂单的订单误判为已撤销
+func (e *OkxExecutor) GetOpenOrders() []*model.OrderRecord {
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+
+	pending, err := e.fetchPendingOrders(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		e.logger.Warnf("OKX GetOpenOrders failed, using cached orders: %v", err)
+		records := make([]*model.OrderRecord, 0, len(e.liveOrders))
+		for _, record := range e.liveOrders {
+			records = append(records, record)
+		}
+		return records
+	}
+
+	e.liveOrders = make(map[string]*model.OrderRecord, len(pending))
+	e.unfilled = make(map[string]float64, len(pending))
+	records := make([]*model.OrderRecord, 0, len(pending))
+	for _, order := range pending {
+		record := &model.OrderRecord{
+			ClientOrderID: order.ClOrdID,
+			Symbol:        e.cfg.Symbol,
+			OrderType:     model.OrderType(order.OrdType),
+			LimitPrice:    parseFloat(order.Px),
+			SubmitTime:    time.UnixMilli(int64(parseFloat(order.CTime))),
+			Status:        model.OrderLive,
+		}
+		if signal, ok := e.orders[order.ClOrdID]; ok {
+			record.Action = signal.Action
+			record.Direction = signal.Direction
+			record.PosSide = signal.PosSide
+		}
+		records = append(records, record)
+		if order.ClOrdID != "" {
+			e.liveOrders[order.ClOrdID] = record
+			e.unfilled[order.ClOrdID] = parseFloat(order.Sz) - parseFloat(order.AccFillSz)
+		}
+	}
+	return records
+}
+
+// DrainFills 实现 FillSource 接口：查询上次之后的成交明细 (至多每 okxFillPollInterval 一次) 并返回。
+// 第一次查询只记录位置，不返回执行器启动前的成交
+func (e *OkxExecutor) DrainFills() []model.Fill {
+	e.mu.Lock()
+	now := e.clock.Now()
+	if now.Sub(e.lastFillPoll) < okxFillPollInterval {
+		e.mu.Unlock()
+		return nil
+	}
+	e.lastFillPoll = now
+	lastBillID := e.lastBillID
+	e.mu.Unlock()
+
+	query := url.Values{"instType": {"SWAP"}, "instId": {e.instID()}}
+	if lastBillID != "" {
+		query.Set("before", lastBillID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+	data, err := e.doRequest(ctx, http.MethodGet, "/api/v5/trade/fills?"+query.Encode(), nil)
+	if err != nil {
+		e.logger.Warnf("OKX fills query failed: %v", err)
+		return nil
+	}
+	var okxFills []okxFill
+	if err := json.Unmarshal(data, &okxFills); err != nil {
+		e.logger.Warnf("OKX fills decode failed: %v", err)
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// 账户没有成交历史时第一次查询为空，之后查到的成交都是启动后发生的，需要返回
+	primed := e.fillsPrimed
+	e.fillsPrimed = true
+	if len(okxFills) == 0 {
+		return nil
+	}
+	e.lastBillID = okxFills[0].BillID
+	if !primed {
+		return nil
+	}
+
+	// 接口按时间倒序返回，转换为发生顺序
+	fills := make([]model.Fill, 0, len(okxFills))
+	for i := len(okxFills) - 1; i >= 0; i-- {
+		fills = append(fills, e.convertFill(okxFills[i]))
+		e.consumeLiveOrder(okxFills[i])
+	}
+	return fills
+}
+
+// consumeLiveOrder 从订单的未成交数量中扣除一笔成交，订单完全成交 (state=filled) 后从 liveOrders 中移除 (调用方需持有 e.mu)。
+// 部分成交的订单仍在订单簿中，查询未完成订单失败时需要继续返回它
+func (e *OkxExecutor) consumeLiveOrder(f okxFill) {
+	remaining := e.unfilled[f.ClOrdID] - parseFloat(f.FillSz)
+	if remaining > 1e-9 {
+		e.unfilled[f.ClOrdID] = remaining
+		return
+	}
+	delete(e.liveOrders, f.ClOrdID)
+	delete(e.unfilled, f.ClOrdID)
+}
+
+// convertFill 将 Okx 成交明细转换为 model.Fill (调用方需持有 e.mu)。
+// 本执行器下的订单按下单信号确定开平方向，其他订单在双向持仓模式下按 posSide 和买卖方向推断
+func (e *OkxExecutor) convertFill(f okxFill) model.Fill {
+	fill := model.Fill{
+		Time:          time.UnixMilli(int64(parseFloat(f.Ts))),
+		Symbol:        e.cfg.Symbol,
+		Price:         parseFloat(f.FillPx),
+		Size:          parseFloat(f.FillSz) * e.contractValue(),
+		Fee:           -parseFloat(f.Fee), // Okx 的手续费为负数表示扣除
+		RealizedPnL:   parseFloat(f.FillPnl),
+		Reason:        "Exchange",
+		ClientOrderID: f.ClOrdID,
+	}
+	if signal, ok := e.orders[f.ClOrdID]; ok && f.ClOrdID != "" {
+		fill.Action, fill.Direction, fill.Reason = signal.Action, signal.Direction, signal.Reason
+		return fill
+	}
+
+	switch {
+	case f.PosSide == string(model.DirLong):
+		fill.Direction = model.DirLong
+		fill.Action = model.ActionOpen
+		if f.Side == "sell" {
+			fill.Action = model.ActionClose
+		}
+	case f.PosSide == string(model.DirShort):
+		fill.Direction = model.DirShort
+		fill.Action = model.ActionOpen
+		if f.Side == "buy" {
+			fill.Action = model.ActionClose
+		}
+	default:
+		// 单向持仓无法仅凭成交区分开平：有已实现盈亏的视为平仓
+		fill.Direction = model.DirLong
+		if f.Side == "sell" {
+			fill.Direction = model.DirShort
+		}
+		fill.Action = model.ActionOpen
+		if fill.RealizedPnL != 0 {
+			fill.Action = model.ActionClose
+		}
+	}
+	return fill
+}
+
+// trackOrder 记录已下订单的信号，用于成交回报确定开平方向
+func (e *OkxExecutor) trackOrder(signal model.Signal) {
+	if signal.ClientOrderID == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.orders[signal.ClientOrderID] = signal
+	e.liveOrders[signal.ClientOrderID] = &model.OrderRecord{
+		ClientOrderID: signal.ClientOrderID,
+		Symbol:        e.cfg.Symbol,
+		Action:        signal.Action,
+		OrderType:     signal.OrderType,
+		Direction:     signal.Direction,
+		PosSide:       signal.PosSide,
+		LimitPrice:    signal.Price,
+		SubmitTime:    e.clock.Now(),
+		Status:        model.OrderLive,
+		Reason:        signal.Reason,
+	}
+	e.unfilled[signal.ClientOrderID] = signal.PositionSize / e.contractValue()
+}
+
+// marginMode 下单使用的保证金模式 (tdMode)，默认逐仓
+func (e *OkxExecutor) marginMode() string {
+	if e.cfg.MarginMode == "" {
+		return MarginModeIsolated
+	}
+	return e.cfg.MarginMode
+}
+
+// contractValue 每张合约对应的币数量，未配置时按 1 处理
+func (e *OkxExecutor) contractValue() float64 {
+	if e.cfg.ContractValue <= 0 {
+		return 1
+	}
+	return e.cfg.ContractValue
+}
+
+// posSide 双向持仓模式下订单的 posSide (PosSide 为空时取 Direction)，单向模式不传
+func (e *OkxExecutor) posSide(signal model.Signal) string {
+	if e.cfg.PositionMode != model.PosModeLongShort {
+		return ""
+	}
+	if signal.PosSide != "" {
+		return string(signal.PosSide)
+	}
+	return string(signal.Direction)
+}
+
+// checkOrderAcks 检查批量接口中每一条结果的 sCode
+func checkOrderAcks(data json.RawMessage) error {
+	var acks []okxOrderAck
+	if err := json.Unmarshal(data, &acks); err != nil {
+		return fmt.Errorf("decode order ack: %w", err)
+	}
+	for _, ack := range acks {
+		if ack.SCode != "" && ack.SCode != "0" {
+			return fmt.Errorf("clOrdId=%s sCode=%s sMsg=%s", ack.ClOrdID, ack.SCode, ack.SMsg)
+		}
+	}
+	return nil
+}
+
+// formatContracts 将币数量换算为张数字符串 (去掉换算产生的浮点误差)
+func (e *OkxExecutor) formatContracts(size float64) string {
+	return strconv.FormatFloat(math.Round(size/e.contractValue()*1e8)/1e8, 'f', -1, 64)
+}
+
+// formatPrice 将价格格式化为 Okx 请求使用的字符串
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// parseFloat 解析 Okx 以字符串返回的数值，空串或格式错误时为 0
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// okxRequest 测试服务器收到的一次请求
+type okxRequest struct {
+	Method string
+	Path   string // 不含查询参数
+	Query  string
+	Body   string
+}
+
+// fakeOkx 记录请求并按路径返回预设 data 的 Okx REST 测试服务器 (未预设的路径返回空数组)
+type fakeOkx struct {
+	mu        sync.Mutex
+	requests  []okxRequest
+	responses map[string][]string // 路径 -> 依次返回的 data (用完后重复最后一个)
+}
+
+func (f *fakeOkx) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, okxRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: string(body)})
+	data := "[]"
+	if queue := f.responses[r.URL.Path]; len(queue) > 0 {
+		data = queue[0]
+		if len(queue) > 1 {
+			f.responses[r.URL.Path] = queue[1:]
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"code": "0", "msg": "", "data": json.RawMessage(data)})
+}
+
+// reply 设置 path 依次返回的 data
+func (f *fakeOkx) reply(path string, data ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[path] = data
+}
+
+// sent 返回发往 path 的请求
+func (f *fakeOkx) sent(path string) []okxRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []okxRequest
+	for _, r := range f.requests {
+		if r.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// newTestOkx 创建连接到测试服务器的 BTCUSDT 执行器 (每张 0.01 BTC，使用手动时钟)
+func newTestOkx(t *testing.T, cfg OkxConfig) (*OkxExecutor, *fakeOkx, *service.ManualClock) {
+	t.Helper()
+	fake := &fakeOkx{responses: make(map[string][]string)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg.Symbol, cfg.RESTURL, cfg.ContractValue = "BTCUSDT", server.URL, 0.01
+	e := NewOkxExecutor(&cfg, zap.NewNop().Sugar())
+	clock := service.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	e.SetClock(clock)
+	return e, fake, clock
+}
+
+func TestOkxLiveOrderKeptUntilFilled(t *testing.T) {
+	e := NewOkxExecutor(&OkxConfig{Symbol: "BTCUSDT", ContractValue: 0.01}, zap.NewNop().Sugar())
+	e.trackOrder(model.Signal{
+		Action:        model.ActionOpen,
+		Direction:     model.DirLong,
+		OrderType:     model.OrderLimit,
+		Price:         100,
+		PositionSize:  0.05, // 5 张
+		ClientOrderID: "g1",
+	})
+
+	// 部分成交的订单仍在订单簿中，查询未完成订单失败时需要继续返回
+	e.consumeLiveOrder(okxFill{ClOrdID: "g1", FillSz: "2"})
+	e.consumeLiveOrder(okxFill{ClOrdID: "g1", FillSz: "2"})
+	if _, ok := e.liveOrders["g1"]; !ok {
+		t.Fatalf("partially filled order dropped from liveOrders")
+	}
+	e.consumeLiveOrder(okxFill{ClOrdID: "g1", FillSz: "1"})
+	if _, ok := e.liveOrders["g1"]; ok {
+		t.Errorf("fully filled order still in liveOrders")
+	}
+	if _, ok := e.unfilled["g1"]; ok {
+		t.Errorf("fully filled order still tracked in unfilled")
+	}
+}
+
+func TestOkxDrainFillsAfterEmptyFirstPoll(t *testing.T) {
+	e, fake, clock := newTestOkx(t, OkxConfig{})
+	e.trackOrder(model.Signal{Action: model.ActionOpen, Direction: model.DirLong, OrderType: model.OrderLimit, Price: 100, PositionSize: 0.05, ClientOrderID: "g1"})
+
+	// 没有成交历史的账户：第一次查询为空，之后的成交都是启动后发生的
+	fake.reply("/api/v5/trade/fills",
+		`[]`,
+		`[{"billId":"12","clOrdId":"g1","fillPx":"100","fillSz":"3","side":"buy","posSide":"long","fee":"-0.1","ts":"1704067201000"},
+		  {"billId":"11","clOrdId":"g1","fillPx":"100","fillSz":"2","side":"buy","posSide":"long","fee":"-0.1","ts":"1704067200000"}]`,
+		`[]`,
+	)
+	if fills := e.DrainFills(); len(fills) != 0 {
+		t.Fatalf("first poll returned %d fills", len(fills))
+	}
+	clock.Step(okxFillPollInterval)
+	fills := e.DrainFills()
+	if len(fills) != 2 {
+		t.Fatalf("second poll returned %d fills, want 2", len(fills))
+	}
+	if fills[0].Size != 0.02 || fills[1].Size != 0.03 || fills[0].Action != model.ActionOpen || fills[0].ClientOrderID != "g1" {
+		t.Errorf("fills %+v, want 0.02 then 0.03 of open g1", fills)
+	}
+	if _, ok := e.liveOrders["g1"]; ok {
+		t.Errorf("fully filled order still in liveOrders")
+	}
+
+	// 之后的查询从最新的 billId 开始
+	clock.Step(okxFillPollInterval)
+	e.DrainFills()
+	requests := fake.sent("/api/v5/trade/fills")
+	if last := requests[len(requests)-1].Query; !strings.Contains(last, "before=12") {
+		t.Errorf("third poll query %q, want before=12", last)
+	}
+}
+
+func TestOkxDrainFillsSkipsHistory(t *testing.T) {
+	e, fake, clock := newTestOkx(t, OkxConfig{})
+
+	// 第一次查询到的是执行器启动前的成交：只记录位置
+	fake.reply("/api/v5/trade/fills",
+		`[{"billId":"5","clOrdId":"old","fillPx":"100","fillSz":"1","side":"buy","posSide":"long","fee":"0","ts":"1704067200000"}]`,
+		`[{"billId":"6","clOrdId":"new","fillPx":"101","fillSz":"1","side":"sell","posSide":"short","fee":"0","ts":"1704067260000"}]`,
+	)
+	if fills := e.DrainFills(); len(fills) != 0 {
+		t.Fatalf("first poll returned %d historical fills", len(fills))
+	}
+	clock.Step(okxFillPollInterval)
+	fills := e.DrainFills()
+	if len(fills) != 1 || fills[0].ClientOrderID != "new" || fills[0].Direction != model.DirShort {
+		t.Fatalf("second poll returned %+v, want the new short fill", fills)
+	}
+}
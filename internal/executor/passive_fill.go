@@ -0,0 +1,267 @@
+package executor
+
+import (
+	"crypto-algo-trader/internal/model"
+	"fmt"
+	"time"
+)
+
+// 限价单 (被动单) 成交模型
+const (
+	PassiveFillQueue         = "queue"         // 按订单簿排队位置成交：同价位前方挂单被吃完后才成交 (默认)
+	PassiveFillProbability   = "probabilistic" // 每笔触及挂单价的成交以固定概率带来成交 (queue 模型无深度数据时也使用)
+	PassiveFillTouch         = "touch"         // 价格触及挂单价即成交 (乐观)
+	PassiveFillThrough       = "through"       // 价格穿过挂单价才成交 (悲观)
+	defaultPassiveFillChance = 0.5
+)
+
+// restingOrder 已挂入订单簿、等待成交的限价单
+type restingOrder struct {
+	record     *model.OrderRecord
+	owner      *SimulatorExecutor
+	signal     model.Signal
+	isBuy      bool    // 买单挂在 bid 侧，卖单挂在 ask 侧
+	price      float64 // 挂单价格
+	queueAhead float64 // 同价位排在本单前面的挂单量
+	hasQueue   bool    // 是否有深度数据估计过排队位置 (否则按概率成交处理)
+	cancelTime int64   // 撤单到达交易所的时间 (0 表示未撤单)
+}
+
+// UpdateOrderBook 更新交易对的 L2 深度快照，并据此修正挂单的排队位置：
+// 同价位总量减少说明有撤单或成交，前方挂单量不会超过该价位当前总量
+func (a *SimulatorAccount) UpdateOrderBook(book model.OrderBook) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.books[book.Symbol] = book
+	for _, resting := range a.restingOrders {
+		if resting.signal.Symbol != book.Symbol {
+			continue
+		}
+		queue, ok := estimateQueueAhead(book, resting.isBuy, resting.price)
+		if !ok {
+			continue
+		}
+		if !resting.hasQueue || queue < resting.queueAhead {
+			resting.queueAhead = queue
+			resting.hasQueue = true
+		}
+	}
+}
+
+// arriveLocked 订单到达交易所：市价单和可立即成交的限价单直接成交，
+// 其余开平仓限价单挂入订单簿排队 (调用方需持有写锁)
+func (a *SimulatorAccount) arriveLocked(owner *SimulatorExecutor, signal model.Signal, record *model.OrderRecord, now int64) error {
+	record.ArrivalTime = time.UnixMilli(now)
+	price := a.lastPrices[signal.Symbol]
+
+	isOrder := signal.Action == model.ActionOpen || signal.Action == model.ActionClose
+	if !isOrder || signal.OrderType != model.OrderLimit {
+		err := a.applySignalLocked(owner, signal, price)
+		a.completeOrderLocked(record, now, price, err)
+		return err
+	}
+
+	if signal.Price <= 0 {
+		err := fmt.Errorf("limit order for %s without price", signal.Symbol)
+		a.completeOrderLocked(record, now, 0, err)
+		return err
+	}
+	isBuy, err := a.orderSideLocked(signal)
+	if err != nil {
+		a.completeOrderLocked(record, now, 0, err)
+		return err
+	}
+	record.LimitPrice = signal.Price
+
+	// 可立即成交 (买价不低于卖一 / 卖价不高于买一)：按对手价成交，价格不劣于限价
+	if fillPrice, ok := a.marketableFillPrice(signal.Symbol, isBuy, signal.Price); ok {
+		err := a.applySignalLocked(owner, signal, fillPrice)
+		a.completeOrderLocked(record, now, fillPrice, err)
+		return err
+	}
+
+	resting := &restingOrder{
+		record: record,
+		owner:  owner,
+		signal: signal,
+		isBuy:  isBuy,
+		price:  signal.Price,
+	}
+	if book, ok := a.books[signal.Symbol]; ok {
+		resting.queueAhead, resting.hasQueue = estimateQueueAhead(book, isBuy, signal.Price)
+	}
+	record.QueueAhead = resting.queueAhead
+	record.Status = model.OrderLive
+	a.restingOrders = append(a.restingOrders, resting)
+
+	owner.logger.Infof("Sim LIMIT ORDER RESTING: #%d %s %s @ %.4f, queue ahead %.4f",
+		record.OrderID, signal.Action, signal.Symbol, signal.Price, resting.queueAhead)
+	return nil
+}
+
+// processRestingOrdersLocked 用一笔成交推进 ticker.Symbol 上挂单的排队位置，并撮合满足条件的挂单 (调用方需持有写锁)
+func (a *SimulatorAccount) processRestingOrdersLocked(ticker model.Ticker) {
+	if len(a.restingOrders) == 0 {
+		return
+	}
+
+	remaining := a.restingOrders[:0]
+	for _, resting := range a.restingOrders {
+		if resting.signal.Symbol != ticker.Symbol {
+			remaining = append(remaining, resting)
+			continue
+		}
+
+		// 撤单已到达交易所：挂单撤销
+		if resting.cancelTime != 0 && resting.cancelTime <= ticker.Timestamp {
+			resting.record.Status = model.OrderCanceled
+			resting.owner.logger.Infof("Sim LIMIT ORDER CANCELED: #%d %s %s @ %.4f",
+				resting.record.OrderID, resting.signal.Action, ticker.Symbol, resting.price)
+			continue
+		}
+
+		if !a.passiveFillLocked(resting, ticker) {
+			remaining = append(remaining, resting)
+			continue
+		}
+
+		err := a.applySignalLocked(resting.owner, resting.signal, resting.price)
+		a.completeOrderLocked(resting.record, ticker.Timestamp, resting.price, err)
+		if err != nil {
+			resting.owner.logger.Warnf("Sim LIMIT ORDER REJECTED on fill: #%d %s %s: %v",
+				resting.record.OrderID, resting.signal.Action, ticker.Symbol, err)
+			continue
+		}
+		resting.owner.logger.Infof("Sim LIMIT ORDER FILLED: #%d %s %s @ %.4f",
+			resting.record.OrderID, resting.signal.Action, ticker.Symbol, resting.price)
+	}
+	a.restingOrders = remaining
+}
+
+// passiveFillLocked 判断一笔成交是否使挂单成交 (会扣减排队数量)
+func (a *SimulatorAccount) passiveFillLocked(resting *restingOrder, ticker model.Ticker) bool {
+	// 价格穿过挂单价：该价位已被完全吃掉，任何模型下都成交
+	if (resting.isBuy && ticker.Price < resting.price) || (!resting.isBuy && ticker.Price > resting.price) {
+		return true
+	}
+	if ticker.Price != resting.price {
+		return false
+	}
+
+	// 价格恰好触及挂单价：只有主动方在对手侧 (主动卖出打 bid / 主动买入打 ask) 的成交才消耗本侧队列
+	// IsBuyerMaker 为 true 表示买方是 Maker，即主动卖出
+	hitsOurSide := ticker.IsBuyerMaker == resting.isBuy
+
+	switch a.cfg.PassiveFillModel {
+	case PassiveFillThrough:
+		return false
+	case PassiveFillTouch:
+		return true
+	case PassiveFillProbability:
+		return hitsOurSide && a.randomFillLocked(ticker)
+	default: // PassiveFillQueue
+		if !hitsOurSide {
+			return false
+		}
+		if !resting.hasQueue {
+			// 只有成交数据、没有深度时无法估计排队位置，退化为概率成交
+			return a.randomFillLocked(ticker)
+		}
+		resting.queueAhead -= ticker.Volume
+		return resting.queueAhead < 0
+	}
+}
+
+// randomFillLocked 概率成交模型：每笔触及挂单价的成交以 PassiveFillProbability 的概率使挂单成交
+func (a *SimulatorAccount) randomFillLocked(ticker model.Ticker) bool {
+	if ticker.Volume <= 0 {
+		return false // 价格快照不代表真实成交
+	}
+	chance := a.cfg.PassiveFillProbability
+	if chance <= 0 {
+		chance = defaultPassiveFillChance
+	}
+	return a.fillRng.Float64() < chance
+}
+
+// marketableFillPrice 判断限价单到达时能否立即成交，返回成交价 (有深度时取对手一档，否则取最新价)
+func (a *SimulatorAccount) marketableFillPrice(symbol string, isBuy bool, limit float64) (float64, bool) {
+	market := a.lastPrices[symbol]
+	if book, ok := a.books[symbol]; ok {
+		if isBuy && len(book.Asks) > 0 {
+			market = book.Asks[0].Price
+		} else if !isBuy && len(book.Bids) > 0 {
+			market = book.Bids[0].Price
+		}
+	}
+	if market <= 0 {
+		return 0, false
+	}
+	if isBuy && limit >= market {
+		return market, true
+	}
+	if !isBuy && limit <= market {
+		return market, true
+	}
+	return 0, false
+}
+
+// orderSideLocked 返回订单的买卖方向：开多/平空为买，开空/平多为卖
+func (a *SimulatorAccount) orderSideLocked(signal model.Signal) (bool, error) {
+	if signal.Action == model.ActionOpen {
+		return signal.Direction == model.DirLong, nil
+	}
+
+	key, err := a.targetKey(signal)
+	if err != nil {
+		return false, err
+	}
+	pos, ok := a.positions[key]
+	if !ok {
+		return false, fmt.Errorf("no open position for %s/%s", key.Symbol, key.PosSide)
+	}
+	return pos.Side == model.DirShort, nil
+}
+
+// estimateQueueAhead 根据深度快照估计挂在 price 上的新订单前方的排队数量。
+// 价格优于本侧最优价 (挂在买卖价差之内) 时排在第一位；价位不在快照档位内时无法估计
+func estimateQueueAhead(book model.OrderBook, isBuy bool, price float64) (float64, bool) {
+	levels := book.Asks
+	if isBuy {
+		levels = book.Bids
+	}
+	if len(levels) == 0 {
+		return 0, false
+	}
+
+	best := levels[0].Price
+	if (isBuy && price > best) || (!isBuy && price < best) {
+		return 0, true
+	}
+	for _, level := range levels {
+		if level.Price == price {
+			return level.Size, true
+		}
+	}
+	return 0, false
+}
+
+// GetOpenOrders 实现 OrderLister 接口：返回本实例尚未完成的订单 (在途订单和仍挂在订单簿上的限价单)
+func (e *SimulatorExecutor) GetOpenOrders() []*model.OrderRecord {
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
+
+	records := make([]*model.OrderRecord, 0, len(e.account.pendingOrders)+len(e.account.restingOrders))
+	for _, pending := range e.account.pendingOrders {
+		if pending.owner == e {
+			records = append(records, pending.record)
+		}
+	}
+	for _, resting := range e.account.restingOrders {
+		if resting.owner == e {
+			records = append(records, resting.record)
+		}
+	}
+	return records
+}
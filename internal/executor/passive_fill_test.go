@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"testing"
+)
+
+func TestPassiveFillLocked(t *testing.T) {
+	// 挂单价 100，前方排队 5；IsBuyerMaker 为 true 表示主动卖出 (打 bid 侧)
+	tests := []struct {
+		name      string
+		model     string
+		isBuy     bool
+		hasQueue  bool
+		ticker    model.Ticker
+		wantFill  bool
+		wantAhead float64
+	}{
+		{"queue consumed by sells", PassiveFillQueue, true, true, model.Ticker{Price: 100, Volume: 3, IsBuyerMaker: true}, false, 2},
+		{"queue exactly consumed", PassiveFillQueue, true, true, model.Ticker{Price: 100, Volume: 5, IsBuyerMaker: true}, false, 0},
+		{"queue passed", PassiveFillQueue, true, true, model.Ticker{Price: 100, Volume: 6, IsBuyerMaker: true}, true, -1},
+		{"buys do not consume the bid queue", PassiveFillQueue, true, true, model.Ticker{Price: 100, Volume: 6}, false, 5},
+		{"ask queue consumed by buys", PassiveFillQueue, false, true, model.Ticker{Price: 100, Volume: 6}, true, -1},
+		{"price through the bid", PassiveFillQueue, true, true, model.Ticker{Price: 99.5, Volume: 0.1}, true, 5},
+		{"price away from the bid", PassiveFillQueue, true, true, model.Ticker{Price: 100.5, Volume: 9, IsBuyerMaker: true}, false, 5},
+		{"no depth falls back to probability", PassiveFillQueue, true, false, model.Ticker{Price: 100, Volume: 1, IsBuyerMaker: true}, true, 5},
+		{"no depth ignores snapshots", PassiveFillQueue, true, false, model.Ticker{Price: 100, IsBuyerMaker: true}, false, 5},
+		{"touch", PassiveFillTouch, true, true, model.Ticker{Price: 100, Volume: 0.1}, true, 5},
+		{"through needs a better price", PassiveFillThrough, true, true, model.Ticker{Price: 100, Volume: 9, IsBuyerMaker: true}, false, 5},
+		{"probabilistic", PassiveFillProbability, true, true, model.Ticker{Price: 100, Volume: 1, IsBuyerMaker: true}, true, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{PassiveFillModel: tt.model, PassiveFillProbability: 1})
+			resting := &restingOrder{isBuy: tt.isBuy, price: 100, queueAhead: 5, hasQueue: tt.hasQueue}
+			if got := account.passiveFillLocked(resting, tt.ticker); got != tt.wantFill {
+				t.Errorf("fill = %v, want %v", got, tt.wantFill)
+			}
+			if resting.queueAhead != tt.wantAhead {
+				t.Errorf("queue ahead %.2f, want %.2f", resting.queueAhead, tt.wantAhead)
+			}
+		})
+	}
+}
+
+func TestSimulatorLimitOrderQueue(t *testing.T) {
+	account := newTestAccount(SimulatorConfig{})
+	e := newTestSim(account, "BTCUSDT", 0)
+	book := func(bid float64) model.OrderBook {
+		return model.OrderBook{
+			Symbol: "BTCUSDT",
+			Bids:   []model.OrderBookLevel{{Price: 100, Size: bid}, {Price: 99, Size: 10}},
+			Asks:   []model.OrderBookLevel{{Price: 101, Size: 10}},
+		}
+	}
+	sell := func(ts int64, volume float64) {
+		e.OnTicker(model.Ticker{Symbol: "BTCUSDT", Timestamp: ts, Price: 100, Volume: volume, IsBuyerMaker: true})
+	}
+
+	tick(e, "BTCUSDT", 1, 100.5)
+	account.UpdateOrderBook(book(5))
+	signal := openSignal(model.DirLong, "", 1)
+	signal.OrderType, signal.Price = model.OrderLimit, 100
+	if err := e.ExecuteSignal(context.Background(), signal); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	record := e.GetOpenOrders()[0]
+	if record.Status != model.OrderLive || record.QueueAhead != 5 {
+		t.Fatalf("order %s with %.2f ahead, want LIVE behind 5", record.Status, record.QueueAhead)
+	}
+
+	// 成交消耗前方队列；深度减少 (前方撤单) 时排队位置前移，但深度增加不会让位置后退
+	steps := []struct {
+		sell      float64 // 主动卖出的成交量 (0 表示改为推送深度)
+		bid       float64 // 推送的 100 价位总量
+		wantAhead float64
+	}{
+		{sell: 2, wantAhead: 3},
+		{bid: 8, wantAhead: 3},
+		{bid: 1, wantAhead: 1},
+		{sell: 1, wantAhead: 0},
+	}
+	resting := account.restingOrders[0]
+	for i, step := range steps {
+		if step.sell > 0 {
+			sell(int64(10+i), step.sell)
+		} else {
+			account.UpdateOrderBook(book(step.bid))
+		}
+		if resting.queueAhead != step.wantAhead {
+			t.Fatalf("step %d: %.2f ahead, want %.2f", i, resting.queueAhead, step.wantAhead)
+		}
+	}
+	if len(account.positions) != 0 {
+		t.Fatalf("filled while still at the front of the queue")
+	}
+
+	sell(20, 0.5)
+	if record.Status != model.OrderFilled || record.FillPrice != 100 || len(account.positions) != 1 {
+		t.Errorf("order %s @ %.2f with %d positions, want FILLED @ 100", record.Status, record.FillPrice, len(account.positions))
+	}
+}
@@ -0,0 +1,919 @@
+package executor
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 保证金模式，与 Okx 的 tdMode 一致
+const (
+	MarginModeIsolated = "isolated" // 逐仓：每条持仓腿独立计算强平价 (默认)
+	MarginModeCross    = "cross"    // 全仓：所有持仓共享账户权益，按账户保证金率强平
+)
+
+// DefaultMaintenanceMarginRate 未配置时使用的维持保证金率 (Okx BTC 永续第一档约 0.4%)
+const DefaultMaintenanceMarginRate = 0.004
+
+// AccountSummary 模拟账户的合并视图 (所有交易实例共享)
+type AccountSummary struct {
+	Balance           float64            // 账户余额 (包含已实现盈亏)
+	Equity            float64            // 账户净值 = 余额 + 浮动盈亏
+	UPL               float64            // 全部持仓的浮动盈亏
+	MaxEquity         float64            // 历史最高净值
+	MarginUsed        float64            // 已用保证金
+	AvailableBalance  float64            // 可用余额 = 余额 - 已用保证金
+	MaintenanceMargin float64            // 维持保证金
+	MarginRatio       float64            // 账户保证金率 = 净值 / 维持保证金 (无持仓时为 0)
+	Positions         model.Positions    // 全部持仓腿
+	Prices            map[string]float64 // 各交易对的最新价格
+}
+
+// SimulatorAccount 模拟的交易所账户：余额、持仓和交易记录在多个交易实例之间共享。
+// 每个交易实例通过自己的 SimulatorExecutor 下单，账户负责保证金、强平和净值的统一计算。
+type SimulatorAccount struct {
+	cfg    *SimulatorConfig
+	logger *zap.SugaredLogger
+
+	mu sync.RWMutex // 保护账户状态
+
+	// 账户状态 (接近交易所的资产视图)
+	balance   float64 // 账户余额 (包含已实现盈亏)
+	equity    float64 // 账户净值 = 余额 + 浮动盈亏
+	maxEquity float64 // 历史最高账户净值
+
+	lastPrices map[string]float64 // 各交易对实时更新的最新价格
+	clock      service.Clock      // 账户时钟 (默认由 Ticker 时间戳推进的事件时钟)
+
+	// 持仓状态：按 (Symbol, PosSide) 区分的持仓腿
+	positions map[positionKey]*SimulatorPosition
+
+	tradeHistory      []*model.TradeRecord      // 存储所有已平仓的交易记录
+	stopUpdateHistory []*model.StopUpdateRecord // 存储所有止损/止盈修改记录
+	orderHistory      []*model.OrderRecord      // 存储所有订单的延迟/成交记录
+	fills             map[string][]model.Fill   // 各交易对尚未被执行器取走的成交 (DrainFills)
+
+	// 订单链路延迟 (为 nil 时信号立即按最新价成交)
+	latency       LatencyModel
+	pendingOrders []*pendingOrder // 已发出但尚未到达交易所的订单
+	nextOrderID   int64
+
+	// 被动 (限价) 订单撮合
+	restingOrders []*restingOrder            // 已挂入订单簿、等待成交的限价单
+	books         map[string]model.OrderBook // 各交易对最新的 L2 深度快照
+	fillRng       *rand.Rand                 // 概率成交模型的随机源 (固定种子)
+}
+
+// pendingOrder 已发出、尚未到达交易所的订单 (时间均为事件时钟毫秒)
+type pendingOrder struct {
+	record      *model.OrderRecord
+	owner       *SimulatorExecutor
+	signal      model.Signal
+	arrivalTime int64 // 订单到达交易所的时间
+	cancelTime  int64 // 撤单到达交易所的时间 (0 表示未撤单)
+}
+
+// NewSimulatorAccount 创建一个共享的模拟账户
+func NewSimulatorAccount(cfg *SimulatorConfig, logger *zap.SugaredLogger) *SimulatorAccount {
+	if cfg.PositionMode == "" {
+		cfg.PositionMode = model.PosModeNet
+	}
+	if cfg.MarginMode == "" {
+		cfg.MarginMode = MarginModeIsolated
+	}
+	if cfg.MaintenanceMarginRate <= 0 {
+		cfg.MaintenanceMarginRate = DefaultMaintenanceMarginRate
+	}
+	if cfg.PassiveFillModel == "" {
+		cfg.PassiveFillModel = PassiveFillQueue
+	}
+
+	return &SimulatorAccount{
+		cfg:        cfg,
+		logger:     logger,
+		balance:    cfg.InitialCapital,
+		equity:     cfg.InitialCapital,
+		maxEquity:  cfg.InitialCapital, // <-- 初始化时，最大净值 = 初始资金
+		lastPrices: make(map[string]float64),
+		positions:  make(map[positionKey]*SimulatorPosition), // 初始空仓
+		books:      make(map[string]model.OrderBook),
+		fills:      make(map[string][]model.Fill),
+		fillRng:    rand.New(rand.NewSource(cfg.PassiveFillSeed)),
+		clock:      service.NewSimulatedClock(time.UnixMilli(0)),
+	}
+}
+
+// SetClock 设置账户时钟 (订单提交、开仓和止损修改的时间戳取自该时钟)。
+// 事件时钟会被每条 Ticker 推进，回测时可与其他组件共享同一个事件时钟
+func (a *SimulatorAccount) SetClock(clock service.Clock) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clock = clock
+}
+
+// nowMillis 账户时钟的当前时间 (毫秒)
+func (a *SimulatorAccount) nowMillis() int64 {
+	return a.clock.Now().UnixMilli()
+}
+
+// SetLatencyModel 设置订单链路的延迟模型 (nil 表示立即成交)
+func (a *SimulatorAccount) SetLatencyModel(latency LatencyModel) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.latency = latency
+}
+
+// GetSummary 返回账户的合并余额/净值/浮动盈亏视图
+func (a *SimulatorAccount) GetSummary() AccountSummary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	summary := AccountSummary{
+		Balance:           a.balance,
+		Equity:            a.equity,
+		UPL:               a.equity - a.balance,
+		MaxEquity:         a.maxEquity,
+		MarginUsed:        a.marginUsed(""),
+		AvailableBalance:  a.availableBalance(),
+		MaintenanceMargin: a.maintenanceMargin(),
+		Positions:         a.positionsView(""),
+		Prices:            make(map[string]float64, len(a.lastPrices)),
+	}
+	for symbol, price := range a.lastPrices {
+		summary.Prices[symbol] = price
+	}
+	if summary.MaintenanceMargin > 0 {
+		summary.MarginRatio = a.equity / summary.MaintenanceMargin
+	}
+	return summary
+}
+
+// GetTradeHistory 返回账户内所有交易对的已平仓交易记录
+func (a *SimulatorAccount) GetTradeHistory() []*model.TradeRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	records := make([]*model.TradeRecord, len(a.tradeHistory))
+	copy(records, a.tradeHistory)
+	return records
+}
+
+// onTicker 处理一条 Ticker：更新价格和净值，并检查该交易对持仓的止损/止盈/强平 (调用方需持有写锁)
+func (a *SimulatorAccount) onTicker(ticker model.Ticker, logger *zap.SugaredLogger) {
+	currentPrice := ticker.Price
+	a.lastPrices[ticker.Symbol] = currentPrice                      // 维护最新的价格供 ExecuteSignal 使用
+	service.AdvanceClock(a.clock, time.UnixMilli(ticker.Timestamp)) // 用 Ticker 时间推进事件时钟
+
+	// 0. 撮合已到达交易所的在途订单 (按到达时的价格成交)，再用本笔成交推进挂单的排队位置
+	a.processPendingOrdersLocked(ticker.Symbol, ticker.Timestamp)
+	a.processRestingOrdersLocked(ticker)
+
+	// 1. 计算浮动盈亏并更新当前净值 a.equity
+	a.updateEquity()
+
+	// 2. 实时更新最大净值 (Max Equity) <-- 关键步骤
+	if a.equity > a.maxEquity {
+		a.maxEquity = a.equity
+	}
+
+	// 3. 逐条持仓腿检查止损/止盈/强平 (每条腿有独立的 SL/TP)
+	for _, key := range a.sortedKeys() {
+		if key.Symbol != ticker.Symbol {
+			continue
+		}
+		pos := a.positions[key]
+
+		// 记录持仓期间的极值价格 (供吊灯止损等策略参考)
+		pos.HighestPrice = math.Max(pos.HighestPrice, currentPrice)
+		pos.LowestPrice = math.Min(pos.LowestPrice, currentPrice)
+
+		isSLTriggered := a.checkStopLoss(pos, currentPrice)
+		isTPTriggered := a.checkTakeProfit(pos, currentPrice)
+		isLiqTriggered := a.cfg.MarginMode != MarginModeCross && a.checkLiquidation(pos, currentPrice)
+
+		if isSLTriggered || isTPTriggered || isLiqTriggered {
+			// 构造交易记录
+			triggerType := "Manual Close"
+			if isTPTriggered {
+				triggerType = "TAKE PROFIT"
+			}
+			if isLiqTriggered {
+				triggerType = "LIQUIDATION"
+			}
+			if isSLTriggered {
+				triggerType = "SL"
+			}
+
+			a.closePositionLocked(key, currentPrice, 0, ticker.Timestamp, triggerType, logger)
+			a.updateEquity()
+
+			logger.Infof("Sim CLOSE TRIGGERED: [%s] %s @ %.4f. New Balance: %.4f. Equity: %.4f",
+				triggerType, key.PosSide, currentPrice, a.balance, a.equity)
+		} else {
+			// 检查分批止盈阶梯
+			a.checkTakeProfitLevels(key, currentPrice, ticker.Timestamp, logger)
+		}
+	}
+
+	// 4. 全仓模式：账户净值低于维持保证金时强平全部持仓
+	if a.cfg.MarginMode == MarginModeCross {
+		a.checkCrossLiquidation(ticker.Timestamp)
+	}
+}
+
+// submitSignalLocked 提交一个信号：无延迟模型时立即执行，否则进入在途队列，
+// 等到事件时钟越过到达时间后才在下一条 Ticker 上按当时的价格成交 (调用方需持有写锁)
+func (a *SimulatorAccount) submitSignalLocked(owner *SimulatorExecutor, signal model.Signal) error {
+	a.nextOrderID++
+	record := &model.OrderRecord{
+		OrderID:       a.nextOrderID,
+		ClientOrderID: signal.ClientOrderID,
+		Symbol:        signal.Symbol,
+		Action:        signal.Action,
+		OrderType:     signal.OrderType,
+		Direction:     signal.Direction,
+		PosSide:       signal.PosSide,
+		SignalPrice:   a.lastPrices[signal.Symbol],
+		SubmitTime:    time.UnixMilli(a.nowMillis()),
+		Reason:        signal.Reason,
+	}
+	a.orderHistory = append(a.orderHistory, record)
+
+	submitDelay := time.Duration(0)
+	if a.latency != nil {
+		submitDelay = a.latency.Sample(LatencySubmit)
+	}
+	if submitDelay <= 0 {
+		return a.arriveLocked(owner, signal, record, a.nowMillis())
+	}
+
+	// 同一持仓腿已有在途开仓单时拒绝重复开仓 (客户端风控，加仓单除外)
+	if signal.Action == model.ActionOpen && !signal.AddToPosition {
+		key := a.openKey(signal)
+		for _, pending := range a.pendingOrders {
+			if pending.signal.Action == model.ActionOpen && a.openKey(pending.signal) == key {
+				err := fmt.Errorf("open order for %s/%s already in flight", key.Symbol, key.PosSide)
+				a.completeOrderLocked(record, a.nowMillis(), 0, err)
+				return err
+			}
+		}
+	}
+
+	a.pendingOrders = append(a.pendingOrders, &pendingOrder{
+		record:      record,
+		owner:       owner,
+		signal:      signal,
+		arrivalTime: a.nowMillis() + submitDelay.Milliseconds(),
+	})
+	owner.logger.Debugf("Sim ORDER SUBMITTED: #%d %s %s, arrives in %s", record.OrderID, signal.Action, signal.Symbol, submitDelay)
+
+	return nil
+}
+
+// cancelPendingOrdersLocked 为 owner 的在途订单和挂单发出撤单 (clientOrderID 非空时只撤该订单)，撤单同样受延迟影响：
+// 撤单晚于订单到达 (或挂单先成交) 时，订单仍会成交 (调用方需持有写锁)
+func (a *SimulatorAccount) cancelPendingOrdersLocked(owner *SimulatorExecutor, clientOrderID string) int {
+	count := 0
+	for _, pending := range a.pendingOrders {
+		if pending.owner != owner || pending.cancelTime != 0 {
+			continue
+		}
+		if clientOrderID != "" && pending.signal.ClientOrderID != clientOrderID {
+			continue
+		}
+		pending.cancelTime = a.nowMillis() + a.sampleLatency(LatencyCancel).Milliseconds()
+		count++
+	}
+	for _, resting := range a.restingOrders {
+		if resting.owner != owner || resting.cancelTime != 0 {
+			continue
+		}
+		if clientOrderID != "" && resting.signal.ClientOrderID != clientOrderID {
+			continue
+		}
+		resting.cancelTime = a.nowMillis() + a.sampleLatency(LatencyCancel).Milliseconds()
+		count++
+	}
+	return count
+}
+
+// sampleLatency 抽取一个延迟样本，未配置延迟模型时为 0
+func (a *SimulatorAccount) sampleLatency(kind LatencyKind) time.Duration {
+	if a.latency == nil {
+		return 0
+	}
+	return a.latency.Sample(kind)
+}
+
+// processPendingOrdersLocked 撮合 symbol 上所有到达时间 <= now 的在途订单 (按到达顺序)
+func (a *SimulatorAccount) processPendingOrdersLocked(symbol string, now int64) {
+	if len(a.pendingOrders) == 0 {
+		return
+	}
+
+	sort.SliceStable(a.pendingOrders, func(i, j int) bool {
+		return a.pendingOrders[i].arrivalTime < a.pendingOrders[j].arrivalTime
+	})
+
+	remaining := a.pendingOrders[:0]
+	for _, pending := range a.pendingOrders {
+		if pending.signal.Symbol != symbol || pending.arrivalTime > now {
+			remaining = append(remaining, pending)
+			continue
+		}
+
+		// 撤单先于订单到达：订单作废
+		if pending.cancelTime != 0 && pending.cancelTime <= pending.arrivalTime {
+			pending.record.Status = model.OrderCanceled
+			pending.record.ArrivalTime = time.UnixMilli(pending.arrivalTime)
+			pending.owner.logger.Infof("Sim ORDER CANCELED: #%d %s %s", pending.record.OrderID, pending.signal.Action, symbol)
+			continue
+		}
+
+		err := a.arriveLocked(pending.owner, pending.signal, pending.record, now)
+		if err != nil {
+			pending.owner.logger.Warnf("Sim ORDER REJECTED on arrival: #%d %s %s: %v", pending.record.OrderID, pending.signal.Action, symbol, err)
+		}
+	}
+	a.pendingOrders = remaining
+}
+
+// completeOrderLocked 记录订单成交/回报时间和最终状态 (fillTime 为成交或拒绝发生的时间)
+func (a *SimulatorAccount) completeOrderLocked(record *model.OrderRecord, fillTime int64, fillPrice float64, err error) {
+	if record.ArrivalTime.IsZero() {
+		record.ArrivalTime = time.UnixMilli(fillTime)
+	}
+	record.AckTime = time.UnixMilli(fillTime).Add(a.sampleLatency(LatencyAck))
+	record.FillPrice = fillPrice
+	record.Status = model.OrderFilled
+	if err != nil {
+		record.Status = model.OrderRejected
+		record.Reason = err.Error()
+	}
+}
+
+// applySignalLocked 在交易所侧以成交价 price 执行信号 (开仓、平仓、修改止盈止损) (调用方需持有写锁)
+func (a *SimulatorAccount) applySignalLocked(owner *SimulatorExecutor, signal model.Signal, price float64) error {
+	if signal.Action == model.ActionOpen {
+		// ... (开仓逻辑：计算保证金、手续费、强平价，并新增持仓腿)
+		if err := a.openPositionLocked(owner, signal, price); err != nil {
+			return err
+		}
+
+	} else if signal.Action == model.ActionClose {
+		// 平仓逻辑：PositionSize 为 0 表示平掉该腿全部持仓
+		key, err := a.targetKey(signal)
+		if err != nil {
+			return err
+		}
+		if price <= 0 {
+			return fmt.Errorf("no market price for %s", key.Symbol)
+		}
+		n := len(a.fills[key.Symbol])
+		a.closePositionLocked(key, price, signal.PositionSize, a.nowMillis(), "Signal", owner.logger)
+		if n < len(a.fills[key.Symbol]) {
+			a.fills[key.Symbol][n].ClientOrderID = signal.ClientOrderID
+		}
+
+	} else if signal.Action == model.ActionUpdate {
+		key, err := a.targetKey(signal)
+		if err != nil {
+			return err
+		}
+		a.updateStopsLocked(key, signal, owner.logger)
+	}
+
+	// 每次操作后更新净值
+	a.updateEquity()
+
+	return nil
+}
+
+// checkCrossLiquidation 全仓模式下的账户级强平：净值 <= 维持保证金 (保证金率 <= 100%) 时按最新价平掉全部持仓
+func (a *SimulatorAccount) checkCrossLiquidation(timestamp int64) {
+	mm := a.maintenanceMargin()
+	if mm == 0 || a.equity > mm {
+		return
+	}
+
+	a.logger.Warnf("Sim CROSS LIQUIDATION: Equity %.4f <= Maintenance Margin %.4f. Closing %d positions.",
+		a.equity, mm, len(a.positions))
+
+	for _, key := range a.sortedKeys() {
+		price, ok := a.lastPrices[key.Symbol]
+		if !ok {
+			price = a.positions[key].AvgPrice
+		}
+		a.closePositionLocked(key, price, 0, timestamp, "LIQUIDATION", a.logger)
+	}
+	a.updateEquity()
+}
+
+// openPositionLocked 以成交价 currentPrice 为实例 owner 开一条新的持仓腿 (调用方需持有写锁)
+func (a *SimulatorAccount) openPositionLocked(owner *SimulatorExecutor, signal model.Signal, currentPrice float64) error {
+	if currentPrice <= 0 {
+		return fmt.Errorf("no market price for %s", signal.Symbol)
+	}
+	if signal.PositionSize <= 0 {
+		return fmt.Errorf("invalid position size %.8f for %s", signal.PositionSize, signal.Symbol)
+	}
+
+	key := a.openKey(signal)
+	existing, exists := a.positions[key]
+	if exists && (!signal.AddToPosition || existing.Side != signal.Direction) {
+		owner.logger.Infof("Sim Rejected: %s %s leg already open (%s %.4f)", key.Symbol, key.PosSide, existing.Side, existing.Size)
+		return fmt.Errorf("position leg %s/%s already open", key.Symbol, key.PosSide)
+	}
+
+	requiredMargin := signal.PositionSize * currentPrice / owner.leverage
+	if a.availableBalance() < requiredMargin {
+		owner.logger.Infof("Sim Rejected: Insufficient balance. Need: %.2f, Have: %.2f", requiredMargin, a.availableBalance())
+		return fmt.Errorf("insufficient margin")
+	}
+
+	// 实例资金分配上限：该实例的已用保证金不能超过分配额度
+	if owner.allocation > 0 {
+		instanceMargin := a.marginUsed(owner.symbol)
+		if instanceMargin+requiredMargin > owner.allocation {
+			owner.logger.Infof("Sim Rejected: Allocation exceeded. Used: %.2f, Need: %.2f, Allocation: %.2f",
+				instanceMargin, requiredMargin, owner.allocation)
+			return fmt.Errorf("instance allocation exceeded")
+		}
+	}
+
+	// 扣除开仓手续费
+	fee := signal.PositionSize * currentPrice * a.cfg.FeeRate
+	a.balance -= fee
+
+	if exists {
+		a.addToPositionLocked(owner, existing, signal, currentPrice, requiredMargin, fee)
+		return nil
+	}
+
+	// 更新持仓状态
+	pos := &SimulatorPosition{
+		Symbol:           signal.Symbol,
+		Side:             signal.Direction,
+		Size:             signal.PositionSize,
+		InitialSize:      signal.PositionSize,
+		AvgPrice:         currentPrice,
+		Margin:           requiredMargin,
+		StopLossPrice:    signal.StopLossPrice,
+		TakeProfitPrice:  signal.TakeProfitPrice,
+		InitialStopLoss:  signal.StopLossPrice,
+		TakeProfitLevels: append([]model.TakeProfitLevel(nil), signal.TakeProfitLevels...),
+		HighestPrice:     currentPrice,
+		LowestPrice:      currentPrice,
+		EntryTime:        time.UnixMilli(a.nowMillis()), // 使用最新 Ticker 时间
+		EntryFee:         fee,                           // 记录开仓手续费
+		SourceState:      signal.SourceState,
+	}
+	// 全仓模式下没有单腿强平价，由账户保证金率统一判断
+	if a.cfg.MarginMode != MarginModeCross {
+		pos.LiquidationPrice = a.calculateLiquidationPrice(currentPrice, signal.Direction, owner.leverage)
+	}
+	a.positions[key] = pos
+	a.fills[pos.Symbol] = append(a.fills[pos.Symbol], model.Fill{
+		Time:      pos.EntryTime,
+		Symbol:    pos.Symbol,
+		Action:    model.ActionOpen,
+		Direction: pos.Side,
+		Price:     currentPrice,
+		Size:      pos.Size,
+		Fee:       fee,
+		Reason:    signal.Reason,
+
+		ClientOrderID: signal.ClientOrderID,
+	})
+
+	owner.logger.Infof("Sim ORDER FILLED (OPEN): %s %s [%s] %.4f @ %.4f. Fee: %.4f. SL: %.4f, Liq: %.4f, TP Levels: %d",
+		signal.Direction.String(), signal.Symbol, key.PosSide, signal.PositionSize, currentPrice, fee, pos.StopLossPrice, pos.LiquidationPrice, len(pos.TakeProfitLevels))
+
+	return nil
+}
+
+// addToPositionLocked 在已有持仓腿 pos 上加仓：均价按成交价加权，保证金和开仓手续费累加，
+// 信号给出的止损止盈覆盖原值 (调用方需持有写锁)
+func (a *SimulatorAccount) addToPositionLocked(owner *SimulatorExecutor, pos *SimulatorPosition, signal model.Signal, currentPrice float64, margin float64, fee float64) {
+	size := pos.Size + signal.PositionSize
+	pos.AvgPrice = (pos.AvgPrice*pos.Size + currentPrice*signal.PositionSize) / size
+	pos.Size = size
+	pos.InitialSize += signal.PositionSize
+	pos.Margin += margin
+	pos.EntryFee += fee
+	pos.HighestPrice = math.Max(pos.HighestPrice, currentPrice)
+	pos.LowestPrice = math.Min(pos.LowestPrice, currentPrice)
+	if signal.StopLossPrice > 0 {
+		pos.StopLossPrice = signal.StopLossPrice
+	}
+	if signal.TakeProfitPrice > 0 {
+		pos.TakeProfitPrice = signal.TakeProfitPrice
+	}
+	if a.cfg.MarginMode != MarginModeCross {
+		pos.LiquidationPrice = a.calculateLiquidationPrice(pos.AvgPrice, pos.Side, owner.leverage)
+	}
+	a.fills[pos.Symbol] = append(a.fills[pos.Symbol], model.Fill{
+		Time:      time.UnixMilli(a.nowMillis()),
+		Symbol:    pos.Symbol,
+		Action:    model.ActionOpen,
+		Direction: pos.Side,
+		Price:     currentPrice,
+		Size:      signal.PositionSize,
+		Fee:       fee,
+		Reason:    signal.Reason,
+
+		ClientOrderID: signal.ClientOrderID,
+	})
+
+	owner.logger.Infof("Sim ORDER FILLED (ADD): %s %s %.4f @ %.4f. Fee: %.4f. Size: %.4f, Avg: %.4f, Liq: %.4f",
+		pos.Side.String(), pos.Symbol, signal.PositionSize, currentPrice, fee, pos.Size, pos.AvgPrice, pos.LiquidationPrice)
+}
+
+// openKey 计算开仓信号对应的持仓腿：单向模式固定为 net，双向模式取 PosSide (为空时取 Direction)
+func (a *SimulatorAccount) openKey(signal model.Signal) positionKey {
+	if a.cfg.PositionMode != model.PosModeLongShort {
+		return positionKey{Symbol: signal.Symbol, PosSide: model.PosSideNet}
+	}
+	side := signal.PosSide
+	if side == "" {
+		side = signal.Direction
+	}
+	return positionKey{Symbol: signal.Symbol, PosSide: side}
+}
+
+// targetKey 查找 CLOSE/UPDATE 信号指向的持仓腿。
+// 双向模式下 PosSide 为空且同时存在多空两条腿时，信号无法确定目标，返回错误。
+func (a *SimulatorAccount) targetKey(signal model.Signal) (positionKey, error) {
+	if a.cfg.PositionMode != model.PosModeLongShort {
+		for _, key := range a.sortedKeys() {
+			if signal.Symbol == "" || key.Symbol == signal.Symbol {
+				return key, nil
+			}
+		}
+		return positionKey{}, fmt.Errorf("no open position for %s", signal.Symbol)
+	}
+
+	var matched []positionKey
+	for _, key := range a.sortedKeys() {
+		if signal.Symbol != "" && key.Symbol != signal.Symbol {
+			continue
+		}
+		if signal.PosSide != "" && key.PosSide != signal.PosSide {
+			continue
+		}
+		matched = append(matched, key)
+	}
+	switch len(matched) {
+	case 0:
+		return positionKey{}, fmt.Errorf("no open %s position for %s", signal.PosSide, signal.Symbol)
+	case 1:
+		return matched[0], nil
+	default:
+		return positionKey{}, fmt.Errorf("ambiguous %s signal for %s: posSide required in long/short mode", signal.Action, signal.Symbol)
+	}
+}
+
+// sortedKeys 返回按 (Symbol, PosSide) 排序的持仓腿，保证遍历顺序确定
+func (a *SimulatorAccount) sortedKeys() []positionKey {
+	keys := make([]positionKey, 0, len(a.positions))
+	for key := range a.positions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Symbol != keys[j].Symbol {
+			return keys[i].Symbol < keys[j].Symbol
+		}
+		return keys[i].PosSide < keys[j].PosSide
+	})
+	return keys
+}
+
+// availableBalance 可用余额 = 余额 - 已用保证金 (全仓模式下浮动盈亏也计入可用)
+func (a *SimulatorAccount) availableBalance() float64 {
+	if a.cfg.MarginMode == MarginModeCross {
+		return a.equity - a.marginUsed("")
+	}
+	return a.balance - a.marginUsed("")
+}
+
+// marginUsed 已用保证金之和，symbol 为空时统计全部持仓
+func (a *SimulatorAccount) marginUsed(symbol string) float64 {
+	total := 0.0
+	for key, pos := range a.positions {
+		if symbol == "" || key.Symbol == symbol {
+			total += pos.Margin
+		}
+	}
+	return total
+}
+
+// maintenanceMargin 维持保证金 = Σ 持仓名义价值 * 维持保证金率
+func (a *SimulatorAccount) maintenanceMargin() float64 {
+	total := 0.0
+	for key, pos := range a.positions {
+		price, ok := a.lastPrices[key.Symbol]
+		if !ok {
+			price = pos.AvgPrice
+		}
+		total += pos.Size * price * a.cfg.MaintenanceMarginRate
+	}
+	return total
+}
+
+// positionsView 将持仓腿转换为 model.Positions，symbol 为空时返回全部
+func (a *SimulatorAccount) positionsView(symbol string) model.Positions {
+	positions := make(model.Positions, 0, len(a.positions))
+	for _, key := range a.sortedKeys() {
+		if symbol != "" && key.Symbol != symbol {
+			continue
+		}
+		pos := a.positions[key]
+		positions = append(positions, &model.Position{
+			InstID:           pos.Symbol,
+			Direction:        pos.Side,
+			Size:             pos.Size,
+			AvgPrice:         pos.AvgPrice,
+			UPL:              pos.UPL,
+			EntryTime:        pos.EntryTime,
+			SourceState:      pos.SourceState,
+			InitialSize:      pos.InitialSize,
+			StopLossPrice:    pos.StopLossPrice,
+			TakeProfitPrice:  pos.TakeProfitPrice,
+			InitialStopLoss:  pos.InitialStopLoss,
+			TakeProfitLevels: append([]model.TakeProfitLevel(nil), pos.TakeProfitLevels...),
+			HighestPrice:     pos.HighestPrice,
+			LowestPrice:      pos.LowestPrice,
+		})
+	}
+	return positions
+}
+
+// updateStopsLocked 应用 ActionUpdate 信号：修改止损、止盈和分批止盈阶梯 (调用方需持有写锁)
+func (a *SimulatorAccount) updateStopsLocked(key positionKey, signal model.Signal, logger *zap.SugaredLogger) {
+	pos := a.positions[key]
+	currentPrice := a.lastPrices[key.Symbol]
+
+	record := &model.StopUpdateRecord{
+		Time:          time.UnixMilli(a.nowMillis()),
+		Symbol:        pos.Symbol,
+		PosSide:       pos.Side,
+		MarketPrice:   currentPrice,
+		OldStopLoss:   pos.StopLossPrice,
+		NewStopLoss:   pos.StopLossPrice,
+		OldTakeProfit: pos.TakeProfitPrice,
+		NewTakeProfit: pos.TakeProfitPrice,
+		Reason:        signal.Reason,
+	}
+
+	if signal.StopLossPrice > 0 {
+		pos.StopLossPrice = signal.StopLossPrice
+		record.NewStopLoss = signal.StopLossPrice
+	}
+	if signal.TakeProfitPrice > 0 {
+		pos.TakeProfitPrice = signal.TakeProfitPrice
+		record.NewTakeProfit = signal.TakeProfitPrice
+	}
+	if signal.TakeProfitLevels != nil {
+		pos.TakeProfitLevels = append([]model.TakeProfitLevel(nil), signal.TakeProfitLevels...)
+	}
+	record.TakeProfitLevels = len(pos.TakeProfitLevels)
+
+	a.stopUpdateHistory = append(a.stopUpdateHistory, record)
+
+	logger.Infof("Sim STOPS UPDATED: %s %s SL: %.4f -> %.4f, TP: %.4f -> %.4f @ %.4f. Reason: %s",
+		pos.Side.String(), pos.Symbol, record.OldStopLoss, record.NewStopLoss, record.OldTakeProfit, record.NewTakeProfit, currentPrice, signal.Reason)
+}
+
+// closePositionLocked 以给定价格平掉持仓腿 key 的 size 数量 (size <= 0 或超过持仓时平掉全部)，
+// 记录交易、释放对应比例的保证金并更新余额 (调用方需持有写锁)
+func (a *SimulatorAccount) closePositionLocked(key positionKey, price float64, size float64, timestamp int64, reason string, logger *zap.SugaredLogger) {
+	pos, ok := a.positions[key]
+	if !ok || pos.Size == 0 {
+		return
+	}
+	if size <= 0 || size > pos.Size {
+		size = pos.Size
+	}
+	ratio := size / pos.Size
+
+	// 1. 计算平仓盈亏 (PnL) 和手续费 (开仓手续费和保证金按平仓比例分摊)
+	pnl := a.calculateClosedPnL(&SimulatorPosition{Side: pos.Side, Size: size, AvgPrice: pos.AvgPrice}, price)
+	closeFee := size * price * a.cfg.FeeRate
+	entryFee := pos.EntryFee * ratio
+	releasedMargin := pos.Margin * ratio
+
+	// 2. 构造交易记录
+	newRecord := &model.TradeRecord{
+		EntryTime:     pos.EntryTime,
+		ExitTime:      time.UnixMilli(timestamp),
+		Symbol:        pos.Symbol,
+		PosSide:       pos.Side,
+		EntryPrice:    pos.AvgPrice,
+		ExitPrice:     price,
+		Size:          size,
+		RealizedPnL:   pnl,
+		Fee:           entryFee + closeFee,
+		TriggerReason: reason,
+		SourceState:   pos.SourceState,
+	}
+	a.tradeHistory = append(a.tradeHistory, newRecord)
+	a.fills[pos.Symbol] = append(a.fills[pos.Symbol], model.Fill{
+		Time:        newRecord.ExitTime,
+		Symbol:      pos.Symbol,
+		Action:      model.ActionClose,
+		Direction:   pos.Side,
+		Price:       price,
+		Size:        size,
+		Fee:         closeFee,
+		RealizedPnL: pnl,
+		Reason:      reason,
+	})
+
+	// 3. 更新余额，释放保证金
+	// 开仓时保证金并未从余额中扣除 (余额 = 权益基准)，因此释放时只需结算盈亏和手续费
+	a.balance += pnl - closeFee
+
+	logger.Infof("Sim POSITION CLOSED: [%s] %s %s %.4f @ %.4f. Realized PnL: %.4f. New Balance: %.4f",
+		reason, pos.Side.String(), pos.Symbol, size, price, pnl, a.balance)
+
+	// 4. 部分平仓时保留剩余持仓，否则移除该持仓腿
+	if size >= pos.Size {
+		delete(a.positions, key)
+		return
+	}
+	pos.Size -= size
+	pos.EntryFee -= entryFee
+	pos.Margin -= releasedMargin
+}
+
+// checkTakeProfitLevels 依次检查持仓腿的分批止盈阶梯，触发的阶梯按初始仓位比例部分平仓
+func (a *SimulatorAccount) checkTakeProfitLevels(key positionKey, currentPrice float64, timestamp int64, logger *zap.SugaredLogger) {
+	for {
+		pos, ok := a.positions[key]
+		if !ok || len(pos.TakeProfitLevels) == 0 {
+			return
+		}
+		level := pos.TakeProfitLevels[0]
+
+		hit := (pos.Side == model.DirLong && currentPrice >= level.Price) ||
+			(pos.Side == model.DirShort && currentPrice <= level.Price)
+		if !hit {
+			return
+		}
+
+		pos.TakeProfitLevels = pos.TakeProfitLevels[1:]
+		pos.takeProfitHits++
+		reason := fmt.Sprintf("TP%d", pos.takeProfitHits)
+
+		size := pos.InitialSize * level.SizeRatio
+		if size <= 0 {
+			continue
+		}
+		// 剩余仓位不足本级数量时平掉全部 (避免浮点残留)
+		if size >= pos.Size*0.999 {
+			size = pos.Size
+		}
+		a.closePositionLocked(key, currentPrice, size, timestamp, reason, logger)
+		a.updateEquity()
+	}
+}
+
+// calculateLiquidationPrice 计算强平价格 (简化模型，使用初始保证金率)
+func (a *SimulatorAccount) calculateLiquidationPrice(avgPrice float64, side model.Direction, leverage float64) float64 {
+	if leverage <= 0 || side == model.DirFlat {
+		return 0.0
+	}
+
+	// 假设初始保证金率 = 1 / 杠杆
+	marginRatio := 1.0 / leverage
+
+	// 忽略维持保证金、穿仓保障基金等复杂因素
+
+	if side == model.DirLong {
+		// 多头强平价: 价格下跌 (亏损) 导致保证金不足
+		return avgPrice * (1.0 - marginRatio)
+	}
+
+	if side == model.DirShort {
+		// 空头强平价: 价格上涨 (亏损) 导致保证金不足
+		return avgPrice * (1.0 + marginRatio)
+	}
+
+	return 0.0
+}
+
+// calculateClosedPnL 计算已实现盈亏 (Realized PnL)
+func (a *SimulatorAccount) calculateClosedPnL(pos *SimulatorPosition, closePrice float64) float64 {
+	if pos.Size == 0 || pos.Side == model.DirFlat {
+		return 0.0
+	}
+
+	var pnl float64
+	if pos.Side == model.DirLong {
+		// 多头：平仓价高于均价则盈利
+		pnl = (closePrice - pos.AvgPrice) * pos.Size
+	} else { // Short
+		// 空头：平仓价低于均价则盈利
+		pnl = (pos.AvgPrice - closePrice) * pos.Size
+	}
+
+	return pnl
+}
+
+// updateEquity 按各交易对的最新价格计算浮动盈亏 (UPL) 并更新账户净值 (Equity)
+func (a *SimulatorAccount) updateEquity() {
+	// 空仓时，净值 = 余额 (UPL = 0)
+	totalUPL := 0.0
+	for key, pos := range a.positions {
+		currentPrice, ok := a.lastPrices[key.Symbol]
+		if !ok {
+			continue
+		}
+		// 计算浮动盈亏 (Unrealized PnL)
+		if pos.Side == model.DirLong {
+			pos.UPL = (currentPrice - pos.AvgPrice) * pos.Size
+		} else { // Short
+			pos.UPL = (pos.AvgPrice - currentPrice) * pos.Size
+		}
+		totalUPL += pos.UPL
+	}
+	// 更新账户净值 (Equity = Balance + UPL)
+	a.equity = a.balance + totalUPL
+}
+
+// checkStopLoss 检查是否触发止损
+func (a *SimulatorAccount) checkStopLoss(pos *SimulatorPosition, currentPrice float64) bool {
+	// 检查是否有持仓，且设置了止损价
+	if pos.Side == model.DirFlat || pos.StopLossPrice == 0.0 {
+		return false
+	}
+
+	if pos.Side == model.DirLong {
+		// 多头止损：当前价格 <= 止损价
+		// 价格下跌
+		return currentPrice <= pos.StopLossPrice
+	}
+
+	if pos.Side == model.DirShort {
+		// 空头止损：当前价格 >= 止损价
+		// 价格上涨
+		return currentPrice >= pos.StopLossPrice
+	}
+
+	return false
+}
+
+// checkTakeProfit 检查是否触发止盈
+func (a *SimulatorAccount) checkTakeProfit(pos *SimulatorPosition, currentPrice float64) bool {
+	// 检查是否有持仓，且设置了止盈价
+	if pos.Side == model.DirFlat || pos.TakeProfitPrice == 0.0 {
+		return false
+	}
+
+	if pos.Side == model.DirLong {
+		// 多头止盈：当前价格 >= 止盈价
+		// 价格上涨
+		return currentPrice >= pos.TakeProfitPrice
+	}
+
+	if pos.Side == model.DirShort {
+		// 空头止盈：当前价格 <= 止盈价
+		// 价格下跌
+		return currentPrice <= pos.TakeProfitPrice
+	}
+
+	return false
+}
+
+// checkLiquidation 检查是否触发强平 (逐仓模式)
+func (a *SimulatorAccount) checkLiquidation(pos *SimulatorPosition, currentPrice float64) bool {
+	// 强平价为 0.0 通常意味着没有开仓，或使用了 1 倍杠杆 (实际上 1 倍杠杆不会被强平)
+	if pos.Side == model.DirFlat || pos.LiquidationPrice == 0.0 {
+		return false
+	}
+
+	// 注意：强平价通常比止损价更接近开仓价 (即风险更大)
+
+	if pos.Side == model.DirLong {
+		// 多头强平：当前价格 <= 强平价
+		// 价格下跌
+		return currentPrice <= pos.LiquidationPrice
+	}
+
+	if pos.Side == model.DirShort {
+		// 空头强平：当前价格 >= 强平价
+		// 价格上涨
+		return currentPrice >= pos.LiquidationPrice
+	}
+
+	return false
+}
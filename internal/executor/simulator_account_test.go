@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"context"
+	"crypto-algo-trader/internal/model"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestAccount 创建初始资金 10000、10 倍杠杆、无手续费的模拟账户
+func newTestAccount(cfg SimulatorConfig) *SimulatorAccount {
+	cfg.InitialCapital, cfg.Leverage = 10000, 10
+	return NewSimulatorAccount(&cfg, zap.NewNop().Sugar())
+}
+
+// newTestSim 在 account 上为 symbol 创建执行器 (账户默认杠杆)
+func newTestSim(account *SimulatorAccount, symbol string, allocation float64) *SimulatorExecutor {
+	return NewSharedSimulatorExecutor(account, symbol, 0, allocation, nil, zap.NewNop().Sugar())
+}
+
+// tick 推送一笔 symbol 在 ts 毫秒的价格快照
+func tick(e *SimulatorExecutor, symbol string, ts int64, price float64) {
+	e.OnTicker(model.Ticker{Symbol: symbol, Timestamp: ts, Price: price})
+}
+
+// openSignal BTCUSDT 的市价开仓信号
+func openSignal(direction, posSide model.Direction, size float64) model.Signal {
+	return model.Signal{Action: model.ActionOpen, Symbol: "BTCUSDT", Direction: direction, PosSide: posSide, PositionSize: size}
+}
+
+func TestSimulatorOpenLegKeying(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     model.PositionMode
+		signals  []model.Signal
+		wantErrs []bool
+		wantLegs map[model.Direction]model.Direction // 持仓腿 PosSide -> 方向
+	}{
+		{
+			name:     "hedge long and short legs",
+			mode:     model.PosModeLongShort,
+			signals:  []model.Signal{openSignal(model.DirLong, model.DirLong, 1), openSignal(model.DirShort, model.DirShort, 1)},
+			wantErrs: []bool{false, false},
+			wantLegs: map[model.Direction]model.Direction{model.DirLong: model.DirLong, model.DirShort: model.DirShort},
+		},
+		{
+			name:     "hedge posSide defaults to direction",
+			mode:     model.PosModeLongShort,
+			signals:  []model.Signal{openSignal(model.DirShort, "", 1)},
+			wantErrs: []bool{false},
+			wantLegs: map[model.Direction]model.Direction{model.DirShort: model.DirShort},
+		},
+		{
+			name:     "hedge second open on the same leg",
+			mode:     model.PosModeLongShort,
+			signals:  []model.Signal{openSignal(model.DirLong, model.DirLong, 1), openSignal(model.DirLong, "", 1)},
+			wantErrs: []bool{false, true},
+			wantLegs: map[model.Direction]model.Direction{model.DirLong: model.DirLong},
+		},
+		{
+			name:     "net mode keys every open to the net leg",
+			mode:     model.PosModeNet,
+			signals:  []model.Signal{openSignal(model.DirLong, model.DirLong, 1), openSignal(model.DirShort, model.DirShort, 1)},
+			wantErrs: []bool{false, true},
+			wantLegs: map[model.Direction]model.Direction{model.PosSideNet: model.DirLong},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{PositionMode: tt.mode})
+			e := newTestSim(account, "BTCUSDT", 0)
+			tick(e, "BTCUSDT", 1, 100)
+			for i, signal := range tt.signals {
+				err := e.ExecuteSignal(context.Background(), signal)
+				if (err != nil) != tt.wantErrs[i] {
+					t.Fatalf("signal %d: err = %v, want error %v", i, err, tt.wantErrs[i])
+				}
+			}
+			if len(account.positions) != len(tt.wantLegs) {
+				t.Fatalf("%d legs, want %d", len(account.positions), len(tt.wantLegs))
+			}
+			for posSide, side := range tt.wantLegs {
+				pos, ok := account.positions[positionKey{Symbol: "BTCUSDT", PosSide: posSide}]
+				if !ok || pos.Side != side {
+					t.Errorf("leg %s: %+v, want a %s position", posSide, pos, side)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulatorTargetKey(t *testing.T) {
+	btcLong := positionKey{Symbol: "BTCUSDT", PosSide: model.DirLong}
+	btcShort := positionKey{Symbol: "BTCUSDT", PosSide: model.DirShort}
+	btcNet := positionKey{Symbol: "BTCUSDT", PosSide: model.PosSideNet}
+	ethNet := positionKey{Symbol: "ETHUSDT", PosSide: model.PosSideNet}
+
+	tests := []struct {
+		name    string
+		mode    model.PositionMode
+		legs    []positionKey
+		signal  model.Signal
+		want    positionKey
+		wantErr bool
+	}{
+		{"net by symbol", model.PosModeNet, []positionKey{btcNet, ethNet}, model.Signal{Symbol: "ETHUSDT"}, ethNet, false},
+		{"net ignores posSide", model.PosModeNet, []positionKey{btcNet}, model.Signal{Symbol: "BTCUSDT", PosSide: model.DirShort}, btcNet, false},
+		{"net without symbol", model.PosModeNet, []positionKey{ethNet, btcNet}, model.Signal{}, btcNet, false},
+		{"net no position", model.PosModeNet, []positionKey{ethNet}, model.Signal{Symbol: "BTCUSDT"}, positionKey{}, true},
+		{"hedge by posSide", model.PosModeLongShort, []positionKey{btcLong, btcShort}, model.Signal{Symbol: "BTCUSDT", PosSide: model.DirShort}, btcShort, false},
+		{"hedge single leg", model.PosModeLongShort, []positionKey{btcLong}, model.Signal{Symbol: "BTCUSDT"}, btcLong, false},
+		{"hedge ambiguous", model.PosModeLongShort, []positionKey{btcLong, btcShort}, model.Signal{Symbol: "BTCUSDT"}, positionKey{}, true},
+		{"hedge missing leg", model.PosModeLongShort, []positionKey{btcLong}, model.Signal{Symbol: "BTCUSDT", PosSide: model.DirShort}, positionKey{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{PositionMode: tt.mode})
+			for _, key := range tt.legs {
+				account.positions[key] = &SimulatorPosition{Symbol: key.Symbol, Side: model.DirLong, Size: 1}
+			}
+			got, err := account.targetKey(tt.signal)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("targetKey = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSimulatorCrossLiquidation(t *testing.T) {
+	// 9 BTC @ 10000 + 1 ETH @ 100：净值 = 10000 + 9 * (BTC - 10000) + (ETH - 100)，
+	// 维持保证金 0.4% * 名义价值，BTC 跌破约 8924.6 时净值低于维持保证金
+	tests := []struct {
+		name       string
+		marginMode string
+		btcPrice   float64
+		liquidated bool
+	}{
+		{"cross above maintenance", MarginModeCross, 8950, false},
+		{"cross below maintenance", MarginModeCross, 8900, true},
+		{"isolated keeps the ETH leg", MarginModeIsolated, 8900, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{MarginMode: tt.marginMode})
+			btc, eth := newTestSim(account, "BTCUSDT", 0), newTestSim(account, "ETHUSDT", 0)
+			tick(btc, "BTCUSDT", 1, 10000)
+			tick(eth, "ETHUSDT", 1, 100)
+			if err := btc.ExecuteSignal(context.Background(), openSignal(model.DirLong, "", 9)); err != nil {
+				t.Fatalf("open BTC: %v", err)
+			}
+			if err := eth.ExecuteSignal(context.Background(), model.Signal{Action: model.ActionOpen, Direction: model.DirLong, PositionSize: 1}); err != nil {
+				t.Fatalf("open ETH: %v", err)
+			}
+
+			tick(btc, "BTCUSDT", 2, tt.btcPrice)
+			ethOpen := len(account.positionsView("ETHUSDT")) == 1
+			if ethOpen == tt.liquidated {
+				t.Errorf("ETH leg open = %v at BTC %.0f, want liquidated %v", ethOpen, tt.btcPrice, tt.liquidated)
+			}
+			if !tt.liquidated {
+				return
+			}
+			trades := account.GetTradeHistory()
+			if len(trades) != 2 {
+				t.Fatalf("%d trades, want both legs closed", len(trades))
+			}
+			for _, trade := range trades {
+				if trade.TriggerReason != "LIQUIDATION" {
+					t.Errorf("%s closed by %q, want LIQUIDATION", trade.Symbol, trade.TriggerReason)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulatorAllocation(t *testing.T) {
+	type step struct {
+		symbol  string
+		size    float64
+		add     bool
+		wantErr bool
+	}
+	// BTC 价格 10000、ETH 价格 100，10 倍杠杆；BTC 实例分配 2000 保证金，ETH 实例不限制
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"within allocation", []step{{"BTCUSDT", 1, false, false}, {"BTCUSDT", 1, true, false}}},
+		{"add exceeds allocation", []step{{"BTCUSDT", 1, false, false}, {"BTCUSDT", 1.5, true, true}}},
+		{"other instance margin not counted", []step{{"ETHUSDT", 50, false, false}, {"BTCUSDT", 1.9, false, false}}},
+		{"unlimited instance bounded by the account", []step{{"ETHUSDT", 900, false, false}, {"BTCUSDT", 1.5, false, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := newTestAccount(SimulatorConfig{})
+			executors := map[string]*SimulatorExecutor{
+				"BTCUSDT": newTestSim(account, "BTCUSDT", 2000),
+				"ETHUSDT": newTestSim(account, "ETHUSDT", 0),
+			}
+			tick(executors["BTCUSDT"], "BTCUSDT", 1, 10000)
+			tick(executors["ETHUSDT"], "ETHUSDT", 1, 100)
+			for i, s := range tt.steps {
+				signal := model.Signal{Action: model.ActionOpen, Direction: model.DirLong, PositionSize: s.size, AddToPosition: s.add}
+				err := executors[s.symbol].ExecuteSignal(context.Background(), signal)
+				if (err != nil) != s.wantErr {
+					t.Fatalf("step %d (%s %.1f): err = %v, want error %v", i, s.symbol, s.size, err, s.wantErr)
+				}
+			}
+			if used := account.marginUsed("BTCUSDT"); used > 2000 {
+				t.Errorf("BTC instance margin %.2f over its 2000 allocation", used)
+			}
+		})
+	}
+}
@@ -3,9 +3,8 @@ package executor
 import (
 	"context"
 	"crypto-algo-trader/internal/model"
-	"fmt"
 	"go.uber.org/zap"
-	"sync"
+	"sort"
 	"time"
 )
 
@@ -14,6 +13,14 @@ type SimulatorConfig struct {
 	InitialCapital float64 // 初始资金
 	Leverage       float64 // 杠杆倍数 (例如 10)
 	FeeRate        float64 // 交易手续费率 (例如 0.0005)
+
+	PositionMode          model.PositionMode // 持仓模式：单向 (默认) 或双向 (多空同时持仓)
+	MarginMode            string             // 保证金模式："isolated" 逐仓 (默认) 或 "cross" 全仓
+	MaintenanceMarginRate float64            // 维持保证金率 (全仓强平判断使用，默认 0.4%)
+
+	PassiveFillModel       string  // 限价单成交模型: "queue" (默认), "probabilistic", "touch", "through"
+	PassiveFillProbability float64 // probabilistic 模型下，每笔触及挂单价的成交带来成交的概率
+	PassiveFillSeed        int64   // probabilistic 模型的随机种子
 }
 
 // SimulatorPosition 模拟 Okx 的持仓数据结构
@@ -21,139 +28,109 @@ type SimulatorPosition struct {
 	Symbol           string
 	Side             model.Direction // Long/Short/Flat
 	Size             float64         // 持仓数量
+	InitialSize      float64         // 初始开仓数量 (分批止盈按此计算比例)
 	AvgPrice         float64         // 平均开仓价格
-	LiquidationPrice float64         // 强平价格 (核心风控)
+	Margin           float64         // 该持仓腿占用的保证金
+	LiquidationPrice float64         // 强平价格 (核心风控，全仓模式下为 0)
 	StopLossPrice    float64         // 止损价格 (由策略给出)
 	TakeProfitPrice  float64         // 止盈价格 (由策略给出)
+	InitialStopLoss  float64         // 开仓时的止损价格 (1R 风险距离)
 	UPL              float64         // 未实现盈亏
 
+	TakeProfitLevels []model.TakeProfitLevel // 尚未触发的分批止盈阶梯
+	takeProfitHits   int                     // 已触发的阶梯数量 (用于生成 "TP1".."TPn" 记录)
+
+	HighestPrice float64 // 持仓期间的最高价
+	LowestPrice  float64 // 持仓期间的最低价
+
 	EntryTime   time.Time         // 记录开仓时间
-	EntryFee    float64           // 记录开仓手续费
+	EntryFee    float64           // 记录开仓手续费 (分批平仓时按比例分摊)
 	SourceState model.MarketState // 开仓时的市场状态
 }
 
-// SimulatorExecutor 实现了 Executor 接口
+// positionKey 持仓腿的唯一标识 (Symbol, PosSide)，单向持仓模式下 PosSide 固定为 "net"
+type positionKey struct {
+	Symbol  string
+	PosSide model.Direction
+}
+
+// SimulatorExecutor 实现了 Executor 接口。
+// 它是某个交易实例在 SimulatorAccount 上的操作入口：多个实例可以共享同一个账户 (全仓组合视图)，
+// 每个实例有自己的交易对、杠杆和资金分配上限。
 type SimulatorExecutor struct {
-	cfg      *SimulatorConfig
+	account  *SimulatorAccount
 	tickerCh <-chan model.Ticker
 	logger   *zap.SugaredLogger
 
-	mu sync.RWMutex // 保护账户状态
-
-	// 账户状态 (接近交易所的资产视图)
-	balance    float64 // 账户余额 (包含已实现盈亏)
-	equity     float64 // 账户净值 = 余额 + 浮动盈亏
-	maxEquity  float64 // 历史最高账户净值
-	marginUsed float64 // 已用保证金
-	lastPrice  float64 // 实时更新的最新市场价格 (解决 ExecuteSignal 的价格依赖)
-
-	// 持仓状态
-	position *SimulatorPosition
-
-	tradeHistory             []*model.TradeRecord // 存储所有已平仓的交易记录
-	lastPriceTickerTimestamp int64                // 最新 Ticker 的时间戳 (毫秒)
+	symbol     string  // 本实例的交易对 (为空时不区分交易对，用于独立账户)
+	leverage   float64 // 本实例的开仓杠杆
+	allocation float64 // 本实例可占用的最大保证金 (USD)，0 表示不限制
 }
 
-// NewSimulatorExecutor 构造函数
+// NewSimulatorExecutor 构造函数：创建一个独立账户的模拟执行器
 func NewSimulatorExecutor(
 	cfg *SimulatorConfig,
 	tickerCh <-chan model.Ticker,
 	logger *zap.SugaredLogger,
 ) *SimulatorExecutor {
-	// 初始状态设置
-	sim := &SimulatorExecutor{
-		cfg:       cfg,
-		tickerCh:  tickerCh,
-		logger:    logger,
-		balance:   cfg.InitialCapital,
-		equity:    cfg.InitialCapital,
-		maxEquity: cfg.InitialCapital,                      // <-- 初始化时，最大净值 = 初始资金
-		position:  &SimulatorPosition{Side: model.DirFlat}, // 初始空仓
-	}
-	sim.position.Symbol = "Default" // 确保有默认Symbol
+	account := NewSimulatorAccount(cfg, logger)
+	return NewSharedSimulatorExecutor(account, "", cfg.Leverage, 0, tickerCh, logger)
+}
 
-	// 假设初始价格为安全值
-	sim.lastPrice = 1.0
+// NewSharedSimulatorExecutor 在共享账户上为一个交易实例创建模拟执行器
+// leverage <= 0 时使用账户默认杠杆；allocation 为该实例可占用的最大保证金 (0 表示不限制)
+func NewSharedSimulatorExecutor(
+	account *SimulatorAccount,
+	symbol string,
+	leverage float64,
+	allocation float64,
+	tickerCh <-chan model.Ticker,
+	logger *zap.SugaredLogger,
+) *SimulatorExecutor {
+	if leverage <= 0 {
+		leverage = account.cfg.Leverage
+	}
+	return &SimulatorExecutor{
+		account:    account,
+		tickerCh:   tickerCh,
+		logger:     logger,
+		symbol:     symbol,
+		leverage:   leverage,
+		allocation: allocation,
+	}
+}
 
-	return sim
+// Account 返回执行器所属的模拟账户
+func (e *SimulatorExecutor) Account() *SimulatorAccount {
+	return e.account
 }
 
-// ExecuteSignal 模拟下单和执行
+// ExecuteSignal 模拟下单和执行。
+// 账户配置了延迟模型时，订单先进入在途队列，到达交易所后按当时的价格成交，此时返回 nil 仅表示已提交。
 func (e *SimulatorExecutor) ExecuteSignal(ctx context.Context, signal model.Signal) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	currentPrice := e.lastPrice // 使用实时监控到的最新价格
-
-	if signal.Action == model.ActionOpen {
-		// ... (开仓逻辑：计算保证金、手续费、强平价，并更新 e.position)
+	a := e.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-		requiredMargin := signal.PositionSize * currentPrice / e.cfg.Leverage
-		if e.balance < requiredMargin {
-			e.logger.Infof("Sim Rejected: Insufficient balance. Need: %.2f, Have: %.2f", requiredMargin, e.balance)
-			return fmt.Errorf("insufficient margin")
-		}
-
-		// 扣除开仓手续费
-		fee := signal.PositionSize * currentPrice * e.cfg.FeeRate
-		e.balance -= fee
-		e.marginUsed = requiredMargin
-
-		// 更新持仓状态
-		e.position = &SimulatorPosition{
-			Symbol:           signal.Symbol,
-			Side:             signal.Direction,
-			Size:             signal.PositionSize,
-			AvgPrice:         currentPrice,
-			StopLossPrice:    signal.StopLossPrice,
-			TakeProfitPrice:  signal.TakeProfitPrice,
-			LiquidationPrice: e.calculateLiquidationPrice(currentPrice, signal.Direction, e.cfg.Leverage),
-			EntryTime:        time.UnixMilli(e.lastPriceTickerTimestamp), // 使用最新 Ticker 时间
-			EntryFee:         fee,                                        // 记录开仓手续费
-			SourceState:      signal.SourceState,
-		}
-
-		e.logger.Infof("Sim ORDER FILLED (OPEN): %s %s %.4f @ %.4f. Fee: %.4f. SL: %.4f, Liq: %.4f",
-			signal.Direction.String(), signal.Symbol, signal.PositionSize, currentPrice, fee, e.position.StopLossPrice, e.position.LiquidationPrice)
-
-	} else if signal.Action == model.ActionClose && e.position.Side != model.DirFlat {
-		// ... (平仓逻辑：计算已实现 PnL，扣除平仓手续费，更新 e.balance)
-
-		// 1. 计算平仓盈亏 (PnL) 和手续费
-		currentPrice := e.lastPrice
-		pnl := e.calculateClosedPnL(e.position, currentPrice)
-		closeFee := e.position.Size * currentPrice * e.cfg.FeeRate
-
-		// 2. 构造交易记录
-		newRecord := &model.TradeRecord{
-			EntryTime:     e.position.EntryTime,
-			ExitTime:      time.UnixMilli(e.lastPriceTickerTimestamp),
-			Symbol:        e.position.Symbol,
-			PosSide:       e.position.Side,
-			EntryPrice:    e.position.AvgPrice,
-			ExitPrice:     currentPrice,
-			Size:          e.position.Size,
-			RealizedPnL:   pnl,
-			Fee:           e.position.EntryFee + closeFee,
-			TriggerReason: "Signal",
-		}
-		e.tradeHistory = append(e.tradeHistory, newRecord)
-
-		// 3. 更新余额，释放保证金，重置持仓
-		e.balance += e.marginUsed + pnl - closeFee
-		e.marginUsed = 0.0
-
-		e.logger.Infof("Sim POSITION CLOSED: %s %s @ %.4f. Realized PnL: %.4f. New Balance: %.4f",
-			e.position.Side.String(), e.position.Symbol, currentPrice, pnl, e.balance)
-
-		// 4. 重置持仓
-		e.position = &SimulatorPosition{Side: model.DirFlat}
+	if signal.Symbol == "" {
+		signal.Symbol = e.symbol
+	}
+	// 撤单不进入订单链路，直接发出 (撤单到达交易所的延迟在 cancelPendingOrdersLocked 中计算)
+	if signal.Action == model.ActionCancel {
+		a.cancelPendingOrdersLocked(e, signal.ClientOrderID)
+		return nil
 	}
 
-	// 每次操作后更新净值
-	e.updateEquity(currentPrice)
+	return a.submitSignalLocked(e, signal)
+}
 
-	return nil
+// CancelPendingOrders 撤销本实例所有在途订单，返回发出撤单的数量。
+// 撤单本身也有延迟，若订单先于撤单到达交易所，订单仍会成交。
+func (e *SimulatorExecutor) CancelPendingOrders(ctx context.Context) int {
+	e.account.mu.Lock()
+	defer e.account.mu.Unlock()
+
+	return e.account.cancelPendingOrdersLocked(e, "")
 }
 
 // StartMonitor 启动实时监控 Goroutine
@@ -161,239 +138,103 @@ func (e *SimulatorExecutor) StartMonitor() {
 	e.logger.Info("SimulatorExecutor: Real-time PnL monitor started.")
 
 	for ticker := range e.tickerCh {
-		e.mu.Lock()
-
-		currentPrice := ticker.Price
-		e.lastPrice = currentPrice                    // 维护最新的价格供 ExecuteSignal 使用
-		e.lastPriceTickerTimestamp = ticker.Timestamp // 实时更新时间戳
-
-		// 1. 计算浮动盈亏并更新当前净值 e.equity
-		e.updateEquity(currentPrice)
-
-		// 2. 实时更新最大净值 (Max Equity) <-- 关键步骤
-		if e.equity > e.maxEquity {
-			e.maxEquity = e.equity
-		}
-
-		if e.position.Side != model.DirFlat {
-			// 2. 检查止损 (SL) / 止盈 (TP) / 强平 (Liq) 触发
-			isSLTriggered := e.checkStopLoss(currentPrice)
-			isTPTriggered := e.checkTakeProfit(currentPrice)
-			isLiqTriggered := e.checkLiquidation(currentPrice)
-
-			if isSLTriggered || isTPTriggered || isLiqTriggered {
-
-				// 模拟平仓，计算最终盈亏
-				closedPnL := e.calculateClosedPnL(e.position, currentPrice)
-				closeFee := e.position.Size * currentPrice * e.cfg.FeeRate
-
-				// 构造交易记录
-				triggerType := "Manual Close"
-				if isSLTriggered {
-					triggerType = "STOP LOSS"
-				}
-				if isTPTriggered {
-					triggerType = "TAKE PROFIT"
-				}
-				if isLiqTriggered {
-					triggerType = "LIQUIDATION"
-				}
-				if isSLTriggered {
-					triggerType = "SL"
-				}
-
-				newRecord := &model.TradeRecord{
-					// ... (数据填充与 ExecuteSignal 类似)
-					EntryTime:     e.position.EntryTime,
-					ExitTime:      time.UnixMilli(ticker.Timestamp), // 使用当前 Ticker 时间
-					RealizedPnL:   closedPnL,
-					TriggerReason: triggerType,
-					// ...
-				}
-				e.tradeHistory = append(e.tradeHistory, newRecord)
-
-				// 更新余额，释放保证金
-				e.balance += e.marginUsed + closedPnL - closeFee
-				e.marginUsed = 0.0
-
-				e.logger.Infof("Sim CLOSE TRIGGERED: [%s] %s %s @ %.4f. Final PnL: %.4f. New Balance: %.4f. Equity: %.4f",
-					triggerType, e.position.Side.String(), e.position.Symbol, currentPrice, closedPnL, e.balance, e.equity)
-
-				e.position = &SimulatorPosition{Side: model.DirFlat}
-			}
-		}
-
-		e.mu.Unlock()
-	}
-}
-
-// calculateLiquidationPrice 计算强平价格 (简化模型，使用初始保证金率)
-func (e *SimulatorExecutor) calculateLiquidationPrice(avgPrice float64, side model.Direction, leverage float64) float64 {
-	if leverage <= 0 || side == model.DirFlat {
-		return 0.0
-	}
-
-	// 假设初始保证金率 = 1 / 杠杆
-	marginRatio := 1.0 / leverage
-
-	// 忽略维持保证金、穿仓保障基金等复杂因素
-
-	if side == model.DirLong {
-		// 多头强平价: 价格下跌 (亏损) 导致保证金不足
-		return avgPrice * (1.0 - marginRatio)
-	}
-
-	if side == model.DirShort {
-		// 空头强平价: 价格上涨 (亏损) 导致保证金不足
-		return avgPrice * (1.0 + marginRatio)
+		e.OnTicker(ticker)
 	}
-
-	return 0.0
 }
 
-// calculateClosedPnL 计算已实现盈亏 (Realized PnL)
-func (e *SimulatorExecutor) calculateClosedPnL(pos *SimulatorPosition, closePrice float64) float64 {
-	if pos.Size == 0 || pos.Side == model.DirFlat {
-		return 0.0
-	}
+// OnTicker 同步处理一个 Ticker：撮合在途订单和挂单、更新净值、检查止盈止损和强平。
+// 实时模式由 StartMonitor 调用，回测引擎直接调用以保证确定性。
+func (e *SimulatorExecutor) OnTicker(ticker model.Ticker) {
+	e.account.mu.Lock()
+	defer e.account.mu.Unlock()
 
-	var pnl float64
-	if pos.Side == model.DirLong {
-		// 多头：平仓价高于均价则盈利
-		pnl = (closePrice - pos.AvgPrice) * pos.Size
-	} else { // Short
-		// 空头：平仓价低于均价则盈利
-		pnl = (pos.AvgPrice - closePrice) * pos.Size
-	}
-
-	return pnl
+	e.account.onTicker(ticker, e.logger)
 }
 
-// updateEquity 计算浮动盈亏 (UPL) 并更新账户净值 (Equity)
-func (e *SimulatorExecutor) updateEquity(currentPrice float64) {
-	if e.position.Side == model.DirFlat {
-		// 空仓时，净值 = 余额 (UPL = 0)
-		e.equity = e.balance
-		return
-	}
-
-	// 计算浮动盈亏 (Unrealized PnL)
-	var upl float64
-	if e.position.Side == model.DirLong {
-		upl = (currentPrice - e.position.AvgPrice) * e.position.Size
-	} else { // Short
-		upl = (e.position.AvgPrice - currentPrice) * e.position.Size
-	}
-	e.position.UPL = upl
-	// 更新账户净值 (Equity = Balance + UPL)
-	e.equity = e.balance + upl
-}
-
-// GetTradeHistory 实现 Executor 接口
+// GetTradeHistory 实现 Executor 接口 (共享账户下只返回本实例交易对的记录)
 func (e *SimulatorExecutor) GetTradeHistory() ([]*model.TradeRecord, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
 
 	// 返回记录的副本，防止外部修改
-	records := make([]*model.TradeRecord, len(e.tradeHistory))
-	copy(records, e.tradeHistory)
+	records := make([]*model.TradeRecord, 0, len(e.account.tradeHistory))
+	for _, record := range e.account.tradeHistory {
+		if e.symbol == "" || record.Symbol == e.symbol {
+			records = append(records, record)
+		}
+	}
 	return records, nil
 }
 
-// internal/executor/simulator_executor.go
+// DrainFills 实现 FillSource 接口：返回并清空本实例交易对的成交 (共享账户下同一交易对的实例共用成交队列)
+func (e *SimulatorExecutor) DrainFills() []model.Fill {
+	e.account.mu.Lock()
+	defer e.account.mu.Unlock()
 
-// checkStopLoss 检查是否触发止损
-func (e *SimulatorExecutor) checkStopLoss(currentPrice float64) bool {
-	// 检查是否有持仓，且设置了止损价
-	if e.position.Side == model.DirFlat || e.position.StopLossPrice == 0.0 {
-		return false
+	if e.symbol != "" {
+		fills := e.account.fills[e.symbol]
+		delete(e.account.fills, e.symbol)
+		return fills
 	}
-
-	if e.position.Side == model.DirLong {
-		// 多头止损：当前价格 <= 止损价
-		// 价格下跌
-		return currentPrice <= e.position.StopLossPrice
-	}
-
-	if e.position.Side == model.DirShort {
-		// 空头止损：当前价格 >= 止损价
-		// 价格上涨
-		return currentPrice >= e.position.StopLossPrice
+	// 独立账户：取走全部交易对的成交并按时间排序
+	var fills []model.Fill
+	for symbol, symbolFills := range e.account.fills {
+		fills = append(fills, symbolFills...)
+		delete(e.account.fills, symbol)
 	}
-
-	return false
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+	return fills
 }
 
-// internal/executor/simulator_executor.go
-
-// checkTakeProfit 检查是否触发止盈
-func (e *SimulatorExecutor) checkTakeProfit(currentPrice float64) bool {
-	// 检查是否有持仓，且设置了止盈价
-	if e.position.Side == model.DirFlat || e.position.TakeProfitPrice == 0.0 {
-		return false
-	}
-
-	if e.position.Side == model.DirLong {
-		// 多头止盈：当前价格 >= 止盈价
-		// 价格上涨
-		return currentPrice >= e.position.TakeProfitPrice
-	}
+// GetStopUpdateHistory 实现 Executor 接口 (共享账户下只返回本实例交易对的记录)
+func (e *SimulatorExecutor) GetStopUpdateHistory() ([]*model.StopUpdateRecord, error) {
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
 
-	if e.position.Side == model.DirShort {
-		// 空头止盈：当前价格 <= 止盈价
-		// 价格下跌
-		return currentPrice <= e.position.TakeProfitPrice
+	records := make([]*model.StopUpdateRecord, 0, len(e.account.stopUpdateHistory))
+	for _, record := range e.account.stopUpdateHistory {
+		if e.symbol == "" || record.Symbol == e.symbol {
+			records = append(records, record)
+		}
 	}
-
-	return false
+	return records, nil
 }
 
-// internal/executor/simulator_executor.go
-
-// checkLiquidation 检查是否触发强平
-func (e *SimulatorExecutor) checkLiquidation(currentPrice float64) bool {
-	// 强平价为 0.0 通常意味着没有开仓，或使用了 1 倍杠杆 (实际上 1 倍杠杆不会被强平)
-	if e.position.Side == model.DirFlat || e.position.LiquidationPrice == 0.0 {
-		return false
-	}
+// GetOrderHistory 返回本实例的订单记录 (提交/到达/回报时间、信号价与成交价)，用于分析延迟敏感度
+func (e *SimulatorExecutor) GetOrderHistory() []*model.OrderRecord {
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
 
-	// 注意：强平价通常比止损价更接近开仓价 (即风险更大)
-
-	if e.position.Side == model.DirLong {
-		// 多头强平：当前价格 <= 强平价
-		// 价格下跌
-		return currentPrice <= e.position.LiquidationPrice
-	}
-
-	if e.position.Side == model.DirShort {
-		// 空头强平：当前价格 >= 强平价
-		// 价格上涨
-		return currentPrice >= e.position.LiquidationPrice
+	records := make([]*model.OrderRecord, 0, len(e.account.orderHistory))
+	for _, record := range e.account.orderHistory {
+		if e.symbol == "" || record.Symbol == e.symbol {
+			records = append(records, record)
+		}
 	}
-
-	return false
+	return records
 }
 
 // GetMaxEquity 返回账户历史上的最高净值
 func (e *SimulatorExecutor) GetMaxEquity() float64 {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
 
-	return e.maxEquity
+	return e.account.maxEquity
 }
 
 // GetBalance (Executor 接口要求的方法，用于获取当前余额，可根据需求返回 balance 或 equity)
 // 在策略风控中，我们更关心净值 (Equity)，因为它包含了浮动盈亏。
+// 共享账户下返回整个账户的净值 (组合视角)。
 func (e *SimulatorExecutor) GetBalance(ctx context.Context) (float64, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
 
 	// 返回净值 Equity，作为策略计算回撤的基准
-	return e.equity, nil
+	return e.account.equity, nil
 }
 
-// GetCurrentPosition 模拟查询当前持仓
-func (e *SimulatorExecutor) GetCurrentPosition(ctx context.Context) (*model.Position, error) {
+// GetCurrentPosition 模拟查询当前持仓 (双向持仓模式下返回多空两条腿)
+func (e *SimulatorExecutor) GetCurrentPosition(ctx context.Context) (model.Positions, error) {
 	// 实际应调用 Okx API 查询持仓
 
 	// --- 实际的 Okx API 调用占位符 ---
@@ -412,14 +253,9 @@ func (e *SimulatorExecutor) GetCurrentPosition(ctx context.Context) (*model.Posi
 	// }
 	// ---------------------------------
 
+	e.account.mu.RLock()
+	defer e.account.mu.RUnlock()
+
 	// 返回内部模拟的仓位 (在真实环境中，应返回查询 API 结果)
-	return &model.Position{
-		InstID:      e.position.Symbol,
-		Direction:   e.position.Side,
-		Size:        e.position.Size,
-		AvgPrice:    e.position.AvgPrice,
-		UPL:         e.position.UPL,
-		EntryTime:   time.Time{},
-		SourceState: e.position.SourceState,
-	}, nil
+	return e.account.positionsView(e.symbol), nil
 }
@@ -19,8 +19,13 @@ type DataEngine struct {
 	forwardTickerChan chan Ticker // 用于将 Ticker 转发给所有 Aggregator
 
 	broadcasterTickerChan chan Ticker // <-- Ticker 广播通道
+
+	clock service.Clock // 引擎时钟 (事件时钟会被 Ticker 时间戳推进)
 }
 
+// KlineFlushDelay 周期结束后等待迟到 Ticker 的时间，超过后即使没有新 Ticker 也会发送 K 线
+const KlineFlushDelay = 2 * time.Second
+
 // NewDataEngine 创建并初始化 DataEngine
 func NewDataEngine(tickerChan chan Ticker, symbol string) *DataEngine {
 	// 定义我们需要的 K 线周期
@@ -33,12 +38,14 @@ func NewDataEngine(tickerChan chan Ticker, symbol string) *DataEngine {
 	}
 
 	de := &DataEngine{
-		tickerChan:        tickerChan,
-		klineChan:         make(chan KLine, 100),
-		aggregators:       make(map[string]*KlineAggregator),
-		intervals:         intervals,
-		symbol:            symbol,
-		forwardTickerChan: make(chan Ticker, 1000), // 更大的转发缓冲区
+		tickerChan:            tickerChan,
+		klineChan:             make(chan KLine, 100),
+		aggregators:           make(map[string]*KlineAggregator),
+		intervals:             intervals,
+		symbol:                symbol,
+		forwardTickerChan:     make(chan Ticker, 1000), // 更大的转发缓冲区
+		broadcasterTickerChan: make(chan Ticker, 1000),
+		clock:                 service.RealClock{},
 	}
 
 	// 初始化所有周期的聚合器，并传入转发 Channel
@@ -52,39 +59,87 @@ func NewDataEngine(tickerChan chan Ticker, symbol string) *DataEngine {
 	return de
 }
 
+// SetClock 设置引擎及其所有聚合器使用的时钟，需在 Start 之前调用
+func (de *DataEngine) SetClock(clock service.Clock) {
+	de.clock = clock
+	for _, agg := range de.aggregators {
+		agg.SetClock(clock)
+	}
+}
+
 // Start 启动数据处理循环
 func (de *DataEngine) Start() {
 	service.Logger.Info("Data Engine started, monitoring ticker stream...")
 
-	// 启动所有 K 线聚合器的 Run 循环
-	for _, agg := range de.aggregators {
-		go agg.Run()
-	}
+	// 定时检查周期是否结束：行情清淡时没有新 Ticker，也要按时钟发送完成的 K 线
+	flushTicker := time.NewTicker(time.Second)
+	defer flushTicker.Stop()
 
-	// 主循环：接收原始 Ticker，过滤并转发到转发 Channel
-	for ticker := range de.tickerChan {
-		// 核心过滤逻辑：只处理与本 DataEngine 实例 Symbol 匹配的数据
-		if ticker.Symbol != de.symbol {
-			continue
+	// 主循环：接收原始 Ticker，过滤后同步聚合 K 线 (与回测使用同一条 ProcessTicker 路径)
+	for {
+		select {
+		case ticker, ok := <-de.tickerChan:
+			if !ok {
+				return
+			}
+			// 核心过滤逻辑：只处理与本 DataEngine 实例 Symbol 匹配的数据
+			if ticker.Symbol != de.symbol {
+				continue
+			}
+
+			// Ticker 属于本实例，聚合 K 线并发送完成的 K 线
+			de.emit(de.ProcessTicker(ticker))
+
+			// 转发给 Ticker 广播通道
+			select {
+			case de.broadcasterTickerChan <- ticker:
+				// 发送成功
+			default:
+			}
+		case <-flushTicker.C:
+			de.emit(de.Flush())
 		}
+	}
+}
 
-		// 转发给 KLine 聚合器
-		// Ticker 属于本实例，转发给 K 线聚合器
+// emit 将完成的 K 线发送给策略层 (使用 select/default 防止阻塞)
+func (de *DataEngine) emit(klines []KLine) {
+	for _, kline := range klines {
 		select {
-		case de.forwardTickerChan <- ticker:
+		case de.klineChan <- kline:
 			// 成功发送
 		default:
-			service.Logger.Warn("Forward Ticker Channel Full! Dropping Ticker.",
-				zap.String("Symbol", de.symbol), zap.Int64("TS", ticker.Timestamp))
+			service.Logger.Warn("KLine output channel full! Dropping completed KLine.",
+				zap.String("Symbol", de.symbol), zap.String("Interval", kline.Interval))
 		}
+	}
+}
 
-		// 转发给 Ticker 广播通道
-		select {
-		case de.broadcasterTickerChan <- ticker:
-			// 发送成功
-		default:
+// Flush 按引擎时钟关闭所有已经结束 (超过 KlineFlushDelay) 但尚未收到下一周期 Ticker 的 K 线
+func (de *DataEngine) Flush() []KLine {
+	var completed []KLine
+	for _, interval := range de.intervals {
+		agg := de.aggregators[service.FormatInterval(interval)]
+		if kline, ok := agg.Flush(); ok {
+			completed = append(completed, kline)
+		}
+	}
+	return completed
+}
+
+// ProcessTicker 同步地将一个 Ticker 聚合到所有周期，返回因此完成的 K 线 (按周期从小到大排列)。
+// 回测引擎直接调用它以保证确定性；实时模式由 Start 调用。
+func (de *DataEngine) ProcessTicker(ticker Ticker) []KLine {
+	service.AdvanceClock(de.clock, time.UnixMilli(ticker.Timestamp))
+
+	var completed []KLine
+	for _, interval := range de.intervals {
+		agg := de.aggregators[service.FormatInterval(interval)]
+		if kline, ok := agg.ProcessTicker(ticker); ok {
+			completed = append(completed, kline)
 		}
 	}
+	return completed
 }
 
 // 供 SimulatorExecutor 等需要实时 Ticker 的组件使用
@@ -105,6 +160,8 @@ type KlineAggregator struct {
 	Current  KLine       // 正在构建的当前 K 线
 	OutChan  chan KLine  // K 线输出通道 (DataEngine 的 klineChan)
 	inChan   chan Ticker // Ticker 输入通道 (DataEngine 的 forwardTickerChan)
+
+	clock service.Clock // 判断周期是否结束使用的时钟 (Flush)
 }
 
 // NewKlineAggregator 创建一个新的聚合器
@@ -119,6 +176,7 @@ func NewKlineAggregator(
 		OutChan:  outChan,
 		Symbol:   symbol,
 		inChan:   inChan,
+		clock:    service.RealClock{},
 		Current: KLine{
 			Symbol:    symbol,
 			Interval:  intervalStr,
@@ -141,7 +199,16 @@ func (agg *KlineAggregator) Run() {
 		if ticker.Symbol != agg.Symbol {
 			continue
 		}
-		agg.ProcessTicker(ticker) // 在各自的 Goroutine 中处理
+		// 在各自的 Goroutine 中处理，异步发送完成的 K 线
+		if kline, ok := agg.ProcessTicker(ticker); ok {
+			select {
+			case agg.OutChan <- kline:
+				// 成功发送
+			default:
+				service.Logger.Warn("KLine output channel full! Dropping completed KLine.",
+					zap.String("Symbol", agg.Symbol), zap.String("Interval", agg.Interval))
+			}
+		}
 	}
 
 	// 如果 inChan 关闭，退出循环
@@ -150,19 +217,55 @@ func (agg *KlineAggregator) Run() {
 		zap.String("Interval", agg.Interval))
 }
 
-// ProcessTicker 负责将 Ticker 聚合到 Current KLine
-func (agg *KlineAggregator) ProcessTicker(ticker Ticker) {
+// SetClock 设置聚合器的时钟
+func (agg *KlineAggregator) SetClock(clock service.Clock) {
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+
+	agg.clock = clock
+}
 
+// Flush 按时钟检查当前 K 线：周期结束超过 KlineFlushDelay 仍未收到下一周期的 Ticker 时，
+// 返回该 K 线并以其收盘价开启时钟所在周期的新 K 线
+func (agg *KlineAggregator) Flush() (KLine, bool) {
 	agg.mu.Lock()
 	defer agg.mu.Unlock()
 
-	// 1. 计算 Ticker 应该属于哪个 K 线周期
-	// 假设我们有一个工具函数来计算 K 线的起始时间
-	intervalDuration := time.Duration(1) * time.Minute // 实际需要解析 agg.Interval
+	now := agg.clock.Now().Add(-KlineFlushDelay)
+	if agg.Current.StartTime.IsZero() || !now.After(agg.Current.EndTime) {
+		return KLine{}, false
+	}
 
-	// (简化：这里只处理 1m 周期，实际需要完善周期解析逻辑)
-	if agg.Interval != "1m" {
-		// ... (处理其他周期的起始时间计算)
+	intervalDuration, err := service.ParseIntervalDuration(agg.Interval)
+	if err != nil {
+		intervalDuration = time.Minute
+	}
+
+	completedKLine := agg.Current
+	start := now.Truncate(intervalDuration)
+	agg.Current = KLine{
+		Symbol:    agg.Symbol,
+		Interval:  agg.Interval,
+		Open:      completedKLine.Close,
+		High:      completedKLine.Close,
+		Low:       completedKLine.Close,
+		Close:     completedKLine.Close,
+		StartTime: start,
+		EndTime:   start.Add(intervalDuration).Add(-time.Millisecond),
+	}
+	return completedKLine, true
+}
+
+// ProcessTicker 负责将 Ticker 聚合到 Current KLine，Ticker 开启新周期时返回刚完成的 K 线
+func (agg *KlineAggregator) ProcessTicker(ticker Ticker) (KLine, bool) {
+
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+
+	// 1. 计算 Ticker 应该属于哪个 K 线周期
+	intervalDuration, err := service.ParseIntervalDuration(agg.Interval)
+	if err != nil {
+		intervalDuration = time.Minute
 	}
 
 	// 将 Ticker 时间戳对齐到 K 线起始时间
@@ -172,10 +275,13 @@ func (agg *KlineAggregator) ProcessTicker(ticker Ticker) {
 	// 2. 检查 K 线是否完成 (Close KLine)
 	// 如果当前聚合器正在构建的 K 线的起始时间在 Ticker 所在的周期之前，
 	// 说明之前的 K 线已完成，需要先发送。
+	var completedKLine KLine
+	completed := false
 	if !agg.Current.StartTime.IsZero() && currentKlineStart.After(agg.Current.StartTime) {
 
-		// K 线完成，发送出去
-		completedKLine := agg.Current
+		// K 线完成，由调用方发送出去
+		completedKLine = agg.Current
+		completed = true
 
 		// 重置 Current KLine，以 tickerTime 为基准开启新 K 线
 		agg.Current = KLine{
@@ -188,15 +294,6 @@ func (agg *KlineAggregator) ProcessTicker(ticker Ticker) {
 			StartTime: currentKlineStart,
 			EndTime:   currentKlineStart.Add(intervalDuration).Add(-time.Millisecond),
 		}
-
-		// 异步发送完成的 K 线
-		select {
-		case agg.OutChan <- completedKLine:
-			// 成功发送
-		default:
-			service.Logger.Warn("KLine output channel full! Dropping completed KLine.",
-				zap.String("Symbol", agg.Symbol), zap.String("Interval", agg.Interval))
-		}
 	}
 
 	// 3. 初始化/更新当前 K 线 (Open/High/Low/Close/Volume)
@@ -219,4 +316,6 @@ func (agg *KlineAggregator) ProcessTicker(ticker Ticker) {
 	agg.Current.High = math.Max(agg.Current.High, ticker.Price)
 	agg.Current.Low = math.Min(agg.Current.Low, ticker.Price)
 	agg.Current.Volume += ticker.Volume // 累加交易量
+
+	return completedKLine, completed
 }
@@ -23,3 +23,17 @@ type KLine struct {
 	StartTime time.Time
 	EndTime   time.Time
 }
+
+// OrderBookLevel 订单簿中的一个价位
+type OrderBookLevel struct {
+	Price float64
+	Size  float64 // 该价位的挂单总量
+}
+
+// OrderBook 代表某一时刻的 L2 深度快照
+type OrderBook struct {
+	Symbol    string
+	Timestamp int64            // 毫秒时间戳
+	Bids      []OrderBookLevel // 买盘，价格从高到低
+	Asks      []OrderBookLevel // 卖盘，价格从低到高
+}
@@ -26,6 +26,7 @@ const (
 	ActionOpen   ActionType = "OPEN"   // 开仓
 	ActionClose  ActionType = "CLOSE"  // 平仓 (指平掉当前仓位)
 	ActionUpdate ActionType = "UPDATE" // 更新止损/止盈
+	ActionCancel ActionType = "CANCEL" // 撤单：撤销 ClientOrderID 对应的订单，为空时撤销本实例的全部订单
 )
 
 type Direction string
@@ -36,23 +37,57 @@ const (
 	DirFlat  Direction = "flat"  // 空仓
 )
 
+// PosSideNet 单向持仓模式下的持仓方向 (与 Okx posSide="net" 一致)
+const PosSideNet Direction = "net"
+
 func (s Direction) String() string {
 	return string(s)
 }
 
+// PositionMode 持仓模式，与 Okx 账户的 posMode 一致
+type PositionMode string
+
+const (
+	PosModeNet       PositionMode = "net_mode"        // 单向持仓：每个交易对只有一个净持仓
+	PosModeLongShort PositionMode = "long_short_mode" // 双向持仓 (对冲)：多空两条腿可同时存在
+)
+
+// OrderType 订单类型
+type OrderType string
+
+const (
+	OrderMarket OrderType = "market" // 市价单 (默认)：以到达交易所时的价格立即成交
+	OrderLimit  OrderType = "limit"  // 限价单：以 Signal.Price 挂单，非立即成交时进入订单簿排队
+)
+
 // Signal 结构体定义了策略层向执行层发出的具体指令
 type Signal struct {
 	Symbol          string
 	Timestamp       time.Time   // 信号生成时间
 	Action          ActionType  // 操作类型: OPEN, CLOSE, UPDATE
 	Direction       Direction   // 期望方向: LONG, SHORT, FLAT
+	PosSide         Direction   // 目标持仓腿 (双向持仓模式下 CLOSE/UPDATE 必须指定 long/short，OPEN 为空时取 Direction)
 	Price           float64     // 期望的入场/平仓价格 (可以是市价或限价)
+	OrderType       OrderType   // 订单类型：为空时按市价单处理，限价单的挂单价为 Price
 	RiskedUSD       float64     // 本次交易愿意承担的最大USD损失
 	PositionSize    float64     // 期望的开仓数量 (币本位，例如 BTC 数量)
 	StopLossPrice   float64     // 止损价格
 	TakeProfitPrice float64     // 止盈价格
 	SourceState     MarketState // 信号来源的市场状态
 	Reason          string      // 信号生成的文字描述
+	ClientOrderID   string      // 客户端订单号 (与 Okx clOrdId 一致，只能包含字母和数字)，成交回报 Fill 原样带回
+	AddToPosition   bool        // 持仓腿已存在时加仓 (均价按成交价加权)，否则拒绝重复开仓；网格等多次开仓的策略使用
+
+	// 分批止盈阶梯 (按触发顺序排列)。为空时仅使用 TakeProfitPrice 一次性止盈；
+	// 阶梯比例之和小于 1 时，剩余仓位不设固定止盈，交由跟踪止损管理。
+	// ActionUpdate 时：nil 表示不修改，StopLossPrice/TakeProfitPrice 为 0 表示不修改。
+	TakeProfitLevels []TakeProfitLevel
+}
+
+// TakeProfitLevel 分批止盈阶梯中的一级
+type TakeProfitLevel struct {
+	Price     float64 // 触发价格
+	SizeRatio float64 // 触发时平掉的仓位比例 (相对于初始开仓数量)
 }
 
 func (s Signal) String() string {
@@ -69,6 +104,57 @@ type Position struct {
 	UPL         float64 // 未实现盈亏
 	EntryTime   time.Time
 	SourceState MarketState // 记录开仓时的市场状态
+
+	InitialSize      float64           // 初始开仓数量 (分批止盈后 Size 会减少)
+	StopLossPrice    float64           // 当前止损价格
+	TakeProfitPrice  float64           // 当前止盈价格
+	InitialStopLoss  float64           // 开仓时的止损价格 (用于计算 1R 风险距离)
+	TakeProfitLevels []TakeProfitLevel // 尚未触发的分批止盈阶梯
+	HighestPrice     float64           // 持仓期间的最高价
+	LowestPrice      float64           // 持仓期间的最低价
+}
+
+// StopUpdateRecord 记录一次止损/止盈修改，用于事后分析跟踪止损效果
+type StopUpdateRecord struct {
+	Time             time.Time
+	Symbol           string
+	PosSide          Direction
+	MarketPrice      float64 // 修改时的市场价格
+	OldStopLoss      float64
+	NewStopLoss      float64
+	OldTakeProfit    float64
+	NewTakeProfit    float64
+	TakeProfitLevels int    // 修改后剩余的止盈阶梯数量
+	Reason           string // 修改原因: "Breakeven", "ATR Trailing", "Chandelier" 等
+}
+
+// Positions 某个执行器当前的全部持仓腿 (单向模式最多一条，双向模式最多多空两条)
+type Positions []*Position
+
+// Leg 返回指定方向的持仓腿，不存在时返回一个空仓 Position
+func (ps Positions) Leg(side Direction) *Position {
+	for _, p := range ps {
+		if p.Direction == side && p.Size > 0 {
+			return p
+		}
+	}
+	return &Position{Direction: DirFlat}
+}
+
+// Open 返回所有非空仓的持仓腿
+func (ps Positions) Open() Positions {
+	open := make(Positions, 0, len(ps))
+	for _, p := range ps {
+		if p.Direction != DirFlat && p.Size > 0 {
+			open = append(open, p)
+		}
+	}
+	return open
+}
+
+// IsFlat 判断是否没有任何持仓
+func (ps Positions) IsFlat() bool {
+	return len(ps.Open()) == 0
 }
 
 // TradeRecord 记录一次完整的开仓和平仓交易
@@ -80,9 +166,66 @@ type TradeRecord struct {
 	EntryPrice    float64
 	ExitPrice     float64
 	Size          float64
-	RealizedPnL   float64 // 已实现盈亏 (Realized PnL)
-	Fee           float64 // 总手续费 (开仓 + 平仓)
-	TriggerReason string  // 平仓原因: "Signal", "SL", "TP", "Liquidation"
+	RealizedPnL   float64     // 已实现盈亏 (Realized PnL)
+	Fee           float64     // 总手续费 (开仓 + 平仓)
+	TriggerReason string      // 平仓原因: "Signal", "SL", "TP", "TP1".."TPn" (分批止盈), "Liquidation"
+	SourceState   MarketState // 开仓时的市场状态
+}
+
+// Fill 一次成交：开仓，或者信号、止损止盈、强平导致的 (部分) 平仓。执行器通过它通知策略
+type Fill struct {
+	Time        time.Time
+	Symbol      string
+	Action      ActionType // OPEN / CLOSE
+	Direction   Direction  // 持仓方向
+	Price       float64
+	Size        float64
+	Fee         float64
+	RealizedPnL float64 // 平仓的已实现盈亏 (开仓为 0)
+	Reason      string  // 开仓为信号描述，平仓与 TradeRecord.TriggerReason 一致
+
+	ClientOrderID string // 触发成交的订单的客户端订单号 (止损止盈和强平为空)
+}
+
+// EquityPoint 账户净值曲线上的一个采样点
+type EquityPoint struct {
+	Time    time.Time
+	Equity  float64
+	Balance float64
+}
+
+// PricePoint 价格序列上的一个采样点
+type PricePoint struct {
+	Time  time.Time
+	Price float64
+}
+
+// 订单状态
+const (
+	OrderLive     = "LIVE"     // 限价单已挂入订单簿，等待成交
+	OrderFilled   = "FILLED"   // 已成交 / 已生效
+	OrderRejected = "REJECTED" // 被交易所拒绝 (保证金不足、仓位冲突等)
+	OrderCanceled = "CANCELED" // 撤单先于订单到达，订单未成交
+)
+
+// OrderRecord 记录一笔订单从信号到成交的完整链路，用于分析延迟和滑点
+type OrderRecord struct {
+	OrderID       int64
+	ClientOrderID string
+	Symbol        string
+	Action        ActionType
+	OrderType     OrderType
+	Direction     Direction
+	PosSide       Direction
+	LimitPrice    float64   // 限价单挂单价格 (市价单为 0)
+	QueueAhead    float64   // 限价单挂单时估计的排队数量 (前方挂单量)
+	SignalPrice   float64   // 信号发出时的价格
+	FillPrice     float64   // 订单到达交易所时的成交价格
+	SubmitTime    time.Time // 信号发出 (下单) 时间
+	ArrivalTime   time.Time // 订单到达交易所的时间
+	AckTime       time.Time // 客户端收到回报的时间
+	Status        string    // FILLED / REJECTED / CANCELED
+	Reason        string    // 拒绝原因或信号描述
 }
 
 // 市场状态常量
@@ -100,3 +243,11 @@ const (
 	// 初始状态
 	StateInitial MarketState = "INITIALIZING"
 )
+
+// StateTransition 状态机的一次状态切换记录
+type StateTransition struct {
+	Time   time.Time
+	Symbol string
+	From   MarketState
+	To     MarketState
+}
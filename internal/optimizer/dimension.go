@@ -0,0 +1,176 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// DimensionKind 搜索空间中一个维度的类型
+type DimensionKind int
+
+const (
+	DimFloat       DimensionKind = iota // 连续取值 [Min, Max]
+	DimInt                              // 整数取值 [Min, Max] (指标周期等整数参数)
+	DimCategorical                      // 从 Choices 中选择，取值之间没有顺序关系
+)
+
+// Dimension 遗传算法 / TPE 搜索空间中的一个参数。
+// 与 ParamRange (离散网格) 不同，连续维度可以取区间内的任意值
+type Dimension struct {
+	Name    string
+	Kind    DimensionKind
+	Min     float64
+	Max     float64
+	Log     bool      // 按对数刻度采样 (Min 必须大于 0)，适用于跨数量级的阈值
+	Choices []float64 // DimCategorical 的候选值
+}
+
+// ParseDimension 解析搜索维度，支持三种写法：
+//
+//	Name=min:max      (连续区间；整数参数自动按整数取值)
+//	Name=min:max:log  (对数刻度的连续区间)
+//	Name=v1,v2,v3     (分类取值)
+func ParseDimension(spec string) (Dimension, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return Dimension{}, fmt.Errorf("dimension spec %q must be Name=min:max[:log] or Name=v1,v2", spec)
+	}
+	name := strings.TrimSpace(parts[0])
+	param, ok := params[name]
+	if !ok {
+		return Dimension{}, fmt.Errorf("unknown param %q (available: %s)", name, strings.Join(ParamNames(), ", "))
+	}
+
+	if bounds := strings.Split(parts[1], ":"); len(bounds) == 2 || len(bounds) == 3 {
+		d := Dimension{Name: name, Kind: DimFloat}
+		if param.Integer {
+			d.Kind = DimInt
+		}
+		if len(bounds) == 3 {
+			if strings.TrimSpace(bounds[2]) != "log" {
+				return Dimension{}, fmt.Errorf("param %s: unknown scale %q (only \"log\" is supported)", name, bounds[2])
+			}
+			d.Log = true
+		}
+		var err error
+		if d.Min, err = strconv.ParseFloat(strings.TrimSpace(bounds[0]), 64); err != nil {
+			return Dimension{}, fmt.Errorf("param %s: %w", name, err)
+		}
+		if d.Max, err = strconv.ParseFloat(strings.TrimSpace(bounds[1]), 64); err != nil {
+			return Dimension{}, fmt.Errorf("param %s: %w", name, err)
+		}
+		if d.Kind == DimInt {
+			d.Min, d.Max = math.Round(d.Min), math.Round(d.Max)
+		}
+		if d.Max <= d.Min || (d.Log && d.Min <= 0) {
+			return Dimension{}, fmt.Errorf("param %s: invalid range %s", name, parts[1])
+		}
+		return d, nil
+	}
+
+	r, err := ParseRange(spec)
+	if err != nil {
+		return Dimension{}, err
+	}
+	if len(r.Values) < 2 {
+		return Dimension{}, fmt.Errorf("param %s: categorical dimension needs at least two values", name)
+	}
+	return Dimension{Name: name, Kind: DimCategorical, Choices: r.Values}, nil
+}
+
+// String 输出与 ParseDimension 对应的写法 (记录在 study 日志中，用于恢复时校验搜索空间)
+func (d Dimension) String() string {
+	if d.Kind == DimCategorical {
+		values := make([]string, len(d.Choices))
+		for i, v := range d.Choices {
+			values[i] = FormatValue(v)
+		}
+		return d.Name + "=" + strings.Join(values, ",")
+	}
+	spec := d.Name + "=" + FormatValue(d.Min) + ":" + FormatValue(d.Max)
+	if d.Log {
+		spec += ":log"
+	}
+	return spec
+}
+
+// DimensionNames 返回各维度的参数名 (保持顺序)
+func DimensionNames(space []Dimension) []string {
+	names := make([]string, len(space))
+	for i, d := range space {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// sample 在维度上均匀随机取值 (对数刻度时在对数空间均匀)
+func (d Dimension) sample(rng *rand.Rand) float64 {
+	if d.Kind == DimCategorical {
+		return d.Choices[rng.Intn(len(d.Choices))]
+	}
+	return d.fromUnit(rng.Float64())
+}
+
+// toUnit 将连续/整数维度的取值映射到 [0, 1]
+func (d Dimension) toUnit(v float64) float64 {
+	lo, hi := d.bounds()
+	if d.Log {
+		v = math.Log(v)
+	}
+	return math.Min(1, math.Max(0, (v-lo)/(hi-lo)))
+}
+
+// fromUnit 将 [0, 1] 映射回取值 (超出范围时截断，整数维度四舍五入)
+func (d Dimension) fromUnit(u float64) float64 {
+	u = math.Min(1, math.Max(0, u))
+	lo, hi := d.bounds()
+	v := lo + u*(hi-lo)
+	if d.Log {
+		v = math.Exp(v)
+	}
+	if d.Kind == DimInt {
+		return math.Min(d.Max, math.Max(d.Min, math.Round(v)))
+	}
+	// 消除浮点尾差，便于去重和输出
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 10, 64), 64)
+	return v
+}
+
+// bounds 返回映射使用的区间 (整数维度向两端各扩展 0.5，使端点与中间值被取到的概率相同)
+func (d Dimension) bounds() (float64, float64) {
+	lo, hi := d.Min, d.Max
+	if d.Kind == DimInt && !d.Log {
+		lo, hi = lo-0.5, hi+0.5-1e-9
+	}
+	if d.Log {
+		return math.Log(lo), math.Log(hi)
+	}
+	return lo, hi
+}
+
+// choiceIndex 返回分类维度中取值的序号 (不存在时为 -1)
+func (d Dimension) choiceIndex(v float64) int {
+	for i, c := range d.Choices {
+		if c == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// randomSet 在整个搜索空间上均匀随机取一组参数
+func randomSet(space []Dimension, rng *rand.Rand) ParamSet {
+	set := make(ParamSet, len(space))
+	for _, d := range space {
+		set[d.Name] = d.sample(rng)
+	}
+	return set
+}
+
+// setKey 参数组合的唯一键 (用于去重)
+func setKey(space []Dimension, set ParamSet) string {
+	return set.Format(DimensionNames(space))
+}
@@ -0,0 +1,150 @@
+package optimizer
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// 遗传算法的默认设置 (GeneticConfig 中对应字段为 0 时使用)
+const (
+	DefaultPopulation    = 20
+	DefaultTournament    = 3
+	DefaultCrossoverRate = 0.9
+	DefaultMutationRate  = 0.2
+	DefaultMutationScale = 0.1
+
+	maxProposalAttempts = 50 // 生成一个未评估过的候选的最大尝试次数
+)
+
+// GeneticConfig 遗传算法的设置
+type GeneticConfig struct {
+	Population    int     // 每代个体数 (即每批候选数)
+	Tournament    int     // 锦标赛选择的参赛个体数
+	CrossoverRate float64 // 两个父代做均匀交叉的概率 (否则直接复制一个父代)
+	MutationRate  float64 // 每个基因的变异概率
+	MutationScale float64 // 连续/整数基因的高斯变异标准差 (占取值范围的比例)
+}
+
+// Genetic 遗传算法：第一代在搜索空间中均匀随机采样，之后每一代由锦标赛选出父代，
+// 经均匀交叉和高斯变异产生子代；父代与子代合并后保留分数最高的 Population 个 ((μ+λ) 选择)。
+// 已评估过的参数组合不会重复提出
+type Genetic struct {
+	space []Dimension
+	cfg   GeneticConfig
+	rng   *rand.Rand
+	pool  []Trial // 当前父代 (按分数从高到低)
+	seen  map[string]bool
+}
+
+// NewGenetic 创建遗传算法，相同的 seed 和评估结果得到相同的搜索轨迹
+func NewGenetic(space []Dimension, cfg GeneticConfig, seed int64) *Genetic {
+	if cfg.Population <= 0 {
+		cfg.Population = DefaultPopulation
+	}
+	if cfg.Tournament <= 0 {
+		cfg.Tournament = DefaultTournament
+	}
+	if cfg.CrossoverRate <= 0 {
+		cfg.CrossoverRate = DefaultCrossoverRate
+	}
+	if cfg.MutationRate <= 0 {
+		cfg.MutationRate = DefaultMutationRate
+	}
+	if cfg.MutationScale <= 0 {
+		cfg.MutationScale = DefaultMutationScale
+	}
+	return &Genetic{
+		space: space,
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(seed)),
+		seen:  make(map[string]bool),
+	}
+}
+
+// Name 实现 Sampler 接口
+func (g *Genetic) Name() string {
+	return fmt.Sprintf("genetic population=%d tournament=%d crossover=%g mutation=%g scale=%g",
+		g.cfg.Population, g.cfg.Tournament, g.cfg.CrossoverRate, g.cfg.MutationRate, g.cfg.MutationScale)
+}
+
+// Ask 实现 Sampler 接口：返回下一代的全部个体
+func (g *Genetic) Ask() []ParamSet {
+	children := make([]ParamSet, 0, g.cfg.Population)
+	for len(children) < g.cfg.Population {
+		child, ok := g.propose()
+		if !ok {
+			break // 搜索空间中已找不到未评估的组合
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+// propose 生成一个未评估过的个体：先尝试交叉变异，多次重复后改为随机采样
+func (g *Genetic) propose() (ParamSet, bool) {
+	for attempt := 0; attempt < 2*maxProposalAttempts; attempt++ {
+		var child ParamSet
+		if len(g.pool) == 0 || attempt >= maxProposalAttempts {
+			child = randomSet(g.space, g.rng)
+		} else {
+			child = g.offspring()
+		}
+		if key := setKey(g.space, child); !g.seen[key] {
+			g.seen[key] = true
+			return child, true
+		}
+	}
+	return nil, false
+}
+
+// offspring 选择父代并交叉、变异产生一个子代
+func (g *Genetic) offspring() ParamSet {
+	first := g.tournament()
+	child := make(ParamSet, len(g.space))
+	for name, v := range first.Params {
+		child[name] = v
+	}
+
+	if g.rng.Float64() < g.cfg.CrossoverRate {
+		second := g.tournament()
+		for _, d := range g.space {
+			if g.rng.Float64() < 0.5 {
+				child[d.Name] = second.Params[d.Name]
+			}
+		}
+	}
+
+	for _, d := range g.space {
+		if g.rng.Float64() >= g.cfg.MutationRate {
+			continue
+		}
+		if d.Kind == DimCategorical {
+			child[d.Name] = d.sample(g.rng)
+			continue
+		}
+		child[d.Name] = d.fromUnit(d.toUnit(child[d.Name]) + g.rng.NormFloat64()*g.cfg.MutationScale)
+	}
+	return child
+}
+
+// tournament 从父代中随机抽取 Tournament 个个体，返回分数最高的一个
+func (g *Genetic) tournament() Trial {
+	best := g.pool[g.rng.Intn(len(g.pool))]
+	for i := 1; i < g.cfg.Tournament; i++ {
+		if contender := g.pool[g.rng.Intn(len(g.pool))]; contender.Score > best.Score {
+			best = contender
+		}
+	}
+	return best
+}
+
+// Tell 实现 Sampler 接口：父代与子代合并，保留分数最高的 Population 个作为下一代的父代
+func (g *Genetic) Tell(trials []Trial) {
+	for _, trial := range trials {
+		g.seen[setKey(g.space, trial.Params)] = true
+	}
+	g.pool = Rank(append(g.pool, trials...))
+	if len(g.pool) > g.cfg.Population {
+		g.pool = g.pool[:g.cfg.Population]
+	}
+}
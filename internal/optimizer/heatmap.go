@@ -0,0 +1,172 @@
+package optimizer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+// Heatmap 两个参数构成的二维切片：每个格子取其余参数任意取值下的最高分数
+type Heatmap struct {
+	X, Y    string
+	XValues []float64
+	YValues []float64
+	Cells   [][]float64 // Cells[y][x]，没有有效结果的格子为 NaN
+}
+
+// BuildHeatmap 从评估结果构建 x/y 两个参数的热力图
+func BuildHeatmap(trials []Trial, x ParamRange, y ParamRange) Heatmap {
+	h := Heatmap{X: x.Name, Y: y.Name, XValues: x.Values, YValues: y.Values}
+
+	xIndex := valueIndex(x.Values)
+	yIndex := valueIndex(y.Values)
+	h.Cells = make([][]float64, len(y.Values))
+	for i := range h.Cells {
+		h.Cells[i] = make([]float64, len(x.Values))
+		for j := range h.Cells[i] {
+			h.Cells[i][j] = math.NaN()
+		}
+	}
+
+	for _, trial := range trials {
+		if math.IsInf(trial.Score, 0) || math.IsNaN(trial.Score) {
+			continue
+		}
+		xi, okX := xIndex[trial.Params[x.Name]]
+		yi, okY := yIndex[trial.Params[y.Name]]
+		if !okX || !okY {
+			continue
+		}
+		if cell := h.Cells[yi][xi]; math.IsNaN(cell) || trial.Score > cell {
+			h.Cells[yi][xi] = trial.Score
+		}
+	}
+	return h
+}
+
+// Heatmaps 为每一对扫描参数 (取值多于一个) 构建热力图
+func Heatmaps(trials []Trial, ranges []ParamRange) []Heatmap {
+	var maps []Heatmap
+	for i := 0; i < len(ranges); i++ {
+		for j := i + 1; j < len(ranges); j++ {
+			if len(ranges[i].Values) < 2 || len(ranges[j].Values) < 2 {
+				continue
+			}
+			maps = append(maps, BuildHeatmap(trials, ranges[i], ranges[j]))
+		}
+	}
+	return maps
+}
+
+func valueIndex(values []float64) map[float64]int {
+	index := make(map[float64]int, len(values))
+	for i, v := range values {
+		index[v] = i
+	}
+	return index
+}
+
+// bounds 有效格子的最小值和最大值
+func (h Heatmap) bounds() (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, row := range h.Cells {
+		for _, v := range row {
+			if !math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	return lo, hi
+}
+
+// WriteText 以终端表格输出热力图 (行是 Y 参数，列是 X 参数)
+func (h Heatmap) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%s \\ %s\t", h.Y, h.X)
+	for _, x := range h.XValues {
+		fmt.Fprintf(tw, "%s\t", FormatValue(x))
+	}
+	fmt.Fprintln(tw)
+	for yi, y := range h.YValues {
+		fmt.Fprintf(tw, "%s\t", FormatValue(y))
+		for xi := range h.XValues {
+			if v := h.Cells[yi][xi]; math.IsNaN(v) {
+				fmt.Fprint(tw, "-\t")
+			} else {
+				fmt.Fprintf(tw, "%.3f\t", v)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// WriteHeatmapsHTML 将多张热力图写入一个无外部依赖的 HTML 文件 (内联 SVG，颜色从红到绿表示分数从低到高)
+func WriteHeatmapsHTML(w io.Writer, title string, objective string, maps []Heatmap) error {
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>%[1]s</title>
+<style>body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 20px; } h2 { font-size: 16px; margin-top: 28px; }</style>
+</head><body><h1>%[1]s</h1><p>Objective: %[2]s (best over the remaining parameters)</p>
+`, html.EscapeString(title), html.EscapeString(objective))
+
+	for _, h := range maps {
+		fmt.Fprintf(w, "<h2>%s × %s</h2>\n", html.EscapeString(h.Y), html.EscapeString(h.X))
+		h.writeSVG(w)
+	}
+	_, err := fmt.Fprintln(w, "</body></html>")
+	return err
+}
+
+// writeSVG 绘制一张热力图
+func (h Heatmap) writeSVG(w io.Writer) {
+	const cell, left, top = 56.0, 90.0, 10.0
+	width := left + cell*float64(len(h.XValues)) + 10
+	height := top + cell*float64(len(h.YValues)) + 50
+	lo, hi := h.bounds()
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f">`, width, height)
+	for yi, y := range h.YValues {
+		// Y 轴从下到上递增
+		py := top + cell*float64(len(h.YValues)-1-yi)
+		fmt.Fprintf(w, `<text x="%.0f" y="%.0f" font-size="11" text-anchor="end">%s</text>`, left-6, py+cell/2+4, FormatValue(y))
+		for xi := range h.XValues {
+			px := left + cell*float64(xi)
+			v := h.Cells[yi][xi]
+			label, color := "-", "#e0e0e0"
+			if !math.IsNaN(v) {
+				label, color = fmt.Sprintf("%.2f", v), heatColor(v, lo, hi)
+			}
+			fmt.Fprintf(w, `<rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" fill="%s" stroke="white"><title>%s=%s %s=%s: %s</title></rect>`,
+				px, py, cell, cell, color,
+				html.EscapeString(h.X), FormatValue(h.XValues[xi]), html.EscapeString(h.Y), FormatValue(y), label)
+			fmt.Fprintf(w, `<text x="%.0f" y="%.0f" font-size="11" text-anchor="middle">%s</text>`, px+cell/2, py+cell/2+4, label)
+		}
+	}
+	for xi, x := range h.XValues {
+		fmt.Fprintf(w, `<text x="%.0f" y="%.0f" font-size="11" text-anchor="middle">%s</text>`,
+			left+cell*float64(xi)+cell/2, top+cell*float64(len(h.YValues))+16, FormatValue(x))
+	}
+	fmt.Fprintf(w, `<text x="%.0f" y="%.0f" font-size="12" text-anchor="middle">%s</text>`,
+		left+cell*float64(len(h.XValues))/2, height-6, html.EscapeString(h.X))
+	fmt.Fprintf(w, `<text x="12" y="%.0f" font-size="12" transform="rotate(-90 12 %.0f)" text-anchor="middle">%s</text>`,
+		top+cell*float64(len(h.YValues))/2, top+cell*float64(len(h.YValues))/2, html.EscapeString(h.Y))
+	fmt.Fprintln(w, "</svg>")
+}
+
+// heatColor 将分数线性映射为红 (最低) -> 黄 -> 绿 (最高)
+func heatColor(v, lo, hi float64) string {
+	t := 0.5
+	if hi > lo {
+		t = (v - lo) / (hi - lo)
+	}
+	var r, g float64
+	if t < 0.5 {
+		r, g = 230, 80+t*2*150
+	} else {
+		r, g = 230-(t-0.5)*2*180, 200
+	}
+	return fmt.Sprintf("rgb(%.0f,%.0f,90)", r, g)
+}
@@ -0,0 +1,194 @@
+package optimizer
+
+import (
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Param 一个可优化的策略/风控参数，应用到配置中的每个交易实例
+type Param struct {
+	Name    string
+	Integer bool // 整数参数 (例如指标周期)，取值时四舍五入
+	apply   func(instance *service.InstanceConfig, value float64)
+}
+
+// params 所有可扫描的参数 (名称与配置字段一致)
+var params = map[string]Param{
+	"TrendThreshold": {Name: "TrendThreshold", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.TrendThreshold = v
+	}},
+	"ATRVolThreshold": {Name: "ATRVolThreshold", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.ATRVolThreshold = v
+	}},
+	"ADXTrendThreshold": {Name: "ADXTrendThreshold", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.ADXTrendThreshold = v
+	}},
+	"ChannelWidthThreshold": {Name: "ChannelWidthThreshold", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.ChannelWidthThreshold = v
+	}},
+	"VolPercentileThreshold": {Name: "VolPercentileThreshold", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.VolPercentileThreshold = v
+	}},
+	"RangingStopATRFactor": {Name: "RangingStopATRFactor", apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.RangingStopATRFactor = v
+	}},
+	"MAPeriod": {Name: "MAPeriod", Integer: true, apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.MAPeriod = int(v)
+	}},
+	"RSIPeriod": {Name: "RSIPeriod", Integer: true, apply: func(i *service.InstanceConfig, v float64) {
+		i.Strategy.RSIPeriod = int(v)
+	}},
+	"DefaultRiskRewardRatio": {Name: "DefaultRiskRewardRatio", apply: func(i *service.InstanceConfig, v float64) {
+		i.Risk.DefaultRiskRewardRatio = v
+	}},
+	"DefaultStopLossATRMultiplier": {Name: "DefaultStopLossATRMultiplier", apply: func(i *service.InstanceConfig, v float64) {
+		i.Risk.DefaultStopLossATRMultiplier = v
+	}},
+	"MaxPerTradeRisk": {Name: "MaxPerTradeRisk", apply: func(i *service.InstanceConfig, v float64) {
+		i.Risk.MaxPerTradeRisk = v
+	}},
+	"TrailingATRMultiplier": {Name: "TrailingATRMultiplier", apply: func(i *service.InstanceConfig, v float64) {
+		i.Risk.TrailingStop.ATRMultiplier = v
+	}},
+}
+
+// ParamNames 返回所有可扫描的参数名 (排序)
+func ParamNames() []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupParam 按名称查找参数
+func LookupParam(name string) (Param, bool) {
+	p, ok := params[name]
+	return p, ok
+}
+
+// ParamSet 一组参数取值 (参数名 -> 值)
+type ParamSet map[string]float64
+
+// Format 按给定的参数顺序输出 "Name=Value" 列表
+func (s ParamSet) Format(names []string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, FormatValue(s[name])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatValue 以最短的形式输出参数值
+func FormatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// ParamRange 一个参数的候选取值
+type ParamRange struct {
+	Name   string
+	Values []float64
+}
+
+// ParseRange 解析参数范围，支持两种写法：
+//
+//	Name=min:max:step  (闭区间等差序列)
+//	Name=v1,v2,v3      (显式列表)
+func ParseRange(spec string) (ParamRange, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return ParamRange{}, fmt.Errorf("param spec %q must be Name=min:max:step or Name=v1,v2", spec)
+	}
+	name := strings.TrimSpace(parts[0])
+	param, ok := params[name]
+	if !ok {
+		return ParamRange{}, fmt.Errorf("unknown param %q (available: %s)", name, strings.Join(ParamNames(), ", "))
+	}
+
+	var values []float64
+	if bounds := strings.Split(parts[1], ":"); len(bounds) == 3 {
+		var nums [3]float64
+		for i, b := range bounds {
+			v, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+			if err != nil {
+				return ParamRange{}, fmt.Errorf("param %s: %w", name, err)
+			}
+			nums[i] = v
+		}
+		min, max, step := nums[0], nums[1], nums[2]
+		if step <= 0 || max < min {
+			return ParamRange{}, fmt.Errorf("param %s: invalid range %s", name, parts[1])
+		}
+		// 按步数计算，避免浮点累加误差
+		for i := 0; ; i++ {
+			v := min + float64(i)*step
+			if v > max+step*1e-9 {
+				break
+			}
+			values = append(values, v)
+		}
+	} else {
+		for _, item := range strings.Split(parts[1], ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				return ParamRange{}, fmt.Errorf("param %s: %w", name, err)
+			}
+			values = append(values, v)
+		}
+	}
+
+	return ParamRange{Name: name, Values: normalizeValues(param, values)}, nil
+}
+
+// normalizeValues 整数参数四舍五入，并去掉重复值 (保持原顺序)
+func normalizeValues(param Param, values []float64) []float64 {
+	seen := make(map[float64]bool, len(values))
+	out := make([]float64, 0, len(values))
+	for _, v := range values {
+		if param.Integer {
+			v = math.Round(v)
+		} else {
+			// 消除等差序列的浮点尾差，例如 0.30000000000000004
+			v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 10, 64), 64)
+		}
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// RangeNames 返回范围列表中的参数名 (保持顺序)
+func RangeNames(ranges []ParamRange) []string {
+	names := make([]string, len(ranges))
+	for i, r := range ranges {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// ApplyParams 返回应用了参数的配置副本 (参数作用于每个交易实例，原配置不受影响)
+func ApplyParams(cfg *service.Config, set ParamSet) (*service.Config, error) {
+	out := *cfg
+	out.Instances = make(map[string]service.InstanceConfig, len(cfg.Instances))
+	for name, instance := range cfg.Instances {
+		for paramName, value := range set {
+			param, ok := params[paramName]
+			if !ok {
+				return nil, fmt.Errorf("unknown param %q", paramName)
+			}
+			if param.Integer {
+				value = math.Round(value)
+			}
+			param.apply(&instance, value)
+		}
+		out.Instances[name] = instance
+	}
+	return &out, nil
+}
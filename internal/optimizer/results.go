@@ -0,0 +1,132 @@
+package optimizer
+
+import (
+	"crypto-algo-trader/internal/analytics"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteTable 以终端表格输出排序后的评估结果 (top <= 0 时输出全部)
+func WriteTable(w io.Writer, names []string, objective string, ranked []Trial, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprint(tw, "Rank\t")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t", name)
+	}
+	fmt.Fprintf(tw, "Score(%s)\tReturn\tSharpe\tMaxDD\tTrades\tWinRate\tPF\t\n", objective)
+
+	for i, trial := range ranked {
+		if top > 0 && i >= top {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t", i+1)
+		for _, name := range names {
+			fmt.Fprintf(tw, "%s\t", FormatValue(trial.Params[name]))
+		}
+		if trial.Report == nil {
+			fmt.Fprintf(tw, "error: %v\t\t\t\t\t\t\t\n", trial.Err)
+			continue
+		}
+		r := trial.Report
+		fmt.Fprintf(tw, "%s\t%.2f%%\t%.2f\t%.2f%%\t%d\t%.1f%%\t%s\t\n",
+			formatScore(trial.Score), r.TotalReturn*100, r.Sharpe, r.MaxDrawdown*100, r.Trades, r.WinRate*100, formatScore(r.ProfitFactor))
+	}
+	return tw.Flush()
+}
+
+// WriteCSV 以 CSV 输出全部评估结果 (每组参数一行，score 列为优化目标分数)
+func WriteCSV(w io.Writer, names []string, ranked []Trial) error {
+	cw := csv.NewWriter(w)
+
+	header := append([]string{"rank"}, names...)
+	header = append(header, "score", "total_return", "annualized_return", "sharpe", "sortino", "calmar",
+		"max_drawdown", "trades", "win_rate", "profit_factor", "expectancy", "net_pnl", "fees", "error")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i, trial := range ranked {
+		row := []string{strconv.Itoa(i + 1)}
+		for _, name := range names {
+			row = append(row, FormatValue(trial.Params[name]))
+		}
+		if trial.Report == nil {
+			row = append(row, make([]string, 12)...)
+			row = append(row, fmt.Sprint(trial.Err))
+		} else {
+			r := trial.Report
+			row = append(row, formatScore(trial.Score),
+				ftoa(r.TotalReturn), ftoa(r.AnnualizedReturn), ftoa(r.Sharpe), ftoa(r.Sortino), ftoa(r.Calmar),
+				ftoa(r.MaxDrawdown), strconv.Itoa(r.Trades), ftoa(r.WinRate), formatScore(r.ProfitFactor),
+				ftoa(r.Expectancy), ftoa(r.NetPnL), ftoa(r.TotalFees), "")
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+// formatScore 输出分数，无穷大输出为 inf / -inf
+func formatScore(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	case math.IsNaN(v):
+		return "n/a"
+	}
+	return fmt.Sprintf("%.4f", v)
+}
+
+// WriteWalkForward 以终端表格输出各窗口结果、参数稳定性和整体效率
+func WriteWalkForward(w io.Writer, result *WalkForwardResult, names []string, objective string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Window\tTrain\tTest\tBest Params\tIS %s\tIS Return\tOOS %s\tOOS Return\tOOS Trades\tWFE\n", objective, objective)
+	for _, wr := range result.Windows {
+		oosScore := math.NaN()
+		if wr.OutOfSample != nil && wr.Best.Report != nil {
+			oosScore = objectiveOf(objective, wr.OutOfSample)
+		}
+		fmt.Fprintf(tw, "%d\t%s -> %s\t%s -> %s\t%s\t%s\t%.2f%%\t%s\t%.2f%%\t%d\t%s\n",
+			wr.Index,
+			wr.TrainStart.UTC().Format("2006-01-02"), wr.TrainEnd.UTC().Format("2006-01-02"),
+			wr.TestStart.UTC().Format("2006-01-02"), wr.TestEnd.UTC().Format("2006-01-02"),
+			wr.Best.Params.Format(names),
+			formatScore(wr.Best.Score), wr.Best.Report.TotalReturn*100,
+			formatScore(oosScore), wr.OutOfSample.TotalReturn*100, wr.OutOfSample.Trades,
+			formatScore(wr.Efficiency))
+	}
+
+	fmt.Fprintf(tw, "\nParam\tMean\tStd\tCV\tMin\tMax\tDistinct\tChanges\n")
+	for _, s := range result.Stability {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%s\t%s\t%d\t%d/%d\n",
+			s.Name, FormatValue(s.Mean), FormatValue(s.Std), s.CV, FormatValue(s.Min), FormatValue(s.Max),
+			s.Distinct, s.Changes, len(result.Windows)-1)
+	}
+
+	fmt.Fprintf(tw, "\nWalk-Forward Efficiency\t%s\n", formatScore(result.Efficiency))
+	fmt.Fprintf(tw, "Recommended Params\t%s (best in %.0f%% of windows)\n", result.Recommended.Format(names), result.RecommendedShare*100)
+	return tw.Flush()
+}
+
+// objectiveOf 按目标名称计算报告的分数 (未知目标返回 NaN)
+func objectiveOf(name string, report *analytics.Report) float64 {
+	objective, err := analytics.ParseObjective(name)
+	if err != nil {
+		return math.NaN()
+	}
+	return objective.Score(report)
+}
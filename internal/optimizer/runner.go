@@ -0,0 +1,144 @@
+package optimizer
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/service"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Trial 一组参数的一次回测评估
+type Trial struct {
+	Index  int // 在候选列表中的序号
+	Params ParamSet
+	Report *analytics.Report
+	Score  float64 // 优化目标分数 (失败或交易数不足时为 -Inf)
+	Err    error
+}
+
+// Dataset 一段回测数据：Events 中早于 TradeFrom 的部分只用于预热指标，不交易也不计入绩效
+type Dataset struct {
+	Events    []backtest.Event
+	TradeFrom time.Time // 零值表示全部事件都参与交易
+}
+
+// Runner 用回测引擎并行评估参数组合。每次评估都创建独立的引擎和模拟账户，
+// 事件数据在内存中只读共享，因此多个 goroutine 可以安全地同时回放同一份数据。
+type Runner struct {
+	Config    *service.Config // 基础配置 (参数覆盖在其副本上)
+	Objective analytics.Objective
+	Options   analytics.Options
+	Workers   int // 并行的回测数量，<= 0 时为 CPU 核数
+	MinTrades int // 交易数少于该值的结果不参与排名 (分数记为 -Inf)
+
+	logger       *zap.SugaredLogger
+	engineLogger *zap.SugaredLogger // 回测引擎使用的日志 (只输出 Error，避免逐笔信号日志)
+}
+
+// NewRunner 创建参数评估器
+func NewRunner(cfg *service.Config, objective analytics.Objective, logger *zap.SugaredLogger) *Runner {
+	return &Runner{
+		Config:       cfg,
+		Objective:    objective,
+		logger:       logger,
+		engineLogger: logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar(),
+	}
+}
+
+// Run 并行评估全部参数组合，结果顺序与 sets 一致。ctx 取消时未开始的评估返回 ctx 的错误
+func (r *Runner) Run(ctx context.Context, data Dataset, sets []ParamSet) []Trial {
+	return r.run(ctx, data, sets, true)
+}
+
+// run 同 Run，progress 为 false 时不输出进度日志 (Search 按批次调用，自行输出进度)
+func (r *Runner) run(ctx context.Context, data Dataset, sets []ParamSet, progress bool) []Trial {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	trials := make([]Trial, len(sets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	done := 0
+	progressStep := len(sets)/20 + 1 // 大约每 5% 输出一次进度
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				trials[index] = r.Evaluate(ctx, data, sets[index])
+				trials[index].Index = index
+
+				doneMu.Lock()
+				done++
+				if progress && (done%progressStep == 0 || done == len(sets)) {
+					r.logger.Infow("Optimizer progress", "Done", done, "Total", len(sets))
+				}
+				doneMu.Unlock()
+			}
+		}()
+	}
+	for index := range sets {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	return trials
+}
+
+// Evaluate 用一组参数回测 data 并计算目标分数
+func (r *Runner) Evaluate(ctx context.Context, data Dataset, set ParamSet) Trial {
+	trial := Trial{Params: set, Score: math.Inf(-1)}
+	if err := ctx.Err(); err != nil {
+		trial.Err = err
+		return trial
+	}
+
+	result, err := r.Backtest(ctx, data, set)
+	if err != nil {
+		trial.Err = err
+		return trial
+	}
+
+	trial.Report = analytics.Analyze(result.Trades, result.EquityCurve, r.Options)
+	if trial.Report.Trades >= r.MinTrades {
+		trial.Score = r.Objective.Score(trial.Report)
+	}
+	return trial
+}
+
+// Backtest 用一组参数完整回测 data，返回原始回测结果
+func (r *Runner) Backtest(ctx context.Context, data Dataset, set ParamSet) (*backtest.Result, error) {
+	cfg, err := ApplyParams(r.Config, set)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := backtest.NewEngine(cfg, r.engineLogger)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetWarmupUntil(data.TradeFrom)
+	return engine.Run(ctx, backtest.NewSliceSource(data.Events))
+}
+
+// Rank 按分数从高到低排序 (分数相同时保持候选顺序)，返回新的切片
+func Rank(trials []Trial) []Trial {
+	ranked := make([]Trial, len(trials))
+	copy(ranked, trials)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
@@ -0,0 +1,132 @@
+package optimizer
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Sampler 自适应搜索算法 (遗传算法、TPE)：按批次提出候选参数，并根据评估结果调整后续的候选。
+// 相同的种子和相同的结果序列必须产生相同的候选序列，study 日志的恢复依赖这一点
+type Sampler interface {
+	Name() string        // 算法及其设置 (记录在 study 日志头部)
+	Ask() []ParamSet     // 下一批候选 (同一批内并行评估)，返回空表示搜索空间已穷尽
+	Tell(trials []Trial) // 上一批候选的评估结果 (顺序与 Ask 一致)
+}
+
+// 搜索结束的原因
+const (
+	StopMaxTrials = "max-trials" // 达到试验次数上限
+	StopEarly     = "early-stop" // 连续 Patience 次试验没有足够的提升
+	StopExhausted = "exhausted"  // 搜索算法没有新的候选
+	StopCancelled = "cancelled"  // ctx 被取消
+)
+
+// SearchConfig 自适应搜索的设置
+type SearchConfig struct {
+	MaxTrials int     // 最多评估的试验次数
+	Patience  int     // 连续多少次试验的最优分数提升不超过 MinDelta 时提前停止，<= 0 不启用
+	MinDelta  float64 // 视为有效提升的最小分数增量
+}
+
+// SearchResult 自适应搜索的结果
+type SearchResult struct {
+	Trials     []Trial // 按评估顺序 (Trial.Index 为全局序号)
+	Best       Trial
+	Resumed    int // 从 study 日志直接复用的试验数
+	StopReason string
+}
+
+// Search 用 sampler 提出的候选驱动回测，直到达到试验上限、提前停止或 ctx 被取消。
+// study 不为 nil 时，每次试验的结果都追加写入日志；日志中已有的试验不再回测
+func Search(ctx context.Context, runner *Runner, data Dataset, sampler Sampler, study *Study, cfg SearchConfig) (*SearchResult, error) {
+	result := &SearchResult{Best: Trial{Index: -1, Score: math.Inf(-1)}}
+	sinceImprovement := 0
+
+	for len(result.Trials) < cfg.MaxTrials {
+		if err := ctx.Err(); err != nil {
+			result.StopReason = StopCancelled
+			return result, err
+		}
+
+		batch := sampler.Ask()
+		if len(batch) == 0 {
+			result.StopReason = StopExhausted
+			return result, nil
+		}
+		if remaining := cfg.MaxTrials - len(result.Trials); len(batch) > remaining {
+			batch = batch[:remaining]
+		}
+
+		// 先从日志中复用已有的结果，只回测缺失的试验
+		trials := make([]Trial, len(batch))
+		var missing []int
+		var missingSets []ParamSet
+		for i, set := range batch {
+			index := len(result.Trials) + i
+			if study != nil {
+				trial, ok, err := study.Lookup(index, set)
+				if err != nil {
+					return result, err
+				}
+				if ok {
+					trials[i] = trial
+					result.Resumed++
+					continue
+				}
+			}
+			missing = append(missing, i)
+			missingSets = append(missingSets, set)
+		}
+
+		cancelled := false
+		for k, trial := range runner.run(ctx, data, missingSets, false) {
+			i := missing[k]
+			trial.Index = len(result.Trials) + i
+			trials[i] = trial
+			if errors.Is(trial.Err, context.Canceled) || errors.Is(trial.Err, context.DeadlineExceeded) {
+				cancelled = true // 被中断的试验不写入日志，恢复时重新回测
+				continue
+			}
+			if study != nil {
+				if err := study.Record(trial); err != nil {
+					return result, err
+				}
+			}
+		}
+		if cancelled {
+			result.StopReason = StopCancelled
+			return result, ctx.Err()
+		}
+
+		sampler.Tell(trials)
+		result.Trials = append(result.Trials, trials...)
+		for _, trial := range trials {
+			improved := !math.IsInf(trial.Score, -1) &&
+				(math.IsInf(result.Best.Score, -1) || trial.Score > result.Best.Score+cfg.MinDelta)
+			if trial.Score > result.Best.Score {
+				result.Best = trial
+			}
+			if improved {
+				sinceImprovement = 0
+			} else {
+				sinceImprovement++
+			}
+		}
+
+		runner.logger.Infow("Search progress",
+			"Sampler", sampler.Name(),
+			"Trials", len(result.Trials),
+			"Resumed", result.Resumed,
+			"BestTrial", result.Best.Index,
+			"BestScore", result.Best.Score,
+		)
+		if cfg.Patience > 0 && sinceImprovement >= cfg.Patience {
+			result.StopReason = StopEarly
+			return result, nil
+		}
+	}
+
+	result.StopReason = StopMaxTrials
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package optimizer
+
+import (
+	"math/rand"
+)
+
+// GridSize 笛卡尔网格的组合数量
+func GridSize(ranges []ParamRange) int {
+	if len(ranges) == 0 {
+		return 0
+	}
+	size := 1
+	for _, r := range ranges {
+		size *= len(r.Values)
+	}
+	return size
+}
+
+// Grid 生成全部参数组合 (笛卡尔积，最后一个参数变化最快)
+func Grid(ranges []ParamRange) []ParamSet {
+	size := GridSize(ranges)
+	sets := make([]ParamSet, 0, size)
+	for index := 0; index < size; index++ {
+		sets = append(sets, gridPoint(ranges, index))
+	}
+	return sets
+}
+
+// RandomSample 从笛卡尔网格中无放回地随机抽取 n 个组合 (n 不小于网格大小时返回全部组合)，
+// 相同的 seed 得到相同的抽样
+func RandomSample(ranges []ParamRange, n int, seed int64) []ParamSet {
+	size := GridSize(ranges)
+	if n >= size {
+		return Grid(ranges)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	picked := make(map[int]bool, n)
+	sets := make([]ParamSet, 0, n)
+	for len(sets) < n {
+		index := rng.Intn(size)
+		if picked[index] {
+			continue
+		}
+		picked[index] = true
+		sets = append(sets, gridPoint(ranges, index))
+	}
+	return sets
+}
+
+// gridPoint 将网格序号按混合进制展开为参数组合
+func gridPoint(ranges []ParamRange, index int) ParamSet {
+	set := make(ParamSet, len(ranges))
+	for i := len(ranges) - 1; i >= 0; i-- {
+		values := ranges[i].Values
+		set[ranges[i].Name] = values[index%len(values)]
+		index /= len(values)
+	}
+	return set
+}
@@ -0,0 +1,167 @@
+package optimizer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto-algo-trader/internal/analytics"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+)
+
+// StudyHeader study 日志的第一行：决定搜索轨迹的全部设置。恢复时必须与日志中的完全一致，
+// 否则同一序号的试验会提出不同的参数
+type StudyHeader struct {
+	Sampler   string   // 搜索算法及其设置 (Sampler.Name)
+	Seed      int64    // 搜索算法的随机种子
+	Space     []string // 搜索空间 (Dimension.String)
+	Objective string
+	MinTrades int
+	Data      string // 数据来源描述 (例如命令行的 -data)，只用于校验
+}
+
+// StudyRecord study 日志中的一次试验 (每行一条 JSON)
+type StudyRecord struct {
+	Trial  int
+	Params ParamSet
+	Score  float64           // 目标分数，Valid 为 false 时无意义
+	Valid  bool              // 分数有效 (回测成功且交易数满足 MinTrades)
+	Report *analytics.Report `json:",omitempty"`
+	Error  string            `json:",omitempty"`
+}
+
+// Study 追加写入的试验日志。优化被中断后用同一个日志重新运行，已记录的试验直接复用结果，
+// 搜索算法按相同的随机种子重放，得到与未中断时完全相同的轨迹
+type Study struct {
+	file    *os.File
+	records map[int]StudyRecord
+}
+
+// OpenStudy 打开或创建 study 日志。已有日志的头部与 header 不一致时返回错误；
+// 最后一行不完整 (写入时被中断) 时将其丢弃
+func OpenStudy(path string, header StudyHeader) (*Study, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	study := &Study{records: make(map[int]StudyRecord)}
+	valid := 0 // 有效内容的字节数，之后的不完整行会被截断
+	if len(data) > 0 {
+		valid, err = study.load(data, header)
+		if err != nil {
+			return nil, fmt.Errorf("study %s: %w", path, err)
+		}
+	}
+
+	study.file, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := study.file.Truncate(int64(valid)); err != nil {
+		study.file.Close()
+		return nil, err
+	}
+	if _, err := study.file.Seek(0, io.SeekEnd); err != nil {
+		study.file.Close()
+		return nil, err
+	}
+	if valid == 0 {
+		if err := study.writeLine(header); err != nil {
+			study.file.Close()
+			return nil, err
+		}
+	}
+	return study, nil
+}
+
+// load 解析日志内容，返回完整行的字节数
+func (s *Study) load(data []byte, header StudyHeader) (int, error) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+	offset := 0
+	for lineNo := 0; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil // 没有换行结尾的最后一行视为不完整
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if lineNo == 0 {
+			var existing StudyHeader
+			if err := json.Unmarshal(line, &existing); err != nil {
+				return 0, fmt.Errorf("invalid header: %w", err)
+			}
+			if !reflect.DeepEqual(existing, header) {
+				return 0, fmt.Errorf("settings differ from the existing log (log: %+v, current: %+v)", existing, header)
+			}
+		} else {
+			var record StudyRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return 0, fmt.Errorf("line %d: %w", lineNo+1, err)
+			}
+			s.records[record.Trial] = record
+		}
+		offset += len(line)
+	}
+}
+
+// Len 日志中已记录的试验数量
+func (s *Study) Len() int {
+	return len(s.records)
+}
+
+// Lookup 返回已记录的试验。记录的参数与 set 不一致时返回错误 (搜索轨迹发生了变化)
+func (s *Study) Lookup(index int, set ParamSet) (Trial, bool, error) {
+	record, ok := s.records[index]
+	if !ok {
+		return Trial{}, false, nil
+	}
+	if !reflect.DeepEqual(record.Params, set) {
+		return Trial{}, false, fmt.Errorf("study trial %d was recorded with different params (%v, now %v)", index, record.Params, set)
+	}
+
+	trial := Trial{Index: index, Params: record.Params, Report: record.Report, Score: math.Inf(-1)}
+	if record.Valid {
+		trial.Score = record.Score
+	}
+	if record.Error != "" {
+		trial.Err = errors.New(record.Error)
+	}
+	return trial, true, nil
+}
+
+// Record 追加一次试验
+func (s *Study) Record(trial Trial) error {
+	record := StudyRecord{Trial: trial.Index, Params: trial.Params, Report: trial.Report}
+	if !math.IsInf(trial.Score, 0) && !math.IsNaN(trial.Score) {
+		record.Score, record.Valid = trial.Score, true
+	}
+	if trial.Err != nil {
+		record.Error = trial.Err.Error()
+	}
+	if err := s.writeLine(record); err != nil {
+		return err
+	}
+	s.records[trial.Index] = record
+	return nil
+}
+
+// Close 关闭日志文件
+func (s *Study) Close() error {
+	return s.file.Close()
+}
+
+func (s *Study) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
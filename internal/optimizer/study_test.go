@@ -0,0 +1,40 @@
+package optimizer
+
+import (
+	"crypto-algo-trader/internal/analytics"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestStudyRecordNonFiniteReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "study.jsonl")
+	header := StudyHeader{Sampler: "random", Seed: 1, Objective: "return"}
+	study, err := OpenStudy(path, header)
+	if err != nil {
+		t.Fatalf("OpenStudy: %v", err)
+	}
+
+	// 很短的回测窗口：年化收益率和 Calmar 溢出为 +Inf，不应使整个搜索失败
+	report := &analytics.Report{TotalReturn: 0.5, AnnualizedReturn: math.Inf(1), Calmar: math.Inf(1), ProfitFactor: math.Inf(1)}
+	params := ParamSet{"TrendThreshold": 60}
+	if err := study.Record(Trial{Index: 0, Params: params, Report: report, Score: 0.5}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := study.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	resumed, err := OpenStudy(path, header)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer resumed.Close()
+	trial, ok, err := resumed.Lookup(0, params)
+	if err != nil || !ok {
+		t.Fatalf("Lookup: ok=%v err=%v", ok, err)
+	}
+	if trial.Score != 0.5 || trial.Report.TotalReturn != 0.5 || trial.Report.AnnualizedReturn != 0 {
+		t.Errorf("resumed trial %+v, report %+v", trial, trial.Report)
+	}
+}
@@ -0,0 +1,256 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// TPE 的默认设置 (TPEConfig 中对应字段为 0 时使用)
+const (
+	DefaultTPEStartup    = 10
+	DefaultTPEGamma      = 0.25
+	DefaultTPECandidates = 24
+	DefaultTPEBatch      = 1
+
+	minTPEBandwidth = 0.02 // 单位区间上的最小核宽度，避免密度退化为尖峰
+)
+
+// TPEConfig Tree-structured Parzen Estimator 的设置
+type TPEConfig struct {
+	Startup    int     // 前 Startup 次试验在搜索空间中均匀随机采样
+	Gamma      float64 // 分数最高的 Gamma 比例的试验作为 "好" 的一组
+	Candidates int     // 每次建议时从 "好" 的密度中抽取并比较的候选数
+	Batch      int     // 每批提出的候选数 (同一批并行评估；越大越快，但每个候选利用的信息越少)
+}
+
+// TPE 贝叶斯优化 (TPE)：把已评估的试验按分数分为好 (l) 和差 (g) 两组，在每个维度上
+// 分别用 Parzen 窗 (连续/整数维度为截断到 [0, 1] 的高斯核，分类维度为平滑后的频率) 估计密度，
+// 从 l 中抽取候选并选择 l(x)/g(x) 最大的一个，即期望提升最大的候选。
+// 失败或交易数不足的试验归入差的一组
+type TPE struct {
+	space   []Dimension
+	cfg     TPEConfig
+	rng     *rand.Rand
+	history []Trial
+	seen    map[string]bool
+}
+
+// NewTPE 创建 TPE 优化器，相同的 seed 和评估结果得到相同的搜索轨迹
+func NewTPE(space []Dimension, cfg TPEConfig, seed int64) *TPE {
+	if cfg.Startup <= 0 {
+		cfg.Startup = DefaultTPEStartup
+	}
+	if cfg.Gamma <= 0 || cfg.Gamma >= 1 {
+		cfg.Gamma = DefaultTPEGamma
+	}
+	if cfg.Candidates <= 0 {
+		cfg.Candidates = DefaultTPECandidates
+	}
+	if cfg.Batch <= 0 {
+		cfg.Batch = DefaultTPEBatch
+	}
+	return &TPE{
+		space: space,
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(seed)),
+		seen:  make(map[string]bool),
+	}
+}
+
+// Name 实现 Sampler 接口
+func (t *TPE) Name() string {
+	return fmt.Sprintf("tpe startup=%d gamma=%g candidates=%d batch=%d",
+		t.cfg.Startup, t.cfg.Gamma, t.cfg.Candidates, t.cfg.Batch)
+}
+
+// Ask 实现 Sampler 接口
+func (t *TPE) Ask() []ParamSet {
+	good, bad := t.split()
+	if len(t.history) < t.cfg.Startup || len(good) == 0 {
+		return t.randomBatch()
+	}
+
+	l := t.estimators(good)
+	g := t.estimators(bad)
+	batch := make([]ParamSet, 0, t.cfg.Batch)
+	for len(batch) < t.cfg.Batch {
+		set, ok := t.suggest(l, g)
+		if !ok {
+			break
+		}
+		batch = append(batch, set)
+	}
+	return batch
+}
+
+// Tell 实现 Sampler 接口
+func (t *TPE) Tell(trials []Trial) {
+	for _, trial := range trials {
+		t.seen[setKey(t.space, trial.Params)] = true
+	}
+	t.history = append(t.history, trials...)
+}
+
+// randomBatch 启动阶段的均匀随机采样 (不超过剩余的启动次数)
+func (t *TPE) randomBatch() []ParamSet {
+	n := t.cfg.Batch
+	if remaining := t.cfg.Startup - len(t.history); remaining > 0 && remaining < n {
+		n = remaining
+	}
+	batch := make([]ParamSet, 0, n)
+	for len(batch) < n {
+		set, ok := t.unseen(func() ParamSet { return randomSet(t.space, t.rng) })
+		if !ok {
+			break
+		}
+		batch = append(batch, set)
+	}
+	return batch
+}
+
+// suggest 从 l 中抽取 Candidates 个候选，返回 l(x)/g(x) 最大且未评估过的一个
+func (t *TPE) suggest(l, g []parzen) (ParamSet, bool) {
+	type candidate struct {
+		set   ParamSet
+		ratio float64
+	}
+	candidates := make([]candidate, 0, t.cfg.Candidates)
+	for i := 0; i < t.cfg.Candidates; i++ {
+		set := make(ParamSet, len(t.space))
+		ratio := 0.0
+		for d, dim := range t.space {
+			v := l[d].sample(dim, t.rng)
+			set[dim.Name] = v
+			ratio += l[d].logDensity(dim, v) - g[d].logDensity(dim, v)
+		}
+		candidates = append(candidates, candidate{set: set, ratio: ratio})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].ratio > candidates[j].ratio })
+
+	for _, c := range candidates {
+		if key := setKey(t.space, c.set); !t.seen[key] {
+			t.seen[key] = true
+			return c.set, true
+		}
+	}
+	// 候选全部评估过 (小的离散空间)，退回随机采样
+	return t.unseen(func() ParamSet { return randomSet(t.space, t.rng) })
+}
+
+// unseen 重复调用 generate 直到得到未评估过的组合
+func (t *TPE) unseen(generate func() ParamSet) (ParamSet, bool) {
+	for attempt := 0; attempt < maxProposalAttempts; attempt++ {
+		set := generate()
+		if key := setKey(t.space, set); !t.seen[key] {
+			t.seen[key] = true
+			return set, true
+		}
+	}
+	return nil, false
+}
+
+// split 将历史试验按分数分为好 (前 Gamma 比例的有效试验) 和差 (其余试验) 两组
+func (t *TPE) split() ([]Trial, []Trial) {
+	ranked := Rank(t.history)
+	valid := 0
+	for valid < len(ranked) && !math.IsInf(ranked[valid].Score, -1) {
+		valid++
+	}
+	if valid == 0 {
+		return nil, ranked
+	}
+	n := int(math.Ceil(t.cfg.Gamma * float64(valid)))
+	return ranked[:n], ranked[n:]
+}
+
+// estimators 为每个维度建立 Parzen 密度估计
+func (t *TPE) estimators(trials []Trial) []parzen {
+	estimators := make([]parzen, len(t.space))
+	for d, dim := range t.space {
+		p := parzen{}
+		if dim.Kind == DimCategorical {
+			p.weights = make([]float64, len(dim.Choices))
+			for i := range p.weights {
+				p.weights[i] = 1 // 拉普拉斯平滑
+			}
+			for _, trial := range trials {
+				if i := dim.choiceIndex(trial.Params[dim.Name]); i >= 0 {
+					p.weights[i]++
+				}
+			}
+		} else {
+			for _, trial := range trials {
+				p.points = append(p.points, dim.toUnit(trial.Params[dim.Name]))
+			}
+			p.bandwidth = bandwidth(p.points)
+		}
+		estimators[d] = p
+	}
+	return estimators
+}
+
+// bandwidth Scott 规则的核宽度 (单位区间上)
+func bandwidth(points []float64) float64 {
+	if len(points) < 2 {
+		return 0.25
+	}
+	_, std := meanStd(points)
+	return math.Max(minTPEBandwidth, 1.06*std*math.Pow(float64(len(points)), -0.2))
+}
+
+// parzen 一个维度上的 Parzen 密度估计 (连续维度混合一个均匀分布的先验分量)
+type parzen struct {
+	points    []float64 // 连续/整数维度：观测值在 [0, 1] 上的位置
+	bandwidth float64
+	weights   []float64 // 分类维度：各取值的 (平滑后) 计数
+}
+
+// sample 从密度中抽取一个取值
+func (p parzen) sample(dim Dimension, rng *rand.Rand) float64 {
+	if dim.Kind == DimCategorical {
+		total := 0.0
+		for _, w := range p.weights {
+			total += w
+		}
+		r := rng.Float64() * total
+		for i, w := range p.weights {
+			if r < w {
+				return dim.Choices[i]
+			}
+			r -= w
+		}
+		return dim.Choices[len(dim.Choices)-1]
+	}
+
+	// 先验分量与每个观测点的权重相同
+	k := rng.Intn(len(p.points) + 1)
+	if k == len(p.points) {
+		return dim.fromUnit(rng.Float64())
+	}
+	return dim.fromUnit(p.points[k] + rng.NormFloat64()*p.bandwidth)
+}
+
+// logDensity 取值处的对数密度
+func (p parzen) logDensity(dim Dimension, v float64) float64 {
+	if dim.Kind == DimCategorical {
+		total := 0.0
+		for _, w := range p.weights {
+			total += w
+		}
+		i := dim.choiceIndex(v)
+		if i < 0 {
+			return math.Inf(-1)
+		}
+		return math.Log(p.weights[i] / total)
+	}
+
+	u := dim.toUnit(v)
+	density := 1.0 // 均匀先验
+	for _, x := range p.points {
+		z := (u - x) / p.bandwidth
+		density += math.Exp(-0.5*z*z) / (p.bandwidth * math.Sqrt(2*math.Pi))
+	}
+	return math.Log(density / float64(len(p.points)+1))
+}
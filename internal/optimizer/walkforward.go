@@ -0,0 +1,309 @@
+package optimizer
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/backtest"
+	"crypto-algo-trader/internal/model"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// WalkForwardConfig 滚动优化的窗口设置
+type WalkForwardConfig struct {
+	Train    time.Duration // 样本内 (优化) 窗口长度
+	Test     time.Duration // 样本外 (验证) 窗口长度
+	Step     time.Duration // 相邻窗口的推进距离，<= 0 时等于 Test (样本外窗口首尾相接)
+	Anchored bool          // true: 样本内窗口固定从数据起点开始 (逐步扩大)；false: 固定长度滚动
+	Warmup   time.Duration // 每个窗口之前额外回放的预热数据 (只更新指标，不交易)
+}
+
+// Window 一个样本内/样本外窗口
+type Window struct {
+	Index      int
+	TrainStart time.Time
+	TrainEnd   time.Time
+	TestStart  time.Time
+	TestEnd    time.Time
+}
+
+// Windows 在 [start, end) 内生成滚动或锚定的窗口。最后一个样本外窗口在数据结束处截断
+func Windows(start time.Time, end time.Time, cfg WalkForwardConfig) ([]Window, error) {
+	if cfg.Train <= 0 || cfg.Test <= 0 {
+		return nil, fmt.Errorf("walk-forward: train and test windows must be positive")
+	}
+	step := cfg.Step
+	if step <= 0 {
+		step = cfg.Test
+	}
+
+	var windows []Window
+	for k := 0; ; k++ {
+		w := Window{Index: k, TrainStart: start.Add(time.Duration(k) * step)}
+		if cfg.Anchored {
+			w.TrainStart = start
+		}
+		w.TrainEnd = start.Add(cfg.Train + time.Duration(k)*step)
+		w.TestStart = w.TrainEnd
+		w.TestEnd = w.TestStart.Add(cfg.Test)
+		if !w.TestStart.Before(end) {
+			break
+		}
+		if w.TestEnd.After(end) {
+			w.TestEnd = end
+		}
+		windows = append(windows, w)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("walk-forward: data span %s is shorter than train %s + test window", end.Sub(start), cfg.Train)
+	}
+	return windows, nil
+}
+
+// WindowResult 一个窗口的优化和验证结果
+type WindowResult struct {
+	Window
+	Best        Trial             // 样本内最优的参数组合 (Best.Report 为样本内绩效)
+	OutOfSample *analytics.Report // 最优参数在样本外窗口的绩效
+	Efficiency  float64           // 本窗口的 walk-forward 效率 (样本外收益速率 / 样本内收益速率)
+	Equity      []model.EquityPoint
+	Trades      []*model.TradeRecord
+	Transitions []model.StateTransition // 样本外窗口内的状态切换 (预热期结束时的状态记为窗口起点的一次切换)
+	Prices      map[string][]model.PricePoint
+}
+
+// ParamStability 某个参数在各窗口最优解中的稳定性
+type ParamStability struct {
+	Name     string
+	Mean     float64
+	Std      float64
+	CV       float64 // 变异系数 Std / |Mean|
+	Min      float64
+	Max      float64
+	Distinct int // 出现过的不同取值数量
+	Changes  int // 相邻窗口之间最优取值发生变化的次数
+}
+
+// WalkForwardResult 滚动优化的完整结果
+type WalkForwardResult struct {
+	Windows []WindowResult
+
+	// 拼接后的样本外结果：各窗口的净值曲线按复利首尾相接 (窗口 i 的起点等于窗口 i-1 的终点)。
+	// 交易记录保留各窗口的原始金额，因此报告中的逐笔统计 (胜率、盈亏比等) 不受缩放影响
+	Equity []model.EquityPoint
+	Trades []*model.TradeRecord
+	Report *analytics.Report
+
+	// Efficiency 整体 walk-forward 效率：样本外平均收益速率 / 样本内平均收益速率。
+	// 收益速率为窗口总收益率除以窗口时长；通常认为 >= 0.5 表示参数在样本外仍然有效
+	Efficiency       float64
+	Stability        []ParamStability
+	Recommended      ParamSet // 出现次数最多的最优参数 (次数相同时取最近的窗口)
+	RecommendedShare float64  // Recommended 作为最优参数的窗口比例
+}
+
+// WalkForward 对每个窗口在样本内评估全部候选参数，选出目标分数最高的一组，再在紧接着的样本外窗口上验证
+func WalkForward(ctx context.Context, runner *Runner, events []backtest.Event, cfg WalkForwardConfig, sets []ParamSet, names []string) (*WalkForwardResult, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("walk-forward: no events")
+	}
+	start := time.UnixMilli(events[0].Timestamp)
+	end := time.UnixMilli(events[len(events)-1].Timestamp + 1)
+	windows, err := Windows(start, end, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WalkForwardResult{}
+	for _, w := range windows {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		train := Dataset{Events: backtest.SliceEvents(events, w.TrainStart.Add(-cfg.Warmup), w.TrainEnd), TradeFrom: w.TrainStart}
+		ranked := Rank(runner.Run(ctx, train, sets))
+		best := ranked[0]
+		if best.Report == nil || math.IsInf(best.Score, -1) {
+			runner.logger.Warnw("Walk-forward window has no valid in-sample result, skipping", "Window", w.Index, "Error", best.Err)
+			continue
+		}
+
+		test := Dataset{Events: backtest.SliceEvents(events, w.TestStart.Add(-cfg.Warmup), w.TestEnd), TradeFrom: w.TestStart}
+		oos, err := runner.Backtest(ctx, test, best.Params)
+		if err != nil {
+			return result, fmt.Errorf("walk-forward window %d: %w", w.Index, err)
+		}
+
+		wr := WindowResult{
+			Window:      w,
+			Best:        best,
+			OutOfSample: analytics.Analyze(oos.Trades, oos.EquityCurve, runner.Options),
+			Equity:      oos.EquityCurve,
+			Trades:      oos.Trades,
+			Transitions: clipTransitions(oos.Transitions, w.TestStart),
+			Prices:      oos.Prices,
+		}
+		wr.Efficiency = efficiency(best.Report, w.TrainEnd.Sub(w.TrainStart), wr.OutOfSample, w.TestEnd.Sub(w.TestStart))
+		result.Windows = append(result.Windows, wr)
+
+		runner.logger.Infow("Walk-forward window finished",
+			"Window", w.Index,
+			"Params", best.Params.Format(names),
+			"InSampleScore", best.Score,
+			"OutOfSampleReturn", wr.OutOfSample.TotalReturn,
+		)
+	}
+	if len(result.Windows) == 0 {
+		return result, fmt.Errorf("walk-forward: no window produced a valid result")
+	}
+
+	result.stitch(runner.Options)
+	result.Efficiency = overallEfficiency(result.Windows)
+	result.Stability = paramStability(result.Windows, names)
+	result.Recommended, result.RecommendedShare = mostFrequent(result.Windows, names)
+	return result, nil
+}
+
+// clipTransitions 丢弃 from 之前 (预热期) 的状态切换，并把预热结束时各交易对所处的状态记为 from 时刻的一次切换
+func clipTransitions(transitions []model.StateTransition, from time.Time) []model.StateTransition {
+	var clipped []model.StateTransition
+	warm := make(map[string]model.StateTransition)
+	var symbols []string
+	for _, tr := range transitions {
+		if tr.Time.Before(from) {
+			if _, ok := warm[tr.Symbol]; !ok {
+				symbols = append(symbols, tr.Symbol)
+			}
+			warm[tr.Symbol] = tr
+			continue
+		}
+		clipped = append(clipped, tr)
+	}
+
+	initial := make([]model.StateTransition, 0, len(symbols))
+	for _, symbol := range symbols {
+		initial = append(initial, model.StateTransition{Time: from, Symbol: symbol, From: model.StateInitial, To: warm[symbol].To})
+	}
+	return append(initial, clipped...)
+}
+
+// returnRate 总收益率 / 时长 (按天)，用于比较不同长度窗口的收益
+func returnRate(report *analytics.Report, span time.Duration) float64 {
+	if report == nil || span <= 0 {
+		return 0
+	}
+	return report.TotalReturn / span.Hours() * 24
+}
+
+// efficiency 单个窗口的 walk-forward 效率 (样本内收益速率 <= 0 时无意义，返回 NaN)
+func efficiency(inSample *analytics.Report, trainSpan time.Duration, outOfSample *analytics.Report, testSpan time.Duration) float64 {
+	is := returnRate(inSample, trainSpan)
+	if is <= 0 {
+		return math.NaN()
+	}
+	return returnRate(outOfSample, testSpan) / is
+}
+
+// overallEfficiency 全部窗口的样本外平均收益速率 / 样本内平均收益速率
+func overallEfficiency(windows []WindowResult) float64 {
+	var is, oos float64
+	for _, w := range windows {
+		is += returnRate(w.Best.Report, w.TrainEnd.Sub(w.TrainStart))
+		oos += returnRate(w.OutOfSample, w.TestEnd.Sub(w.TestStart))
+	}
+	if is <= 0 {
+		return math.NaN()
+	}
+	return oos / is
+}
+
+// stitch 按复利拼接各窗口的样本外净值曲线，并计算拼接后的绩效
+func (r *WalkForwardResult) stitch(opts analytics.Options) {
+	scale := 1.0
+	for _, w := range r.Windows {
+		if len(w.Equity) == 0 {
+			continue
+		}
+		if len(r.Equity) > 0 && w.Equity[0].Equity > 0 {
+			scale = r.Equity[len(r.Equity)-1].Equity / w.Equity[0].Equity
+		}
+		for _, p := range w.Equity {
+			r.Equity = append(r.Equity, model.EquityPoint{Time: p.Time, Equity: p.Equity * scale, Balance: p.Balance * scale})
+		}
+		r.Trades = append(r.Trades, w.Trades...)
+	}
+	r.Report = analytics.Analyze(r.Trades, r.Equity, opts)
+}
+
+// paramStability 统计各参数在窗口最优解中的分布和切换次数
+func paramStability(windows []WindowResult, names []string) []ParamStability {
+	stats := make([]ParamStability, 0, len(names))
+	for _, name := range names {
+		s := ParamStability{Name: name, Min: math.Inf(1), Max: math.Inf(-1)}
+		values := make([]float64, 0, len(windows))
+		distinct := make(map[float64]bool)
+		for i, w := range windows {
+			v := w.Best.Params[name]
+			values = append(values, v)
+			distinct[v] = true
+			s.Min, s.Max = math.Min(s.Min, v), math.Max(s.Max, v)
+			if i > 0 && v != windows[i-1].Best.Params[name] {
+				s.Changes++
+			}
+		}
+		s.Distinct = len(distinct)
+		s.Mean, s.Std = meanStd(values)
+		if s.Mean != 0 {
+			s.CV = s.Std / math.Abs(s.Mean)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// mostFrequent 返回出现次数最多的最优参数组合及其占比 (次数相同时取较晚的窗口)
+func mostFrequent(windows []WindowResult, names []string) (ParamSet, float64) {
+	counts := make(map[string]int)
+	latest := make(map[string]int)
+	for i, w := range windows {
+		key := w.Best.Params.Format(names)
+		counts[key]++
+		latest[key] = i
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return latest[keys[i]] > latest[keys[j]]
+	})
+
+	best := keys[0]
+	return windows[latest[best]].Best.Params, float64(counts[best]) / float64(len(windows))
+}
+
+// meanStd 样本均值和标准差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
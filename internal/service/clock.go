@@ -0,0 +1,91 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 交易流水线读取 "当前时间" 的唯一入口。
+// 实时运行使用 RealClock；回测使用 SimulatedClock，由事件时间戳推进；测试可使用 ManualClock 手动步进。
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockAdvancer 可由事件时间推进的时钟
+type ClockAdvancer interface {
+	Advance(t time.Time)
+}
+
+// AdvanceClock 用事件时间 t 推进 clock (仅对可推进的时钟生效，RealClock 和 ManualClock 不受影响)
+func AdvanceClock(clock Clock, t time.Time) {
+	if advancer, ok := clock.(ClockAdvancer); ok {
+		advancer.Advance(t)
+	}
+}
+
+// RealClock 系统时钟
+type RealClock struct{}
+
+// Now 实现 Clock 接口
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// SimulatedClock 由事件时间戳驱动的时钟：时间只会向前推进，Now 返回已观察到的最新事件时间
+type SimulatedClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewSimulatedClock 创建以 start 为起点的事件时钟
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start}
+}
+
+// Now 实现 Clock 接口
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Advance 实现 ClockAdvancer 接口，早于当前时间的事件不会让时钟倒退
+func (c *SimulatedClock) Advance(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// ManualClock 手动步进的时钟，只能通过 Set 和 Step 改变时间
+type ManualClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewManualClock 创建以 start 为起点的手动时钟
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now 实现 Clock 接口
+func (c *ManualClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Set 将时钟设置为 t (允许倒退，便于测试)
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Step 将时钟前进 d
+func (c *ManualClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -3,19 +3,70 @@ package service
 
 import (
 	"log"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
 type InstanceConfig struct {
-	Symbol   string
-	Risk     RiskConfig
-	Strategy StrategyConfig
+	Symbol     string
+	Allocation float64 // 该实例在共享模拟账户中可占用的最大保证金 (USD)，0 表示不限制
+	Risk       RiskConfig
+	Strategy   StrategyConfig
+	Shadow     ShadowConfig // 影子优化器 (默认关闭)
 }
 
 type Config struct {
 	Exchange  ExchangeConfig            `mapstructure:"Exchange"`
+	Simulator SimulatorConfig           `mapstructure:"Simulator"`
 	Instances map[string]InstanceConfig `mapstructure:"Instances"`
+	Analytics AnalyticsConfig           `mapstructure:"Analytics"`
+}
+
+// AnalyticsConfig 定义了跨交易实例的行情分析 (滚动相关性、对基准的 beta、配对价差)，默认关闭
+type AnalyticsConfig struct {
+	Enabled     bool
+	Interval    string       // 使用的 K 线周期，默认 "1h"
+	Window      int          // 滚动窗口 (K 线数量)，默认 100
+	Benchmark   string       // 计算 beta 的基准交易对，默认 "BTCUSDT"
+	ADFCritical float64      // 价差 ADF 检验的临界值 (t 统计量低于该值视为协整)，默认 -3.34 (Engle-Granger 5%)
+	Pairs       []PairConfig // 计算价差和 z-score 的交易对
+}
+
+// PairConfig 定义一个配对：价差 = log(Base) - HedgeRatio * log(Quote) - 截距，对冲比例由滚动 OLS 估计
+type PairConfig struct {
+	Base   string
+	Quote  string
+	Window int // 价差的滚动窗口，默认使用 AnalyticsConfig.Window
+}
+
+// SimulatorConfig 定义了所有交易实例共享的模拟账户
+type SimulatorConfig struct {
+	InitialCapital        float64 // 账户初始资金 (USD)
+	Leverage              float64 // 默认杠杆 (实例未配置 Risk.FixedLeverage 时使用)
+	FeeRate               float64 // 交易手续费率
+	MarginMode            string  // "isolated" 逐仓 / "cross" 全仓
+	MaintenanceMarginRate float64 // 维持保证金率
+	PositionMode          string  // "net_mode" / "long_short_mode"
+	Latency               LatencyConfig
+
+	PassiveFillModel       string  // 限价单成交模型: "queue" 排队位置 (默认), "probabilistic", "touch", "through"
+	PassiveFillProbability float64 // probabilistic 模型下每笔触及挂单价的成交带来成交的概率
+	PassiveFillSeed        int64   // probabilistic 模型的随机种子
+
+	ReportFile string // 进程退出 (SIGINT/SIGTERM) 时写入 HTML 会话报告的路径，为空时不生成
+}
+
+// LatencyConfig 定义了模拟订单链路的延迟 (下单、回报、撤单)
+type LatencyConfig struct {
+	Mode         string  // "" 关闭, "fixed" 固定值, "distribution" 分布抽样, "replay" 回放实测延迟
+	SubmitMs     float64 // 下单延迟 (毫秒)
+	AckMs        float64 // 回报延迟 (毫秒)
+	CancelMs     float64 // 撤单延迟 (毫秒)
+	Distribution string  // "normal", "lognormal", "exponential"
+	StdDevRatio  float64 // 标准差 / 均值
+	Seed         int64   // 随机种子
+	ReplayFile   string  // 实测延迟文件 (CSV: kind,ms)
 }
 
 // ExchangeConfig 定义了交易所的连接信息
@@ -26,6 +77,7 @@ type ExchangeConfig struct {
 	Passphrase string // Okx 独有
 	WSURL      string
 	RESTURL    string
+	RecordFile string // 录制原始 WS 消息的文件 (每行一条，可用于回测回放)，为空时不录制
 }
 
 // RiskConfig 定义了风控和交易对信息
@@ -39,18 +91,180 @@ type RiskConfig struct {
 	DefaultStopLossATRMultiplier float64
 	DefaultRiskRewardRatio       float64
 	MinPositionSize              float64
+	PositionMode                 string // 持仓模式: "net_mode" (默认, 单向) 或 "long_short_mode" (双向/对冲)，由账户级 Simulator.PositionMode 决定
+
+	TrailingStop     TrailingStopConfig      // 持仓期间的动态止损规则
+	TakeProfitLadder []TakeProfitLadderLevel // 分批止盈阶梯 (为空时使用 DefaultRiskRewardRatio 一次性止盈)
+}
+
+// TrailingStopConfig 定义持仓期间止损的移动规则 (只会朝有利方向移动)
+type TrailingStopConfig struct {
+	Mode             string  // "" 关闭跟踪止损, "atr" ATR 跟踪止损, "chandelier" 吊灯止损
+	ATRMultiplier    float64 // 止损距离 = ATR * ATRMultiplier
+	ChandelierPeriod int     // 吊灯止损回看的 K 线数量 (最高价/最低价窗口)
+	BreakevenAtR     float64 // 浮盈达到 N 倍初始风险 (R) 后将止损移至开仓价 (0 表示关闭)
+}
+
+// TakeProfitLadderLevel 分批止盈阶梯中的一级，例如 {R: 1, SizeRatio: 0.5} 表示 1R 处平掉 50%
+// 阶梯比例之和小于 1 时，剩余仓位不设固定止盈，交由跟踪止损退出
+type TakeProfitLadderLevel struct {
+	R         float64 // 止盈距离 = 初始止损距离 * R
+	SizeRatio float64 // 平掉初始仓位的比例
 }
 
 // StrategyConfig 定义了策略启动参数
 type StrategyConfig struct {
+	Name        string             // 策略名称 (strategy.RegisterStrategy 注册的名称)，为空时使用 "regime" 趋势/震荡策略
+	Params      map[string]float64 // 自定义策略的参数 (参数名不区分大小写，通过 Param 读取)
 	DefaultMode string
 	Grid        struct {
-		InitialSpacing float64
+		InitialSpacing float64 // 网格间距：fixed / geometric 为相对参考价的比例 (0.005 = 0.5%)，atr 为 ATR 的倍数
+		SpacingMode    string  // fixed (等差，默认)、geometric (等比) 或 atr (按 ATR 缩放的等差)
+		Levels         int     // 参考价上下各布置的档数，默认 5
+		LevelSize      float64 // 每档的下单数量 (币)
+		Direction      string  // neutral (下方开多、上方开空，默认)、long (只在下方开多) 或 short (只在上方开空)
+		UpperBound     float64 // 网格价格上限，档位不超出边界，价格越过边界时暂停网格 (0 为不限)
+		LowerBound     float64 // 网格价格下限 (0 为不限)
+		ATRInterval    string  // atr 模式使用的 ATR 周期，默认 1h
+		Interval       string  // 检查越界重置和核对挂单的 K 线周期，默认 5m
+		// 高波动震荡 (HIGH_VOL_RANGING) 下的间距倍数，例如 2 表示间距加倍；进出该状态时按新间距重新布置网格 (0 或 1 为不调整)
+		HighVolSpacingMultiplier float64
 	}
 	Trend struct {
 		FastMA int
 		SlowMA int
 	}
+
+	// 状态机和信号参数 (0 表示使用默认值，参数扫描/优化时覆盖)
+	TrendThreshold       float64 // 强趋势的 H1 RSI 阈值 (多头 >= 阈值，空头 <= 100-阈值)，默认 60
+	ATRVolThreshold      float64 // 区分高/低波动震荡的 H1 ATR/价格 阈值，默认 0.0005
+	RangingStopATRFactor float64 // 低波动震荡开仓的止损 ATR 乘数，默认 0.7
+	HighVolRanging       HighVolRangingConfig
+	MAPeriod             int // 均线周期，默认 20
+	RSIPeriod            int // RSI 周期，默认 14
+	HistoryLen           int // 每个周期保留的 K 线数量，默认 100 (指标为流式计算，加长历史不增加每根 K 线的计算量)
+
+	// 状态机的可选过滤 (0 / 空表示关闭，沿用 RSI 和 ATR/价格 的判断)
+	ADXTrendThreshold     float64 // 强趋势还要求 H1 ADX >= 阈值且 +DI/-DI 方向一致，例如 25
+	ADXPeriod             int     // ADX 周期，默认 14
+	RangingChannel        string  // 用通道宽度区分高/低波动震荡: keltner 或 donchian
+	ChannelWidthThreshold float64 // 通道相对宽度 (上轨-下轨)/中轨 的阈值，超过为高波动，例如 0.02
+	ChannelPeriod         int     // 通道周期，默认 20
+
+	// 自适应波动率阈值：按 H1 波动率在滚动窗口内的百分位区分高/低波动震荡 (优先于通道宽度和 ATR/价格)
+	VolEstimator           string  // close_to_close, parkinson, garman_klass, rogers_satchell, yang_zhang 或 realized (逐笔)；空为关闭
+	VolPeriod              int     // 波动率窗口 (K 线数量)，默认 20
+	VolRankWindow          int     // 百分位排名的滚动窗口，默认 500
+	VolPercentileThreshold float64 // 百分位 (0~1) 达到该值为高波动，例如 0.7
+
+	// Indicators 按 K 线周期配置要计算的指标 (键 "*" 对所有周期生效)，策略通过 TAData.Value(名称) 查询。
+	// 内置指标 ma, rsi, bbands, macd, atr 总是计算，同名配置覆盖其参数
+	Indicators map[string][]IndicatorConfig
+}
+
+// HighVolRangingConfig 高波动震荡 (HIGH_VOL_RANGING) 的均值回归策略：价格越出 M5 通道外侧且 RSI 处于极值时反向开仓，
+// 止损按 ATR 放宽，目标为通道中轨。默认开启 (使用 M5 布林带)，Disabled 时该状态下不开仓
+type HighVolRangingConfig struct {
+	Disabled       bool    // 关闭高波动震荡开仓
+	Band           string  // 通道: bbands (布林带，默认) 或 keltner
+	BandPeriod     int     // keltner 通道周期，默认 20 (bbands 使用内置的 M5 布林带)
+	BandMultiplier float64 // keltner 通道的 ATR 倍数，默认 2
+	RSIOversold    float64 // 开多要求 M5 RSI 低于该值，默认 30
+	RSIOverbought  float64 // 开空要求 M5 RSI 高于该值，默认 70
+	StopATRFactor  float64 // 止损的 M5 ATR 乘数，默认 1.5 (宽幅震荡需要比低波动震荡更宽的止损)
+}
+
+// Param 返回自定义策略参数 (参数名不区分大小写)，未设置时返回 def
+func (s StrategyConfig) Param(key string, def float64) float64 {
+	for k, v := range s.Params {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return def
+}
+
+// IndicatorConfig 定义一个按名称查询的指标
+type IndicatorConfig struct {
+	Name   string             // 查询名称，例如 "ema_fast"；多输出指标另有 "名称.后缀" (bbands: upper/lower，macd: signal/hist)
+	Type   string             // 指标类型: sma, ema, rsi, bbands, macd, atr, adx, supertrend, keltner, donchian, ichimoku, chop 及波动率估计量
+	Period int                // 周期 (0 为该类型的默认值)
+	Params map[string]float64 // 其他参数，例如 bbands 的 dev/devup/devdn，macd 的 fast/slow/signal，keltner 的 atr/multiplier
+}
+
+// Param 返回指标参数 (参数名不区分大小写)，未设置时返回 def
+func (c IndicatorConfig) Param(key string, def float64) float64 {
+	for k, v := range c.Params {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return def
+}
+
+// IndicatorSet 返回按周期配置的指标，并将 Trend.FastMA/SlowMA 作为所有周期的 ema_fast / ema_slow，
+// 启用 ADX / 通道 / 波动率百分位时在 1h 上加入状态机使用的 adx / channel / vol，
+// 高波动震荡使用 keltner 通道时在 5m 上加入 hv_band (Indicators 中的同名配置优先)
+func (s StrategyConfig) IndicatorSet() map[string][]IndicatorConfig {
+	set := make(map[string][]IndicatorConfig, len(s.Indicators)+1)
+	for interval, configs := range s.Indicators {
+		set[interval] = configs
+	}
+
+	var trend []IndicatorConfig
+	if s.Trend.FastMA > 0 {
+		trend = append(trend, IndicatorConfig{Name: "ema_fast", Type: "ema", Period: s.Trend.FastMA})
+	}
+	if s.Trend.SlowMA > 0 {
+		trend = append(trend, IndicatorConfig{Name: "ema_slow", Type: "ema", Period: s.Trend.SlowMA})
+	}
+	if len(trend) > 0 {
+		set["*"] = append(trend, set["*"]...)
+	}
+
+	var regime []IndicatorConfig
+	if s.ADXTrendThreshold > 0 {
+		regime = append(regime, IndicatorConfig{Name: "adx", Type: "adx", Period: s.ADXPeriod})
+	}
+	if s.RangingChannel != "" && s.ChannelWidthThreshold > 0 {
+		regime = append(regime, IndicatorConfig{Name: "channel", Type: strings.ToLower(s.RangingChannel), Period: s.ChannelPeriod})
+	}
+	if s.VolEstimator != "" && s.VolPercentileThreshold > 0 {
+		vol := IndicatorConfig{Name: "vol", Type: strings.ToLower(s.VolEstimator), Period: s.VolPeriod}
+		if s.VolRankWindow > 0 {
+			vol.Params = map[string]float64{"rank_window": float64(s.VolRankWindow)}
+		}
+		regime = append(regime, vol)
+	}
+	if len(regime) > 0 {
+		set["1h"] = append(regime, set["1h"]...)
+	}
+	if hv := s.HighVolRanging; !hv.Disabled && strings.EqualFold(hv.Band, "keltner") {
+		band := IndicatorConfig{Name: "hv_band", Type: "keltner", Period: hv.BandPeriod}
+		if hv.BandMultiplier > 0 {
+			band.Params = map[string]float64{"multiplier": hv.BandMultiplier}
+		}
+		set["5m"] = append([]IndicatorConfig{band}, set["5m"]...)
+	}
+	return set
+}
+
+// ShadowConfig 定义影子优化器：用扰动后的参数并行运行多个影子策略 (各自独立的模拟账户)，
+// 按滚动窗口评估表现，并推荐 (或在 AutoSwap 时热切换) 表现最好的参数给主策略
+type ShadowConfig struct {
+	Enabled            bool
+	Count              int      // 扰动参数的影子数量 (另有一个使用当前参数的基准影子)，默认 4
+	Spread             float64  // 参数扰动幅度 (±比例)，默认 0.2
+	Seed               int64    // 扰动的随机种子
+	Params             []string // 参与扰动的参数名 (与 StrategyConfig/RiskConfig 字段同名)，默认全部
+	InitialCapital     float64  // 每个影子账户的初始资金，默认 Simulator.InitialCapital
+	Objective          string   // 滚动评估目标 (sharpe, sortino, return 等)，默认 sharpe
+	WindowHours        int      // 滚动评估窗口 (小时)，默认 72
+	MinTrades          int      // 窗口内至少需要的交易数，默认 5
+	MinImprovement     float64  // 候选分数至少比当前参数高出的幅度，默认 0.2
+	ConfirmEvaluations int      // 候选需要连续保持最优的评估次数 (每根 1h K 线评估一次)，默认 3
+	MinSwitchHours     int      // 两次推荐/切换之间的最小间隔 (小时)，默认 24
+	AutoSwap           bool     // 自动把最优参数热切换到主策略 (默认只记录推荐)
 }
 
 // GlobalConfig 存储加载后的全局配置
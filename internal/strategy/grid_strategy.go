@@ -0,0 +1,594 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/executor"
+	"crypto-algo-trader/internal/model"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// StrategyGrid 网格策略的名称
+const StrategyGrid = "grid"
+
+// 网格间距模式 (StrategyConfig.Grid.SpacingMode)
+const (
+	GridSpacingFixed     = "fixed"     // 等差：第 k 档 = 参考价 * (1 + k*间距)
+	GridSpacingGeometric = "geometric" // 等比：第 k 档 = 参考价 * (1+间距)^k
+	GridSpacingATR       = "atr"       // 按波动缩放的等差：第 k 档 = 参考价 + k*间距*ATR
+)
+
+// 网格方向 (StrategyConfig.Grid.Direction)
+const (
+	GridNeutral = "neutral" // 参考价下方开多、上方开空 (需要双向持仓模式)
+	GridLong    = "long"    // 只在参考价下方开多
+	GridShort   = "short"   // 只在参考价上方开空
+)
+
+// 网格参数的默认值 (配置中对应字段为 0 / 空时使用)
+const (
+	DefaultGridLevels        = 5
+	DefaultGridSpacing       = 0.005 // fixed / geometric: 0.5%
+	DefaultGridATRSpacing    = 1.0   // atr: 1 倍 ATR
+	DefaultGridATRInterval   = "1h"
+	DefaultGridCheckInterval = "5m"
+)
+
+// gridSizeEpsilon 判断订单累计成交是否达到下单数量时的容差 (成交数量由张数换算，存在浮点误差)
+const gridSizeEpsilon = 1e-9
+
+func init() {
+	RegisterStrategy(StrategyGrid, func(env *Context) (Strategy, error) {
+		return NewGridStrategy(env)
+	})
+}
+
+// GridLevelStats 网格中一档的成交统计 (按档位累计，重新布置网格后档位价格会变化)
+type GridLevelStats struct {
+	Index       int     // 相对参考价的档位 (负数在参考价下方)
+	Price       float64 // 当前网格中该档的开仓价
+	Entries     int     // 开仓成交次数
+	RoundTrips  int     // 完成的开平仓次数
+	RealizedPnL float64 // 已实现盈亏 (按该档自己的开仓价和平仓价计算，已扣除手续费)
+	Fees        float64 // 该档支付的手续费
+}
+
+// gridLevel 网格中的一档：在 price 开仓，在相邻一档 exitPrice 平仓
+type gridLevel struct {
+	index     int
+	dir       model.Direction // 下方档开多，上方档开空
+	price     float64
+	exitPrice float64
+
+	open *gridOrder // 当前的开仓挂单 (nil 表示没有，开仓单完全成交后清空)
+
+	holding    float64 // 该档持有的数量
+	entryPrice float64 // 该档的开仓成交价
+	entryFee   float64 // 尚未分摊的开仓手续费
+}
+
+// gridOrder 网格挂出的一笔限价单。部分成交时保留登记，直到累计成交达到下单数量
+type gridOrder struct {
+	id      string
+	seq     int
+	level   *gridLevel
+	action  model.ActionType // 开仓还是平仓
+	size    float64          // 下单数量
+	filled  float64          // 累计成交数量
+	missing bool             // 上次核对时已不在未完成订单中 (再次缺失才判定为丢失，留出成交回报的时间)
+}
+
+// gridFlatten 重新布置网格时市价平掉的库存 (平仓成交按各档的持仓分摊盈亏)
+type gridFlatten struct {
+	levels []gridLevel
+}
+
+// GridStrategy 网格策略：在参考价上下按间距布置限价单，每档开仓成交后在相邻一档挂平仓单，平仓成交后重新挂开仓单。
+// 价格越出网格 (或进出高波动震荡需要调整间距) 时撤单、市价平掉库存并以当前价重新布置；价格越过 UpperBound / LowerBound 时暂停，回到边界内后恢复。
+// 每档的盈亏按该档自己的开平仓价单独统计 (LevelStats)
+type GridStrategy struct {
+	BaseStrategy
+
+	idPrefix string
+	seq      int
+
+	ref      float64 // 当前网格的参考价 (0 表示尚未布置或已暂停)
+	scale    float64 // 当前网格使用的间距倍数 (高波动震荡时为 Grid.HighVolSpacingMultiplier)
+	levels   []*gridLevel
+	byOrder  map[string]*gridOrder
+	flatten  map[string]*gridFlatten // 市价平仓单 -> 被平掉的库存
+	stale    map[string]bool         // 已撤销网格的挂单 (撤单前成交时需要处理)
+	stats    map[int]*GridLevelStats
+	recenter int // 重新布置网格的次数
+}
+
+// NewGridStrategy 按 env.Instance.Strategy.Grid 创建网格策略 (配置无效时返回错误)
+func NewGridStrategy(env *Context) (*GridStrategy, error) {
+	grid := env.Instance.Strategy.Grid
+	switch strings.ToLower(grid.SpacingMode) {
+	case "", GridSpacingFixed, GridSpacingGeometric, GridSpacingATR:
+	default:
+		return nil, fmt.Errorf("invalid Grid.SpacingMode %q (fixed, geometric or atr)", grid.SpacingMode)
+	}
+	switch strings.ToLower(grid.Direction) {
+	case "", GridNeutral:
+		// 双向网格同时持有多空库存，单向持仓模式下反向开仓会被拒绝或与另一侧轧差
+		if model.PositionMode(env.Instance.Risk.PositionMode) != model.PosModeLongShort {
+			return nil, fmt.Errorf("neutral grid needs %s (Simulator.PositionMode), use Grid.Direction long or short in %s",
+				model.PosModeLongShort, orDefault(env.Instance.Risk.PositionMode, string(model.PosModeNet)))
+		}
+	case GridLong, GridShort:
+	default:
+		return nil, fmt.Errorf("invalid Grid.Direction %q (neutral, long or short)", grid.Direction)
+	}
+	if grid.LevelSize <= 0 {
+		return nil, fmt.Errorf("Grid.LevelSize must be positive, got %v", grid.LevelSize)
+	}
+	if grid.InitialSpacing < 0 || grid.Levels < 0 || grid.HighVolSpacingMultiplier < 0 {
+		return nil, fmt.Errorf("Grid.InitialSpacing, Grid.Levels and Grid.HighVolSpacingMultiplier must not be negative")
+	}
+	if grid.UpperBound > 0 && grid.LowerBound >= grid.UpperBound {
+		return nil, fmt.Errorf("Grid.LowerBound %v must be below Grid.UpperBound %v", grid.LowerBound, grid.UpperBound)
+	}
+
+	return &GridStrategy{
+		idPrefix: gridOrderPrefix(env.Name),
+		byOrder:  make(map[string]*gridOrder),
+		flatten:  make(map[string]*gridFlatten),
+		stale:    make(map[string]bool),
+		stats:    make(map[int]*GridLevelStats),
+	}, nil
+}
+
+// gridOrderPrefix 由实例名生成客户端订单号前缀 (Okx clOrdId 只允许字母和数字，最长 32 位)
+func gridOrderPrefix(name string) string {
+	var b strings.Builder
+	b.WriteString("g")
+	for _, r := range name {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) && b.Len() < 16 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// LevelStats 返回各档的成交统计 (按档位排序)
+func (s *GridStrategy) LevelStats() []GridLevelStats {
+	stats := make([]GridLevelStats, 0, len(s.stats))
+	for _, st := range s.stats {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Index < stats[j].Index })
+	return stats
+}
+
+// Reference 返回当前网格的参考价 (0 表示尚未布置或已暂停)
+func (s *GridStrategy) Reference() float64 {
+	return s.ref
+}
+
+// OnBar 实现 Strategy 接口：首次布置网格、核对丢失的挂单，价格越出网格时重新布置，越过边界时暂停
+func (s *GridStrategy) OnBar(env *Context, kline model.KLine) []model.Signal {
+	cfg := env.Instance.Strategy.Grid
+	if kline.Interval != orDefault(cfg.Interval, DefaultGridCheckInterval) {
+		return nil
+	}
+	price := kline.Close
+
+	if (cfg.UpperBound > 0 && price > cfg.UpperBound) || (cfg.LowerBound > 0 && price < cfg.LowerBound) {
+		if s.ref == 0 {
+			return nil
+		}
+		env.Logger.Infof("GRID PAUSED: price %.4f outside bounds [%.4f, %.4f]", price, cfg.LowerBound, cfg.UpperBound)
+		return s.teardown(env, price, "Grid Out Of Bounds")
+	}
+
+	if s.ref == 0 {
+		return s.layout(env, price)
+	}
+	if s.escaped(price) {
+		s.recenter++
+		env.Logger.Infof("GRID RECENTER #%d: price %.4f escaped grid around %.4f", s.recenter, price, s.ref)
+		signals := s.teardown(env, price, "Grid Recenter")
+		s.logStats(env)
+		return append(signals, s.layout(env, price)...)
+	}
+	if scale := s.spacingScale(env); scale != s.scale {
+		s.recenter++
+		env.Logger.Infof("GRID RESCALE #%d: regime %s, spacing x%.2f -> x%.2f", s.recenter, env.State.GetCurrentState(), s.scale, scale)
+		signals := s.teardown(env, price, "Grid Rescale")
+		s.logStats(env)
+		return append(signals, s.layout(env, price)...)
+	}
+	return s.reconcile(env)
+}
+
+// OnFill 实现 Strategy 接口：开仓成交后为成交的数量挂平仓单，平仓成交后统计该档盈亏，
+// 该档库存全部平掉且开仓单已完全成交时重新挂开仓单。部分成交的订单保留登记，直到累计成交达到下单数量
+func (s *GridStrategy) OnFill(env *Context, fill model.Fill) []model.Signal {
+	if flat, ok := s.flatten[fill.ClientOrderID]; ok {
+		if s.settleFlatten(flat, fill) {
+			delete(s.flatten, fill.ClientOrderID)
+		}
+		return nil
+	}
+
+	o, ok := s.byOrder[fill.ClientOrderID]
+	if !ok || fill.ClientOrderID == "" {
+		return s.orphanFill(env, fill)
+	}
+	first := o.filled == 0
+	o.filled += fill.Size
+	o.missing = false
+	lv := o.level
+	if o.filled >= o.size-gridSizeEpsilon {
+		delete(s.byOrder, o.id)
+		if lv.open == o {
+			lv.open = nil
+		}
+	}
+	st := s.levelStats(lv)
+
+	if fill.Action == model.ActionOpen {
+		lv.entryPrice = (lv.entryPrice*lv.holding + fill.Price*fill.Size) / (lv.holding + fill.Size)
+		lv.holding += fill.Size
+		lv.entryFee += fill.Fee
+		if first {
+			st.Entries++
+		}
+		st.Fees += fill.Fee
+		// 只为这笔成交的数量挂平仓单，开仓单剩余部分成交后再各自挂出
+		return []model.Signal{s.order(env, lv, model.ActionClose, fill.Size)}
+	}
+
+	size := math.Min(fill.Size, lv.holding)
+	st.RealizedPnL += s.settle(lv, size, fill.Price) - fill.Fee
+	st.Fees += fill.Fee
+	if lv.holding > 0 || lv.open != nil {
+		// 剩余库存的平仓单仍在挂单，或开仓单还有未成交部分
+		return nil
+	}
+	st.RoundTrips++
+	return []model.Signal{s.order(env, lv, model.ActionOpen, env.Instance.Strategy.Grid.LevelSize)}
+}
+
+// settle 以 price 结算 lv 的 size 数量库存，返回扣除分摊开仓手续费后的盈亏
+func (s *GridStrategy) settle(lv *gridLevel, size float64, price float64) float64 {
+	if lv.holding <= 0 || size <= 0 {
+		return 0
+	}
+	ratio := size / lv.holding
+	pnl := (price - lv.entryPrice) * size
+	if lv.dir == model.DirShort {
+		pnl = -pnl
+	}
+	fee := lv.entryFee * ratio
+	lv.entryFee -= fee
+	lv.holding -= size
+	if lv.holding <= 1e-12 {
+		lv.holding, lv.entryPrice, lv.entryFee = 0, 0, 0
+	}
+	return pnl - fee
+}
+
+// settleFlatten 将市价平仓的成交按持仓比例分摊到被平掉的各档，全部库存结算后返回 true
+func (s *GridStrategy) settleFlatten(flat *gridFlatten, fill model.Fill) bool {
+	total := 0.0
+	for _, lv := range flat.levels {
+		total += lv.holding
+	}
+	if total <= 0 {
+		return true
+	}
+	share := math.Min(fill.Size/total, 1)
+	for i := range flat.levels {
+		lv := &flat.levels[i]
+		ratio := lv.holding / total
+		st := s.levelStats(lv)
+		st.RealizedPnL += s.settle(lv, lv.holding*share, fill.Price) - fill.Fee*ratio
+		st.Fees += fill.Fee * ratio
+	}
+	return share >= 1-gridSizeEpsilon
+}
+
+// orphanFill 处理不属于当前网格的成交：已撤销网格的开仓单在撤单生效前成交时立即市价平掉；
+// 止损、强平等外部平仓使库存无法对应到档位，撤单并在下一根 K 线重新布置
+func (s *GridStrategy) orphanFill(env *Context, fill model.Fill) []model.Signal {
+	if s.stale[fill.ClientOrderID] && fill.Action == model.ActionOpen {
+		// 不移除 stale 标记：同一订单后续的部分成交也需要平掉
+		env.Logger.Warnf("GRID: canceled order %s filled before cancel, closing %.4f @ market", fill.ClientOrderID, fill.Size)
+		s.seq++
+		id := fmt.Sprintf("%s%d", s.idPrefix, s.seq)
+		s.stale[id] = true
+		return []model.Signal{{
+			Symbol:        env.Symbol,
+			Timestamp:     env.Now(),
+			Action:        model.ActionClose,
+			Direction:     fill.Direction,
+			PosSide:       fill.Direction,
+			Price:         fill.Price,
+			PositionSize:  fill.Size,
+			SourceState:   env.State.GetCurrentState(),
+			Reason:        "Grid Stale Fill",
+			ClientOrderID: id,
+		}}
+	}
+	if fill.Action == model.ActionClose && s.ref != 0 && !s.stale[fill.ClientOrderID] {
+		env.Logger.Warnf("GRID RESET: external close (%s) %.4f @ %.4f", fill.Reason, fill.Size, fill.Price)
+		for _, lv := range s.levels {
+			if lv.dir == fill.Direction {
+				s.levelStats(lv).RealizedPnL += s.settle(lv, lv.holding, fill.Price)
+			}
+		}
+		return s.teardown(env, fill.Price, "Grid Reset")
+	}
+	return nil
+}
+
+// layout 以 price 为参考价布置网格 (atr 模式下 ATR 尚未就绪时不布置)
+func (s *GridStrategy) layout(env *Context, price float64) []model.Signal {
+	cfg := env.Instance.Strategy.Grid
+	prices, ok := s.levelPrices(env, price)
+	if !ok {
+		return nil
+	}
+	n := len(prices)/2 - 1 // prices 包含 -n-1 .. n+1 档 (两端多一档作为最外侧档的平仓价)
+
+	direction := strings.ToLower(cfg.Direction)
+	s.ref = price
+	s.scale = s.spacingScale(env)
+	s.levels = s.levels[:0]
+	var signals []model.Signal
+	for k := -n; k <= n; k++ {
+		if k == 0 {
+			continue
+		}
+		lv := &gridLevel{index: k, price: prices[k+n+1]}
+		if k < 0 {
+			if direction == GridShort {
+				continue
+			}
+			lv.dir, lv.exitPrice = model.DirLong, prices[k+n+2]
+		} else {
+			if direction == GridLong {
+				continue
+			}
+			lv.dir, lv.exitPrice = model.DirShort, prices[k+n]
+		}
+		if !s.withinBounds(cfg.LowerBound, cfg.UpperBound, lv.price) {
+			continue
+		}
+		s.levels = append(s.levels, lv)
+		s.levelStats(lv).Price = lv.price
+		signals = append(signals, s.order(env, lv, model.ActionOpen, cfg.LevelSize))
+	}
+	env.Logger.Infof("GRID LAYOUT: ref %.4f, %d levels [%.4f .. %.4f], mode %s, spacing x%.2f",
+		price, len(s.levels), prices[1], prices[len(prices)-2], orDefault(cfg.SpacingMode, GridSpacingFixed), s.scale)
+	return signals
+}
+
+// levelPrices 返回 -n-1 .. n+1 档的价格 (下标 i 对应第 i-n-1 档)
+func (s *GridStrategy) levelPrices(env *Context, ref float64) ([]float64, bool) {
+	cfg := env.Instance.Strategy.Grid
+	n := cfg.Levels
+	if n <= 0 {
+		n = DefaultGridLevels
+	}
+	mode := strings.ToLower(cfg.SpacingMode)
+
+	spacing := cfg.InitialSpacing
+	step := 0.0
+	if mode == GridSpacingATR {
+		if spacing <= 0 {
+			spacing = DefaultGridATRSpacing
+		}
+		data, err := env.TA.GetTAData(orDefault(cfg.ATRInterval, DefaultGridATRInterval))
+		if err != nil || data.ATR <= 0 {
+			return nil, false
+		}
+		step = data.ATR * spacing
+	} else if spacing <= 0 {
+		spacing = DefaultGridSpacing
+	}
+	scale := s.spacingScale(env)
+	spacing, step = spacing*scale, step*scale
+
+	prices := make([]float64, 2*n+3)
+	for i := range prices {
+		k := float64(i - n - 1)
+		switch mode {
+		case GridSpacingATR:
+			prices[i] = ref + k*step
+		case GridSpacingGeometric:
+			prices[i] = ref * math.Pow(1+spacing, k)
+		default:
+			prices[i] = ref * (1 + k*spacing)
+		}
+	}
+	if prices[0] <= 0 {
+		env.Logger.Warnf("GRID: spacing too wide for price %.4f, lowest level %.4f", ref, prices[0])
+		return nil, false
+	}
+	return prices, true
+}
+
+// spacingScale 返回当前市场状态下的间距倍数：高波动震荡时为 Grid.HighVolSpacingMultiplier，其余为 1
+func (s *GridStrategy) spacingScale(env *Context) float64 {
+	mult := env.Instance.Strategy.Grid.HighVolSpacingMultiplier
+	if mult <= 0 || env.State == nil || env.State.GetCurrentState() != model.StateHighVolRanging {
+		return 1
+	}
+	return mult
+}
+
+// withinBounds 判断价格是否在网格边界内 (边界为 0 表示不限)
+func (s *GridStrategy) withinBounds(lower float64, upper float64, price float64) bool {
+	return (lower <= 0 || price >= lower) && (upper <= 0 || price <= upper)
+}
+
+// escaped 判断价格是否越出了网格 (超过最外侧档位的平仓价一侧)
+func (s *GridStrategy) escaped(price float64) bool {
+	if len(s.levels) == 0 {
+		return false
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, lv := range s.levels {
+		lo = math.Min(lo, math.Min(lv.price, lv.exitPrice))
+		hi = math.Max(hi, math.Max(lv.price, lv.exitPrice))
+	}
+	step := (hi - lo) / float64(len(s.levels))
+	return price < lo-step || price > hi+step
+}
+
+// teardown 撤销网格的全部挂单并市价平掉库存 (参考价清零，下一次布置使用新价格)
+func (s *GridStrategy) teardown(env *Context, price float64, reason string) []model.Signal {
+	signals := []model.Signal{{
+		Symbol:    env.Symbol,
+		Timestamp: env.Now(),
+		Action:    model.ActionCancel,
+		Reason:    reason,
+	}}
+	for id := range s.byOrder {
+		s.stale[id] = true
+	}
+
+	for _, dir := range []model.Direction{model.DirLong, model.DirShort} {
+		flat := &gridFlatten{}
+		size := 0.0
+		for _, lv := range s.levels {
+			if lv.dir == dir && lv.holding > 0 {
+				flat.levels = append(flat.levels, *lv)
+				size += lv.holding
+			}
+		}
+		if size <= 0 {
+			continue
+		}
+		s.seq++
+		id := fmt.Sprintf("%s%d", s.idPrefix, s.seq)
+		s.flatten[id] = flat
+		signals = append(signals, model.Signal{
+			Symbol:        env.Symbol,
+			Timestamp:     env.Now(),
+			Action:        model.ActionClose,
+			Direction:     dir,
+			PosSide:       dir,
+			Price:         price,
+			PositionSize:  size,
+			SourceState:   env.State.GetCurrentState(),
+			Reason:        reason,
+			ClientOrderID: id,
+		})
+	}
+
+	s.ref = 0
+	s.levels = nil
+	s.byOrder = make(map[string]*gridOrder)
+	return signals
+}
+
+// reconcile 核对挂单：连续两次核对都不在执行器未完成订单中 (被拒绝或撤销) 的挂单按未成交的数量重新挂出。
+// 执行器不支持列出订单时不核对
+func (s *GridStrategy) reconcile(env *Context) []model.Signal {
+	lister, ok := env.Executor.(executor.OrderLister)
+	if !ok || len(s.byOrder) == 0 {
+		return nil
+	}
+	open := make(map[string]bool)
+	for _, record := range lister.GetOpenOrders() {
+		open[record.ClientOrderID] = true
+	}
+
+	orders := make([]*gridOrder, 0, len(s.byOrder))
+	for _, o := range s.byOrder {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].seq < orders[j].seq })
+
+	var signals []model.Signal
+	for _, o := range orders {
+		if open[o.id] {
+			o.missing = false
+			continue
+		}
+		if !o.missing {
+			o.missing = true
+			continue
+		}
+		lv := o.level
+		env.Logger.Warnf("GRID: order %s for level %d lost, re-placing %s", o.id, lv.index, o.action)
+		delete(s.byOrder, o.id)
+		if lv.open == o {
+			lv.open = nil
+		}
+		signals = append(signals, s.order(env, lv, o.action, o.size-o.filled))
+	}
+	return signals
+}
+
+// order 为 lv 生成 size 数量的开仓 (在 price) 或平仓 (在 exitPrice) 限价单，并登记客户端订单号
+func (s *GridStrategy) order(env *Context, lv *gridLevel, action model.ActionType, size float64) model.Signal {
+	s.seq++
+	o := &gridOrder{
+		id:     fmt.Sprintf("%s%d", s.idPrefix, s.seq),
+		seq:    s.seq,
+		level:  lv,
+		action: action,
+		size:   size,
+	}
+	s.byOrder[o.id] = o
+	if action == model.ActionOpen {
+		lv.open = o
+	}
+
+	signal := model.Signal{
+		Symbol:        env.Symbol,
+		Timestamp:     env.Now(),
+		Action:        action,
+		Direction:     lv.dir,
+		PosSide:       lv.dir,
+		OrderType:     model.OrderLimit,
+		Price:         lv.price,
+		PositionSize:  size,
+		SourceState:   env.State.GetCurrentState(),
+		Reason:        fmt.Sprintf("Grid L%d %s", lv.index, strings.ToLower(string(action))),
+		ClientOrderID: o.id,
+		AddToPosition: true,
+	}
+	if action == model.ActionClose {
+		signal.Price = lv.exitPrice
+		signal.AddToPosition = false
+	}
+	return signal
+}
+
+// levelStats 返回档位的统计 (不存在时创建)
+func (s *GridStrategy) levelStats(lv *gridLevel) *GridLevelStats {
+	st, ok := s.stats[lv.index]
+	if !ok {
+		st = &GridLevelStats{Index: lv.index, Price: lv.price}
+		s.stats[lv.index] = st
+	}
+	return st
+}
+
+// logStats 输出各档的累计盈亏
+func (s *GridStrategy) logStats(env *Context) {
+	total := 0.0
+	for _, st := range s.LevelStats() {
+		total += st.RealizedPnL
+		env.Logger.Debugf("GRID L%d @ %.4f: entries %d, round trips %d, PnL %.4f, fees %.4f",
+			st.Index, st.Price, st.Entries, st.RoundTrips, st.RealizedPnL, st.Fees)
+	}
+	env.Logger.Infof("GRID STATS: %d levels, realized PnL %.4f", len(s.stats), total)
+}
+
+// orDefault 返回 v，为空时返回 def
+func orDefault(v string, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
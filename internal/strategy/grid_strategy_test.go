@@ -0,0 +1,195 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newTestGrid 创建参考价 100、上下各一档 (99 开多 / 101 开空，平仓价都是 100)、每档 1 个的网格，返回布置网格的信号
+func newTestGrid(t *testing.T) (*GridStrategy, *Context, []model.Signal) {
+	t.Helper()
+	instance := &service.InstanceConfig{Symbol: "BTCUSDT"}
+	instance.Risk.PositionMode = string(model.PosModeLongShort)
+	instance.Strategy.Grid.LevelSize = 1
+	instance.Strategy.Grid.Levels = 1
+	instance.Strategy.Grid.InitialSpacing = 0.01
+	env := &Context{
+		Name:     "test",
+		Symbol:   "BTCUSDT",
+		Instance: instance,
+		State:    NewStateMachine(nil, &instance.Strategy),
+		Clock:    service.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Logger:   zap.NewNop().Sugar(),
+	}
+	s, err := NewGridStrategy(env)
+	if err != nil {
+		t.Fatalf("NewGridStrategy: %v", err)
+	}
+	signals := s.OnBar(env, model.KLine{Symbol: "BTCUSDT", Interval: DefaultGridCheckInterval, Close: 100})
+	if len(signals) != 2 {
+		t.Fatalf("layout: %d signals, want 2", len(signals))
+	}
+	return s, env, signals
+}
+
+// fillOf 返回 signal 对应订单成交 size 的回报
+func fillOf(signal model.Signal, size float64) model.Fill {
+	return model.Fill{
+		Symbol:        signal.Symbol,
+		Action:        signal.Action,
+		Direction:     signal.Direction,
+		Price:         signal.Price,
+		Size:          size,
+		ClientOrderID: signal.ClientOrderID,
+	}
+}
+
+// expectOrder 检查 OnFill 恰好返回一个指定动作、价格和数量的限价单
+func expectOrder(t *testing.T, signals []model.Signal, action model.ActionType, price float64, size float64) model.Signal {
+	t.Helper()
+	if len(signals) != 1 {
+		t.Fatalf("%d signals, want one %s order", len(signals), action)
+	}
+	got := signals[0]
+	if got.Action != action || got.Price != price || math.Abs(got.PositionSize-size) > 1e-12 {
+		t.Fatalf("got %s %.4f @ %.2f, want %s %.4f @ %.2f", got.Action, got.PositionSize, got.Price, action, size, price)
+	}
+	return got
+}
+
+func expectNoOrders(t *testing.T, signals []model.Signal) {
+	t.Helper()
+	if len(signals) != 0 {
+		t.Fatalf("got %d signals (%s %.4f), want none", len(signals), signals[0].Action, signals[0].PositionSize)
+	}
+}
+
+func TestGridPartialOpenFills(t *testing.T) {
+	s, env, layout := newTestGrid(t)
+	open := layout[0]
+	if open.Direction != model.DirLong || open.Price != 99 {
+		t.Fatalf("first layout order %s @ %.2f, want long @ 99", open.Direction, open.Price)
+	}
+
+	// 每笔部分成交只为成交的数量挂平仓单，开仓单保持登记直到累计成交达到下单数量
+	exit1 := expectOrder(t, s.OnFill(env, fillOf(open, 0.4)), model.ActionClose, 100, 0.4)
+	if _, ok := s.byOrder[open.ClientOrderID]; !ok {
+		t.Fatalf("partially filled open order unregistered")
+	}
+	exit2 := expectOrder(t, s.OnFill(env, fillOf(open, 0.6)), model.ActionClose, 100, 0.6)
+	if _, ok := s.byOrder[open.ClientOrderID]; ok {
+		t.Errorf("fully filled open order still registered")
+	}
+	lv := s.byOrder[exit1.ClientOrderID].level
+	if lv.holding != 1 || lv.open != nil {
+		t.Fatalf("holding %.4f, open order %v after full fill, want 1 and none", lv.holding, lv.open)
+	}
+
+	// 平仓单部分成交不重复挂单，库存全部平掉后重新挂开仓单
+	expectNoOrders(t, s.OnFill(env, fillOf(exit1, 0.25)))
+	expectNoOrders(t, s.OnFill(env, fillOf(exit1, 0.15)))
+	expectNoOrders(t, s.OnFill(env, fillOf(exit2, 0.5)))
+	if math.Abs(lv.holding-0.1) > 1e-12 {
+		t.Fatalf("holding %.4f after closing 0.9, want 0.1", lv.holding)
+	}
+	expectOrder(t, s.OnFill(env, fillOf(exit2, 0.1)), model.ActionOpen, 99, 1)
+
+	st := s.LevelStats()[0]
+	if st.Entries != 1 || st.RoundTrips != 1 || math.Abs(st.RealizedPnL-1) > 1e-9 {
+		t.Errorf("stats: entries %d, round trips %d, PnL %.4f, want 1, 1, 1", st.Entries, st.RoundTrips, st.RealizedPnL)
+	}
+	if len(s.byOrder) != 2 {
+		t.Errorf("%d registered orders, want the re-placed open and the untouched short level", len(s.byOrder))
+	}
+}
+
+func TestGridExitBeforeOpenCompletes(t *testing.T) {
+	s, env, layout := newTestGrid(t)
+	open := layout[1]
+
+	// 开仓单只成交一半时平仓单已经成交：开仓单剩余部分仍在挂单，不重新挂开仓单
+	exit1 := expectOrder(t, s.OnFill(env, fillOf(open, 0.5)), model.ActionClose, 100, 0.5)
+	expectNoOrders(t, s.OnFill(env, fillOf(exit1, 0.5)))
+	lv := s.byOrder[open.ClientOrderID].level
+	if lv.holding != 0 || lv.open == nil {
+		t.Fatalf("holding %.4f, open order %v, want 0 with the open order still live", lv.holding, lv.open)
+	}
+
+	exit2 := expectOrder(t, s.OnFill(env, fillOf(open, 0.5)), model.ActionClose, 100, 0.5)
+	expectOrder(t, s.OnFill(env, fillOf(exit2, 0.5)), model.ActionOpen, 101, 1)
+	st := s.LevelStats()[1]
+	if st.RoundTrips != 1 || math.Abs(st.RealizedPnL-1) > 1e-9 {
+		t.Errorf("stats: round trips %d, PnL %.4f, want 1 and 1", st.RoundTrips, st.RealizedPnL)
+	}
+}
+
+func TestGridPartialFlattenFills(t *testing.T) {
+	s, env, layout := newTestGrid(t)
+	open := layout[0]
+	s.OnFill(env, fillOf(open, 1))
+
+	// 价格越出网格：撤单并市价平掉库存
+	signals := s.OnBar(env, model.KLine{Symbol: "BTCUSDT", Interval: DefaultGridCheckInterval, Close: 90})
+	var flatten model.Signal
+	for _, signal := range signals {
+		if signal.Action == model.ActionClose {
+			flatten = signal
+		}
+	}
+	if flatten.PositionSize != 1 {
+		t.Fatalf("flatten size %.4f, want 1", flatten.PositionSize)
+	}
+
+	// 市价单分多笔成交时每笔按数量结算，全部成交后才移除
+	flatten.Price = 90
+	s.OnFill(env, fillOf(flatten, 0.3))
+	if _, ok := s.flatten[flatten.ClientOrderID]; !ok {
+		t.Fatalf("flatten order removed after a partial fill")
+	}
+	s.OnFill(env, fillOf(flatten, 0.7))
+	if _, ok := s.flatten[flatten.ClientOrderID]; ok {
+		t.Errorf("flatten order still registered after the full size filled")
+	}
+	for _, st := range s.LevelStats() {
+		if st.Index == -1 && math.Abs(st.RealizedPnL+9) > 1e-9 {
+			t.Errorf("level -1 PnL %.4f, want -9", st.RealizedPnL)
+		}
+	}
+
+	// 已撤销的开仓单分多笔成交时每笔都市价平掉
+	for i := 0; i < 2; i++ {
+		stale := fillOf(layout[1], 0.5)
+		if got := s.OnFill(env, stale); len(got) != 1 || got[0].Action != model.ActionClose || got[0].PositionSize != 0.5 {
+			t.Fatalf("stale fill %d: got %v, want a 0.5 market close", i, got)
+		}
+	}
+}
+
+func TestNewGridStrategyNeutralNeedsHedgeMode(t *testing.T) {
+	tests := []struct {
+		direction    string
+		positionMode model.PositionMode
+		ok           bool
+	}{
+		{"", model.PosModeNet, false},
+		{GridNeutral, "", false},
+		{GridNeutral, model.PosModeLongShort, true},
+		{GridLong, model.PosModeNet, true},
+		{GridShort, model.PosModeNet, true},
+	}
+	for _, tt := range tests {
+		instance := &service.InstanceConfig{Symbol: "BTCUSDT"}
+		instance.Risk.PositionMode = string(tt.positionMode)
+		instance.Strategy.Grid.LevelSize = 1
+		instance.Strategy.Grid.Direction = tt.direction
+		_, err := NewGridStrategy(&Context{Name: "test", Instance: instance})
+		if (err == nil) != tt.ok {
+			t.Errorf("direction %q in %q: err = %v, want ok = %v", tt.direction, tt.positionMode, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"strings"
+)
+
+// StrategyParams 可在运行时调整的策略参数 (已解析默认值)，影子优化器在这些参数上做扰动和热切换
+type StrategyParams struct {
+	TrendThreshold               float64
+	ATRVolThreshold              float64
+	RangingStopATRFactor         float64
+	MAPeriod                     int
+	RSIPeriod                    int
+	DefaultRiskRewardRatio       float64
+	DefaultStopLossATRMultiplier float64
+}
+
+// paramAccessor 按名称读写 StrategyParams 中的一个参数
+type paramAccessor struct {
+	integer bool
+	get     func(p *StrategyParams) float64
+	set     func(p *StrategyParams, v float64)
+}
+
+// strategyParams 参数名与配置字段同名
+var strategyParams = map[string]paramAccessor{
+	"TrendThreshold": {
+		get: func(p *StrategyParams) float64 { return p.TrendThreshold },
+		set: func(p *StrategyParams, v float64) { p.TrendThreshold = v },
+	},
+	"ATRVolThreshold": {
+		get: func(p *StrategyParams) float64 { return p.ATRVolThreshold },
+		set: func(p *StrategyParams, v float64) { p.ATRVolThreshold = v },
+	},
+	"RangingStopATRFactor": {
+		get: func(p *StrategyParams) float64 { return p.RangingStopATRFactor },
+		set: func(p *StrategyParams, v float64) { p.RangingStopATRFactor = v },
+	},
+	"MAPeriod": {
+		integer: true,
+		get:     func(p *StrategyParams) float64 { return float64(p.MAPeriod) },
+		set:     func(p *StrategyParams, v float64) { p.MAPeriod = int(v) },
+	},
+	"RSIPeriod": {
+		integer: true,
+		get:     func(p *StrategyParams) float64 { return float64(p.RSIPeriod) },
+		set:     func(p *StrategyParams, v float64) { p.RSIPeriod = int(v) },
+	},
+	"DefaultRiskRewardRatio": {
+		get: func(p *StrategyParams) float64 { return p.DefaultRiskRewardRatio },
+		set: func(p *StrategyParams, v float64) { p.DefaultRiskRewardRatio = v },
+	},
+	"DefaultStopLossATRMultiplier": {
+		get: func(p *StrategyParams) float64 { return p.DefaultStopLossATRMultiplier },
+		set: func(p *StrategyParams, v float64) { p.DefaultStopLossATRMultiplier = v },
+	},
+}
+
+// strategyParamNames 固定的参数顺序 (日志输出和扰动顺序)
+var strategyParamNames = []string{
+	"TrendThreshold", "ATRVolThreshold", "RangingStopATRFactor", "MAPeriod", "RSIPeriod",
+	"DefaultRiskRewardRatio", "DefaultStopLossATRMultiplier",
+}
+
+// String 输出 "Name=Value" 列表
+func (p StrategyParams) String() string {
+	parts := make([]string, 0, len(strategyParamNames))
+	for _, name := range strategyParamNames {
+		parts = append(parts, fmt.Sprintf("%s=%.6g", name, strategyParams[name].get(&p)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ApplyTo 将参数写入实例配置 (用于创建使用这组参数的新流水线)
+func (p StrategyParams) ApplyTo(instance *service.InstanceConfig) {
+	instance.Strategy.TrendThreshold = p.TrendThreshold
+	instance.Strategy.ATRVolThreshold = p.ATRVolThreshold
+	instance.Strategy.RangingStopATRFactor = p.RangingStopATRFactor
+	instance.Strategy.MAPeriod = p.MAPeriod
+	instance.Strategy.RSIPeriod = p.RSIPeriod
+	instance.Risk.DefaultRiskRewardRatio = p.DefaultRiskRewardRatio
+	instance.Risk.DefaultStopLossATRMultiplier = p.DefaultStopLossATRMultiplier
+}
+
+// Params 返回流水线当前生效的参数 (不能在策略回调中调用)
+func (p *Pipeline) Params() StrategyParams {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	trendThreshold, atrVolThreshold := p.StateMachine.Thresholds()
+	maPeriod, rsiPeriod := p.TA.Periods()
+	return StrategyParams{
+		TrendThreshold:               trendThreshold,
+		ATRVolThreshold:              atrVolThreshold,
+		RangingStopATRFactor:         rangingStopATRFactor(&p.instance.Strategy),
+		MAPeriod:                     maPeriod,
+		RSIPeriod:                    rsiPeriod,
+		DefaultRiskRewardRatio:       p.instance.Risk.DefaultRiskRewardRatio,
+		DefaultStopLossATRMultiplier: p.instance.Risk.DefaultStopLossATRMultiplier,
+	}
+}
+
+// ApplyParams 热切换流水线参数。持有策略回调的锁修改实例配置，可以在任意 goroutine 中调用
+// (不能在策略回调中调用)；已有持仓不受影响，新参数从下一根 K 线开始生效
+func (p *Pipeline) ApplyParams(params StrategyParams) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	params.ApplyTo(p.instance)
+	p.StateMachine.SetThresholds(params.TrendThreshold, params.ATRVolThreshold)
+	p.TA.SetPeriods(params.MAPeriod, params.RSIPeriod)
+}
@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// configReader 在逐笔回调中读取实例配置的测试策略
+type configReader struct {
+	BaseStrategy
+	sum float64
+}
+
+func (s *configReader) OnTick(env *Context, ticker model.Ticker) []model.Signal {
+	s.sum += env.Instance.Risk.DefaultRiskRewardRatio + env.Instance.Strategy.RangingStopATRFactor
+	return nil
+}
+
+func init() {
+	RegisterStrategy("test_config_reader", func(env *Context) (Strategy, error) {
+		return &configReader{}, nil
+	})
+}
+
+// 参数热切换与逐笔回调在不同的 goroutine 中运行 (用 -race 检查)
+func TestApplyParamsConcurrentWithOnTicker(t *testing.T) {
+	instance := &service.InstanceConfig{Symbol: "BTCUSDT"}
+	instance.Strategy.Name = "test_config_reader"
+	pipeline, err := NewPipeline("test", instance, nil, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	started, done := make(chan struct{}), make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		close(started)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				pipeline.OnTicker(model.Ticker{Symbol: "BTCUSDT", Price: 100})
+			}
+		}
+	}()
+	<-started
+	for i := 0; i < 1000; i++ {
+		params := pipeline.Params()
+		params.DefaultRiskRewardRatio = float64(i%3 + 1)
+		params.RangingStopATRFactor = 0.5 + float64(i%2)
+		pipeline.ApplyParams(params)
+	}
+	close(done)
+	wg.Wait()
+
+	if got := pipeline.Params().DefaultRiskRewardRatio; got != 1 {
+		t.Errorf("DefaultRiskRewardRatio = %v after the last ApplyParams, want 1", got)
+	}
+}
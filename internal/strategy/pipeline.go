@@ -0,0 +1,181 @@
+package strategy
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/executor"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"crypto-algo-trader/pkg/ta"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Pipeline 将一个交易实例的 TA、状态机、策略和执行器串联起来。
+// 实时主循环和回测引擎都通过 OnKLine / OnTicker 驱动决策，保证两者的逻辑不会分叉。
+type Pipeline struct {
+	Name         string
+	Symbol       string
+	TA           *ta.TACalculator
+	StateMachine *StateMachine
+	Strategy     Strategy // 按 StrategyConfig.Name 从注册表创建的策略
+	Executor     executor.Executor
+	Market       *analytics.MarketAnalytics // 跨实例行情分析 (相关性、beta、配对价差)，未开启时为 nil
+	instance     *service.InstanceConfig    // 状态机和策略引用的实例配置 (参数热切换时修改)
+	env          *Context                   // 策略回调的运行环境
+	logger       *zap.SugaredLogger
+
+	mu sync.Mutex // 串行化策略回调、信号执行和参数热切换 (实时模式下 OnKLine 和 OnTicker 在不同的 goroutine)
+}
+
+// NewPipeline 按实例配置创建决策流水线，信号交给 exec 执行
+func NewPipeline(name string, instance *service.InstanceConfig, exec executor.Executor, logger *zap.SugaredLogger) (*Pipeline, error) {
+	switch strings.ToLower(instance.Strategy.RangingChannel) {
+	case "", ta.TypeKeltner, ta.TypeDonchian:
+	default:
+		return nil, fmt.Errorf("invalid RangingChannel %q (keltner or donchian)", instance.Strategy.RangingChannel)
+	}
+	switch strings.ToLower(instance.Strategy.HighVolRanging.Band) {
+	case "", ta.TypeBBands, ta.TypeKeltner:
+	default:
+		return nil, fmt.Errorf("invalid HighVolRanging.Band %q (bbands or keltner)", instance.Strategy.HighVolRanging.Band)
+	}
+	taClient := ta.NewTACalculator(logger)
+	taClient.SetPeriods(instance.Strategy.MAPeriod, instance.Strategy.RSIPeriod)
+	taClient.SetHistoryLen(instance.Strategy.HistoryLen)
+	if err := taClient.SetIndicators(instance.Strategy.IndicatorSet()); err != nil {
+		return nil, fmt.Errorf("invalid indicator config: %w", err)
+	}
+	stateMachine := NewStateMachine(taClient, &instance.Strategy)
+	env := &Context{
+		Name:     name,
+		Symbol:   instance.Symbol,
+		Instance: instance,
+		TA:       taClient,
+		State:    stateMachine,
+		Executor: exec,
+		Clock:    service.RealClock{},
+		Logger:   logger,
+	}
+	strategy, err := NewStrategy(instance.Strategy.Name, env)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{
+		Name:         name,
+		Symbol:       instance.Symbol,
+		TA:           taClient,
+		StateMachine: stateMachine,
+		Strategy:     strategy,
+		Executor:     exec,
+		instance:     instance,
+		env:          env,
+		logger:       logger,
+	}, nil
+}
+
+// SetClock 设置流水线中状态机和策略使用的时钟
+func (p *Pipeline) SetClock(clock service.Clock) {
+	p.StateMachine.SetClock(clock)
+	p.env.Clock = clock
+	if s, ok := p.Strategy.(interface{ SetClock(service.Clock) }); ok {
+		s.SetClock(clock)
+	}
+}
+
+// SetMarketAnalytics 设置策略可查询的跨实例行情分析服务
+func (p *Pipeline) SetMarketAnalytics(market *analytics.MarketAnalytics) {
+	p.Market = market
+	p.env.Market = market
+}
+
+// OnTicker 将逐笔成交交给需要 Ticker 的指标 (已实现波动率等)，再把执行器报告的成交和这笔 Ticker 交给策略。
+// 可以与 OnKLine 在不同的 goroutine 中调用
+func (p *Pipeline) OnTicker(ticker model.Ticker) {
+	p.TA.UpdateTicker(ticker)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ctx := context.Background()
+	signals, err := p.syncLocked(ctx, false)
+	if err != nil {
+		p.logger.Warn("Failed to get current position", zap.Error(err))
+		return
+	}
+	p.executeLocked(ctx, signals)
+	p.executeLocked(ctx, p.Strategy.OnTick(p.env, ticker))
+}
+
+// OnKLine 处理一根完成的 K 线：更新指标 -> 状态机检查 -> 刷新持仓 -> 策略生成信号 -> 执行信号，返回本根 K 线产生的信号
+func (p *Pipeline) OnKLine(ctx context.Context, kline model.KLine) []model.Signal {
+	// A: 更新指标
+	p.TA.UpdateKLine(kline)
+	// B: 状态机检查状态
+	p.StateMachine.CheckAndTransition(kline)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// C: 获取当前持仓 (双向持仓模式下包含多空两条腿)，并把尚未通知的成交和持仓变化交给策略
+	signals, err := p.syncLocked(ctx, true)
+	if err != nil {
+		p.logger.Warn("Failed to get current position", zap.Error(err))
+		return nil
+	}
+	p.executeLocked(ctx, signals)
+
+	// D: 策略生成信号
+	barSignals := p.Strategy.OnBar(p.env, kline)
+
+	// E: 执行器执行信号
+	p.executeLocked(ctx, barSignals)
+	return append(signals, barSignals...)
+}
+
+// syncLocked 取走执行器报告的成交交给 OnFill，并在持仓变化时调用 OnPositionChange，返回策略产生的信号。
+// fetch 为 false 时只在有新成交时才查询持仓，避免逐笔路径上频繁查询实盘持仓 (调用方需持有 p.mu)
+func (p *Pipeline) syncLocked(ctx context.Context, fetch bool) ([]model.Signal, error) {
+	var fills []model.Fill
+	if source, ok := p.Executor.(executor.FillSource); ok {
+		fills = source.DrainFills()
+	}
+	if !fetch && len(fills) == 0 {
+		return nil, nil
+	}
+
+	positions, err := p.Executor.GetCurrentPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prev := p.env.Positions
+	p.env.Positions = positions
+
+	var signals []model.Signal
+	for _, fill := range fills {
+		signals = append(signals, p.Strategy.OnFill(p.env, fill)...)
+	}
+	if positionsChanged(prev, positions) {
+		signals = append(signals, p.Strategy.OnPositionChange(p.env, positions)...)
+	}
+	return signals, nil
+}
+
+// executeLocked 将策略信号交给执行器 (调用方需持有 p.mu)
+func (p *Pipeline) executeLocked(ctx context.Context, signals []model.Signal) {
+	for _, signal := range signals {
+		p.logger.Info("!!! NEW TRADING SIGNAL !!!", zap.String("Signal", signal.String()))
+		if err := p.Executor.ExecuteSignal(ctx, signal); err != nil {
+			p.logger.Warn("Signal execution failed", zap.Error(err))
+		}
+	}
+}
+
+// Warmup 只用 K 线更新指标和状态机，不调用策略也不执行信号 (回测预热期使用，保证指标在交易开始前就绪)
+func (p *Pipeline) Warmup(kline model.KLine) {
+	p.TA.UpdateKLine(kline)
+	p.StateMachine.CheckAndTransition(kline)
+}
@@ -0,0 +1,26 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/model"
+)
+
+// StrategyRegime 趋势/震荡状态机策略的名称
+const StrategyRegime = "regime"
+
+func init() {
+	RegisterStrategy(StrategyRegime, func(env *Context) (Strategy, error) {
+		return &RegimeStrategy{SignalGenerator: NewSignalGenerator(env.TA, env.State, &env.Instance.Risk, env.Logger)}, nil
+	})
+}
+
+// RegimeStrategy 按状态机的市场状态交易：强趋势顺势开仓，低波动震荡做均值回归，
+// 持仓期间管理止损 (保本、ATR 跟踪、吊灯止损)。决策全部在 K 线上完成
+type RegimeStrategy struct {
+	BaseStrategy
+	*SignalGenerator
+}
+
+// OnBar 实现 Strategy 接口
+func (s *RegimeStrategy) OnBar(env *Context, kline model.KLine) []model.Signal {
+	return s.GenerateSignal(kline, env.Positions)
+}
@@ -1 +1,428 @@
 package strategy
+
+import (
+	"context"
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/executor"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 影子优化器的默认设置 (ShadowConfig 中对应字段为 0 时使用)
+const (
+	DefaultShadowCount              = 4
+	DefaultShadowSpread             = 0.2
+	DefaultShadowObjective          = "sharpe"
+	DefaultShadowWindow             = 72 * time.Hour
+	DefaultShadowMinTrades          = 5
+	DefaultShadowMinImprovement     = 0.2
+	DefaultShadowConfirmEvaluations = 3
+	DefaultShadowMinSwitchInterval  = 24 * time.Hour
+
+	shadowSampleInterval   = "1m" // 每根 1m K 线采样一次影子账户净值
+	shadowEvaluateInterval = "1h" // 每根 1h K 线评估一次滚动表现
+)
+
+// Shadow 一个影子策略：使用扰动参数的独立流水线，在自己的模拟账户上交易
+type Shadow struct {
+	Name     string
+	Params   StrategyParams
+	Pipeline *Pipeline
+	Executor *executor.SimulatorExecutor
+
+	equity []model.EquityPoint // 滚动窗口内的净值采样
+	report *analytics.Report   // 最近一次评估的窗口绩效
+	score  float64             // 最近一次评估的分数 (交易数不足时为 -Inf)
+}
+
+// ShadowStatus 影子策略的状态快照
+type ShadowStatus struct {
+	Name      string
+	Params    StrategyParams
+	Equity    float64
+	Score     float64
+	Trades    int  // 评估窗口内的交易数
+	Incumbent bool // 参数与主策略当前参数一致
+}
+
+// Recommendation 一次参数推荐 (AutoSwap 时同时热切换到主策略)
+type Recommendation struct {
+	Time           time.Time
+	Shadow         string
+	From           StrategyParams
+	To             StrategyParams
+	Score          float64
+	IncumbentScore float64 // 基准交易数不足 MinTrades 时为 0
+	Trades         int     // 候选在评估窗口内的交易数
+	Applied        bool
+}
+
+// ShadowOptimizer 在实时 K 线流上并行运行多个影子策略 (StateMachine + 策略使用扰动参数)，
+// 每个影子在独立的模拟账户上交易；按滚动窗口评估各影子的表现，在满足护栏条件
+// (最少交易数、最小优势、连续确认次数、最小切换间隔) 时推荐最优参数，开启 AutoSwap 时热切换到主策略。
+//
+// 影子 0 始终使用主策略当前的参数，作为比较基准。
+type ShadowOptimizer struct {
+	mu sync.Mutex
+
+	primary   *Pipeline
+	shadows   []*Shadow
+	objective analytics.Objective
+
+	window             time.Duration
+	minTrades          int
+	minImprovement     float64
+	confirmEvaluations int
+	minSwitchInterval  time.Duration
+	autoSwap           bool
+
+	incumbent       int // 参数与主策略一致的影子序号
+	candidate       int // 连续保持最优的候选影子 (-1 表示无)
+	streak          int // candidate 连续保持最优的评估次数
+	started         time.Time
+	lastSwitch      time.Time
+	recommendations []Recommendation
+
+	clock  service.Clock
+	logger *zap.SugaredLogger
+}
+
+// NewShadowOptimizer 围绕主策略当前的参数创建影子策略。instance 为主策略的实例配置 (影子使用其副本)，
+// simCfg 为影子独立模拟账户的配置 (InitialCapital 可被 ShadowConfig.InitialCapital 覆盖)
+func NewShadowOptimizer(primary *Pipeline, instance service.InstanceConfig, simCfg executor.SimulatorConfig, logger *zap.SugaredLogger) (*ShadowOptimizer, error) {
+	cfg := instance.Shadow
+	objectiveName := cfg.Objective
+	if objectiveName == "" {
+		objectiveName = DefaultShadowObjective
+	}
+	objective, err := analytics.ParseObjective(objectiveName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := cfg.Params
+	if len(names) == 0 {
+		names = strategyParamNames
+	}
+	for _, name := range names {
+		if _, ok := strategyParams[name]; !ok {
+			return nil, fmt.Errorf("shadow optimizer: unknown param %q", name)
+		}
+	}
+
+	o := &ShadowOptimizer{
+		primary:            primary,
+		objective:          objective,
+		window:             hoursOr(cfg.WindowHours, DefaultShadowWindow),
+		minTrades:          intOr(cfg.MinTrades, DefaultShadowMinTrades),
+		minImprovement:     floatOr(cfg.MinImprovement, DefaultShadowMinImprovement),
+		confirmEvaluations: intOr(cfg.ConfirmEvaluations, DefaultShadowConfirmEvaluations),
+		minSwitchInterval:  hoursOr(cfg.MinSwitchHours, DefaultShadowMinSwitchInterval),
+		autoSwap:           cfg.AutoSwap,
+		candidate:          -1,
+		clock:              service.RealClock{},
+		logger:             logger,
+	}
+
+	if cfg.InitialCapital > 0 {
+		simCfg.InitialCapital = cfg.InitialCapital
+	}
+	// 影子只输出错误日志，避免每个影子重复打印信号
+	shadowLogger := logger.Desugar().WithOptions(zap.IncreaseLevel(zapcore.ErrorLevel)).Sugar()
+
+	base := primary.Params()
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	count := intOr(cfg.Count, DefaultShadowCount)
+	spread := floatOr(cfg.Spread, DefaultShadowSpread)
+	for i := 0; i <= count; i++ {
+		params := base
+		if i > 0 {
+			params = perturb(base, names, spread, rng)
+		}
+		shadow, err := newShadow(fmt.Sprintf("%s/shadow-%d", primary.Name, i), params, instance, simCfg, shadowLogger)
+		if err != nil {
+			return nil, err
+		}
+		o.shadows = append(o.shadows, shadow)
+	}
+
+	logger.Infow("Shadow optimizer started", "Shadows", len(o.shadows), "Objective", objective.Name, "AutoSwap", o.autoSwap)
+	for _, shadow := range o.shadows {
+		logger.Infow("Shadow params", "Shadow", shadow.Name, "Params", shadow.Params.String())
+	}
+	return o, nil
+}
+
+// newShadow 创建一个使用 params 的影子流水线及其独立模拟账户
+func newShadow(name string, params StrategyParams, instance service.InstanceConfig, simCfg executor.SimulatorConfig, logger *zap.SugaredLogger) (*Shadow, error) {
+	shadowInstance := instance
+	shadowInstance.Shadow = service.ShadowConfig{}
+	params.ApplyTo(&shadowInstance)
+
+	shadowLogger := logger.With(zap.String("Shadow", name))
+	account := executor.NewSimulatorAccount(&simCfg, shadowLogger)
+	exec := executor.NewSharedSimulatorExecutor(account, instance.Symbol, float64(instance.Risk.FixedLeverage), instance.Allocation, nil, shadowLogger)
+
+	pipeline, err := NewPipeline(name, &shadowInstance, exec, shadowLogger)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &Shadow{
+		Name:     name,
+		Params:   params,
+		Pipeline: pipeline,
+		Executor: exec,
+		score:    math.Inf(-1),
+	}, nil
+}
+
+// perturb 在 base 的基础上对选中的参数做 ±spread 比例的随机扰动 (整数参数取整且不小于 2)
+func perturb(base StrategyParams, names []string, spread float64, rng *rand.Rand) StrategyParams {
+	params := base
+	for _, name := range names {
+		accessor := strategyParams[name]
+		v := accessor.get(&base) * (1 + (rng.Float64()*2-1)*spread)
+		if accessor.integer {
+			v = math.Max(2, math.Round(v))
+		}
+		accessor.set(&params, v)
+	}
+	return params
+}
+
+// SetClock 设置影子流水线、影子账户和评估使用的时钟 (回测时使用事件时钟)
+func (o *ShadowOptimizer) SetClock(clock service.Clock) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.clock = clock
+	for _, shadow := range o.shadows {
+		shadow.Pipeline.SetClock(clock)
+		shadow.Executor.Account().SetClock(clock)
+	}
+}
+
+// SetMarketAnalytics 设置影子策略可查询的跨实例行情分析服务 (与主策略共用)
+func (o *ShadowOptimizer) SetMarketAnalytics(market *analytics.MarketAnalytics) {
+	for _, shadow := range o.shadows {
+		shadow.Pipeline.SetMarketAnalytics(market)
+	}
+}
+
+// OnTicker 将 Ticker 转发给所有影子账户 (撮合、止损止盈和净值更新) 和影子流水线的逐笔指标
+func (o *ShadowOptimizer) OnTicker(ticker model.Ticker) {
+	for _, shadow := range o.shadows {
+		shadow.Executor.OnTicker(ticker)
+		shadow.Pipeline.OnTicker(ticker)
+	}
+}
+
+// Warmup 用 K 线预热影子流水线的指标和状态机 (不交易)
+func (o *ShadowOptimizer) Warmup(kline model.KLine) {
+	for _, shadow := range o.shadows {
+		shadow.Pipeline.Warmup(kline)
+	}
+}
+
+// OnKLine 驱动所有影子流水线，并按周期采样净值、评估表现。
+// 必须在驱动主策略 OnKLine 的同一个 goroutine 中调用 (热切换直接修改主策略参数)
+func (o *ShadowOptimizer) OnKLine(ctx context.Context, kline model.KLine) {
+	for _, shadow := range o.shadows {
+		shadow.Pipeline.OnKLine(ctx, kline)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.clock.Now()
+	switch kline.Interval {
+	case shadowSampleInterval:
+		o.sampleLocked(now)
+	case shadowEvaluateInterval:
+		o.evaluateLocked(now)
+	}
+}
+
+// sampleLocked 采样各影子账户的净值，并丢弃滚动窗口之外的采样
+func (o *ShadowOptimizer) sampleLocked(now time.Time) {
+	if o.started.IsZero() {
+		o.started = now
+	}
+	cutoff := now.Add(-o.window)
+	for _, shadow := range o.shadows {
+		summary := shadow.Executor.Account().GetSummary()
+		shadow.equity = append(shadow.equity, model.EquityPoint{Time: now, Equity: summary.Equity, Balance: summary.Balance})
+
+		drop := 0
+		for drop < len(shadow.equity) && shadow.equity[drop].Time.Before(cutoff) {
+			drop++
+		}
+		shadow.equity = shadow.equity[drop:]
+	}
+}
+
+// evaluateLocked 计算各影子在滚动窗口内的分数，并在满足护栏条件时推荐/切换参数
+func (o *ShadowOptimizer) evaluateLocked(now time.Time) {
+	if o.started.IsZero() || now.Sub(o.started) < o.window {
+		return // 至少积累一个完整窗口再做比较
+	}
+
+	cutoff := now.Add(-o.window)
+	best := -1
+	for i, shadow := range o.shadows {
+		trades, _ := shadow.Executor.GetTradeHistory()
+		recent := make([]*model.TradeRecord, 0, len(trades))
+		for _, trade := range trades {
+			if !trade.ExitTime.Before(cutoff) {
+				recent = append(recent, trade)
+			}
+		}
+
+		shadow.report = analytics.Analyze(recent, shadow.equity, analytics.Options{})
+		shadow.score = math.Inf(-1)
+		if shadow.report.Trades >= o.minTrades {
+			shadow.score = o.objective.Score(shadow.report)
+		}
+		if !math.IsInf(shadow.score, -1) && (best < 0 || shadow.score > o.shadows[best].score) {
+			best = i
+		}
+	}
+
+	incumbent := o.shadows[o.incumbent]
+	o.logger.Infow("Shadow optimizer evaluation",
+		"Best", o.shadowName(best),
+		"BestScore", o.shadowScore(best),
+		"Incumbent", incumbent.Name,
+		"IncumbentScore", incumbent.score,
+	)
+
+	// 护栏 1: 候选必须连续多次评估保持最优
+	if best < 0 || best == o.incumbent {
+		o.candidate, o.streak = -1, 0
+		return
+	}
+	if best == o.candidate {
+		o.streak++
+	} else {
+		o.candidate, o.streak = best, 1
+	}
+	if o.streak < o.confirmEvaluations {
+		return
+	}
+
+	// 护栏 2: 分数优势足够大 (基准交易数不足时其分数为 -Inf，任何有效候选都满足)
+	candidate := o.shadows[best]
+	if candidate.score-incumbent.score < o.minImprovement {
+		return
+	}
+	// 护栏 3: 距离上一次推荐/切换足够久
+	if !o.lastSwitch.IsZero() && now.Sub(o.lastSwitch) < o.minSwitchInterval {
+		return
+	}
+
+	rec := Recommendation{
+		Time:    now,
+		Shadow:  candidate.Name,
+		From:    o.primary.Params(),
+		To:      candidate.Params,
+		Score:   candidate.score,
+		Trades:  candidate.report.Trades,
+		Applied: o.autoSwap,
+	}
+	if !math.IsInf(incumbent.score, -1) {
+		rec.IncumbentScore = incumbent.score
+	}
+	if o.autoSwap {
+		o.primary.ApplyParams(candidate.Params)
+		o.incumbent = best
+	}
+	o.recommendations = append(o.recommendations, rec)
+	o.lastSwitch = now
+	o.candidate, o.streak = -1, 0
+
+	o.logger.Infow("!!! Shadow optimizer recommendation !!!",
+		"Shadow", rec.Shadow,
+		"Score", rec.Score,
+		"IncumbentScore", rec.IncumbentScore,
+		"From", rec.From.String(),
+		"To", rec.To.String(),
+		"Applied", rec.Applied,
+	)
+}
+
+func (o *ShadowOptimizer) shadowName(i int) string {
+	if i < 0 {
+		return ""
+	}
+	return o.shadows[i].Name
+}
+
+func (o *ShadowOptimizer) shadowScore(i int) float64 {
+	if i < 0 {
+		return math.Inf(-1)
+	}
+	return o.shadows[i].score
+}
+
+// Status 返回各影子的状态快照，按最近一次评估的分数从高到低排序
+func (o *ShadowOptimizer) Status() []ShadowStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status := make([]ShadowStatus, 0, len(o.shadows))
+	for i, shadow := range o.shadows {
+		s := ShadowStatus{
+			Name:      shadow.Name,
+			Params:    shadow.Params,
+			Equity:    shadow.Executor.Account().GetSummary().Equity,
+			Score:     shadow.score,
+			Incumbent: i == o.incumbent,
+		}
+		if shadow.report != nil {
+			s.Trades = shadow.report.Trades
+		}
+		status = append(status, s)
+	}
+	sort.SliceStable(status, func(i, j int) bool { return status[i].Score > status[j].Score })
+	return status
+}
+
+// Recommendations 返回全部推荐记录
+func (o *ShadowOptimizer) Recommendations() []Recommendation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	recs := make([]Recommendation, len(o.recommendations))
+	copy(recs, o.recommendations)
+	return recs
+}
+
+func intOr(v int, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func floatOr(v float64, def float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func hoursOr(hours int, def time.Duration) time.Duration {
+	if hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return def
+}
@@ -8,7 +8,8 @@ import (
 	"crypto-algo-trader/internal/service"
 	"crypto-algo-trader/pkg/ta"
 	"math"
-	"time"
+	"sort"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -19,6 +20,7 @@ type SignalGenerator struct {
 	state    *StateMachine
 	riskCfg  *service.RiskConfig
 	logger   *zap.SugaredLogger
+	clock    service.Clock // 信号时间戳使用的时钟 (回测时为事件时钟)
 
 	executor executor.Executor
 }
@@ -30,15 +32,22 @@ func NewSignalGenerator(taClient *ta.TACalculator, state *StateMachine, riskCfg
 		state:    state,
 		riskCfg:  riskCfg,
 		logger:   logger,
+		clock:    service.RealClock{},
 	}
 }
 
-// GenerateSignal 根据最新的 K 线和当前持仓，生成一个交易信号。
-// 它是策略的核心决策入口。
+// SetClock 设置信号生成器使用的时钟，使信号携带事件时间而不是系统时间
+func (sg *SignalGenerator) SetClock(clock service.Clock) {
+	sg.clock = clock
+}
+
+// GenerateSignal 根据最新的 K 线和当前持仓，生成本根 K 线的交易信号。
+// 它是策略的核心决策入口。每条持仓腿最多产生一个 CLOSE/UPDATE 信号；
+// 双向持仓模式下，空闲的一侧仍可产生 OPEN 信号 (例如震荡网格同时持有多空)。
 func (sg *SignalGenerator) GenerateSignal(
 	kline model.KLine,
-	currentPosition *model.Position,
-) model.Signal {
+	positions model.Positions,
+) []model.Signal {
 
 	// ----------------------------------------------------------------------
 	// 1. 【策略自适应和风控参数调整】(低频、高重要性)
@@ -110,31 +119,49 @@ func (sg *SignalGenerator) GenerateSignal(
 
 	// M5 周期作为信号生成的频率 (原逻辑不变)
 	if kline.Interval != "5m" {
-		return model.Signal{Action: model.ActionNone}
+		return nil
 	}
 
 	// 确保所有指标就绪 (原逻辑不变)
 	m5Data, err := sg.taClient.GetTAData("5m")
 	if err != nil {
 		sg.logger.Debug("M5 TA not ready for signal check")
-		return model.Signal{Action: model.ActionNone}
+		return nil
+	}
+	// 快照没有包含当前这根 K 线 (例如乱序到达的旧 K 线被忽略) 时，指标相对行情已过期
+	if m5Data.IsStaleAt(kline.EndTime) {
+		sg.logger.Debugw("M5 TA snapshot is stale, skipping signal check", "BarTime", m5Data.BarTime, "KLine", kline.StartTime)
+		return nil
 	}
 
 	currentState := sg.state.GetCurrentState()
+	var signals []model.Signal
 
-	// 假设当前为 FLAT 仓位，尝试开仓信号 (原逻辑不变)
-	if currentPosition.Direction == model.DirFlat {
-		// 注意：sg.generateOpenSignal 内部必须使用 sg.riskCfg.PositionScaleFactor 来计算仓位大小！
-		return sg.generateOpenSignal(currentState, m5Data, kline.Close)
+	// 已有持仓腿：检查平仓信号，不平仓时检查是否需要移动止损 (保本 / ATR 跟踪 / 吊灯止损)
+	openLegs := positions.Open()
+	for _, currentPosition := range openLegs {
+		// 传递 MarketState 给平仓函数 (用于检查策略是否应提前退出)
+		signal := sg.generateCloseSignal(currentState, currentPosition, m5Data, kline.Close)
+		if signal.Action == model.ActionNone {
+			signal = sg.generateUpdateSignal(currentState, currentPosition, m5Data, kline)
+		}
+		if signal.Action != model.ActionNone {
+			signals = append(signals, signal)
+		}
 	}
 
-	// 假设当前为持仓状态，检查平仓信号
-	if currentPosition.Direction != model.DirFlat {
-		// 传递 MarketState 给平仓函数 (用于检查策略是否应提前退出)
-		return sg.generateCloseSignal(currentState, currentPosition, m5Data, kline.Close)
+	// 开仓：单向模式只在完全空仓时开仓；双向模式下目标方向的腿空仓即可开仓
+	hedgeMode := model.PositionMode(sg.riskCfg.PositionMode) == model.PosModeLongShort
+	if len(openLegs) == 0 || hedgeMode {
+		// 注意：sg.generateOpenSignal 内部必须使用 sg.riskCfg.PositionScaleFactor 来计算仓位大小！
+		signal := sg.generateOpenSignal(currentState, m5Data, kline.Close)
+		if signal.Action == model.ActionOpen && positions.Leg(signal.Direction).Direction == model.DirFlat {
+			signal.PosSide = signal.Direction
+			signals = append(signals, signal)
+		}
 	}
 
-	return model.Signal{Action: model.ActionNone}
+	return signals
 }
 
 // generateOpenSignal 核心策略逻辑：根据状态生成开仓信号
@@ -160,6 +187,9 @@ func (sg *SignalGenerator) generateOpenSignal(
 			riskSignal := sg.calculateRiskAndSize(dir, currentPrice, lastATR, 0.0)
 
 			// 2. 风险计算
+			if riskSignal.Action == model.ActionNone {
+				return riskSignal // 风控计算失败 (止损距离无效或仓位过小)
+			}
 			riskSignal.Action = model.ActionOpen
 			riskSignal.Symbol = m5Data.Symbol
 			riskSignal.Direction = dir
@@ -175,6 +205,9 @@ func (sg *SignalGenerator) generateOpenSignal(
 			dir := model.DirShort
 			riskSignal := sg.calculateRiskAndSize(dir, currentPrice, lastATR, 0.0)
 			// 2. 风险计算
+			if riskSignal.Action == model.ActionNone {
+				return riskSignal // 风控计算失败 (止损距离无效或仓位过小)
+			}
 			riskSignal.Symbol = m5Data.Symbol
 			riskSignal.Action = model.ActionOpen
 			riskSignal.Direction = dir
@@ -192,23 +225,137 @@ func (sg *SignalGenerator) generateOpenSignal(
 		// 简化：如果价格低于下轨，且 RSI < 50
 		if currentPrice < m5Data.BBandsDn && m5Data.RSI < 50 {
 			dir := model.DirLong
-			// 使用更紧密的止损因子 0.7
-			riskSignal := sg.calculateRiskAndSize(dir, currentPrice, m5Data.ATR, 0.7) // 止损因子 0.7
+			// 使用更紧密的止损因子 (默认 0.7)
+			stopFactor := sg.rangingStopATRFactor()
+			riskSignal := sg.calculateRiskAndSize(dir, currentPrice, m5Data.ATR, stopFactor)
+			if riskSignal.Action == model.ActionNone {
+				return riskSignal // 风控计算失败 (止损距离无效或仓位过小)
+			}
 			riskSignal.Action = model.ActionOpen
 			riskSignal.Symbol = m5Data.Symbol
 			riskSignal.Direction = dir
 			riskSignal.SourceState = state
 			riskSignal.Reason = "Low Vol Ranging: BBands DN Bounce"
-			sg.logger.Infof("SIGNAL: OPEN %s (State: %s). Size: %.4f, SL: %.4f, TP: %.4f (ATR Multiplier: 0.7)",
-				dir, state, riskSignal.PositionSize, riskSignal.StopLossPrice, riskSignal.TakeProfitPrice)
+			sg.logger.Infof("SIGNAL: OPEN %s (State: %s). Size: %.4f, SL: %.4f, TP: %.4f (ATR Multiplier: %.2f)",
+				dir, state, riskSignal.PositionSize, riskSignal.StopLossPrice, riskSignal.TakeProfitPrice, stopFactor)
+			return riskSignal
+		}
+		// 价格高于上轨，且 RSI > 50
+		if currentPrice > m5Data.BBandsUp && m5Data.RSI > 50 {
+			dir := model.DirShort
+			stopFactor := sg.rangingStopATRFactor()
+			riskSignal := sg.calculateRiskAndSize(dir, currentPrice, m5Data.ATR, stopFactor)
+			if riskSignal.Action == model.ActionNone {
+				return riskSignal // 风控计算失败 (止损距离无效或仓位过小)
+			}
+			riskSignal.Action = model.ActionOpen
+			riskSignal.Symbol = m5Data.Symbol
+			riskSignal.Direction = dir
+			riskSignal.SourceState = state
+			riskSignal.Reason = "Low Vol Ranging: BBands UP Fade"
+			sg.logger.Infof("SIGNAL: OPEN %s (State: %s). Size: %.4f, SL: %.4f, TP: %.4f (ATR Multiplier: %.2f)",
+				dir, state, riskSignal.PositionSize, riskSignal.StopLossPrice, riskSignal.TakeProfitPrice, stopFactor)
 			return riskSignal
 		}
-		// ... Short 信号逻辑类似
+	}
+
+	// 3. 策略 C: 高波动震荡 (High Vol Ranging) -> 通道外侧反向，回归中轨
+	if state == model.StateHighVolRanging && sg.state != nil && !sg.state.Config.HighVolRanging.Disabled {
+		return sg.generateHighVolRangingSignal(state, m5Data, currentPrice)
 	}
 
 	return model.Signal{Action: model.ActionNone}
 }
 
+// generateHighVolRangingSignal 高波动震荡的均值回归开仓：价格越出通道下轨且 RSI 超卖时开多，越出上轨且 RSI 超买时开空。
+// 止损为 StopATRFactor 倍 M5 ATR (随波动放宽，仓位随之缩小)，止盈为通道中轨 (配置了止盈阶梯时沿用阶梯)
+func (sg *SignalGenerator) generateHighVolRangingSignal(
+	state model.MarketState,
+	m5Data *ta.TAData,
+	currentPrice float64,
+) model.Signal {
+	cfg := sg.state.Config.HighVolRanging
+	upper, middle, lower, ok := highVolBand(cfg, m5Data)
+	if !ok {
+		return model.Signal{Action: model.ActionNone}
+	}
+	oversold, overbought := cfg.RSIOversold, cfg.RSIOverbought
+	if oversold <= 0 {
+		oversold = DefaultHighVolRSIOversold
+	}
+	if overbought <= 0 {
+		overbought = DefaultHighVolRSIOverbought
+	}
+
+	var dir model.Direction
+	var reason string
+	if currentPrice < lower && m5Data.RSI < oversold {
+		dir, reason = model.DirLong, "High Vol Ranging: Band DN Fade"
+	} else if currentPrice > upper && m5Data.RSI > overbought {
+		dir, reason = model.DirShort, "High Vol Ranging: Band UP Fade"
+	} else {
+		return model.Signal{Action: model.ActionNone}
+	}
+
+	stopFactor := highVolStopATRFactor(sg.state.Config)
+	riskSignal := sg.calculateRiskAndSize(dir, currentPrice, m5Data.ATR, stopFactor)
+	if riskSignal.Action == model.ActionNone {
+		return riskSignal // 风控计算失败 (止损距离无效或仓位过小)
+	}
+	// 目标为回到中轨 (中轨必须在入场价的盈利一侧)
+	if len(riskSignal.TakeProfitLevels) == 0 &&
+		((dir == model.DirLong && middle > currentPrice) || (dir == model.DirShort && middle < currentPrice)) {
+		riskSignal.TakeProfitPrice = middle
+	}
+	riskSignal.Action = model.ActionOpen
+	riskSignal.Symbol = m5Data.Symbol
+	riskSignal.Direction = dir
+	riskSignal.SourceState = state
+	riskSignal.Reason = reason
+	sg.logger.Infof("SIGNAL: OPEN %s (State: %s). Size: %.4f, SL: %.4f, TP: %.4f (ATR Multiplier: %.2f, Band: %.4f / %.4f / %.4f)",
+		dir, state, riskSignal.PositionSize, riskSignal.StopLossPrice, riskSignal.TakeProfitPrice, stopFactor, lower, middle, upper)
+	return riskSignal
+}
+
+// highVolBand 返回高波动震荡使用的 M5 通道 (上轨、中轨、下轨)，通道尚未就绪时 ok 为 false
+func highVolBand(cfg service.HighVolRangingConfig, m5Data *ta.TAData) (upper float64, middle float64, lower float64, ok bool) {
+	name := ta.NameBBands
+	if strings.EqualFold(cfg.Band, ta.TypeKeltner) {
+		name = HighVolBandIndicator
+	}
+	middle, ok = m5Data.Value(name)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	upper, _ = m5Data.Value(name + ".upper")
+	lower, _ = m5Data.Value(name + ".lower")
+	return upper, middle, lower, upper > lower
+}
+
+// highVolStopATRFactor 返回配置的高波动震荡止损 ATR 乘数 (未配置时为默认值)
+func highVolStopATRFactor(cfg *service.StrategyConfig) float64 {
+	if cfg != nil && cfg.HighVolRanging.StopATRFactor > 0 {
+		return cfg.HighVolRanging.StopATRFactor
+	}
+	return DefaultHighVolStopATRFactor
+}
+
+// rangingStopATRFactor 低波动震荡开仓的止损 ATR 乘数 (StrategyConfig.RangingStopATRFactor，未配置时为默认值)
+func (sg *SignalGenerator) rangingStopATRFactor() float64 {
+	if sg.state == nil {
+		return DefaultRangingStopATRFactor
+	}
+	return rangingStopATRFactor(sg.state.Config)
+}
+
+// rangingStopATRFactor 返回配置的震荡止损 ATR 乘数 (未配置时为默认值)
+func rangingStopATRFactor(cfg *service.StrategyConfig) float64 {
+	if cfg != nil && cfg.RangingStopATRFactor > 0 {
+		return cfg.RangingStopATRFactor
+	}
+	return DefaultRangingStopATRFactor
+}
+
 // calculateRiskAndSize 核心风控函数：计算止损价格和仓位数量
 // atrFactor 允许在不同状态下调整止损距离 (例如趋势追踪用 1.5，震荡用 0.7)
 // 注意：该函数假设 model.Signal 包含了 PositionSize, StopLossPrice, TakeProfitPrice, RiskedUSD 等字段。
@@ -221,8 +368,8 @@ func (sg *SignalGenerator) calculateRiskAndSize(
 
 	// 默认使用配置中的默认值，如果未传入 atrFactor
 	factor := sg.riskCfg.DefaultStopLossATRMultiplier //  RiskConfig 中有这个字段，例如 1.5
-	if len(atrFactor) > 0 {
-		factor = atrFactor[0]
+	if len(atrFactor) > 0 && atrFactor[0] > 0 {
+		factor = atrFactor[0] // 传入 0 表示使用默认值
 	}
 
 	// 假设 RiskConfig 中定义了默认的风险回报比
@@ -281,6 +428,12 @@ func (sg *SignalGenerator) calculateRiskAndSize(
 		takeProfitPrice = entryPrice - tpDistance
 	}
 
+	// 配置了分批止盈阶梯时，以阶梯替代一次性止盈 (剩余仓位交由跟踪止损管理)
+	takeProfitLevels := sg.buildTakeProfitLadder(dir, entryPrice, slDistance)
+	if len(takeProfitLevels) > 0 {
+		takeProfitPrice = 0
+	}
+
 	// 5. 应用自适应因子 (PositionScaleFactor)
 	finalPositionSize := positionSize * sg.riskCfg.PositionScaleFactor
 
@@ -292,16 +445,50 @@ func (sg *SignalGenerator) calculateRiskAndSize(
 
 	// 6. 构造信号
 	return model.Signal{
-		Timestamp:       time.Now(),
-		Price:           entryPrice,
-		Direction:       dir,
-		RiskedUSD:       maxRisk,
-		PositionSize:    finalPositionSize, // 最终仓位
-		StopLossPrice:   stopLossPrice,
-		TakeProfitPrice: takeProfitPrice,
+		Timestamp:        sg.clock.Now(),
+		Price:            entryPrice,
+		Direction:        dir,
+		RiskedUSD:        maxRisk,
+		PositionSize:     finalPositionSize, // 最终仓位
+		StopLossPrice:    stopLossPrice,
+		TakeProfitPrice:  takeProfitPrice,
+		TakeProfitLevels: takeProfitLevels,
 	}
 }
 
+// buildTakeProfitLadder 根据 RiskConfig.TakeProfitLadder 计算分批止盈价格
+// 每一级的止盈距离 = 初始止损距离 (1R) * 该级的 R 倍数
+func (sg *SignalGenerator) buildTakeProfitLadder(dir model.Direction, entryPrice float64, slDistance float64) []model.TakeProfitLevel {
+	if len(sg.riskCfg.TakeProfitLadder) == 0 {
+		return nil
+	}
+
+	levels := make([]model.TakeProfitLevel, 0, len(sg.riskCfg.TakeProfitLadder))
+	for _, step := range sg.riskCfg.TakeProfitLadder {
+		if step.R <= 0 || step.SizeRatio <= 0 {
+			continue
+		}
+		price := entryPrice + slDistance*step.R
+		if dir == model.DirShort {
+			price = entryPrice - slDistance*step.R
+		}
+		if price <= 0 {
+			continue
+		}
+		levels = append(levels, model.TakeProfitLevel{Price: price, SizeRatio: step.SizeRatio})
+	}
+
+	// 按触发顺序排列：多头价格从低到高，空头价格从高到低
+	sort.Slice(levels, func(i, j int) bool {
+		if dir == model.DirShort {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+
+	return levels
+}
+
 const LookbackTrades = 10 // 只看最近 10 笔交易
 
 // calculateRecentLosses 计算最近交易中的最大连续亏损次数。
@@ -420,29 +607,33 @@ func (sg *SignalGenerator) generateCloseSignal(
 
 	// 获取最新的 RSI 和 MACD 柱状图
 	lastRSI := m5Data.RSI
-	lastMACDHist := m5Data.MACDHist[len(m5Data.MACDHist)-1]
+	lastMACDHist := m5Data.MACDHist.Last()
 
 	isCloseSignal := false
 	reason := ""
 
 	// --- 策略性平仓逻辑分流 ---
 
+	// 震荡策略的持仓由各自的均值回归逻辑退出，不套用趋势退出条件
+	rangingPosition := currentPosition.SourceState == model.StateLowVolRanging ||
+		currentPosition.SourceState == model.StateHighVolRanging
+
 	// 1. **趋势策略退出逻辑 (StateStrongUp/DownTrend)**
 	// 退出条件：趋势反转或力度衰竭。
-	if currentPosition.Direction == model.DirLong {
+	if !rangingPosition && currentPosition.Direction == model.DirLong {
 		// 多头持仓的平仓条件：
 		//   a. 市场进入低波动震荡 (StateLowVolRanging)：趋势结束，转为收割
 		//   b. MACD 柱状图从正转负：趋势动能反转
-		if marketState == model.StateLowVolRanging || (lastMACDHist < 0 && m5Data.MACDHist[len(m5Data.MACDHist)-2] >= 0) {
+		if marketState == model.StateLowVolRanging || (lastMACDHist < 0 && m5Data.MACDHist.Ago(1) >= 0) {
 			isCloseSignal = true
 			reason = "Trend exhaustion/reversal detected."
 		}
 
-	} else if currentPosition.Direction == model.DirShort {
+	} else if !rangingPosition && currentPosition.Direction == model.DirShort {
 		// 空头持仓的平仓条件：
 		//   a. 市场进入低波动震荡 (StateLowVolRanging)：趋势结束，转为收割
 		//   b. MACD 柱状图从负转正：趋势动能反转
-		if marketState == model.StateLowVolRanging || (lastMACDHist > 0 && m5Data.MACDHist[len(m5Data.MACDHist)-2] <= 0) {
+		if marketState == model.StateLowVolRanging || (lastMACDHist > 0 && m5Data.MACDHist.Ago(1) <= 0) {
 			isCloseSignal = true
 			reason = "Trend exhaustion/reversal detected."
 		}
@@ -460,14 +651,31 @@ func (sg *SignalGenerator) generateCloseSignal(
 		}
 	}
 
-	// --- 3. 构造平仓信号 ---
+	// 3. **高波动震荡退出逻辑 (StateHighVolRanging)**
+	// 退出条件：价格回到通道中轨 (止盈价随中轨移动前先行离场)，或市场转为与持仓方向相反的强趋势。
+	if currentPosition.SourceState == model.StateHighVolRanging && sg.state != nil {
+		_, middle, _, ok := highVolBand(sg.state.Config.HighVolRanging, m5Data)
+		isLong := currentPosition.Direction == model.DirLong
+		if ok && ((isLong && currentPrice >= middle) || (!isLong && currentPrice <= middle)) {
+			isCloseSignal = true
+			reason = "Mean reversion success: price returned to band middle."
+		} else if (isLong && marketState == model.StateStrongDownTrend) || (!isLong && marketState == model.StateStrongUpTrend) {
+			isCloseSignal = true
+			reason = "Range broken: strong trend against position."
+		}
+	}
+
+	// --- 4. 构造平仓信号 ---
 	if isCloseSignal {
 		sg.logger.Warnf("SIGNAL: CLOSE %s position. Reason: %s", currentPosition.Direction, reason)
 
 		return model.Signal{
+			Timestamp:    sg.clock.Now(),
 			Action:       model.ActionClose,
 			Symbol:       currentPosition.InstID,
-			PositionSize: 0.0, // 0.0 表示平掉所有持仓（默认行为）
+			Direction:    currentPosition.Direction,
+			PosSide:      currentPosition.Direction, // 双向持仓模式下指定要平的腿
+			PositionSize: 0.0,                       // 0.0 表示平掉该腿所有持仓（默认行为）
 			Price:        currentPrice,
 			Reason:       reason,
 		}
@@ -475,3 +683,108 @@ func (sg *SignalGenerator) generateCloseSignal(
 
 	return model.Signal{Action: model.ActionNone}
 }
+
+// generateUpdateSignal 检查持仓的止损是否应该移动，返回 ActionUpdate 信号。
+// 依次计算保本止损、ATR 跟踪止损和吊灯止损，取其中对持仓最有利的价格；
+// 止损只会朝有利方向移动 (多头上移、空头下移)，且不会越过当前价格。
+func (sg *SignalGenerator) generateUpdateSignal(
+	marketState model.MarketState,
+	currentPosition *model.Position,
+	m5Data *ta.TAData,
+	kline model.KLine,
+) model.Signal {
+	trailCfg := sg.riskCfg.TrailingStop
+	if m5Data == nil || currentPosition.Direction == model.DirFlat || currentPosition.StopLossPrice == 0 {
+		return model.Signal{Action: model.ActionNone}
+	}
+
+	isLong := currentPosition.Direction == model.DirLong
+	currentPrice := kline.Close
+	currentStop := currentPosition.StopLossPrice
+	newStop := currentStop
+	reason := ""
+
+	// better 判断候选止损是否比当前候选更有利
+	better := func(candidate float64) bool {
+		if candidate <= 0 {
+			return false
+		}
+		if isLong {
+			return candidate > newStop && candidate < currentPrice
+		}
+		return candidate < newStop && candidate > currentPrice
+	}
+
+	// 1. 保本止损：浮盈达到 BreakevenAtR 倍初始风险后，止损移至开仓价
+	initialRisk := math.Abs(currentPosition.AvgPrice - currentPosition.InitialStopLoss)
+	if trailCfg.BreakevenAtR > 0 && initialRisk > 0 {
+		profit := currentPrice - currentPosition.AvgPrice
+		if !isLong {
+			profit = -profit
+		}
+		if profit >= initialRisk*trailCfg.BreakevenAtR && better(currentPosition.AvgPrice) {
+			newStop = currentPosition.AvgPrice
+			reason = "Breakeven"
+		}
+	}
+
+	// 2. ATR 跟踪止损 / 吊灯止损
+	if trailCfg.ATRMultiplier > 0 && m5Data.ATR > 0 {
+		distance := m5Data.ATR * trailCfg.ATRMultiplier
+
+		switch trailCfg.Mode {
+		case "atr":
+			candidate := currentPrice - distance
+			if !isLong {
+				candidate = currentPrice + distance
+			}
+			if better(candidate) {
+				newStop = candidate
+				reason = "ATR Trailing"
+			}
+		case "chandelier":
+			// 吊灯止损：多头 = N 根 K 线最高价 - k*ATR，空头 = N 根 K 线最低价 + k*ATR
+			period := trailCfg.ChandelierPeriod
+			if period <= 0 || period > m5Data.High.Len() {
+				period = m5Data.High.Len()
+			}
+			candidate := 0.0
+			if isLong {
+				highest := 0.0
+				for i := 0; i < period; i++ {
+					highest = math.Max(highest, m5Data.High.Ago(i))
+				}
+				candidate = highest - distance
+			} else {
+				lowest := math.MaxFloat64
+				for i := 0; i < period; i++ {
+					lowest = math.Min(lowest, m5Data.Low.Ago(i))
+				}
+				candidate = lowest + distance
+			}
+			if better(candidate) {
+				newStop = candidate
+				reason = "Chandelier"
+			}
+		}
+	}
+
+	if newStop == currentStop {
+		return model.Signal{Action: model.ActionNone}
+	}
+
+	sg.logger.Infof("SIGNAL: UPDATE %s SL %.4f -> %.4f (State: %s). Reason: %s",
+		currentPosition.Direction, currentStop, newStop, marketState, reason)
+
+	return model.Signal{
+		Action:        model.ActionUpdate,
+		Symbol:        currentPosition.InstID,
+		Timestamp:     sg.clock.Now(),
+		Direction:     currentPosition.Direction,
+		PosSide:       currentPosition.Direction,
+		Price:         currentPrice,
+		StopLossPrice: newStop,
+		SourceState:   currentPosition.SourceState,
+		Reason:        reason,
+	}
+}
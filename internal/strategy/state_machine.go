@@ -5,6 +5,7 @@ import (
 	"crypto-algo-trader/internal/service"
 	"crypto-algo-trader/pkg/ta"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -18,18 +19,82 @@ type StateMachine struct {
 	// 状态转换阈值 (可以从配置文件加载)
 	TrendThreshold  float64 // 判断趋势强度的阈值，例如 H1 RSI 超过 60/40
 	ATRVolThreshold float64 // 判断高/低波动的 ATR 绝对值阈值
+	// 可选过滤 (0 表示关闭)
+	ADXTrendThreshold     float64 // 强趋势还要求 H1 ADX 达到该值且 DI 方向一致
+	ChannelWidthThreshold float64 // 用 H1 通道相对宽度代替 ATR/价格 区分高/低波动
+	VolPercentile         float64 // 用 H1 波动率的滚动百分位区分高/低波动 (优先于通道宽度)
+
+	clock          service.Clock // 记录状态切换时间使用的时钟
+	LastTransition time.Time     // 最近一次状态切换的时间 (事件时间)
+	transitions    []model.StateTransition
 }
 
+// 状态机和信号参数的默认值 (StrategyConfig 中对应字段为 0 时使用)
+const (
+	DefaultTrendThreshold       = 60.0   // RSI 超过 60 视为潜在强势
+	DefaultATRVolThreshold      = 0.0005 // 0.05% 的 ATR 阈值 (根据交易对和周期调整)
+	DefaultRangingStopATRFactor = 0.7    // 低波动震荡使用更紧密的止损
+	DefaultHighVolStopATRFactor = 1.5    // 高波动震荡使用更宽的止损
+	DefaultHighVolRSIOversold   = 30.0   // 高波动震荡开多的 RSI 上限
+	DefaultHighVolRSIOverbought = 70.0   // 高波动震荡开空的 RSI 下限
+)
+
+// HighVolBandIndicator 高波动震荡使用 keltner 通道时的 M5 指标名称 (StrategyConfig.IndicatorSet 中加入)
+const HighVolBandIndicator = "hv_band"
+
 // NewStateMachine 初始化状态机
 func NewStateMachine(taClient *ta.TACalculator, cfg *service.StrategyConfig) *StateMachine {
-	// 假设从配置或默认值初始化阈值
-	return &StateMachine{
+	// 从配置初始化阈值，未配置时使用默认值
+	sm := &StateMachine{
 		CurrentState:    model.StateInitial,
 		taClient:        taClient,
 		Config:          cfg,
-		TrendThreshold:  60.0,   // RSI 超过 60 视为潜在强势
-		ATRVolThreshold: 0.0005, // 0.05% 的 ATR 阈值 (根据交易对和周期调整)
+		TrendThreshold:  DefaultTrendThreshold,
+		ATRVolThreshold: DefaultATRVolThreshold,
+		clock:           service.RealClock{},
+	}
+	if cfg != nil && cfg.TrendThreshold > 0 {
+		sm.TrendThreshold = cfg.TrendThreshold
+	}
+	if cfg != nil && cfg.ATRVolThreshold > 0 {
+		sm.ATRVolThreshold = cfg.ATRVolThreshold
 	}
+	if cfg != nil && cfg.ADXTrendThreshold > 0 {
+		sm.ADXTrendThreshold = cfg.ADXTrendThreshold
+	}
+	if cfg != nil && cfg.RangingChannel != "" && cfg.ChannelWidthThreshold > 0 {
+		sm.ChannelWidthThreshold = cfg.ChannelWidthThreshold
+	}
+	if cfg != nil && cfg.VolEstimator != "" && cfg.VolPercentileThreshold > 0 {
+		sm.VolPercentile = cfg.VolPercentileThreshold
+	}
+	return sm
+}
+
+// SetThresholds 更新趋势和波动阈值 (<= 0 表示保持不变)，用于参数热切换
+func (sm *StateMachine) SetThresholds(trendThreshold float64, atrVolThreshold float64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if trendThreshold > 0 {
+		sm.TrendThreshold = trendThreshold
+	}
+	if atrVolThreshold > 0 {
+		sm.ATRVolThreshold = atrVolThreshold
+	}
+}
+
+// Thresholds 返回当前的趋势和波动阈值
+func (sm *StateMachine) Thresholds() (float64, float64) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.TrendThreshold, sm.ATRVolThreshold
+}
+
+// SetClock 设置状态机使用的时钟 (回测时使用事件时钟)
+func (sm *StateMachine) SetClock(clock service.Clock) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.clock = clock
 }
 
 // CheckAndTransition 是状态机驱动的核心函数
@@ -74,7 +139,15 @@ func (sm *StateMachine) CheckAndTransition(kline model.KLine) {
 			zap.String("To", string(newState)),
 			zap.Float64("H1_RSI", h1Data.RSI),
 			zap.Float64("H1_ATR", h1Data.ATR),
+			zap.Time("At", sm.clock.Now()),
 		)
+		sm.LastTransition = sm.clock.Now()
+		sm.transitions = append(sm.transitions, model.StateTransition{
+			Time:   sm.LastTransition,
+			Symbol: h1Data.Symbol,
+			From:   sm.CurrentState,
+			To:     newState,
+		})
 		sm.CurrentState = newState
 	}
 }
@@ -84,35 +157,65 @@ func (sm *StateMachine) checkStrongTrend(h1Data *ta.TAData, h4Data *ta.TAData) (
 
 	// 趋势条件 1: H1 均线排列确认 (FastMA > SlowMA)
 	// 假设 FastMA=5, SlowMA=20 (从 Config.Trend 获取)
-	h1TrendConfirm := h1Data.Close[len(h1Data.Close)-1] > h1Data.MA // 价格在 MA20 之上 (简化)
+	h1TrendConfirm := h1Data.Close.Last() > h1Data.MA // 价格在 MA20 之上 (简化)
 
 	// 趋势条件 2: H1 动量确认 (RSI > 60)
 	h1Momentum := h1Data.RSI >= sm.TrendThreshold
 
 	// 趋势条件 3 (过滤): H4 周期趋势一致性 (避免逆势)
 	h4TrendConfirm := true // 默认允许
-	if h4Data != nil && len(h4Data.Close) > 0 {
+	if h4Data != nil && h4Data.Close.Len() > 0 {
 		// H4 价格必须在 H4 MA之上 (进一步简化判断)
-		h4TrendConfirm = h4Data.Close[len(h4Data.Close)-1] > h4Data.MA
+		h4TrendConfirm = h4Data.Close.Last() > h4Data.MA
 	}
 
 	// 强上涨趋势：H1趋势确认 且 H1动量强 且 H4趋势不冲突
 	isUpTrend = h1TrendConfirm && h1Momentum && h4TrendConfirm
 
 	// 强下跌趋势：逻辑相反
-	h1TrendDownConfirm := h1Data.Close[len(h1Data.Close)-1] < h1Data.MA
+	h1TrendDownConfirm := h1Data.Close.Last() < h1Data.MA
 	h1DownMomentum := h1Data.RSI <= (100 - sm.TrendThreshold) // RSI <= 40
 
 	isDownTrend = h1TrendDownConfirm && h1DownMomentum && !h4TrendConfirm // H4 趋势向下
 
+	// 趋势条件 4 (可选): H1 ADX 确认趋势强度，+DI/-DI 确认方向，过滤价格和 RSI 在噪声中的偶然满足
+	if sm.ADXTrendThreshold > 0 && (isUpTrend || isDownTrend) {
+		adx, ok := h1Data.Value(ta.NameADX)
+		plusDI, _ := h1Data.Value(ta.NameADX + ".plus_di")
+		minusDI, _ := h1Data.Value(ta.NameADX + ".minus_di")
+		strong := ok && adx >= sm.ADXTrendThreshold // ADX 未就绪时不认定强趋势
+		isUpTrend = isUpTrend && strong && plusDI > minusDI
+		isDownTrend = isDownTrend && strong && minusDI > plusDI
+	}
+
 	return isUpTrend, isDownTrend
 }
 
-// determineRangingMode 根据 H1 ATR 确定震荡模式
+// determineRangingMode 根据 H1 ATR (或配置的波动率百分位、通道宽度) 确定震荡模式
 func (sm *StateMachine) determineRangingMode(h1Data *ta.TAData) model.MarketState {
 
+	// 配置了波动率百分位时按当前波动率在自身历史中的位置判断，阈值随交易对自适应
+	if sm.VolPercentile > 0 {
+		if rank, ok := h1Data.Value(ta.NameVol + ".rank"); ok {
+			if rank >= sm.VolPercentile {
+				return model.StateHighVolRanging
+			}
+			return model.StateLowVolRanging
+		}
+	}
+
+	// 配置了通道时按通道相对宽度判断，未就绪时回退到 ATR
+	if sm.ChannelWidthThreshold > 0 {
+		if width, ok := h1Data.Value(ta.NameChannel + ".width"); ok {
+			if width >= sm.ChannelWidthThreshold {
+				return model.StateHighVolRanging
+			}
+			return model.StateLowVolRanging
+		}
+	}
+
 	// 我们需要将 ATR 转换为百分比，例如 ATR / Price
-	latestPrice := h1Data.Close[len(h1Data.Close)-1]
+	latestPrice := h1Data.Close.Last()
 
 	// 检查价格是否有效，防止除以零
 	if latestPrice == 0 {
@@ -131,6 +234,16 @@ func (sm *StateMachine) determineRangingMode(h1Data *ta.TAData) model.MarketStat
 	return model.StateLowVolRanging
 }
 
+// GetTransitionHistory 返回全部状态切换记录 (用于报告中的市场状态时间线)
+func (sm *StateMachine) GetTransitionHistory() []model.StateTransition {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	history := make([]model.StateTransition, len(sm.transitions))
+	copy(history, sm.transitions)
+	return history
+}
+
 // GetCurrentState 供信号生成器查询当前状态
 func (sm *StateMachine) GetCurrentState() model.MarketState {
 	sm.mu.RLock()
@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"crypto-algo-trader/internal/analytics"
+	"crypto-algo-trader/internal/executor"
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"crypto-algo-trader/pkg/ta"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultStrategy 未配置 StrategyConfig.Name 时使用的策略 (趋势/震荡状态机策略)
+const DefaultStrategy = StrategyRegime
+
+// Strategy 交易策略接口：流水线在行情和成交事件上回调策略，策略返回的信号由流水线交给执行器。
+// 同一条流水线的回调是串行的 (不会并发调用)，策略内部无需加锁。
+// 新策略实现该接口并通过 RegisterStrategy 注册后，即可在实例配置 Strategy.Name 中按名称选用，无需修改流水线
+type Strategy interface {
+	// OnBar 每根完成的 K 线 (所有周期) 调用一次，此时指标和状态机已经用这根 K 线更新
+	OnBar(env *Context, kline model.KLine) []model.Signal
+	// OnTick 每笔成交调用一次 (逐笔路径上不重新查询持仓，env.Positions 为最近一次刷新的持仓)
+	OnTick(env *Context, ticker model.Ticker) []model.Signal
+	// OnFill 执行器报告成交时调用 (需要执行器实现 executor.FillSource)
+	OnFill(env *Context, fill model.Fill) []model.Signal
+	// OnPositionChange 持仓 (方向、数量或均价) 变化时调用，positions 与 env.Positions 相同
+	OnPositionChange(env *Context, positions model.Positions) []model.Signal
+}
+
+// BaseStrategy 所有回调都不产生信号的空实现，嵌入后只需实现关心的回调
+type BaseStrategy struct{}
+
+func (BaseStrategy) OnBar(env *Context, kline model.KLine) []model.Signal    { return nil }
+func (BaseStrategy) OnTick(env *Context, ticker model.Ticker) []model.Signal { return nil }
+func (BaseStrategy) OnFill(env *Context, fill model.Fill) []model.Signal     { return nil }
+func (BaseStrategy) OnPositionChange(env *Context, positions model.Positions) []model.Signal {
+	return nil
+}
+
+// Context 流水线提供给策略的运行环境 (同一条流水线的所有回调共用一个 Context)
+type Context struct {
+	Name      string
+	Symbol    string
+	Instance  *service.InstanceConfig    // 实例配置 (参数热切换在回调之间修改，策略应每次读取而不是缓存)
+	TA        *ta.TACalculator           // 指标
+	State     *StateMachine              // 市场状态
+	Market    *analytics.MarketAnalytics // 跨实例行情分析，未开启时为 nil
+	Executor  executor.Executor          // 用于查询余额和交易记录；信号应通过回调的返回值提交
+	Positions model.Positions            // 最近一次刷新的持仓 (K 线和成交时刷新)
+	Clock     service.Clock              // 事件时钟 (回测时为事件时间)
+	Logger    *zap.SugaredLogger
+}
+
+// Now 返回当前事件时间
+func (c *Context) Now() time.Time {
+	return c.Clock.Now()
+}
+
+// StrategyFactory 按运行环境创建策略实例 (配置无效时返回错误)。工厂在流水线创建时调用一次，
+// 此时 env.Market 可能尚未设置
+type StrategyFactory func(env *Context) (Strategy, error)
+
+var (
+	strategyRegistryMu sync.RWMutex
+	strategyRegistry   = make(map[string]StrategyFactory)
+)
+
+// RegisterStrategy 注册策略 (通常在 init 中调用)。名称不区分大小写，重复注册会 panic
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategyRegistryMu.Lock()
+	defer strategyRegistryMu.Unlock()
+
+	name = strings.ToLower(name)
+	if factory == nil {
+		panic("strategy: RegisterStrategy factory is nil for " + name)
+	}
+	if _, dup := strategyRegistry[name]; dup {
+		panic("strategy: RegisterStrategy called twice for " + name)
+	}
+	strategyRegistry[name] = factory
+}
+
+// StrategyNames 返回已注册的策略名称 (排序)
+func StrategyNames() []string {
+	strategyRegistryMu.RLock()
+	defer strategyRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(strategyRegistry))
+	for name := range strategyRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy 按名称从注册表创建策略 (名称为空时使用 DefaultStrategy)
+func NewStrategy(name string, env *Context) (Strategy, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	strategyRegistryMu.RLock()
+	factory, ok := strategyRegistry[strings.ToLower(name)]
+	strategyRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strings.Join(StrategyNames(), ", "))
+	}
+
+	s, err := factory(env)
+	if err != nil {
+		return nil, fmt.Errorf("strategy %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// positionsChanged 判断两次持仓之间是否有持仓腿的方向、数量或均价变化
+func positionsChanged(prev model.Positions, cur model.Positions) bool {
+	prevOpen, curOpen := prev.Open(), cur.Open()
+	if len(prevOpen) != len(curOpen) {
+		return true
+	}
+	for _, p := range curOpen {
+		old := prevOpen.Leg(p.Direction)
+		if old.Size != p.Size || old.AvgPrice != p.AvgPrice {
+			return true
+		}
+	}
+	return false
+}
@@ -2,50 +2,265 @@ package ta
 
 import (
 	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
 	"fmt"
-	"github.com/markcheno/go-talib"
+	"sort"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-// TAData 存储计算指标所需的所有历史数据
+// TAData 存储计算指标所需的所有历史数据。
+// GetTAData 返回的是某个版本的不可变快照：之后的 K 线不会修改它，使用方也不应修改其中的序列
 type TAData struct {
-	Symbol string
-	Close  []float64 // 收盘价序列
-	High   []float64 // 最高价序列
-	Low    []float64 // 最低价序列
-	Volume []float64 // 成交量序列
+	Symbol   string
+	Interval string
+	BarTime  time.Time // 快照包含的最后一根 K 线的开始时间
+	BarEnd   time.Time // 快照包含的最后一根 K 线的结束时间
+	Version  uint64    // 数据版本，每根新 K 线或指标重建后递增
 
-	// 存储最新计算出的指标值，方便外部查询
+	Open   *Ring // 开盘价序列
+	Close  *Ring // 收盘价序列
+	High   *Ring // 最高价序列
+	Low    *Ring // 最低价序列
+	Volume *Ring // 成交量序列
+
+	// 存储最新计算出的指标值，方便外部查询 (来自同名的内置指标，预热期内为 0)
 	MA       float64
 	RSI      float64
 	BBandsUp float64
 	BBandsDn float64
 	ATR      float64
-	MACDHist []float64
-	MACD     []float64
+	MACDHist *Ring // MACD 柱状图序列 (即 History("macd.hist"))
+	MACD     *Ring // MACD 线序列 (即 History("macd"))
+
+	history    map[string]*Ring // 指标输出名称 -> 预热完成后的输出序列
+	indicators *indicatorSet    // 流式指标状态，每根 K 线 O(1) 更新
+}
+
+// IsStaleAt 判断快照在 t 时刻是否已过期：t 时刻之前又完成了一根同周期的 K 线，而快照还没有包含它
+// (K 线没有时间戳时无法判断，返回 false)
+func (d *TAData) IsStaleAt(t time.Time) bool {
+	if d.BarEnd.IsZero() || d.BarTime.IsZero() {
+		return false
+	}
+	period := d.BarEnd.Sub(d.BarTime) + time.Millisecond
+	return !t.Before(d.BarEnd.Add(period))
+}
+
+// clone 复制一份不可变快照 (序列独立于实时数据，不包含指标状态)
+func (d *TAData) clone() *TAData {
+	snapshot := *d
+	snapshot.indicators = nil
+	snapshot.Open = d.Open.Clone()
+	snapshot.Close = d.Close.Clone()
+	snapshot.High = d.High.Clone()
+	snapshot.Low = d.Low.Clone()
+	snapshot.Volume = d.Volume.Clone()
+	snapshot.history = make(map[string]*Ring, len(d.history))
+	for name, series := range d.history {
+		snapshot.history[name] = series.Clone()
+	}
+	snapshot.MACD = snapshot.history[NameMACD]
+	snapshot.MACDHist = snapshot.history[NameMACD+".hist"]
+	return &snapshot
+}
+
+// Value 按名称查询指标的最新值，例如 "ema_fast"、"bbands.upper"、"macd.hist"。
+// 指标未配置或仍在预热时返回 false
+func (d *TAData) Value(name string) (float64, bool) {
+	series := d.history[name]
+	if series.Len() == 0 {
+		return 0, false
+	}
+	return series.Last(), true
 }
 
-// TACalculator 负责管理所有周期的数据和指标计算
+// History 按名称返回指标输出的历史序列 (预热完成后每根 K 线一个值，Ago(n) 回看 n 根之前的值)。
+// 指标未配置或仍在预热时返回 nil (nil 序列的 Len 为 0)
+func (d *TAData) History(name string) *Ring {
+	return d.history[name]
+}
+
+// Names 返回当前可查询的指标输出名称 (排序)
+func (d *TAData) Names() []string {
+	names := make([]string, 0, len(d.history))
+	for name := range d.history {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 默认指标周期
+const (
+	DefaultMAPeriod  = 20
+	DefaultRSIPeriod = 14
+)
+
+// DefaultHistoryLen 默认每个周期保留的 K 线数量
+const DefaultHistoryLen = 100
+
+// TACalculator 负责管理所有周期的数据和指标计算。
+// 每个周期的实时数据只在持有写锁时修改，策略层通过 GetTAData 读取按版本缓存的不可变快照
 type TACalculator struct {
 	mu            sync.RWMutex
-	HistoryMap    map[string]*TAData // Key: K 线周期 (e.g., "1h", "15m")
+	series        map[string]*TAData // Key: K 线周期 (e.g., "1h", "15m")，实时数据 (只在锁内访问)
+	snapshots     map[string]*TAData // 每个周期最近一次发布的快照
 	MinHistoryLen int                // 计算指标所需的最小历史长度
+	HistoryLen    int                // 每个周期保留的 K 线数量 (至少为最小历史长度的两倍)
+	MAPeriod      int                // 均线周期
+	RSIPeriod     int                // RSI 周期
 	Logger        *zap.SugaredLogger
+
+	indicators map[string][]service.IndicatorConfig // 周期 (或 AllIntervals) -> 按名称配置的指标
 }
 
 // NewTACalculator 初始化技术指标计算器
 func NewTACalculator(logger *zap.SugaredLogger) *TACalculator {
 	// 假设我们所需的指标（如MA20）至少需要20根K线
 	return &TACalculator{
-		HistoryMap:    make(map[string]*TAData),
+		series:        make(map[string]*TAData),
+		snapshots:     make(map[string]*TAData),
 		MinHistoryLen: 30, // 预留安全长度
+		HistoryLen:    DefaultHistoryLen,
+		MAPeriod:      DefaultMAPeriod,
+		RSIPeriod:     DefaultRSIPeriod,
 		Logger:        logger,
 	}
 }
 
-// UpdateKLine 更新数据，并重新计算指标
+// SetPeriods 设置均线和 RSI 周期 (<= 0 表示保持不变)，并相应放宽最小历史长度。
+// 已有数据的周期会用保留的历史重建指标
+func (tc *TACalculator) SetPeriods(maPeriod int, rsiPeriod int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	changed := false
+	if maPeriod > 0 && maPeriod != tc.MAPeriod {
+		tc.MAPeriod = maPeriod
+		changed = true
+	}
+	if rsiPeriod > 0 && rsiPeriod != tc.RSIPeriod {
+		tc.RSIPeriod = rsiPeriod
+		changed = true
+	}
+	// 保留 10 根的安全余量 (与默认 MA20 -> 30 一致)
+	for _, period := range []int{tc.MAPeriod + 10, tc.RSIPeriod + 10} {
+		if period > tc.MinHistoryLen {
+			tc.MinHistoryLen = period
+		}
+	}
+
+	for interval, taData := range tc.series {
+		tc.resize(taData)
+		if changed {
+			// 周期来自已校验的默认配置，不会出错
+			set, _ := tc.newIndicatorSet(interval)
+			tc.rebuild(taData, set)
+		}
+	}
+}
+
+// Periods 返回当前的均线和 RSI 周期
+func (tc *TACalculator) Periods() (int, int) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	return tc.MAPeriod, tc.RSIPeriod
+}
+
+// SetIndicators 按周期设置要计算的指标 (键为 K 线周期，AllIntervals 对所有周期生效)。
+// 配置按名称覆盖内置指标 (ma, rsi, bbands, macd, atr) 的参数或追加新指标，
+// 周期专属的配置优先于 AllIntervals。已有数据的周期会用保留的历史重建指标
+func (tc *TACalculator) SetIndicators(indicators map[string][]service.IndicatorConfig) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	previous := tc.indicators
+	tc.indicators = indicators
+	// 先校验所有周期的配置，出错时保持原配置
+	sets := make(map[string]*indicatorSet)
+	for interval := range indicators {
+		if _, err := tc.newIndicatorSet(interval); err != nil {
+			tc.indicators = previous
+			return fmt.Errorf("interval %s: %w", interval, err)
+		}
+	}
+	for interval := range tc.series {
+		set, err := tc.newIndicatorSet(interval)
+		if err != nil {
+			tc.indicators = previous
+			return fmt.Errorf("interval %s: %w", interval, err)
+		}
+		sets[interval] = set
+	}
+
+	for interval, set := range sets {
+		tc.rebuild(tc.series[interval], set)
+	}
+	return nil
+}
+
+// newIndicatorSet 按当前配置创建某个周期的指标
+func (tc *TACalculator) newIndicatorSet(interval string) (*indicatorSet, error) {
+	configs := mergeIndicators(defaultIndicators(tc.MAPeriod, tc.RSIPeriod), tc.indicators[AllIntervals], tc.indicators[interval])
+	return newIndicatorSet(configs)
+}
+
+// SetHistoryLen 设置每个周期保留的 K 线数量 (<= 0 表示保持不变)。
+// 指标是流式计算的，历史长度只影响保留的序列，不影响每根 K 线的计算量
+func (tc *TACalculator) SetHistoryLen(n int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if n > 0 {
+		tc.HistoryLen = n
+	}
+	for _, taData := range tc.series {
+		tc.resize(taData)
+	}
+}
+
+// maxHistoryLen 每个周期实际保留的 K 线数量 (周期较长时至少保留两倍的最小历史长度)
+func (tc *TACalculator) maxHistoryLen() int {
+	maxLen := tc.HistoryLen
+	if 2*tc.MinHistoryLen > maxLen {
+		maxLen = 2 * tc.MinHistoryLen
+	}
+	return maxLen
+}
+
+// resize 按当前的历史长度调整序列容量
+func (tc *TACalculator) resize(taData *TAData) {
+	maxLen := tc.maxHistoryLen()
+	for _, series := range []*Ring{taData.Open, taData.Close, taData.High, taData.Low, taData.Volume} {
+		series.Resize(maxLen)
+	}
+	for _, series := range taData.history {
+		series.Resize(maxLen)
+	}
+	taData.Version++
+}
+
+// rebuild 配置变化后用保留的 K 线历史重放新的指标
+// (EMA、RSI 等递推指标的重放结果与从完整历史计算的值有细微差别，并随新 K 线收敛)
+func (tc *TACalculator) rebuild(taData *TAData, set *indicatorSet) {
+	taData.indicators = set
+	taData.history = make(map[string]*Ring)
+	for i := 0; i < taData.Close.Len(); i++ {
+		tc.calculate(taData, model.KLine{
+			Open:   taData.Open.At(i),
+			High:   taData.High.At(i),
+			Low:    taData.Low.At(i),
+			Close:  taData.Close.At(i),
+			Volume: taData.Volume.At(i),
+		})
+	}
+	taData.Version++
+}
+
+// UpdateKLine 更新数据，并增量计算指标
 func (tc *TACalculator) UpdateKLine(kline model.KLine) {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
@@ -53,96 +268,110 @@ func (tc *TACalculator) UpdateKLine(kline model.KLine) {
 	interval := kline.Interval
 
 	// 初始化或获取历史数据结构
-	taData, ok := tc.HistoryMap[interval]
+	taData, ok := tc.series[interval]
 	if !ok {
+		set, err := tc.newIndicatorSet(interval)
+		if err != nil {
+			// SetIndicators 已校验过配置，这里只会在配置被绕过时出现
+			tc.Logger.Error("Invalid indicator config", zap.String("interval", interval), zap.Error(err))
+			return
+		}
+		maxLen := tc.maxHistoryLen()
 		taData = &TAData{
-			Symbol: kline.Symbol,
-			Close:  make([]float64, 0, 100),
-			High:   make([]float64, 0, 100),
-			Low:    make([]float64, 0, 100),
-			Volume: make([]float64, 0, 100),
+			Symbol:     kline.Symbol,
+			Interval:   interval,
+			Open:       NewRing(maxLen),
+			Close:      NewRing(maxLen),
+			High:       NewRing(maxLen),
+			Low:        NewRing(maxLen),
+			Volume:     NewRing(maxLen),
+			history:    make(map[string]*Ring),
+			indicators: set,
 		}
-		tc.HistoryMap[interval] = taData
+		tc.series[interval] = taData
 		tc.Logger.Debug("Initialized TA history for interval", zap.String("interval", interval))
 	}
 
-	// 检查是否是新的 K 线（通常 Close price 会更新多次，完成时才算新K线）
-	// 这里的逻辑简化为：如果时间戳不同，则添加新 K 线
-	if len(taData.Close) > 0 && taData.Close[len(taData.Close)-1] == kline.Close {
-		// 忽略重复的 K 线数据，只处理完成的 K 线
+	// 检查是否是新的 K 线：开始时间不晚于已包含的最后一根时视为重复或乱序的 K 线并忽略。
+	// 收盘价与上一根相同的 K 线 (横盘) 仍是新 K 线；没有时间戳的 K 线无法判断，总是追加
+	if taData.Close.Len() > 0 && !kline.StartTime.IsZero() && !kline.StartTime.After(taData.BarTime) {
 		return
 	}
 
 	// 实际项目中，需要判断是 K 线完成 (New Bar) 还是 K 线更新 (Bar Update)
 	// 这里我们假设 DataEngine 传进来的是已完成的 K 线
 
-	// 1. 更新历史数据：FIFO (先进先出) 机制
-	taData.Close = append(taData.Close, kline.Close)
-	taData.High = append(taData.High, kline.High)
-	taData.Low = append(taData.Low, kline.Low)
-	taData.Volume = append(taData.Volume, kline.Volume)
+	// 1. 更新历史数据：环形缓冲区写满后自动覆盖最旧的 K 线
+	taData.Open.Push(kline.Open)
+	taData.Close.Push(kline.Close)
+	taData.High.Push(kline.High)
+	taData.Low.Push(kline.Low)
+	taData.Volume.Push(kline.Volume)
 
-	// 保持历史数据长度，例如最多100根
-	maxLen := 100
-	if len(taData.Close) > maxLen {
-		taData.Close = taData.Close[len(taData.Close)-maxLen:]
-		taData.High = taData.High[len(taData.High)-maxLen:]
-		taData.Low = taData.Low[len(taData.Low)-maxLen:]
-		taData.Volume = taData.Volume[len(taData.Volume)-maxLen:]
-	}
-
-	// 2. 检查历史数据长度，进行计算
-	if len(taData.Close) < tc.MinHistoryLen {
-		tc.Logger.Debug("Not enough history for calculation", zap.String("interval", interval), zap.Int("len", len(taData.Close)))
-		return
+	// 2. 每根 K 线增量更新指标；历史长度不足时 GetTAData 不返回数据
+	tc.calculate(taData, kline)
+	taData.BarTime = kline.StartTime
+	taData.BarEnd = kline.EndTime
+	taData.Version++
+	if taData.Close.Len() < tc.MinHistoryLen {
+		tc.Logger.Debug("Not enough history for calculation", zap.String("interval", interval), zap.Int("len", taData.Close.Len()))
 	}
-
-	tc.calculate(taData)
 }
 
-// calculate 集中计算所有需要的指标
-func (tc *TACalculator) calculate(taData *TAData) {
-	closePrices := taData.Close
-
-	// --- 均线 (MA 20) ---
-	// 策略配置中 MA 周期可调，这里暂定为20
-	maPeriod := 20
-	maResult := talib.Sma(closePrices, maPeriod)
-	taData.MA = maResult[len(maResult)-1] // 取最新值
-
-	// --- 相对强弱指数 (RSI 14) ---
-	rsiPeriod := 14
-	rsiResult := talib.Rsi(closePrices, rsiPeriod)
-	taData.RSI = rsiResult[len(rsiResult)-1]
+// UpdateTicker 将逐笔成交转发给各周期中需要 Ticker 的指标 (不产生新版本，K 线完成时才发布)
+func (tc *TACalculator) UpdateTicker(ticker model.Ticker) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 
-	// --- 布林带 (BBands 20, 2) ---
-	bbandsUp, _, bbandsDn := talib.BBands(closePrices, 20, 2, 2, talib.SMA)
-	taData.BBandsUp = bbandsUp[len(bbandsUp)-1]
-	taData.BBandsDn = bbandsDn[len(bbandsDn)-1]
+	for _, taData := range tc.series {
+		taData.indicators.updateTicker(ticker)
+	}
+}
 
-	// MACD
-	macd, _, hist := talib.Macd(closePrices, 12, 26, 9)
-	taData.MACDHist = hist
-	taData.MACD = macd
+// calculate 用最新的 K 线增量更新所有指标，并同步内置指标的字段
+func (tc *TACalculator) calculate(taData *TAData, kline model.KLine) {
+	taData.indicators.update(kline, taData.history, tc.maxHistoryLen())
 
-	// --- 平均真实波动范围 (ATR 14) ---
-	// 注意：talib ATR 需要 High, Low, Previous Close prices
-	atrResult := talib.Atr(taData.High, taData.Low, closePrices, 14)
-	taData.ATR = atrResult[len(atrResult)-1]
+	taData.MA, _ = taData.Value(NameMA)
+	taData.RSI, _ = taData.Value(NameRSI)
+	taData.BBandsUp, _ = taData.Value(NameBBands + ".upper")
+	taData.BBandsDn, _ = taData.Value(NameBBands + ".lower")
+	taData.ATR, _ = taData.Value(NameATR)
 
-	// 记录最新计算结果
-	// tc.Logger.Debug(fmt.Sprintf("[%s] MA: %.2f, RSI: %.2f, ATR: %.4f",
-	// taData.Interval, taData.MA, taData.RSI, taData.ATR))
+	// MACD 序列供信号判断柱状图的穿越
+	taData.MACD = taData.History(NameMACD)
+	taData.MACDHist = taData.History(NameMACD + ".hist")
 }
 
-// GetTAData 用于策略层查询特定周期的指标
+// GetTAData 用于策略层查询特定周期的指标，返回当前版本的不可变快照。
+// 同一版本的快照只复制一次，之后的 K 线更新不会影响已返回的快照
 func (tc *TACalculator) GetTAData(interval string) (*TAData, error) {
 	tc.mu.RLock()
-	defer tc.mu.RUnlock()
+	snapshot, err := tc.snapshot(interval)
+	tc.mu.RUnlock()
+	if err != nil || snapshot != nil {
+		return snapshot, err
+	}
+
+	// 快照过期：在写锁内复制 (期间可能已被其他调用方发布)
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if snapshot, err = tc.snapshot(interval); err != nil || snapshot != nil {
+		return snapshot, err
+	}
+	snapshot = tc.series[interval].clone()
+	tc.snapshots[interval] = snapshot
+	return snapshot, nil
+}
 
-	taData, ok := tc.HistoryMap[interval]
-	if !ok || len(taData.Close) < tc.MinHistoryLen {
+// snapshot 返回与实时数据版本一致的已发布快照，需要重新复制时返回 nil (调用方持有锁)
+func (tc *TACalculator) snapshot(interval string) (*TAData, error) {
+	taData, ok := tc.series[interval]
+	if !ok || taData.Close.Len() < tc.MinHistoryLen {
 		return nil, fmt.Errorf("TA model not available or history too short for interval %s", interval)
 	}
-	return taData, nil
+	if snapshot := tc.snapshots[interval]; snapshot != nil && snapshot.Version == taData.Version {
+		return snapshot, nil
+	}
+	return nil, nil
 }
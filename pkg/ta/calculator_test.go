@@ -0,0 +1,246 @@
+package ta
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"testing"
+	"time"
+
+	"github.com/markcheno/go-talib"
+	"go.uber.org/zap"
+)
+
+const testInterval = "1d"
+
+// kline 返回第 i 根测试日线
+func (b testBars) kline(i int) model.KLine {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i)
+	return model.KLine{
+		Symbol:    "SPY",
+		Interval:  testInterval,
+		Open:      b.open[i],
+		High:      b.high[i],
+		Low:       b.low[i],
+		Close:     b.close[i],
+		Volume:    b.volume[i],
+		StartTime: start,
+		EndTime:   start.Add(24*time.Hour - time.Millisecond),
+	}
+}
+
+func newTestCalculator(historyLen int) *TACalculator {
+	tc := NewTACalculator(zap.NewNop().Sugar())
+	tc.SetHistoryLen(historyLen)
+	return tc
+}
+
+// feed 依次更新 [from, to) 的 K 线
+func (b testBars) feed(tc *TACalculator, from int, to int) {
+	for i := from; i < to; i++ {
+		tc.UpdateKLine(b.kline(i))
+	}
+}
+
+// assertHistory 比较指标的历史序列和 talib 输出中对应的最后几根
+func assertHistory(t *testing.T, data *TAData, name string, want []float64) {
+	t.Helper()
+	got := data.History(name).Values()
+	if len(got) == 0 {
+		t.Fatalf("%s: no history", name)
+	}
+	if len(got) > len(want) {
+		t.Fatalf("%s: %d values, talib only has %d", name, len(got), len(want))
+	}
+	for i, v := range got {
+		w := want[len(want)-len(got)+i]
+		if !closeTo(v, w) {
+			t.Fatalf("%s[%d of %d]: got %.12f, want %.12f", name, i, len(got), v, w)
+		}
+	}
+}
+
+func getTAData(t *testing.T, tc *TACalculator) *TAData {
+	t.Helper()
+	data, err := tc.GetTAData(testInterval)
+	if err != nil {
+		t.Fatalf("GetTAData: %v", err)
+	}
+	return data
+}
+
+func TestTACalculatorMatchesTalib(t *testing.T) {
+	bars := loadTestBars(t)
+	tc := newTestCalculator(len(bars.close))
+	bars.feed(tc, 0, len(bars.close))
+	data := getTAData(t, tc)
+
+	bbUp, bbMid, bbDn := talib.BBands(bars.close, 20, 2, 2, talib.SMA)
+	macd, macdSignal, macdHist := talib.Macd(bars.close, 12, 26, 9)
+	assertHistory(t, data, NameMA, talib.Sma(bars.close, DefaultMAPeriod)[DefaultMAPeriod-1:])
+	assertHistory(t, data, NameRSI, talib.Rsi(bars.close, DefaultRSIPeriod)[DefaultRSIPeriod:])
+	assertHistory(t, data, NameBBands, bbMid[19:])
+	assertHistory(t, data, NameBBands+".upper", bbUp[19:])
+	assertHistory(t, data, NameBBands+".lower", bbDn[19:])
+	assertHistory(t, data, NameMACD, macd[33:])
+	assertHistory(t, data, NameMACD+".signal", macdSignal[33:])
+	assertHistory(t, data, NameMACD+".hist", macdHist[33:])
+	assertHistory(t, data, NameATR, talib.Atr(bars.high, bars.low, bars.close, 14)[14:])
+
+	// 预热边界：历史序列从 talib 的第一个有效输出开始，长度与 talib 的有效输出一致
+	n := len(bars.close)
+	for name, lookback := range map[string]int{NameMA: 19, NameRSI: 14, NameBBands: 19, NameMACD: 33, NameATR: 14} {
+		if got := data.History(name).Len(); got != n-lookback {
+			t.Errorf("%s: %d values, want %d", name, got, n-lookback)
+		}
+	}
+	if data.MA != data.History(NameMA).Last() || data.ATR != data.History(NameATR).Last() {
+		t.Errorf("MA/ATR fields do not match the latest history values")
+	}
+}
+
+func TestTACalculatorWarmUp(t *testing.T) {
+	bars := loadTestBars(t)
+	tc := newTestCalculator(len(bars.close))
+
+	// MinHistoryLen 之前不发布快照
+	bars.feed(tc, 0, tc.MinHistoryLen-1)
+	if _, err := tc.GetTAData(testInterval); err == nil {
+		t.Fatalf("GetTAData succeeded with %d bars, want error below MinHistoryLen %d", tc.MinHistoryLen-1, tc.MinHistoryLen)
+	}
+	bars.feed(tc, tc.MinHistoryLen-1, tc.MinHistoryLen)
+	data := getTAData(t, tc)
+
+	// 30 根时 MACD (lookback 33) 仍在预热：没有历史，也不可查询
+	if _, ok := data.Value(NameMACD + ".hist"); ok {
+		t.Errorf("macd.hist available after %d bars, want warm-up until bar 34", tc.MinHistoryLen)
+	}
+	if data.MACDHist.Len() != 0 {
+		t.Errorf("MACDHist has %d values during warm-up", data.MACDHist.Len())
+	}
+	if got, want := data.History(NameRSI).Len(), tc.MinHistoryLen-DefaultRSIPeriod; got != want {
+		t.Errorf("rsi: %d values after %d bars, want %d", got, tc.MinHistoryLen, want)
+	}
+
+	bars.feed(tc, tc.MinHistoryLen, 34)
+	if got := getTAData(t, tc).History(NameMACD + ".hist").Len(); got != 1 {
+		t.Errorf("macd.hist: %d values after 34 bars, want 1", got)
+	}
+}
+
+func TestTACalculatorRebuildAfterSetPeriods(t *testing.T) {
+	bars := loadTestBars(t)
+	n := len(bars.close)
+
+	t.Run("full history", func(t *testing.T) {
+		tc := newTestCalculator(n)
+		bars.feed(tc, 0, 150)
+		tc.SetPeriods(10, 7)
+		bars.feed(tc, 150, n)
+		data := getTAData(t, tc)
+
+		// 保留了全部 K 线时，重放结果与 talib 对完整序列的计算一致
+		assertHistory(t, data, NameMA, talib.Sma(bars.close, 10)[9:])
+		assertHistory(t, data, NameRSI, talib.Rsi(bars.close, 7)[7:])
+		if got := data.History(NameMA).Len(); got != n-9 {
+			t.Errorf("ma: %d values, want %d", got, n-9)
+		}
+	})
+
+	t.Run("truncated history", func(t *testing.T) {
+		tc := newTestCalculator(60)
+		bars.feed(tc, 0, 200)
+		retained := tc.maxHistoryLen()
+		tc.SetPeriods(10, 7)
+		data := getTAData(t, tc)
+
+		// 只保留了最后 retained 根：重放结果等于 talib 对保留窗口的计算
+		window := bars.close[200-retained : 200]
+		assertHistory(t, data, NameMA, talib.Sma(window, 10)[9:])
+		assertHistory(t, data, NameRSI, talib.Rsi(window, 7)[7:])
+		if got := data.History(NameMA).Len(); got != retained-9 {
+			t.Errorf("ma: %d values, want %d", got, retained-9)
+		}
+	})
+
+	t.Run("unchanged periods keep state", func(t *testing.T) {
+		tc := newTestCalculator(60)
+		bars.feed(tc, 0, 200)
+		tc.SetPeriods(DefaultMAPeriod, DefaultRSIPeriod)
+		bars.feed(tc, 200, n)
+		// 周期未变时不重放，递推指标仍与完整序列一致
+		assertHistory(t, getTAData(t, tc), NameRSI, talib.Rsi(bars.close, DefaultRSIPeriod)[DefaultRSIPeriod:])
+	})
+}
+
+func TestTACalculatorRebuildAfterSetIndicators(t *testing.T) {
+	bars := loadTestBars(t)
+	n := len(bars.close)
+	tc := newTestCalculator(n)
+	bars.feed(tc, 0, 200)
+	before := getTAData(t, tc)
+
+	err := tc.SetIndicators(map[string][]service.IndicatorConfig{
+		AllIntervals: {{Name: "ema_fast", Type: TypeEMA, Period: 8}},
+		testInterval: {{Name: NameBBands, Type: TypeBBands, Period: 10, Params: map[string]float64{"devup": 1.5, "devdn": 2.5}}},
+	})
+	if err != nil {
+		t.Fatalf("SetIndicators: %v", err)
+	}
+	rebuilt := getTAData(t, tc)
+	if rebuilt.Version <= before.Version {
+		t.Errorf("version %d not bumped after rebuild (was %d)", rebuilt.Version, before.Version)
+	}
+	// 之前返回的快照不受重建影响
+	if _, ok := before.Value("ema_fast"); ok {
+		t.Errorf("old snapshot sees ema_fast added by the rebuild")
+	}
+
+	bars.feed(tc, 200, n)
+	data := getTAData(t, tc)
+	up, mid, dn := talib.BBands(bars.close, 10, 1.5, 2.5, talib.SMA)
+	assertHistory(t, data, "ema_fast", talib.Ema(bars.close, 8)[7:])
+	assertHistory(t, data, NameBBands, mid[9:])
+	assertHistory(t, data, NameBBands+".upper", up[9:])
+	assertHistory(t, data, NameBBands+".lower", dn[9:])
+	// 未覆盖的内置指标保持原参数
+	assertHistory(t, data, NameMA, talib.Sma(bars.close, DefaultMAPeriod)[DefaultMAPeriod-1:])
+	if !closeTo(data.BBandsUp, up[n-1]) || !closeTo(data.BBandsDn, dn[n-1]) {
+		t.Errorf("BBandsUp/Dn fields = %.6f/%.6f, want %.6f/%.6f", data.BBandsUp, data.BBandsDn, up[n-1], dn[n-1])
+	}
+
+	// 无效配置返回错误并保持原配置
+	if err := tc.SetIndicators(map[string][]service.IndicatorConfig{testInterval: {{Name: "x", Type: "nope"}}}); err == nil {
+		t.Fatalf("SetIndicators accepted an unknown indicator type")
+	}
+	if _, ok := getTAData(t, tc).Value("ema_fast"); !ok {
+		t.Errorf("ema_fast lost after a rejected SetIndicators")
+	}
+}
+
+func TestTACalculatorDedupByBarTime(t *testing.T) {
+	bars := loadTestBars(t)
+	tc := newTestCalculator(len(bars.close))
+	bars.feed(tc, 0, 40)
+	before := getTAData(t, tc)
+
+	// 收盘价与上一根相同的新 K 线 (横盘) 仍然更新指标并发布新快照
+	flat := bars.kline(40)
+	flat.Close = bars.close[39]
+	tc.UpdateKLine(flat)
+	data := getTAData(t, tc)
+	if data.Close.Len() != 41 || data.Version <= before.Version {
+		t.Fatalf("equal-close bar dropped: %d bars, version %d (was %d)", data.Close.Len(), data.Version, before.Version)
+	}
+	if !data.BarTime.Equal(flat.StartTime) || data.IsStaleAt(flat.EndTime) {
+		t.Errorf("snapshot BarTime %v is stale for the equal-close bar at %v", data.BarTime, flat.StartTime)
+	}
+
+	// 重复和乱序的 K 线 (开始时间不晚于最后一根) 被忽略
+	dup := flat
+	dup.Close++
+	tc.UpdateKLine(dup)
+	tc.UpdateKLine(bars.kline(10))
+	if after := getTAData(t, tc); after.Version != data.Version || after.Close.Len() != 41 {
+		t.Errorf("duplicate or out-of-order bar was applied: %d bars, version %d (was %d)", after.Close.Len(), after.Version, data.Version)
+	}
+}
@@ -0,0 +1,281 @@
+package ta
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 内置指标类型 (IndicatorConfig.Type)
+const (
+	TypeSMA    = "sma"
+	TypeEMA    = "ema"
+	TypeRSI    = "rsi"
+	TypeBBands = "bbands"
+	TypeMACD   = "macd"
+	TypeATR    = "atr"
+)
+
+// 默认指标名称：信号和状态机使用的内置指标，配置中同名的指标会覆盖其参数
+const (
+	NameMA     = "ma"
+	NameRSI    = "rsi"
+	NameBBands = "bbands"
+	NameMACD   = "macd"
+	NameATR    = "atr"
+)
+
+// AllIntervals 配置中对所有周期生效的键
+const AllIntervals = "*"
+
+// Indicator 流式指标接口：每根完成的 K 线调用一次 Update，之后通过 Values 读取各输出的最新值。
+// 自定义指标 (订单流 delta、VWAP 通道、波动率评分等) 实现该接口并通过 RegisterIndicator 注册后，
+// 即可在 StrategyConfig.Indicators 中按类型名配置，无需修改 pkg/ta
+type Indicator interface {
+	// WarmUp 产生第一个有效输出所需的 K 线数量，之前的输出不会被发布
+	WarmUp() int
+	// Update 用一根完成的 K 线更新指标
+	Update(kline model.KLine)
+	// Values 返回各输出的最新值，顺序与注册时的输出后缀一致 (第一个为主输出)
+	Values() []float64
+}
+
+// TickIndicator 还需要逐笔成交的指标 (例如已实现波动率)，TACalculator.UpdateTicker 将 Ticker 转发给它
+type TickIndicator interface {
+	Indicator
+	UpdateTicker(ticker model.Ticker)
+}
+
+// IndicatorFactory 按配置创建指标实例 (参数无效时返回错误)
+type IndicatorFactory func(cfg service.IndicatorConfig) (Indicator, error)
+
+// indicatorType 注册的指标类型
+type indicatorType struct {
+	outputs []string // 输出后缀，"" 为主输出
+	factory IndicatorFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]indicatorType)
+)
+
+// RegisterIndicator 注册指标类型 (通常在 init 中调用)。outputs 为各输出的后缀，
+// 主输出使用空字符串并按指标名称查询，其余输出按 "名称.后缀" 查询；为空时只有主输出。
+// 类型名不区分大小写，重复注册会 panic
+func RegisterIndicator(typ string, outputs []string, factory IndicatorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	typ = strings.ToLower(typ)
+	if factory == nil {
+		panic("ta: RegisterIndicator factory is nil for " + typ)
+	}
+	if _, dup := registry[typ]; dup {
+		panic("ta: RegisterIndicator called twice for " + typ)
+	}
+	if len(outputs) == 0 {
+		outputs = []string{""}
+	}
+	registry[typ] = indicatorType{outputs: outputs, factory: factory}
+}
+
+// IndicatorTypes 返回已注册的指标类型 (排序)
+func IndicatorTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewIndicator 按配置从注册表创建指标，并返回各输出的查询名称
+func NewIndicator(cfg service.IndicatorConfig) (Indicator, []string, error) {
+	if cfg.Name == "" {
+		return nil, nil, fmt.Errorf("indicator of type %q has no name", cfg.Type)
+	}
+	registryMu.RLock()
+	t, ok := registry[strings.ToLower(cfg.Type)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("indicator %s: unknown type %q (available: %s)", cfg.Name, cfg.Type, strings.Join(IndicatorTypes(), ", "))
+	}
+
+	ind, err := t.factory(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("indicator %s: %w", cfg.Name, err)
+	}
+	names := make([]string, len(t.outputs))
+	for i, suffix := range t.outputs {
+		names[i] = cfg.Name
+		if suffix != "" {
+			names[i] += "." + suffix
+		}
+	}
+	return ind, names, nil
+}
+
+func init() {
+	RegisterIndicator(TypeSMA, nil, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &smaIndicator{NewSMA(periodOr(cfg, DefaultMAPeriod))}, nil
+	})
+	RegisterIndicator(TypeEMA, nil, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &emaIndicator{NewEMA(periodOr(cfg, DefaultMAPeriod))}, nil
+	})
+	RegisterIndicator(TypeRSI, nil, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &rsiIndicator{NewRSI(periodOr(cfg, DefaultRSIPeriod))}, nil
+	})
+	RegisterIndicator(TypeBBands, []string{"", "upper", "lower"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		dev := cfg.Param("dev", 2)
+		return &bbandsIndicator{NewBBands(periodOr(cfg, 20), cfg.Param("devup", dev), cfg.Param("devdn", dev))}, nil
+	})
+	RegisterIndicator(TypeMACD, []string{"", "signal", "hist"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		fast, slow, signal := int(cfg.Param("fast", 12)), int(cfg.Param("slow", 26)), int(cfg.Param("signal", 9))
+		if fast < 1 || slow < 1 || signal < 1 {
+			return nil, fmt.Errorf("macd periods must be positive, got %d/%d/%d", fast, slow, signal)
+		}
+		return &macdIndicator{NewMACD(fast, slow, signal)}, nil
+	})
+	RegisterIndicator(TypeATR, nil, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &atrIndicator{NewATR(periodOr(cfg, 14))}, nil
+	})
+}
+
+// periodOr 返回配置的周期，未设置时返回 def
+func periodOr(cfg service.IndicatorConfig, def int) int {
+	if cfg.Period > 0 {
+		return cfg.Period
+	}
+	return def
+}
+
+// 内置流式指标到 Indicator 接口的适配
+
+type smaIndicator struct{ *SMA }
+
+func (i *smaIndicator) WarmUp() int              { return i.period }
+func (i *smaIndicator) Update(kline model.KLine) { i.SMA.Update(kline.Close) }
+func (i *smaIndicator) Values() []float64        { return []float64{i.Value()} }
+
+type emaIndicator struct{ *EMA }
+
+func (i *emaIndicator) WarmUp() int              { return i.period }
+func (i *emaIndicator) Update(kline model.KLine) { i.EMA.Update(kline.Close) }
+func (i *emaIndicator) Values() []float64        { return []float64{i.Value()} }
+
+type rsiIndicator struct{ *RSI }
+
+func (i *rsiIndicator) WarmUp() int              { return i.period + 1 }
+func (i *rsiIndicator) Update(kline model.KLine) { i.RSI.Update(kline.Close) }
+func (i *rsiIndicator) Values() []float64        { return []float64{i.Value()} }
+
+type bbandsIndicator struct{ *BBands }
+
+func (i *bbandsIndicator) WarmUp() int              { return i.period }
+func (i *bbandsIndicator) Update(kline model.KLine) { i.BBands.Update(kline.Close) }
+func (i *bbandsIndicator) Values() []float64 {
+	upper, middle, lower := i.Value()
+	return []float64{middle, upper, lower}
+}
+
+type macdIndicator struct{ *MACD }
+
+func (i *macdIndicator) WarmUp() int              { return i.lookback + 1 }
+func (i *macdIndicator) Update(kline model.KLine) { i.MACD.Update(kline.Close) }
+func (i *macdIndicator) Values() []float64 {
+	macd, signal, hist := i.Value()
+	return []float64{macd, signal, hist}
+}
+
+type atrIndicator struct{ *ATR }
+
+func (i *atrIndicator) WarmUp() int              { return i.period + 1 }
+func (i *atrIndicator) Update(kline model.KLine) { i.ATR.Update(kline.High, kline.Low, kline.Close) }
+func (i *atrIndicator) Values() []float64        { return []float64{i.Value()} }
+
+// indicatorSet 某个周期上按名称配置的一组指标
+type indicatorSet struct {
+	indicators []Indicator
+	names      [][]string // 每个指标各输出的查询名称
+	bars       int        // 已更新的 K 线数量
+}
+
+// newIndicatorSet 按配置创建一组指标
+func newIndicatorSet(configs []service.IndicatorConfig) (*indicatorSet, error) {
+	set := &indicatorSet{}
+	for _, cfg := range configs {
+		ind, names, err := NewIndicator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		set.indicators = append(set.indicators, ind)
+		set.names = append(set.names, names)
+	}
+	return set, nil
+}
+
+// updateTicker 将 Ticker 转发给需要逐笔数据的指标
+func (s *indicatorSet) updateTicker(ticker model.Ticker) {
+	for _, ind := range s.indicators {
+		if ti, ok := ind.(TickIndicator); ok {
+			ti.UpdateTicker(ticker)
+		}
+	}
+}
+
+// update 用一根 K 线更新所有指标，已完成预热的指标输出追加到对应名称的历史序列
+func (s *indicatorSet) update(kline model.KLine, history map[string]*Ring, capacity int) {
+	s.bars++
+	for i, ind := range s.indicators {
+		ind.Update(kline)
+		if s.bars < ind.WarmUp() {
+			continue
+		}
+		for j, v := range ind.Values() {
+			if j >= len(s.names[i]) {
+				break
+			}
+			series, ok := history[s.names[i][j]]
+			if !ok {
+				series = NewRing(capacity)
+				history[s.names[i][j]] = series
+			}
+			series.Push(v)
+		}
+	}
+}
+
+// defaultIndicators 内置指标：均线、RSI、布林带、MACD 和 ATR (策略和状态机依赖这些名称)
+func defaultIndicators(maPeriod int, rsiPeriod int) []service.IndicatorConfig {
+	return []service.IndicatorConfig{
+		{Name: NameMA, Type: TypeSMA, Period: maPeriod},
+		{Name: NameRSI, Type: TypeRSI, Period: rsiPeriod},
+		{Name: NameBBands, Type: TypeBBands, Period: 20, Params: map[string]float64{"dev": 2}},
+		{Name: NameMACD, Type: TypeMACD, Params: map[string]float64{"fast": 12, "slow": 26, "signal": 9}},
+		{Name: NameATR, Type: TypeATR, Period: 14},
+	}
+}
+
+// mergeIndicators 按名称合并指标配置：同名的后者覆盖前者 (保留首次出现的位置)，新名称追加在末尾
+func mergeIndicators(lists ...[]service.IndicatorConfig) []service.IndicatorConfig {
+	var merged []service.IndicatorConfig
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, cfg := range list {
+			if i, ok := index[cfg.Name]; ok {
+				merged[i] = cfg
+				continue
+			}
+			index[cfg.Name] = len(merged)
+			merged = append(merged, cfg)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,96 @@
+package ta
+
+// Ring 固定容量的环形缓冲区：写满后新值覆盖最旧的值，追加和按位置读取都是 O(1)
+type Ring struct {
+	buf   []float64
+	start int // 最旧元素的位置
+	n     int // 当前元素个数
+}
+
+// NewRing 创建容量为 capacity 的环形缓冲区 (至少为 1)
+func NewRing(capacity int) *Ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ring{buf: make([]float64, capacity)}
+}
+
+// Push 追加一个值。缓冲区已满时覆盖并返回最旧的值，evicted 为 true
+func (r *Ring) Push(v float64) (old float64, evicted bool) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return 0, false
+	}
+	old = r.buf[r.start]
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+	return old, true
+}
+
+// Len 返回当前元素个数
+func (r *Ring) Len() int {
+	if r == nil {
+		return 0
+	}
+	return r.n
+}
+
+// Cap 返回容量
+func (r *Ring) Cap() int {
+	return len(r.buf)
+}
+
+// At 返回第 i 个元素 (0 为最旧)
+func (r *Ring) At(i int) float64 {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+// Ago 返回 n 根之前的元素 (0 为最新)，超出范围时返回 0
+func (r *Ring) Ago(n int) float64 {
+	if n < 0 || n >= r.Len() {
+		return 0
+	}
+	return r.At(r.n - 1 - n)
+}
+
+// Last 返回最新的元素，为空时返回 0
+func (r *Ring) Last() float64 {
+	return r.Ago(0)
+}
+
+// Values 按时间顺序 (最旧在前) 返回所有元素的副本
+func (r *Ring) Values() []float64 {
+	values := make([]float64, r.Len())
+	for i := range values {
+		values[i] = r.At(i)
+	}
+	return values
+}
+
+// Clone 返回独立的副本 (nil 的副本为 nil)
+func (r *Ring) Clone() *Ring {
+	if r == nil {
+		return nil
+	}
+	clone := &Ring{buf: make([]float64, len(r.buf)), start: r.start, n: r.n}
+	copy(clone.buf, r.buf)
+	return clone
+}
+
+// Resize 调整容量，容量变小时只保留最新的元素
+func (r *Ring) Resize(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if capacity == len(r.buf) {
+		return
+	}
+	values := r.Values()
+	if len(values) > capacity {
+		values = values[len(values)-capacity:]
+	}
+	r.buf = make([]float64, capacity)
+	copy(r.buf, values)
+	r.start, r.n = 0, len(values)
+}
@@ -0,0 +1,314 @@
+package ta
+
+import "math"
+
+// 流式指标：每根 K 线 O(1) 更新，数值与 go-talib 对完整序列的计算结果一致。
+// 与 talib 相同，预热期 (lookback) 内的输出为 0，Ready 返回 false
+
+// talibEpsilon talib 判断方差 / RSI 分母为零的阈值
+const talibEpsilon = 0.00000000000001
+
+// SMA 简单移动平均
+type SMA struct {
+	period int
+	window *Ring
+	sum    float64
+	value  float64
+}
+
+// NewSMA 创建周期为 period 的简单移动平均
+func NewSMA(period int) *SMA {
+	return &SMA{period: period, window: NewRing(period)}
+}
+
+// Update 追加一个值并返回最新的均值
+func (s *SMA) Update(v float64) float64 {
+	s.sum += v
+	if old, evicted := s.window.Push(v); evicted {
+		s.sum -= old
+	}
+	if s.Ready() {
+		s.value = s.sum / float64(s.period)
+	}
+	return s.value
+}
+
+// Value 返回最新的均值
+func (s *SMA) Value() float64 { return s.value }
+
+// Ready 是否已有足够的数据
+func (s *SMA) Ready() bool { return s.window.Len() >= s.period }
+
+// EMA 指数移动平均，以前 period 个值的简单平均作为初始值 (talib 的初始化方式)
+type EMA struct {
+	period int
+	k      float64
+	count  int
+	sum    float64
+	value  float64
+}
+
+// NewEMA 创建周期为 period 的指数移动平均 (平滑系数 2 / (period + 1))
+func NewEMA(period int) *EMA {
+	return &EMA{period: period, k: 2.0 / float64(period+1)}
+}
+
+// Update 追加一个值并返回最新的均值
+func (e *EMA) Update(v float64) float64 {
+	e.count++
+	switch {
+	case e.count < e.period:
+		e.sum += v
+	case e.count == e.period:
+		e.sum += v
+		e.value = e.sum / float64(e.period)
+	default:
+		e.value = (v-e.value)*e.k + e.value
+	}
+	return e.value
+}
+
+// Value 返回最新的均值
+func (e *EMA) Value() float64 { return e.value }
+
+// Ready 是否已有足够的数据
+func (e *EMA) Ready() bool { return e.count >= e.period }
+
+// RSI 相对强弱指数 (Wilder 平滑)
+type RSI struct {
+	period   int
+	count    int
+	prev     float64
+	avgGain  float64
+	avgLoss  float64
+	value    float64
+	hasValue bool
+}
+
+// NewRSI 创建周期为 period 的 RSI
+func NewRSI(period int) *RSI {
+	return &RSI{period: period}
+}
+
+// Update 追加一个收盘价并返回最新的 RSI
+func (r *RSI) Update(v float64) float64 {
+	r.count++
+	if r.count == 1 || r.period < 2 {
+		r.prev = v
+		return r.value
+	}
+
+	change := v - r.prev
+	r.prev = v
+	gain, loss := 0.0, 0.0
+	if change < 0 {
+		loss = -change
+	} else {
+		gain = change
+	}
+
+	period := float64(r.period)
+	if r.count <= r.period+1 {
+		// 前 period 个涨跌幅的简单平均作为初始值
+		r.avgGain += gain
+		r.avgLoss += loss
+		if r.count < r.period+1 {
+			return r.value
+		}
+		r.avgGain /= period
+		r.avgLoss /= period
+	} else {
+		r.avgGain = (r.avgGain*(period-1) + gain) / period
+		r.avgLoss = (r.avgLoss*(period-1) + loss) / period
+	}
+
+	total := r.avgGain + r.avgLoss
+	if total > -talibEpsilon && total < talibEpsilon {
+		r.value = 0
+	} else {
+		r.value = 100 * (r.avgGain / total)
+	}
+	r.hasValue = true
+	return r.value
+}
+
+// Value 返回最新的 RSI
+func (r *RSI) Value() float64 { return r.value }
+
+// Ready 是否已有足够的数据
+func (r *RSI) Ready() bool { return r.hasValue }
+
+// BBands 布林带 (中轨为简单移动平均，带宽为总体标准差的倍数)。
+// 方差按滑动窗口的 Welford 方法更新 (维护窗口均值和离差平方和)，避免 sumSq/n - mean² 在高价位、
+// 低波动时的相消误差；每滑过一个完整窗口按两遍法重新计算一次，舍入误差不随序列长度累积 (均摊 O(1))
+type BBands struct {
+	period        int
+	devUp, devDn  float64
+	window        *Ring
+	mean, m2      float64 // 窗口均值、窗口离差平方和 Σ(x - mean)²
+	evicted       int     // 上次重新计算之后移出窗口的值的数量
+	upper, middle float64
+	lower         float64
+}
+
+// NewBBands 创建周期为 period、上下轨分别为 devUp / devDn 倍标准差的布林带
+func NewBBands(period int, devUp float64, devDn float64) *BBands {
+	return &BBands{period: period, devUp: devUp, devDn: devDn, window: NewRing(period)}
+}
+
+// Update 追加一个值并返回最新的上轨、中轨、下轨
+func (b *BBands) Update(v float64) (upper float64, middle float64, lower float64) {
+	if old, evicted := b.window.Push(v); evicted {
+		// 同时移入 v、移出 old：Σ(x - mean)² 的变化为 (v - old)(v - mean' + old - mean)
+		mean := b.mean + (v-old)/float64(b.period)
+		b.m2 += (v - old) * (v - mean + old - b.mean)
+		b.mean = mean
+		if b.evicted++; b.evicted >= b.period {
+			b.resync()
+		}
+	} else {
+		delta := v - b.mean
+		b.mean += delta / float64(b.window.Len())
+		b.m2 += delta * (v - b.mean)
+	}
+	if b.Ready() {
+		variance := math.Max(b.m2/float64(b.period), 0) // 舍入误差可能使离差平方和略小于 0
+		stdDev := 0.0
+		if !(variance < talibEpsilon) {
+			stdDev = math.Sqrt(variance)
+		}
+		b.middle = b.mean
+		b.upper = b.mean + stdDev*b.devUp
+		b.lower = b.mean - stdDev*b.devDn
+	}
+	return b.upper, b.middle, b.lower
+}
+
+// resync 按窗口内的值重新计算均值和离差平方和，清除增量更新累积的舍入误差
+func (b *BBands) resync() {
+	sum := 0.0
+	for i := 0; i < b.window.Len(); i++ {
+		sum += b.window.At(i)
+	}
+	b.mean = sum / float64(b.window.Len())
+	b.m2 = 0
+	for i := 0; i < b.window.Len(); i++ {
+		d := b.window.At(i) - b.mean
+		b.m2 += d * d
+	}
+	b.evicted = 0
+}
+
+// Value 返回最新的上轨、中轨、下轨
+func (b *BBands) Value() (upper float64, middle float64, lower float64) {
+	return b.upper, b.middle, b.lower
+}
+
+// Ready 是否已有足够的数据
+func (b *BBands) Ready() bool { return b.window.Len() >= b.period }
+
+// MACD 指数平滑异同移动平均。
+// 与 go-talib 一致：快慢线都从第一根开始计算，MACD 线在 slow+signal-2 根之前视为 0 并参与信号线的计算
+type MACD struct {
+	fast, slow, signal *EMA
+	count              int
+	lookback           int // 柱状图的预热长度
+	macd, sig, hist    float64
+}
+
+// NewMACD 创建 MACD (fast/slow 会按大小交换，与 talib 相同)
+func NewMACD(fastPeriod int, slowPeriod int, signalPeriod int) *MACD {
+	if slowPeriod < fastPeriod {
+		fastPeriod, slowPeriod = slowPeriod, fastPeriod
+	}
+	return &MACD{
+		fast:     NewEMA(fastPeriod),
+		slow:     NewEMA(slowPeriod),
+		signal:   NewEMA(signalPeriod),
+		lookback: (signalPeriod - 1) + (slowPeriod - 1),
+	}
+}
+
+// Update 追加一个收盘价并返回最新的 MACD 线、信号线和柱状图
+func (m *MACD) Update(v float64) (macd float64, signal float64, hist float64) {
+	index := m.count
+	m.count++
+
+	diff := m.fast.Update(v) - m.slow.Update(v)
+	m.macd = 0
+	if index >= m.lookback-1 {
+		m.macd = diff
+	}
+	m.sig = m.signal.Update(m.macd)
+	m.hist = 0
+	if index >= m.lookback {
+		m.hist = m.macd - m.sig
+	}
+	return m.macd, m.sig, m.hist
+}
+
+// Value 返回最新的 MACD 线、信号线和柱状图
+func (m *MACD) Value() (macd float64, signal float64, hist float64) {
+	return m.macd, m.sig, m.hist
+}
+
+// Ready 是否已有足够的数据 (柱状图有效)
+func (m *MACD) Ready() bool { return m.count > m.lookback }
+
+// ATR 平均真实波幅 (Wilder 平滑)
+type ATR struct {
+	period    int
+	count     int
+	prevClose float64
+	sum       float64
+	value     float64
+}
+
+// NewATR 创建周期为 period 的 ATR
+func NewATR(period int) *ATR {
+	return &ATR{period: period}
+}
+
+// Update 追加一根 K 线并返回最新的 ATR
+func (a *ATR) Update(high float64, low float64, closePrice float64) float64 {
+	a.count++
+	if a.count == 1 {
+		a.prevClose = closePrice
+		return a.value
+	}
+
+	tr := TrueRange(high, low, a.prevClose)
+	a.prevClose = closePrice
+	period := float64(a.period)
+	switch {
+	case a.period <= 1:
+		a.value = tr
+	case a.count <= a.period:
+		a.sum += tr
+	case a.count == a.period+1:
+		// 前 period 个真实波幅的简单平均作为初始值
+		a.sum += tr
+		a.value = a.sum / period
+	default:
+		a.value = (a.value*(period-1) + tr) / period
+	}
+	return a.value
+}
+
+// Value 返回最新的 ATR
+func (a *ATR) Value() float64 { return a.value }
+
+// Ready 是否已有足够的数据
+func (a *ATR) Ready() bool { return a.count > a.period }
+
+// TrueRange 真实波幅：max(high-low, |high-prevClose|, |low-prevClose|)
+func TrueRange(high float64, low float64, prevClose float64) float64 {
+	tr := high - low
+	if v := math.Abs(prevClose - high); v > tr {
+		tr = v
+	}
+	if v := math.Abs(prevClose - low); v > tr {
+		tr = v
+	}
+	return tr
+}
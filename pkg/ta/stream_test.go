@@ -0,0 +1,249 @@
+package ta
+
+import (
+	"encoding/csv"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/markcheno/go-talib"
+)
+
+// testBars 测试用的日线 (testdata/spy_daily.csv：SPY 的 252 根日线，取自 go-talib 的测试数据)
+type testBars struct {
+	open, high, low, close, volume []float64
+}
+
+func loadTestBars(t *testing.T) testBars {
+	t.Helper()
+	f, err := os.Open("testdata/spy_daily.csv")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var bars testBars
+	for _, row := range rows[1:] {
+		v := make([]float64, len(row))
+		for i, field := range row {
+			if v[i], err = strconv.ParseFloat(field, 64); err != nil {
+				t.Fatalf("parse %q: %v", field, err)
+			}
+		}
+		bars.open = append(bars.open, v[0])
+		bars.high = append(bars.high, v[1])
+		bars.low = append(bars.low, v[2])
+		bars.close = append(bars.close, v[3])
+		bars.volume = append(bars.volume, v[4])
+	}
+	return bars
+}
+
+// assertSeries 逐根比较流式指标和 talib 的完整输出 (包括预热期内的 0)
+func assertSeries(t *testing.T, got []float64, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("length %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !closeTo(got[i], want[i]) {
+			t.Fatalf("bar %d: got %.12f, want %.12f", i, got[i], want[i])
+		}
+	}
+}
+
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// assertReadyAt 检查 Ready 恰好在 talib 的第一个有效输出 (下标 lookback) 时变为 true
+func assertReadyAt(t *testing.T, ready []bool, lookback int) {
+	t.Helper()
+	for i, r := range ready {
+		if r != (i >= lookback) {
+			t.Fatalf("bar %d: Ready = %v, want first ready bar %d", i, r, lookback)
+		}
+	}
+}
+
+// streamOutput 单输出流式指标逐根的值和 Ready
+type streamOutput struct {
+	values []float64
+	ready  []bool
+}
+
+// streamCase 单输出流式指标与 talib 的对比用例 (lookback 为 talib 第一个有效输出的下标)
+type streamCase struct {
+	name     string
+	got      func() streamOutput
+	want     []float64
+	lookback int
+}
+
+func TestStreamingIndicatorsMatchTalib(t *testing.T) {
+	bars := loadTestBars(t)
+	n := len(bars.close)
+
+	// run 逐根更新流式指标，返回每根 K 线后的输出和 Ready
+	run := func(update func(i int) (float64, bool)) streamOutput {
+		out := streamOutput{values: make([]float64, n), ready: make([]bool, n)}
+		for i := 0; i < n; i++ {
+			out.values[i], out.ready[i] = update(i)
+		}
+		return out
+	}
+
+	var tests []streamCase
+
+	for _, period := range []int{2, 5, 20, 50} {
+		sma, ema := NewSMA(period), NewEMA(period)
+		tests = append(tests,
+			streamCase{"sma" + strconv.Itoa(period), func() streamOutput {
+				return run(func(i int) (float64, bool) { return sma.Update(bars.close[i]), sma.Ready() })
+			}, talib.Sma(bars.close, period), period - 1},
+			streamCase{"ema" + strconv.Itoa(period), func() streamOutput {
+				return run(func(i int) (float64, bool) { return ema.Update(bars.close[i]), ema.Ready() })
+			}, talib.Ema(bars.close, period), period - 1},
+		)
+	}
+	for _, period := range []int{2, 7, 14, 30} {
+		rsi, atr := NewRSI(period), NewATR(period)
+		tests = append(tests,
+			streamCase{"rsi" + strconv.Itoa(period), func() streamOutput {
+				return run(func(i int) (float64, bool) { return rsi.Update(bars.close[i]), rsi.Ready() })
+			}, talib.Rsi(bars.close, period), period},
+			streamCase{"atr" + strconv.Itoa(period), func() streamOutput {
+				return run(func(i int) (float64, bool) {
+					return atr.Update(bars.high[i], bars.low[i], bars.close[i]), atr.Ready()
+				})
+			}, talib.Atr(bars.high, bars.low, bars.close, period), period},
+		)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.got()
+			assertSeries(t, out.values, tt.want)
+			assertReadyAt(t, out.ready, tt.lookback)
+		})
+	}
+}
+
+func TestStreamingBBandsMatchTalib(t *testing.T) {
+	bars := loadTestBars(t)
+	tests := []struct {
+		period       int
+		devUp, devDn float64
+	}{
+		{20, 2, 2},
+		{10, 1.5, 2.5},
+		{5, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.period), func(t *testing.T) {
+			wantUp, wantMid, wantDn := talib.BBands(bars.close, tt.period, tt.devUp, tt.devDn, talib.SMA)
+			bb := NewBBands(tt.period, tt.devUp, tt.devDn)
+			n := len(bars.close)
+			up, mid, dn := make([]float64, n), make([]float64, n), make([]float64, n)
+			ready := make([]bool, n)
+			for i, c := range bars.close {
+				up[i], mid[i], dn[i] = bb.Update(c)
+				ready[i] = bb.Ready()
+			}
+			assertSeries(t, up, wantUp)
+			assertSeries(t, mid, wantMid)
+			assertSeries(t, dn, wantDn)
+			assertReadyAt(t, ready, tt.period-1)
+		})
+	}
+}
+
+// btcSeries BTC 量级的随机游走 (价格 ~6e4，按 0.1 的最小变动价位取整)，波动在每 500 根之间交替：
+// 平静时每根最多变动一个价位，窗口标准差只有零点几
+func btcSeries(n int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	series := make([]float64, n)
+	price := 60000.0
+	for i := range series {
+		ticks := rng.Intn(201) - 100
+		if i/500%2 == 1 {
+			ticks = rng.Intn(3) - 1
+		}
+		price += float64(ticks) * 0.1
+		series[i] = math.Round(price*10) / 10
+	}
+	return series
+}
+
+// TestStreamingBBandsLongSeries 流式方差不能随序列长度漂移。talib 对整个序列使用累计的 sumSq/n - mean²，
+// 在这个量级上自身就有相消误差，因此逐根与 talib 对平移后的单个窗口 (减去窗口第一个值，布林带随之平移) 的结果比较
+func TestStreamingBBandsLongSeries(t *testing.T) {
+	const period = 20
+	series := btcSeries(200000)
+	bb := NewBBands(period, 2, 2)
+	window := make([]float64, period)
+	for i, v := range series {
+		up, mid, dn := bb.Update(v)
+		if i < period-1 {
+			continue
+		}
+		shift := series[i-period+1]
+		for j := range window {
+			window[j] = series[i-period+1+j] - shift
+		}
+		wantUp, wantMid, wantDn := talib.BBands(window, period, 2, 2, talib.SMA)
+		last := period - 1
+		if !closeTo(up, wantUp[last]+shift) || !closeTo(mid, wantMid[last]+shift) || !closeTo(dn, wantDn[last]+shift) {
+			t.Fatalf("bar %d: got %.6f/%.6f/%.6f, want %.6f/%.6f/%.6f",
+				i, up, mid, dn, wantUp[last]+shift, wantMid[last]+shift, wantDn[last]+shift)
+		}
+	}
+}
+
+func TestStreamingBBandsConstantWindow(t *testing.T) {
+	// 长序列之后窗口内价格不变：方差为 0 (舍入为负数时也按 0 处理)，上下轨与中轨重合
+	bb := NewBBands(20, 2, 2)
+	for _, v := range btcSeries(20000) {
+		bb.Update(v)
+	}
+	for i := 0; i < 20; i++ {
+		bb.Update(64123.4)
+	}
+	if up, mid, dn := bb.Value(); up != mid || dn != mid || !closeTo(mid, 64123.4) {
+		t.Errorf("constant window: bands %.10f/%.10f/%.10f, want all 64123.4", up, mid, dn)
+	}
+}
+
+func TestStreamingMACDMatchTalib(t *testing.T) {
+	bars := loadTestBars(t)
+	tests := []struct{ fast, slow, signal int }{
+		{12, 26, 9},
+		{5, 35, 5},
+		{26, 12, 9}, // 快慢线周期颠倒时与 talib 一样交换
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.fast)+"_"+strconv.Itoa(tt.slow)+"_"+strconv.Itoa(tt.signal), func(t *testing.T) {
+			wantMACD, wantSignal, wantHist := talib.Macd(bars.close, tt.fast, tt.slow, tt.signal)
+			m := NewMACD(tt.fast, tt.slow, tt.signal)
+			n := len(bars.close)
+			macd, signal, hist := make([]float64, n), make([]float64, n), make([]float64, n)
+			ready := make([]bool, n)
+			for i, c := range bars.close {
+				macd[i], signal[i], hist[i] = m.Update(c)
+				ready[i] = m.Ready()
+			}
+			// talib 在柱状图的预热期内三个输出都为 0，流式 MACD 在预热期内的 MACD 线和信号线只用于递推
+			lookback := m.lookback
+			assertSeries(t, macd[lookback:], wantMACD[lookback:])
+			assertSeries(t, signal[lookback:], wantSignal[lookback:])
+			assertSeries(t, hist, wantHist)
+			assertReadyAt(t, ready, lookback)
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package ta
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"math"
+)
+
+// 趋势 / 通道类指标类型
+const (
+	TypeADX        = "adx"
+	TypeSupertrend = "supertrend"
+	TypeKeltner    = "keltner"
+	TypeDonchian   = "donchian"
+	TypeIchimoku   = "ichimoku"
+	TypeChop       = "chop"
+)
+
+// 状态机使用的指标名称 (StrategyConfig 启用 ADX / 通道过滤时在 1h 上自动配置)
+const (
+	NameADX     = "adx"
+	NameChannel = "channel"
+)
+
+// rollingExtreme 滑动窗口内的最大值 (或最小值)，单调队列实现，每次更新均摊 O(1)
+type rollingExtreme struct {
+	period int
+	max    bool
+	index  int       // 已加入的值的数量
+	idx    []int     // 单调队列中各值的序号
+	vals   []float64 // 单调队列中的值 (max 时递减，min 时递增)
+}
+
+func newRollingExtreme(period int, max bool) *rollingExtreme {
+	return &rollingExtreme{period: period, max: max}
+}
+
+// update 加入一个值并返回窗口内的极值
+func (r *rollingExtreme) update(v float64) float64 {
+	for n := len(r.vals); n > 0; n = len(r.vals) {
+		last := r.vals[n-1]
+		if (r.max && last > v) || (!r.max && last < v) {
+			break
+		}
+		r.vals, r.idx = r.vals[:n-1], r.idx[:n-1]
+	}
+	r.vals = append(r.vals, v)
+	r.idx = append(r.idx, r.index)
+	r.index++
+	for r.idx[0] <= r.index-1-r.period {
+		r.vals, r.idx = r.vals[1:], r.idx[1:]
+	}
+	return r.vals[0]
+}
+
+// ready 窗口是否已填满
+func (r *rollingExtreme) ready() bool { return r.index >= r.period }
+
+// ADX 平均趋向指数及 +DI / -DI (Wilder 平滑，与 talib 的计算方式一致)
+type ADX struct {
+	period                    int
+	count                     int
+	prevHigh, prevLow, prevCl float64
+	plusDM, minusDM, tr       float64 // Wilder 平滑后的 +DM、-DM 和 TR
+	sumDX                     float64
+	adx, plusDI, minusDI      float64
+}
+
+// NewADX 创建周期为 period 的 ADX
+func NewADX(period int) *ADX {
+	return &ADX{period: period}
+}
+
+// Update 追加一根 K 线并返回最新的 ADX、+DI、-DI
+func (a *ADX) Update(high float64, low float64, closePrice float64) (adx float64, plusDI float64, minusDI float64) {
+	a.count++
+	if a.count == 1 {
+		a.prevHigh, a.prevLow, a.prevCl = high, low, closePrice
+		return a.adx, a.plusDI, a.minusDI
+	}
+
+	plusDM, minusDM := 0.0, 0.0
+	diffP, diffM := high-a.prevHigh, a.prevLow-low
+	if diffM > 0 && diffP < diffM {
+		minusDM = diffM
+	} else if diffP > 0 && diffP > diffM {
+		plusDM = diffP
+	}
+	tr := TrueRange(high, low, a.prevCl)
+	a.prevHigh, a.prevLow, a.prevCl = high, low, closePrice
+
+	period := float64(a.period)
+	bars := a.count - 1 // 已有的 DM / TR 数量
+	if bars < a.period {
+		// 前 period-1 个值直接累加作为 Wilder 平滑的初始值
+		a.plusDM += plusDM
+		a.minusDM += minusDM
+		a.tr += tr
+		return a.adx, a.plusDI, a.minusDI
+	}
+	a.plusDM = a.plusDM - a.plusDM/period + plusDM
+	a.minusDM = a.minusDM - a.minusDM/period + minusDM
+	a.tr = a.tr - a.tr/period + tr
+
+	dx, ok := 0.0, false
+	if a.tr != 0 {
+		a.plusDI = 100 * a.plusDM / a.tr
+		a.minusDI = 100 * a.minusDM / a.tr
+		if sum := a.plusDI + a.minusDI; sum != 0 {
+			dx, ok = 100*math.Abs(a.plusDI-a.minusDI)/sum, true
+		}
+	}
+
+	switch {
+	case bars < 2*a.period-1:
+		a.sumDX += dx
+	case bars == 2*a.period-1:
+		a.sumDX += dx
+		a.adx = a.sumDX / period
+	case ok:
+		a.adx = (a.adx*(period-1) + dx) / period
+	}
+	return a.adx, a.plusDI, a.minusDI
+}
+
+// Value 返回最新的 ADX、+DI、-DI
+func (a *ADX) Value() (adx float64, plusDI float64, minusDI float64) {
+	return a.adx, a.plusDI, a.minusDI
+}
+
+// Ready 是否已有足够的数据 (ADX 需要 2*period 根 K 线)
+func (a *ADX) Ready() bool { return a.count >= 2*a.period }
+
+// Supertrend 超级趋势：以 (high+low)/2 ± multiplier*ATR 为上下轨，价格突破轨道时翻转方向
+type Supertrend struct {
+	atr          *ATR
+	multiplier   float64
+	upper, lower float64 // 最终上下轨
+	prevClose    float64
+	value        float64
+	direction    float64 // 1 多头 (价格在下轨之上)，-1 空头
+}
+
+// NewSupertrend 创建 ATR 周期为 period、倍数为 multiplier 的超级趋势
+func NewSupertrend(period int, multiplier float64) *Supertrend {
+	return &Supertrend{atr: NewATR(period), multiplier: multiplier}
+}
+
+// Update 追加一根 K 线并返回最新的趋势线和方向 (1 / -1)
+func (s *Supertrend) Update(high float64, low float64, closePrice float64) (value float64, direction float64) {
+	atr := s.atr.Update(high, low, closePrice)
+	if !s.atr.Ready() {
+		s.prevClose = closePrice
+		return s.value, s.direction
+	}
+
+	mid := (high + low) / 2
+	basicUpper, basicLower := mid+s.multiplier*atr, mid-s.multiplier*atr
+	if s.direction == 0 {
+		// 第一根有效 K 线：按收盘价相对中线确定初始方向
+		s.upper, s.lower = basicUpper, basicLower
+		s.direction = 1
+		if closePrice < mid {
+			s.direction = -1
+		}
+	} else {
+		// 上轨只在下移或前收盘突破时更新，下轨反之
+		if basicUpper < s.upper || s.prevClose > s.upper {
+			s.upper = basicUpper
+		}
+		if basicLower > s.lower || s.prevClose < s.lower {
+			s.lower = basicLower
+		}
+		if s.direction < 0 && closePrice > s.upper {
+			s.direction = 1
+		} else if s.direction > 0 && closePrice < s.lower {
+			s.direction = -1
+		}
+	}
+
+	s.value = s.upper
+	if s.direction > 0 {
+		s.value = s.lower
+	}
+	s.prevClose = closePrice
+	return s.value, s.direction
+}
+
+// Value 返回最新的趋势线和方向
+func (s *Supertrend) Value() (value float64, direction float64) { return s.value, s.direction }
+
+// Ready 是否已有足够的数据
+func (s *Supertrend) Ready() bool { return s.direction != 0 }
+
+// Keltner 肯特纳通道：中轨为收盘价 EMA，上下轨为中轨 ± multiplier*ATR
+type Keltner struct {
+	ema        *EMA
+	atr        *ATR
+	multiplier float64
+	upper      float64
+	middle     float64
+	lower      float64
+}
+
+// NewKeltner 创建 EMA 周期为 period、ATR 周期为 atrPeriod、倍数为 multiplier 的肯特纳通道
+func NewKeltner(period int, atrPeriod int, multiplier float64) *Keltner {
+	return &Keltner{ema: NewEMA(period), atr: NewATR(atrPeriod), multiplier: multiplier}
+}
+
+// Update 追加一根 K 线并返回最新的上轨、中轨、下轨
+func (k *Keltner) Update(high float64, low float64, closePrice float64) (upper float64, middle float64, lower float64) {
+	k.middle = k.ema.Update(closePrice)
+	atr := k.atr.Update(high, low, closePrice)
+	k.upper = k.middle + k.multiplier*atr
+	k.lower = k.middle - k.multiplier*atr
+	return k.upper, k.middle, k.lower
+}
+
+// Value 返回最新的上轨、中轨、下轨
+func (k *Keltner) Value() (upper float64, middle float64, lower float64) {
+	return k.upper, k.middle, k.lower
+}
+
+// Width 返回通道的相对宽度 (upper-lower)/middle
+func (k *Keltner) Width() float64 { return channelWidth(k.upper, k.middle, k.lower) }
+
+// Ready 是否已有足够的数据
+func (k *Keltner) Ready() bool { return k.ema.Ready() && k.atr.Ready() }
+
+// Donchian 唐奇安通道：上轨为 period 根最高价，下轨为 period 根最低价
+type Donchian struct {
+	highest, lowest *rollingExtreme
+	upper, lower    float64
+}
+
+// NewDonchian 创建周期为 period 的唐奇安通道
+func NewDonchian(period int) *Donchian {
+	return &Donchian{highest: newRollingExtreme(period, true), lowest: newRollingExtreme(period, false)}
+}
+
+// Update 追加一根 K 线并返回最新的上轨、中轨、下轨
+func (d *Donchian) Update(high float64, low float64) (upper float64, middle float64, lower float64) {
+	d.upper = d.highest.update(high)
+	d.lower = d.lowest.update(low)
+	return d.Value()
+}
+
+// Value 返回最新的上轨、中轨、下轨
+func (d *Donchian) Value() (upper float64, middle float64, lower float64) {
+	return d.upper, (d.upper + d.lower) / 2, d.lower
+}
+
+// Width 返回通道的相对宽度 (upper-lower)/middle
+func (d *Donchian) Width() float64 {
+	upper, middle, lower := d.Value()
+	return channelWidth(upper, middle, lower)
+}
+
+// Ready 是否已有足够的数据
+func (d *Donchian) Ready() bool { return d.highest.ready() }
+
+// channelWidth 通道的相对宽度 (中轨为 0 时返回 0)
+func channelWidth(upper float64, middle float64, lower float64) float64 {
+	if middle == 0 {
+		return 0
+	}
+	return (upper - lower) / middle
+}
+
+// Ichimoku 一目均衡表。SpanA / SpanB 为当前 K 线所对应的云 (即 displacement 根之前计算并前移的先行带)
+type Ichimoku struct {
+	tenkanHigh, tenkanLow *rollingExtreme
+	kijunHigh, kijunLow   *rollingExtreme
+	spanBHigh, spanBLow   *rollingExtreme
+	displacement          int
+	spanA, spanB          *Ring // 尚未到期的先行带 (最旧的即当前 K 线的云)
+	bars                  int
+	tenkan, kijun         float64
+	cloudA, cloudB        float64
+}
+
+// NewIchimoku 创建一目均衡表 (常用参数 9 / 26 / 52，先行带前移 kijun 根)
+func NewIchimoku(tenkan int, kijun int, spanB int) *Ichimoku {
+	return &Ichimoku{
+		tenkanHigh:   newRollingExtreme(tenkan, true),
+		tenkanLow:    newRollingExtreme(tenkan, false),
+		kijunHigh:    newRollingExtreme(kijun, true),
+		kijunLow:     newRollingExtreme(kijun, false),
+		spanBHigh:    newRollingExtreme(spanB, true),
+		spanBLow:     newRollingExtreme(spanB, false),
+		displacement: kijun,
+		spanA:        NewRing(kijun + 1),
+		spanB:        NewRing(kijun + 1),
+	}
+}
+
+// Update 追加一根 K 线并返回最新的转换线、基准线和当前 K 线的云 (先行带 A / B)
+func (i *Ichimoku) Update(high float64, low float64) (tenkan float64, kijun float64, spanA float64, spanB float64) {
+	i.bars++
+	i.tenkan = (i.tenkanHigh.update(high) + i.tenkanLow.update(low)) / 2
+	i.kijun = (i.kijunHigh.update(high) + i.kijunLow.update(low)) / 2
+	b := (i.spanBHigh.update(high) + i.spanBLow.update(low)) / 2
+
+	// 本根计算的先行带作用于 displacement 根之后的 K 线
+	i.spanA.Push((i.tenkan + i.kijun) / 2)
+	i.spanB.Push(b)
+	if i.spanA.Len() > i.displacement {
+		i.cloudA = i.spanA.Ago(i.displacement)
+		i.cloudB = i.spanB.Ago(i.displacement)
+	}
+	return i.tenkan, i.kijun, i.cloudA, i.cloudB
+}
+
+// Value 返回最新的转换线、基准线和当前 K 线的云
+func (i *Ichimoku) Value() (tenkan float64, kijun float64, spanA float64, spanB float64) {
+	return i.tenkan, i.kijun, i.cloudA, i.cloudB
+}
+
+// Ready 是否已有足够的数据 (先行带 B 需要 spanB 根，再前移 displacement 根)
+func (i *Ichimoku) Ready() bool { return i.bars >= i.warmUp() }
+
+func (i *Ichimoku) warmUp() int { return i.spanBHigh.period + i.displacement }
+
+// Chop 震荡指数 (Choppiness Index)：100 * log10(ΣTR / (最高价 - 最低价)) / log10(period)，
+// 接近 100 表示横盘震荡，接近 0 表示单边趋势
+type Chop struct {
+	period          int
+	trs             *Ring
+	sumTR           float64
+	highest, lowest *rollingExtreme
+	prevClose       float64
+	bars            int
+	value           float64
+}
+
+// NewChop 创建周期为 period 的震荡指数
+func NewChop(period int) *Chop {
+	return &Chop{
+		period:  period,
+		trs:     NewRing(period),
+		highest: newRollingExtreme(period, true),
+		lowest:  newRollingExtreme(period, false),
+	}
+}
+
+// Update 追加一根 K 线并返回最新的震荡指数
+func (c *Chop) Update(high float64, low float64, closePrice float64) float64 {
+	c.bars++
+	tr := high - low
+	if c.bars > 1 {
+		tr = TrueRange(high, low, c.prevClose)
+	}
+	c.prevClose = closePrice
+
+	c.sumTR += tr
+	if old, evicted := c.trs.Push(tr); evicted {
+		c.sumTR -= old
+	}
+	hh, ll := c.highest.update(high), c.lowest.update(low)
+	if c.Ready() && hh > ll && c.period > 1 {
+		c.value = 100 * math.Log10(c.sumTR/(hh-ll)) / math.Log10(float64(c.period))
+	}
+	return c.value
+}
+
+// Value 返回最新的震荡指数
+func (c *Chop) Value() float64 { return c.value }
+
+// Ready 是否已有足够的数据
+func (c *Chop) Ready() bool { return c.bars >= c.period }
+
+func init() {
+	RegisterIndicator(TypeADX, []string{"", "plus_di", "minus_di"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &adxIndicator{NewADX(periodOr(cfg, 14))}, nil
+	})
+	RegisterIndicator(TypeSupertrend, []string{"", "dir"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &supertrendIndicator{NewSupertrend(periodOr(cfg, 10), cfg.Param("multiplier", 3))}, nil
+	})
+	RegisterIndicator(TypeKeltner, []string{"", "upper", "lower", "width"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &keltnerIndicator{NewKeltner(periodOr(cfg, 20), int(cfg.Param("atr", 10)), cfg.Param("multiplier", 2))}, nil
+	})
+	RegisterIndicator(TypeDonchian, []string{"", "upper", "lower", "width"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &donchianIndicator{NewDonchian(periodOr(cfg, 20))}, nil
+	})
+	RegisterIndicator(TypeIchimoku, []string{"", "kijun", "span_a", "span_b"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		tenkan, kijun, spanB := int(cfg.Param("tenkan", 9)), int(cfg.Param("kijun", 26)), int(cfg.Param("span_b", 52))
+		if tenkan < 1 || kijun < 1 || spanB < 1 {
+			return nil, fmt.Errorf("ichimoku periods must be positive, got %d/%d/%d", tenkan, kijun, spanB)
+		}
+		return &ichimokuIndicator{NewIchimoku(tenkan, kijun, spanB)}, nil
+	})
+	RegisterIndicator(TypeChop, nil, func(cfg service.IndicatorConfig) (Indicator, error) {
+		return &chopIndicator{NewChop(periodOr(cfg, 14))}, nil
+	})
+}
+
+type adxIndicator struct{ *ADX }
+
+func (i *adxIndicator) WarmUp() int              { return 2 * i.period }
+func (i *adxIndicator) Update(kline model.KLine) { i.ADX.Update(kline.High, kline.Low, kline.Close) }
+func (i *adxIndicator) Values() []float64 {
+	adx, plusDI, minusDI := i.Value()
+	return []float64{adx, plusDI, minusDI}
+}
+
+type supertrendIndicator struct{ *Supertrend }
+
+func (i *supertrendIndicator) WarmUp() int { return i.atr.period + 1 }
+func (i *supertrendIndicator) Update(kline model.KLine) {
+	i.Supertrend.Update(kline.High, kline.Low, kline.Close)
+}
+func (i *supertrendIndicator) Values() []float64 {
+	value, direction := i.Value()
+	return []float64{value, direction}
+}
+
+type keltnerIndicator struct{ *Keltner }
+
+func (i *keltnerIndicator) WarmUp() int {
+	if i.ema.period > i.atr.period {
+		return i.ema.period
+	}
+	return i.atr.period + 1
+}
+func (i *keltnerIndicator) Update(kline model.KLine) {
+	i.Keltner.Update(kline.High, kline.Low, kline.Close)
+}
+func (i *keltnerIndicator) Values() []float64 {
+	upper, middle, lower := i.Value()
+	return []float64{middle, upper, lower, i.Width()}
+}
+
+type donchianIndicator struct{ *Donchian }
+
+func (i *donchianIndicator) WarmUp() int              { return i.highest.period }
+func (i *donchianIndicator) Update(kline model.KLine) { i.Donchian.Update(kline.High, kline.Low) }
+func (i *donchianIndicator) Values() []float64 {
+	upper, middle, lower := i.Value()
+	return []float64{middle, upper, lower, i.Width()}
+}
+
+type ichimokuIndicator struct{ *Ichimoku }
+
+func (i *ichimokuIndicator) WarmUp() int              { return i.warmUp() }
+func (i *ichimokuIndicator) Update(kline model.KLine) { i.Ichimoku.Update(kline.High, kline.Low) }
+func (i *ichimokuIndicator) Values() []float64 {
+	tenkan, kijun, spanA, spanB := i.Value()
+	return []float64{tenkan, kijun, spanA, spanB}
+}
+
+type chopIndicator struct{ *Chop }
+
+func (i *chopIndicator) WarmUp() int              { return i.period }
+func (i *chopIndicator) Update(kline model.KLine) { i.Chop.Update(kline.High, kline.Low, kline.Close) }
+func (i *chopIndicator) Values() []float64        { return []float64{i.Value()} }
@@ -0,0 +1,311 @@
+package ta
+
+import (
+	"crypto-algo-trader/internal/model"
+	"crypto-algo-trader/internal/service"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// 波动率估计量 (同时也是指标类型)，输出为单根 K 线的对数收益标准差 (未年化)
+const (
+	VolCloseToClose   = "close_to_close"  // 收盘价对数收益的样本标准差
+	VolParkinson      = "parkinson"       // 基于最高/最低价，对跳空不敏感
+	VolGarmanKlass    = "garman_klass"    // 基于 OHLC，假设无漂移
+	VolRogersSatchell = "rogers_satchell" // 基于 OHLC，允许漂移
+	VolYangZhang      = "yang_zhang"      // 隔夜 + 开收盘 + Rogers-Satchell 的加权，同时处理跳空和漂移
+	VolRealized       = "realized"        // 逐笔成交的已实现波动率 (需要 TACalculator.UpdateTicker)
+)
+
+// DefaultVolRankWindow 波动率百分位排名的默认滚动窗口 (K 线数量)
+const DefaultVolRankWindow = 500
+
+// NameVol 状态机使用的波动率指标名称 (StrategyConfig 启用波动率百分位时在 1h 上自动配置)
+const NameVol = "vol"
+
+// rollingVariance 滑动窗口内的均值和样本方差
+type rollingVariance struct {
+	window     *Ring
+	sum, sumSq float64
+}
+
+func newRollingVariance(period int) *rollingVariance {
+	return &rollingVariance{window: NewRing(period)}
+}
+
+func (r *rollingVariance) update(v float64) {
+	r.sum += v
+	r.sumSq += v * v
+	if old, evicted := r.window.Push(v); evicted {
+		r.sum -= old
+		r.sumSq -= old * old
+	}
+}
+
+func (r *rollingVariance) mean() float64 {
+	if r.window.Len() == 0 {
+		return 0
+	}
+	return r.sum / float64(r.window.Len())
+}
+
+func (r *rollingVariance) variance() float64 {
+	n := float64(r.window.Len())
+	if n < 2 {
+		return 0
+	}
+	return math.Max((r.sumSq-r.sum*r.sum/n)/(n-1), 0)
+}
+
+func (r *rollingVariance) ready() bool { return r.window.Len() >= r.window.Cap() }
+
+// Volatility 基于 K 线的滚动波动率估计 (窗口为 period 根 K 线)
+type Volatility struct {
+	estimator string
+	period    int
+	bars      int
+	prevClose float64
+	// close_to_close: 收益; yang_zhang: 隔夜收益、开收盘收益和 RS 项; 其他: 每根 K 线的方差项
+	a, b, c *rollingVariance
+	value   float64
+}
+
+// NewVolatility 创建使用 estimator 估计量 (Vol* 常量，realized 除外)、窗口为 period 的波动率
+func NewVolatility(estimator string, period int) (*Volatility, error) {
+	switch estimator {
+	case VolCloseToClose, VolParkinson, VolGarmanKlass, VolRogersSatchell:
+	case VolYangZhang:
+		if period < 2 {
+			return nil, fmt.Errorf("%s period must be at least 2, got %d", estimator, period)
+		}
+	default:
+		return nil, fmt.Errorf("unknown volatility estimator %q", estimator)
+	}
+	if period < 1 {
+		return nil, fmt.Errorf("%s period must be positive, got %d", estimator, period)
+	}
+	return &Volatility{
+		estimator: estimator,
+		period:    period,
+		a:         newRollingVariance(period),
+		b:         newRollingVariance(period),
+		c:         newRollingVariance(period),
+	}, nil
+}
+
+// Update 追加一根 K 线并返回最新的波动率
+func (v *Volatility) Update(open float64, high float64, low float64, closePrice float64) float64 {
+	v.bars++
+	prevClose := v.prevClose
+	v.prevClose = closePrice
+	if open <= 0 || high <= 0 || low <= 0 || closePrice <= 0 {
+		return v.value
+	}
+
+	hl := math.Log(high / low)
+	co := math.Log(closePrice / open)
+	rs := math.Log(high/closePrice)*math.Log(high/open) + math.Log(low/closePrice)*math.Log(low/open)
+
+	variance := 0.0
+	switch v.estimator {
+	case VolParkinson:
+		v.a.update(hl * hl / (4 * math.Ln2))
+		variance = v.a.mean()
+	case VolGarmanKlass:
+		v.a.update(0.5*hl*hl - (2*math.Ln2-1)*co*co)
+		variance = v.a.mean()
+	case VolRogersSatchell:
+		v.a.update(rs)
+		variance = v.a.mean()
+	case VolCloseToClose, VolYangZhang:
+		// 需要前一根的收盘价
+		if v.bars == 1 || prevClose <= 0 {
+			return v.value
+		}
+		if v.estimator == VolCloseToClose {
+			v.a.update(math.Log(closePrice / prevClose))
+			variance = v.a.variance()
+			break
+		}
+		v.a.update(math.Log(open / prevClose))
+		v.b.update(co)
+		v.c.update(rs)
+		n := float64(v.period)
+		k := 0.34 / (1.34 + (n+1)/(n-1))
+		variance = v.a.variance() + k*v.b.variance() + (1-k)*v.c.mean()
+	}
+
+	if v.Ready() {
+		v.value = math.Sqrt(math.Max(variance, 0))
+	}
+	return v.value
+}
+
+// Value 返回最新的波动率
+func (v *Volatility) Value() float64 { return v.value }
+
+// Ready 是否已有足够的数据
+func (v *Volatility) Ready() bool { return v.a.ready() }
+
+// warmUp 第一个有效输出所需的 K 线数量 (使用前收盘价的估计量多一根)
+func (v *Volatility) warmUp() int {
+	if v.estimator == VolCloseToClose || v.estimator == VolYangZhang {
+		return v.period + 1
+	}
+	return v.period
+}
+
+// RealizedVolatility 逐笔已实现波动率：每根 K 线内相邻成交价对数收益的平方和开方。
+// Ticker 按 K 线起始时间分桶，K 线完成时取对应桶的值，因此 Ticker 和 K 线到达的先后顺序不影响结果
+type RealizedVolatility struct {
+	interval  time.Duration // 从第一根 K 线得到的周期
+	prevPrice float64
+	curStart  time.Time // 当前桶对应的 K 线起始时间
+	cur       float64   // 当前桶的收益平方和
+	lastStart time.Time // 上一个桶
+	last      float64
+	value     float64
+	bars      int
+}
+
+// NewRealizedVolatility 创建逐笔已实现波动率
+func NewRealizedVolatility() *RealizedVolatility {
+	return &RealizedVolatility{}
+}
+
+// UpdateTicker 追加一笔成交 (周期未知前的成交计入第一根 K 线)
+func (r *RealizedVolatility) UpdateTicker(ticker model.Ticker) {
+	if ticker.Price <= 0 {
+		return
+	}
+	ret := 0.0
+	if r.prevPrice > 0 {
+		ret = math.Log(ticker.Price / r.prevPrice)
+	}
+	r.prevPrice = ticker.Price
+
+	if r.interval > 0 {
+		if start := time.UnixMilli(ticker.Timestamp).Truncate(r.interval); start.After(r.curStart) {
+			r.lastStart, r.last = r.curStart, r.cur
+			r.curStart, r.cur = start, 0
+		}
+	}
+	r.cur += ret * ret
+}
+
+// Update 以一根完成的 K 线结束对应的桶并返回最新的波动率 (没有成交的 K 线为 0)
+func (r *RealizedVolatility) Update(kline model.KLine) float64 {
+	r.bars++
+	if r.interval == 0 && !kline.StartTime.IsZero() && kline.EndTime.After(kline.StartTime) {
+		r.interval = kline.EndTime.Sub(kline.StartTime) + time.Millisecond
+		if r.curStart.IsZero() {
+			r.curStart = kline.StartTime
+		}
+	}
+
+	variance := 0.0
+	switch {
+	case !kline.StartTime.IsZero() && kline.StartTime.Equal(r.curStart):
+		variance = r.cur
+	case !kline.StartTime.IsZero() && kline.StartTime.Equal(r.lastStart):
+		variance = r.last
+	}
+	r.value = math.Sqrt(variance)
+	return r.value
+}
+
+// Value 返回最新的波动率
+func (r *RealizedVolatility) Value() float64 { return r.value }
+
+// Ready 是否已有足够的数据 (第一根 K 线的成交可能不完整)
+func (r *RealizedVolatility) Ready() bool { return r.bars >= 2 }
+
+// PercentileRank 当前值在滚动窗口内的百分位排名 (0~1)，用于让阈值随交易对自身的历史分布调整
+type PercentileRank struct {
+	window *Ring
+	sorted []float64
+	value  float64
+}
+
+// NewPercentileRank 创建窗口为 window 的百分位排名
+func NewPercentileRank(window int) *PercentileRank {
+	return &PercentileRank{window: NewRing(window)}
+}
+
+// Update 追加一个值并返回它在窗口内的排名：窗口中其他值小于等于它的比例 (窗口只有一个值时为 0.5)
+func (p *PercentileRank) Update(v float64) float64 {
+	if old, evicted := p.window.Push(v); evicted {
+		i := sort.SearchFloat64s(p.sorted, old)
+		p.sorted = append(p.sorted[:i], p.sorted[i+1:]...)
+	}
+	i := sort.SearchFloat64s(p.sorted, v)
+	p.sorted = append(p.sorted, 0)
+	copy(p.sorted[i+1:], p.sorted[i:])
+	p.sorted[i] = v
+
+	n := len(p.sorted)
+	if n < 2 {
+		p.value = 0.5
+		return p.value
+	}
+	// 小于等于 v 的数量 (不含 v 自身)
+	le := sort.Search(n, func(j int) bool { return p.sorted[j] > v }) - 1
+	p.value = float64(le) / float64(n-1)
+	return p.value
+}
+
+// Value 返回最新的排名
+func (p *PercentileRank) Value() float64 { return p.value }
+
+func init() {
+	for _, estimator := range []string{VolCloseToClose, VolParkinson, VolGarmanKlass, VolRogersSatchell, VolYangZhang} {
+		estimator := estimator
+		RegisterIndicator(estimator, []string{"", "rank"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+			vol, err := NewVolatility(estimator, periodOr(cfg, 20))
+			if err != nil {
+				return nil, err
+			}
+			return &volIndicator{src: vol, warmUp: vol.warmUp(), rank: NewPercentileRank(int(cfg.Param("rank_window", DefaultVolRankWindow)))}, nil
+		})
+	}
+	RegisterIndicator(VolRealized, []string{"", "rank"}, func(cfg service.IndicatorConfig) (Indicator, error) {
+		rv := NewRealizedVolatility()
+		return &realizedVolIndicator{rv, volIndicator{src: rv, warmUp: 2, rank: NewPercentileRank(int(cfg.Param("rank_window", DefaultVolRankWindow)))}}, nil
+	})
+}
+
+// volSource 波动率指标的数据来源
+type volSource interface {
+	Value() float64
+}
+
+// volIndicator 波动率及其百分位排名 (主输出为波动率，"rank" 为排名)
+type volIndicator struct {
+	src    volSource
+	warmUp int
+	bars   int
+	rank   *PercentileRank
+}
+
+func (i *volIndicator) WarmUp() int { return i.warmUp }
+func (i *volIndicator) Update(kline model.KLine) {
+	switch src := i.src.(type) {
+	case *Volatility:
+		src.Update(kline.Open, kline.High, kline.Low, kline.Close)
+	case *RealizedVolatility:
+		src.Update(kline)
+	}
+	if i.bars++; i.bars >= i.warmUp {
+		i.rank.Update(i.src.Value())
+	}
+}
+func (i *volIndicator) Values() []float64 { return []float64{i.src.Value(), i.rank.Value()} }
+
+type realizedVolIndicator struct {
+	rv *RealizedVolatility
+	volIndicator
+}
+
+func (i *realizedVolIndicator) UpdateTicker(ticker model.Ticker) { i.rv.UpdateTicker(ticker) }